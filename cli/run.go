@@ -47,6 +47,7 @@ var runCmd = &cobra.Command{
 		loadViperValue(cmd, runViper, &opts.ModFile, "modfile")
 		loadViperValue(cmd, runViper, &opts.Credential, "credential")
 		loadViperValue(cmd, runViper, &opts.Runtime, "runtime")
+		loadViperValue(cmd, runViper, &opts.InitConfig, "init-config")
 
 		if opts.Name == "" {
 			log.FailureStatusEvent(os.Stdout, "YoMo Stream Function name must be set.")
@@ -105,6 +106,7 @@ func init() {
 	runCmd.Flags().StringVarP(&opts.ModFile, "modfile", "m", "", "custom go.mod")
 	runCmd.Flags().StringVarP(&opts.Credential, "credential", "d", "", "client credential payload, eg: `token:dBbBiRE7`")
 	runCmd.Flags().StringVarP(&opts.Runtime, "runtime", "r", "", "serverless runtime type")
+	runCmd.Flags().StringVar(&opts.InitConfig, "init-config", "", "path to the config file passed to the init function of the wasm stream function")
 
 	runViper = bindViper(runCmd)
 }
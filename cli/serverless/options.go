@@ -16,4 +16,7 @@ type Options struct {
 	Runtime string
 	// use environment variables
 	UseEnv bool
+	// InitConfig is the path to the file that is passed to the init function of the wasm serverless,
+	// so the same wasm file can be configured for each deployment.
+	InitConfig string
 }
@@ -2,8 +2,15 @@
 package wasm
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"sort"
+	"time"
 
+	"github.com/yomorun/yomo/core"
+	"github.com/yomorun/yomo/core/metadata"
 	"github.com/yomorun/yomo/serverless"
 )
 
@@ -14,14 +21,126 @@ const (
 	// WasmFuncObserveDataTags guest module should implement this function
 	WasmFuncObserveDataTags = "yomo_observe_datatags"
 	// WasmFuncObserveDataTag host module should implement this function
-	WasmFuncObserveDataTag  = "yomo_observe_datatag"
-	WasmFuncHandler         = "yomo_handler"
-	WasmFuncWrite           = "yomo_write"
-	WasmFuncContextTag      = "yomo_context_tag"
-	WasmFuncContextData     = "yomo_context_data"
-	WasmFuncContextDataSize = "yomo_context_data_size"
+	WasmFuncObserveDataTag = "yomo_observe_datatag"
+	// WasmFuncObserveDataTagRange host module should implement this function
+	WasmFuncObserveDataTagRange = "yomo_observe_datatag_range"
+	WasmFuncHandler             = "yomo_handler"
+	WasmFuncWrite               = "yomo_write"
+	WasmFuncWriteTimeout        = "yomo_write_timeout"
+	WasmFuncWriteWithMetadata   = "yomo_write_with_metadata"
+	WasmFuncContextTag          = "yomo_context_tag"
+	WasmFuncContextData         = "yomo_context_data"
+	WasmFuncContextDataSize     = "yomo_context_data_size"
+	WasmFuncContextDataChunk    = "yomo_context_data_chunk"
+	// WasmFuncHandlerError host module should implement this function, the guest handler calls it to report the failure
+	WasmFuncHandlerError = "yomo_handler_error"
+	// WasmFuncInitConfig host module should implement this function, the guest init function calls it to read the config
+	WasmFuncInitConfig = "yomo_init_config"
 )
 
+// HandlerError is returned by RunHandler when the guest handler reports that it fails to process the data,
+// unlike the other errors of RunHandler, the runtime keeps working after it.
+type HandlerError struct {
+	Message string
+}
+
+// Error implements error interface.
+func (e *HandlerError) Error() string {
+	return "wasm handler: " + e.Message
+}
+
+// Define the return codes of the yomo_write host function,
+// the guest module maps the nonzero codes to errors.
+const (
+	// WriteCodeOK means the data has been written.
+	WriteCodeOK int32 = 0
+	// WriteCodeMemoryError means the host can not read the data from the guest memory.
+	WriteCodeMemoryError int32 = 1
+	// WriteCodeError means the write failed for an unclassified reason.
+	WriteCodeError int32 = 2
+	// WriteCodeBlocked means the stream can not accept data for now, the guest can retry later.
+	WriteCodeBlocked int32 = 3
+	// WriteCodeClosed means the stream has been closed, the guest should not retry.
+	WriteCodeClosed int32 = 4
+	// WriteCodeTimeout means the write does not finish before the timeout of yomo_write_timeout.
+	WriteCodeTimeout int32 = 5
+)
+
+// writeCode classifies the error returned by serverless.Context.Write to the return code of yomo_write.
+func writeCode(err error) int32 {
+	if err == nil {
+		return WriteCodeOK
+	}
+	if errors.Is(err, core.ErrWriteBlocked) {
+		return WriteCodeBlocked
+	}
+	if errors.Is(err, io.EOF) || errors.Is(err, context.Canceled) || errors.As(err, new(*core.ErrControllSignal)) {
+		return WriteCodeClosed
+	}
+	return WriteCodeError
+}
+
+// writeWithTimeout writes the data like yomo_write, but returns WriteCodeTimeout if the write
+// does not finish in timeoutMs milliseconds, a zero timeoutMs means no timeout.
+// The timed out write is not canceled, it may still be delivered later.
+func writeWithTimeout(ctx serverless.Context, tag uint32, data []byte, timeoutMs uint32) int32 {
+	if timeoutMs == 0 {
+		return writeCode(ctx.Write(tag, data))
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- ctx.Write(tag, data)
+	}()
+
+	timer := time.NewTimer(time.Duration(timeoutMs) * time.Millisecond)
+	defer timer.Stop()
+
+	select {
+	case err := <-done:
+		return writeCode(err)
+	case <-timer.C:
+		return WriteCodeTimeout
+	}
+}
+
+// writeWithMetadata writes the data with the metadata encoded by the guest module.
+func writeWithMetadata(ctx serverless.Context, tag uint32, data []byte, mdBytes []byte) int32 {
+	md, err := metadata.Decode(mdBytes)
+	if err != nil {
+		return WriteCodeError
+	}
+	return writeCode(ctx.WriteWithMetadata(tag, data, md))
+}
+
+// ContextDataChunkError is returned by yomo_context_data_chunk if the host can not write the chunk into
+// the guest memory, the guest reads it as ^uint32(0) and maps it to an error, 0 means the end of the data.
+const ContextDataChunkError int32 = -1
+
+// contextDataChunk returns the chunk of the context data starting at offset, the chunk is at most limit bytes,
+// it is empty if the offset reaches the end of the data.
+func contextDataChunk(ctx serverless.Context, offset, limit uint32) []byte {
+	data := ctx.Data()
+	if offset >= uint32(len(data)) {
+		return nil
+	}
+	data = data[offset:]
+	if uint32(len(data)) > limit {
+		data = data[:limit]
+	}
+	return data
+}
+
+// initConfigChunk returns the init config if it fits in limit bytes, the size of the config is returned
+// in any case, so that the guest can retry with a large enough buffer.
+func initConfigChunk(config []byte, limit uint32) ([]byte, uint32) {
+	size := uint32(len(config))
+	if size > limit {
+		return nil, size
+	}
+	return config, size
+}
+
 // Runtime is the abstract interface for wasm runtime
 type Runtime interface {
 	// Init loads the wasm file, and initialize the runtime environment
@@ -30,8 +149,9 @@ type Runtime interface {
 	// GetObserveDataTags returns observed datatags of the wasm sfn
 	GetObserveDataTags() []uint32
 
-	// RunInit runs the init function of the wasm sfn
-	RunInit() error
+	// RunInit runs the init function of the wasm sfn, the config is read by the guest with yomo_init_config,
+	// it is empty if no config is provided.
+	RunInit(config []byte) error
 
 	// RunHandler runs the wasm application (request -> response mode)
 	RunHandler(ctx serverless.Context) error
@@ -53,3 +173,46 @@ func NewRuntime(runtimeType string) (Runtime, error) {
 		return nil, fmt.Errorf("invalid runtime type: %s, wasmtime and wasmedge are supported in current version", runtimeType)
 	}
 }
+
+// MaxObserveDataTagRange is the max number of data tags that a single observed data tag range can contain.
+const MaxObserveDataTagRange = 1 << 16
+
+// observedTags collects the data tags observed by the wasm sfn.
+// A tag that has been observed will be ignored when it is observed again,
+// so that overlapping ranges do not cause duplicate deliveries.
+type observedTags struct {
+	tags []uint32
+	set  map[uint32]struct{}
+}
+
+// add observes a single data tag.
+func (o *observedTags) add(tag uint32) {
+	if o.set == nil {
+		o.set = make(map[uint32]struct{})
+	}
+	if _, ok := o.set[tag]; ok {
+		return
+	}
+	o.set[tag] = struct{}{}
+	o.tags = append(o.tags, tag)
+}
+
+// addRange observes all the data tags in [min, max].
+func (o *observedTags) addRange(min, max uint32) error {
+	if min > max {
+		return fmt.Errorf("invalid data tag range: [%d, %d]", min, max)
+	}
+	if uint64(max)-uint64(min)+1 > MaxObserveDataTagRange {
+		return fmt.Errorf("data tag range [%d, %d] exceeds the max size %d", min, max, MaxObserveDataTagRange)
+	}
+	for tag := uint64(min); tag <= uint64(max); tag++ {
+		o.add(uint32(tag))
+	}
+	return nil
+}
+
+// list returns the observed data tags in ascending order.
+func (o *observedTags) list() []uint32 {
+	sort.Slice(o.tags, func(i, j int) bool { return o.tags[i] < o.tags[j] })
+	return o.tags
+}
@@ -3,6 +3,8 @@ package wasm
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log"
 	"os"
 	"sync"
@@ -21,9 +23,15 @@ type wasmServerless struct {
 	zipperAddrs []string
 	observed    []uint32
 	credential  string
+	initConfig  []byte
 	mu          *sync.Mutex
 }
 
+// deadLetterer is implemented by the serverless.Context that can give up the incoming data.
+type deadLetterer interface {
+	DeadLetter(reason string) error
+}
+
 // Init initializes the serverless
 func (s *wasmServerless) Init(opts *cli.Options) error {
 	runtime, err := NewRuntime(opts.Runtime)
@@ -36,6 +44,13 @@ func (s *wasmServerless) Init(opts *cli.Options) error {
 		return err
 	}
 
+	if opts.InitConfig != "" {
+		s.initConfig, err = os.ReadFile(opts.InitConfig)
+		if err != nil {
+			return fmt.Errorf("read init config %s: %v", opts.InitConfig, err)
+		}
+	}
+
 	s.runtime = runtime
 	s.name = opts.Name
 	s.zipperAddrs = opts.ZipperAddrs
@@ -70,7 +85,7 @@ func (s *wasmServerless) Run(verbose bool) error {
 		)
 		// init
 		err := sfn.Init(func() error {
-			return s.runtime.RunInit()
+			return s.runtime.RunInit(s.initConfig)
 		})
 		if err != nil {
 			return err
@@ -84,6 +99,17 @@ func (s *wasmServerless) Run(verbose bool) error {
 				s.mu.Lock()
 				defer s.mu.Unlock()
 				err := s.runtime.RunHandler(ctx)
+				// the failure of processing the data does not stop the sfn.
+				if he := new(HandlerError); errors.As(err, &he) {
+					log.Printf("[wasm][%s] handler error: tag=%d %v\n", addr, ctx.Tag(), he)
+					// route the data to the dead-letter tag of the zipper.
+					if dl, ok := ctx.(deadLetterer); ok {
+						if err := dl.DeadLetter(he.Message); err != nil {
+							log.Printf("[wasm][%s] dead letter error: %v\n", addr, err)
+						}
+					}
+					return
+				}
 				if err != nil {
 					ch <- err
 				}
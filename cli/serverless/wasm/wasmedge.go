@@ -20,8 +20,10 @@ type wasmEdgeRuntime struct {
 	conf   *wasmedge.Configure
 	module *wasmedge.Module
 
-	observed      []uint32
+	observed      observedTags
 	serverlessCtx serverless.Context
+	handlerErr    *HandlerError
+	initConfig    []byte
 }
 
 func newWasmEdgeRuntime() (*wasmEdgeRuntime, error) {
@@ -50,6 +52,14 @@ func (r *wasmEdgeRuntime) Init(wasmFile string) error {
 		},
 		[]wasmedge.ValType{}), r.observeDataTag, nil, 0)
 	r.module.AddFunction(WasmFuncObserveDataTag, observeDataTagFunc)
+	// observeDataTagRange
+	observeDataTagRangeFunc := wasmedge.NewFunction(wasmedge.NewFunctionType(
+		[]wasmedge.ValType{
+			wasmedge.ValType_I32,
+			wasmedge.ValType_I32,
+		},
+		[]wasmedge.ValType{}), r.observeDataTagRange, nil, 0)
+	r.module.AddFunction(WasmFuncObserveDataTagRange, observeDataTagRangeFunc)
 	// write
 	writeFunc := wasmedge.NewFunction(wasmedge.NewFunctionType(
 		[]wasmedge.ValType{
@@ -61,6 +71,31 @@ func (r *wasmEdgeRuntime) Init(wasmFile string) error {
 	),
 		r.write, nil, 0)
 	r.module.AddFunction(WasmFuncWrite, writeFunc)
+	// write with timeout
+	writeTimeoutFunc := wasmedge.NewFunction(wasmedge.NewFunctionType(
+		[]wasmedge.ValType{
+			wasmedge.ValType_I32,
+			wasmedge.ValType_I32,
+			wasmedge.ValType_I32,
+			wasmedge.ValType_I32,
+		},
+		[]wasmedge.ValType{wasmedge.ValType_I32},
+	),
+		r.writeTimeout, nil, 0)
+	r.module.AddFunction(WasmFuncWriteTimeout, writeTimeoutFunc)
+	// write with metadata
+	writeWithMetadataFunc := wasmedge.NewFunction(wasmedge.NewFunctionType(
+		[]wasmedge.ValType{
+			wasmedge.ValType_I32,
+			wasmedge.ValType_I32,
+			wasmedge.ValType_I32,
+			wasmedge.ValType_I32,
+			wasmedge.ValType_I32,
+		},
+		[]wasmedge.ValType{wasmedge.ValType_I32},
+	),
+		r.writeWithMetadata, nil, 0)
+	r.module.AddFunction(WasmFuncWriteWithMetadata, writeWithMetadataFunc)
 	// context tag
 	contextTagFunc := wasmedge.NewFunction(wasmedge.NewFunctionType(
 		[]wasmedge.ValType{},
@@ -79,6 +114,31 @@ func (r *wasmEdgeRuntime) Init(wasmFile string) error {
 		[]wasmedge.ValType{},
 		[]wasmedge.ValType{wasmedge.ValType_I32}), r.contextDataSize, nil, 0)
 	r.module.AddFunction(WasmFuncContextDataSize, contextDataSizeFunc)
+	// context data chunk
+	contextDataChunkFunc := wasmedge.NewFunction(wasmedge.NewFunctionType(
+		[]wasmedge.ValType{
+			wasmedge.ValType_I32,
+			wasmedge.ValType_I32,
+			wasmedge.ValType_I32,
+		},
+		[]wasmedge.ValType{wasmedge.ValType_I32}), r.contextDataChunk, nil, 0)
+	r.module.AddFunction(WasmFuncContextDataChunk, contextDataChunkFunc)
+	// handler error
+	handlerErrorFunc := wasmedge.NewFunction(wasmedge.NewFunctionType(
+		[]wasmedge.ValType{
+			wasmedge.ValType_I32,
+			wasmedge.ValType_I32,
+		},
+		[]wasmedge.ValType{}), r.handlerError, nil, 0)
+	r.module.AddFunction(WasmFuncHandlerError, handlerErrorFunc)
+	// init config
+	initConfigFunc := wasmedge.NewFunction(wasmedge.NewFunctionType(
+		[]wasmedge.ValType{
+			wasmedge.ValType_I32,
+			wasmedge.ValType_I32,
+		},
+		[]wasmedge.ValType{wasmedge.ValType_I32}), r.readInitConfig, nil, 0)
+	r.module.AddFunction(WasmFuncInitConfig, initConfigFunc)
 	// http
 	httpSendFunc := wasmedge.NewFunction(
 		wasmedge.NewFunctionType(
@@ -136,16 +196,20 @@ func (r *wasmEdgeRuntime) Init(wasmFile string) error {
 
 // GetObserveDataTags returns observed datatags of the wasm sfn
 func (r *wasmEdgeRuntime) GetObserveDataTags() []uint32 {
-	return r.observed
+	return r.observed.list()
 }
 
 // RunHandler runs the wasm application (request -> response mode)
 func (r *wasmEdgeRuntime) RunHandler(ctx serverless.Context) error {
 	r.serverlessCtx = ctx
+	r.handlerErr = nil
 	// Run the handler function. Given the pointer to the input data.
 	if _, err := r.vm.Execute(WasmFuncHandler); err != nil {
 		return fmt.Errorf("vm.Execute %s: %v", WasmFuncHandler, err)
 	}
+	if r.handlerErr != nil {
+		return r.handlerErr
+	}
 
 	return nil
 }
@@ -159,7 +223,8 @@ func (r *wasmEdgeRuntime) Close() error {
 }
 
 // RunInit runs the init function of the wasm sfn
-func (r *wasmEdgeRuntime) RunInit() error {
+func (r *wasmEdgeRuntime) RunInit(config []byte) error {
+	r.initConfig = config
 	// init
 	initFunc := r.vm.GetActiveModule().FindFunction(WasmFuncInit)
 	if initFunc == nil {
@@ -182,7 +247,20 @@ func (r *wasmEdgeRuntime) observeDataTag(
 	params []any,
 ) ([]any, wasmedge.Result) {
 	tag := params[0].(int32)
-	r.observed = append(r.observed, uint32(tag))
+	r.observed.add(uint32(tag))
+	return nil, wasmedge.Result_Success
+}
+
+func (r *wasmEdgeRuntime) observeDataTagRange(
+	_ any,
+	_ *wasmedge.CallingFrame,
+	params []any,
+) ([]any, wasmedge.Result) {
+	min := params[0].(int32)
+	max := params[1].(int32)
+	if err := r.observed.addRange(uint32(min), uint32(max)); err != nil {
+		log.Printf("observe data tag range: %v\n", err)
+	}
 	return nil, wasmedge.Result_Success
 }
 
@@ -224,6 +302,60 @@ func (r *wasmEdgeRuntime) contextDataSize(
 	return []any{dataLen}, wasmedge.Result_Success
 }
 
+func (r *wasmEdgeRuntime) contextDataChunk(
+	_ any,
+	callframe *wasmedge.CallingFrame,
+	params []any,
+) ([]any, wasmedge.Result) {
+	offset := params[0].(int32)
+	pointer := params[1].(int32)
+	limit := params[2].(int32)
+	chunk := contextDataChunk(r.serverlessCtx, uint32(offset), uint32(limit))
+	chunkLen := int32(len(chunk))
+	if chunkLen == 0 {
+		return []any{chunkLen}, wasmedge.Result_Success
+	}
+	mem := callframe.GetMemoryByIndex(0)
+	if err := mem.SetData(chunk, uint(pointer), uint(chunkLen)); err != nil {
+		log.Printf("Memory.SetData(%d, %d) error: %v\n", pointer, chunkLen, err)
+		return []any{ContextDataChunkError}, wasmedge.Result_Success
+	}
+	return []any{chunkLen}, wasmedge.Result_Success
+}
+
+func (r *wasmEdgeRuntime) handlerError(
+	_ any,
+	callframe *wasmedge.CallingFrame,
+	params []any,
+) ([]any, wasmedge.Result) {
+	pointer := params[0].(int32)
+	length := params[1].(int32)
+	mem := callframe.GetMemoryByIndex(0)
+	msg, err := mem.GetData(uint(pointer), uint(length))
+	if err != nil {
+		return []any{}, wasmedge.Result_Fail
+	}
+	r.handlerErr = &HandlerError{Message: string(msg)}
+	return []any{}, wasmedge.Result_Success
+}
+
+func (r *wasmEdgeRuntime) readInitConfig(
+	_ any,
+	callframe *wasmedge.CallingFrame,
+	params []any,
+) ([]any, wasmedge.Result) {
+	pointer := params[0].(int32)
+	limit := params[1].(int32)
+	config, size := initConfigChunk(r.initConfig, uint32(limit))
+	if len(config) > 0 {
+		mem := callframe.GetMemoryByIndex(0)
+		if err := mem.SetData(config, uint(pointer), uint(size)); err != nil {
+			return []any{0}, wasmedge.Result_Fail
+		}
+	}
+	return []any{int32(size)}, wasmedge.Result_Success
+}
+
 func (r *wasmEdgeRuntime) write(
 	_ any,
 	callframe *wasmedge.CallingFrame,
@@ -235,14 +367,55 @@ func (r *wasmEdgeRuntime) write(
 	mem := callframe.GetMemoryByIndex(0)
 	output, err := mem.GetData(uint(pointer), uint(length))
 	if err != nil {
-		return []any{1}, wasmedge.Result_Fail
+		return []any{WriteCodeMemoryError}, wasmedge.Result_Fail
 	}
 	buf := make([]byte, length)
 	copy(buf, output)
-	if err := r.serverlessCtx.Write(uint32(tag), buf); err != nil {
-		return []any{2}, wasmedge.Result_Fail
+	// the write error is returned as a code, so that the guest can decide to retry or abort.
+	return []any{writeCode(r.serverlessCtx.Write(uint32(tag), buf))}, wasmedge.Result_Success
+}
+
+func (r *wasmEdgeRuntime) writeTimeout(
+	_ any,
+	callframe *wasmedge.CallingFrame,
+	params []any,
+) ([]any, wasmedge.Result) {
+	tag := params[0].(int32)
+	pointer := params[1].(int32)
+	length := params[2].(int32)
+	timeoutMs := params[3].(int32)
+	mem := callframe.GetMemoryByIndex(0)
+	output, err := mem.GetData(uint(pointer), uint(length))
+	if err != nil {
+		return []any{WriteCodeMemoryError}, wasmedge.Result_Fail
 	}
-	return []any{0}, wasmedge.Result_Success
+	buf := make([]byte, length)
+	copy(buf, output)
+	return []any{writeWithTimeout(r.serverlessCtx, uint32(tag), buf, uint32(timeoutMs))}, wasmedge.Result_Success
+}
+
+func (r *wasmEdgeRuntime) writeWithMetadata(
+	_ any,
+	callframe *wasmedge.CallingFrame,
+	params []any,
+) ([]any, wasmedge.Result) {
+	tag := params[0].(int32)
+	pointer := params[1].(int32)
+	length := params[2].(int32)
+	mdPointer := params[3].(int32)
+	mdLength := params[4].(int32)
+	mem := callframe.GetMemoryByIndex(0)
+	output, err := mem.GetData(uint(pointer), uint(length))
+	if err != nil {
+		return []any{WriteCodeMemoryError}, wasmedge.Result_Fail
+	}
+	md, err := mem.GetData(uint(mdPointer), uint(mdLength))
+	if err != nil {
+		return []any{WriteCodeMemoryError}, wasmedge.Result_Fail
+	}
+	buf := make([]byte, length)
+	copy(buf, output)
+	return []any{writeWithMetadata(r.serverlessCtx, uint32(tag), buf, md)}, wasmedge.Result_Success
 }
 
 // httpSend sends http request
@@ -24,8 +24,10 @@ type wasmtimeRuntime struct {
 	observeDataTags *wasmtime.Func
 	handler         *wasmtime.Func
 
-	observed      []uint32
+	observed      observedTags
 	serverlessCtx serverless.Context
+	handlerErr    *HandlerError
+	initConfig    []byte
 }
 
 func newWasmtimeRuntime() (*wasmtimeRuntime, error) {
@@ -64,6 +66,10 @@ func (r *wasmtimeRuntime) Init(wasmFile string) error {
 	if err := r.linker.FuncWrap("env", WasmFuncObserveDataTag, r.observeDataTag); err != nil {
 		return fmt.Errorf("linker.FuncWrap: %s %v", WasmFuncObserveDataTag, err)
 	}
+	// observeDataTagRange
+	if err := r.linker.FuncWrap("env", WasmFuncObserveDataTagRange, r.observeDataTagRange); err != nil {
+		return fmt.Errorf("linker.FuncWrap: %s %v", WasmFuncObserveDataTagRange, err)
+	}
 	// context tag
 	if err := r.linker.FuncWrap("env", WasmFuncContextTag, r.contextTag); err != nil {
 		return fmt.Errorf("linker.FuncWrap: %s %v", WasmFuncContextTag, err)
@@ -76,10 +82,30 @@ func (r *wasmtimeRuntime) Init(wasmFile string) error {
 	if err := r.linker.FuncWrap("env", WasmFuncContextDataSize, r.contextDataSize); err != nil {
 		return fmt.Errorf("linker.FuncWrap: %s %v", WasmFuncContextDataSize, err)
 	}
+	// context data chunk
+	if err := r.linker.FuncWrap("env", WasmFuncContextDataChunk, r.contextDataChunk); err != nil {
+		return fmt.Errorf("linker.FuncWrap: %s %v", WasmFuncContextDataChunk, err)
+	}
+	// handler error
+	if err := r.linker.FuncWrap("env", WasmFuncHandlerError, r.handlerError); err != nil {
+		return fmt.Errorf("linker.FuncWrap: %s %v", WasmFuncHandlerError, err)
+	}
+	// init config
+	if err := r.linker.FuncWrap("env", WasmFuncInitConfig, r.readInitConfig); err != nil {
+		return fmt.Errorf("linker.FuncWrap: %s %v", WasmFuncInitConfig, err)
+	}
 	// write
 	if err := r.linker.FuncWrap("env", WasmFuncWrite, r.write); err != nil {
 		return fmt.Errorf("linker.FuncWrap: %s %v", WasmFuncWrite, err)
 	}
+	// write with timeout
+	if err := r.linker.FuncWrap("env", WasmFuncWriteTimeout, r.writeTimeout); err != nil {
+		return fmt.Errorf("linker.FuncWrap: %s %v", WasmFuncWriteTimeout, err)
+	}
+	// write with metadata
+	if err := r.linker.FuncWrap("env", WasmFuncWriteWithMetadata, r.writeWithMetadata); err != nil {
+		return fmt.Errorf("linker.FuncWrap: %s %v", WasmFuncWriteWithMetadata, err)
+	}
 	// http
 	if err := r.linker.FuncWrap("env", wasmhttp.WasmFuncHTTPSend, r.httpSend); err != nil {
 		return fmt.Errorf("linker.FuncWrap: %s %v", wasmhttp.WasmFuncHTTPSend, err)
@@ -115,16 +141,20 @@ func (r *wasmtimeRuntime) Init(wasmFile string) error {
 
 // GetObserveDataTags returns observed datatags of the wasm sfn
 func (r *wasmtimeRuntime) GetObserveDataTags() []uint32 {
-	return r.observed
+	return r.observed.list()
 }
 
 // RunHandler runs the wasm application (request -> response mode)
 func (r *wasmtimeRuntime) RunHandler(ctx serverless.Context) error {
 	r.serverlessCtx = ctx
+	r.handlerErr = nil
 	// run handler
 	if _, err := r.handler.Call(r.store); err != nil {
 		return fmt.Errorf("handler.Call: %v", err)
 	}
+	if r.handlerErr != nil {
+		return r.handlerErr
+	}
 	return nil
 }
 
@@ -134,7 +164,8 @@ func (r *wasmtimeRuntime) Close() error {
 }
 
 // RunInitruns the init function of the wasm sfn
-func (r *wasmtimeRuntime) RunInit() error {
+func (r *wasmtimeRuntime) RunInit(config []byte) error {
+	r.initConfig = config
 	if r.init == nil {
 		fmt.Println("init function not used")
 		return nil
@@ -150,7 +181,13 @@ func (r *wasmtimeRuntime) RunInit() error {
 }
 
 func (r *wasmtimeRuntime) observeDataTag(tag int32) {
-	r.observed = append(r.observed, uint32(tag))
+	r.observed.add(uint32(tag))
+}
+
+func (r *wasmtimeRuntime) observeDataTagRange(min int32, max int32) {
+	if err := r.observed.addRange(uint32(min), uint32(max)); err != nil {
+		log.Printf("observe data tag range: %v\n", err)
+	}
 }
 
 func (r *wasmtimeRuntime) contextTag() int32 {
@@ -173,6 +210,31 @@ func (r *wasmtimeRuntime) contextDataSize() int32 {
 	return int32(len(r.serverlessCtx.Data()))
 }
 
+func (r *wasmtimeRuntime) contextDataChunk(offset int32, pointer int32, limit int32) int32 {
+	chunk := contextDataChunk(r.serverlessCtx, uint32(offset), uint32(limit))
+	if len(chunk) == 0 {
+		return 0
+	}
+	mem := r.memory.UnsafeData(r.store)
+	if pointer < 0 || int(pointer)+len(chunk) > len(mem) {
+		log.Printf("memory write (%d, %d) out of range\n", pointer, len(chunk))
+		return ContextDataChunkError
+	}
+	copy(mem[pointer:], chunk)
+	return int32(len(chunk))
+}
+
+func (r *wasmtimeRuntime) handlerError(pointer int32, length int32) {
+	msg := r.memory.UnsafeData(r.store)[pointer : pointer+length]
+	r.handlerErr = &HandlerError{Message: string(msg)}
+}
+
+func (r *wasmtimeRuntime) readInitConfig(pointer int32, limit int32) int32 {
+	config, size := initConfigChunk(r.initConfig, uint32(limit))
+	copy(r.memory.UnsafeData(r.store)[pointer:pointer+int32(len(config))], config)
+	return int32(size)
+}
+
 func (r *wasmtimeRuntime) write(tag int32, pointer int32, length int32) int32 {
 	output := r.memory.UnsafeData(r.store)[pointer : pointer+length]
 	if len(output) == 0 {
@@ -180,10 +242,29 @@ func (r *wasmtimeRuntime) write(tag int32, pointer int32, length int32) int32 {
 	}
 	buf := make([]byte, length)
 	copy(buf, output)
-	if err := r.serverlessCtx.Write(uint32(tag), buf); err != nil {
-		return 2
+	return writeCode(r.serverlessCtx.Write(uint32(tag), buf))
+}
+
+func (r *wasmtimeRuntime) writeTimeout(tag int32, pointer int32, length int32, timeoutMs int32) int32 {
+	output := r.memory.UnsafeData(r.store)[pointer : pointer+length]
+	if len(output) == 0 {
+		return 0
 	}
-	return 0
+	buf := make([]byte, length)
+	copy(buf, output)
+	return writeWithTimeout(r.serverlessCtx, uint32(tag), buf, uint32(timeoutMs))
+}
+
+func (r *wasmtimeRuntime) writeWithMetadata(tag int32, pointer int32, length int32, mdPointer int32, mdLength int32) int32 {
+	mem := r.memory.UnsafeData(r.store)
+	output := mem[pointer : pointer+length]
+	if len(output) == 0 {
+		return 0
+	}
+	buf := make([]byte, length)
+	copy(buf, output)
+	md := mem[mdPointer : mdPointer+mdLength]
+	return writeWithMetadata(r.serverlessCtx, uint32(tag), buf, md)
 }
 
 // httpSend sends a HTTP request and returns the response
@@ -26,8 +26,10 @@ type wazeroRuntime struct {
 	module api.Module
 	cache  wazero.CompilationCache
 
-	observed      []uint32
+	observed      observedTags
 	serverlessCtx serverless.Context
+	handlerErr    *HandlerError
+	initConfig    []byte
 }
 
 func newWazeroRuntime() (*wazeroRuntime, error) {
@@ -73,10 +75,22 @@ func (r *wazeroRuntime) Init(wasmFile string) error {
 		NewFunctionBuilder().
 		WithGoFunction(api.GoFunc(r.observeDataTag), []api.ValueType{i32}, []api.ValueType{}).
 		Export(WasmFuncObserveDataTag).
+		// observeDataTagRange
+		NewFunctionBuilder().
+		WithGoFunction(api.GoFunc(r.observeDataTagRange), []api.ValueType{i32, i32}, []api.ValueType{}).
+		Export(WasmFuncObserveDataTagRange).
 		// write
 		NewFunctionBuilder().
 		WithGoModuleFunction(api.GoModuleFunc(r.write), []api.ValueType{i32, i32, i32}, []api.ValueType{i32}).
 		Export(WasmFuncWrite).
+		// write with timeout
+		NewFunctionBuilder().
+		WithGoModuleFunction(api.GoModuleFunc(r.writeTimeout), []api.ValueType{i32, i32, i32, i32}, []api.ValueType{i32}).
+		Export(WasmFuncWriteTimeout).
+		// write with metadata
+		NewFunctionBuilder().
+		WithGoModuleFunction(api.GoModuleFunc(r.writeWithMetadata), []api.ValueType{i32, i32, i32, i32, i32}, []api.ValueType{i32}).
+		Export(WasmFuncWriteWithMetadata).
 		// context tag
 		NewFunctionBuilder().
 		WithGoFunction(api.GoFunc(r.contextTag), []api.ValueType{}, []api.ValueType{i32}).
@@ -88,7 +102,19 @@ func (r *wazeroRuntime) Init(wasmFile string) error {
 		// context data size
 		NewFunctionBuilder().
 		WithGoFunction(api.GoFunc(r.contextDataSize), []api.ValueType{}, []api.ValueType{i32}).
-		Export(WasmFuncContextDataSize)
+		Export(WasmFuncContextDataSize).
+		// context data chunk
+		NewFunctionBuilder().
+		WithGoModuleFunction(api.GoModuleFunc(r.contextDataChunk), []api.ValueType{i32, i32, i32}, []api.ValueType{i32}).
+		Export(WasmFuncContextDataChunk).
+		// handler error
+		NewFunctionBuilder().
+		WithGoModuleFunction(api.GoModuleFunc(r.handlerError), []api.ValueType{i32, i32}, []api.ValueType{}).
+		Export(WasmFuncHandlerError).
+		// init config
+		NewFunctionBuilder().
+		WithGoModuleFunction(api.GoModuleFunc(r.readInitConfig), []api.ValueType{i32, i32}, []api.ValueType{i32}).
+		Export(WasmFuncInitConfig)
 	// http
 	host.ExportHTTPHostFuncs(builder)
 
@@ -121,7 +147,7 @@ func (r *wazeroRuntime) Init(wasmFile string) error {
 
 // GetObserveDataTags returns observed datatags of the wasm sfn
 func (r *wazeroRuntime) GetObserveDataTags() []uint32 {
-	return r.observed
+	return r.observed.list()
 }
 
 // RunHandler runs the wasm application (request -> response mode)
@@ -133,6 +159,7 @@ func (r *wazeroRuntime) RunHandler(ctx serverless.Context) error {
 	default:
 	}
 	r.serverlessCtx = ctx
+	r.handlerErr = nil
 	// run handler
 	handler := r.module.ExportedFunction(WasmFuncHandler)
 	if _, err := handler.Call(r.ctx); err != nil {
@@ -142,6 +169,9 @@ func (r *wazeroRuntime) RunHandler(ctx serverless.Context) error {
 			return fmt.Errorf("handler.Call: %v", err)
 		}
 	}
+	if r.handlerErr != nil {
+		return r.handlerErr
+	}
 	return nil
 }
 
@@ -152,7 +182,8 @@ func (r *wazeroRuntime) Close() error {
 }
 
 // RunInit runs the init function of the wasm sfn
-func (r *wazeroRuntime) RunInit() error {
+func (r *wazeroRuntime) RunInit(config []byte) error {
+	r.initConfig = config
 	initFunc := r.module.ExportedFunction(WasmFuncInit)
 	if initFunc == nil {
 		fmt.Println("init function not used")
@@ -174,7 +205,15 @@ func (r *wazeroRuntime) RunInit() error {
 
 func (r *wazeroRuntime) observeDataTag(ctx context.Context, stack []uint64) {
 	tag := uint32(stack[0])
-	r.observed = append(r.observed, tag)
+	r.observed.add(tag)
+}
+
+func (r *wazeroRuntime) observeDataTagRange(ctx context.Context, stack []uint64) {
+	min := uint32(stack[0])
+	max := uint32(stack[1])
+	if err := r.observed.addRange(min, max); err != nil {
+		log.Printf("observe data tag range: %v\n", err)
+	}
 }
 
 func (r *wazeroRuntime) write(ctx context.Context, m api.Module, stack []uint64) {
@@ -184,17 +223,54 @@ func (r *wazeroRuntime) write(ctx context.Context, m api.Module, stack []uint64)
 	output, ok := m.Memory().Read(pointer, length)
 	if !ok {
 		log.Printf("Memory.Read(%d, %d) out of range\n", pointer, length)
-		stack[0] = 1
+		stack[0] = uint64(WriteCodeMemoryError)
 		return
 	}
 	buf := make([]byte, length)
 	copy(buf, output)
 
-	if err := r.serverlessCtx.Write(tag, buf); err != nil {
-		stack[0] = 2
+	stack[0] = uint64(writeCode(r.serverlessCtx.Write(tag, buf)))
+}
+
+func (r *wazeroRuntime) writeTimeout(ctx context.Context, m api.Module, stack []uint64) {
+	tag := uint32(stack[0])
+	pointer := uint32(stack[1])
+	length := uint32(stack[2])
+	timeoutMs := uint32(stack[3])
+	output, ok := m.Memory().Read(pointer, length)
+	if !ok {
+		log.Printf("Memory.Read(%d, %d) out of range\n", pointer, length)
+		stack[0] = uint64(WriteCodeMemoryError)
+		return
+	}
+	buf := make([]byte, length)
+	copy(buf, output)
+
+	stack[0] = uint64(writeWithTimeout(r.serverlessCtx, tag, buf, timeoutMs))
+}
+
+func (r *wazeroRuntime) writeWithMetadata(ctx context.Context, m api.Module, stack []uint64) {
+	tag := uint32(stack[0])
+	pointer := uint32(stack[1])
+	length := uint32(stack[2])
+	mdPointer := uint32(stack[3])
+	mdLength := uint32(stack[4])
+	output, ok := m.Memory().Read(pointer, length)
+	if !ok {
+		log.Printf("Memory.Read(%d, %d) out of range\n", pointer, length)
+		stack[0] = uint64(WriteCodeMemoryError)
+		return
+	}
+	md, ok := m.Memory().Read(mdPointer, mdLength)
+	if !ok {
+		log.Printf("Memory.Read(%d, %d) out of range\n", mdPointer, mdLength)
+		stack[0] = uint64(WriteCodeMemoryError)
 		return
 	}
-	stack[0] = 0
+	buf := make([]byte, length)
+	copy(buf, output)
+
+	stack[0] = uint64(writeWithMetadata(r.serverlessCtx, tag, buf, md))
 }
 
 func (r *wazeroRuntime) contextTag(ctx context.Context, stack []uint64) {
@@ -224,3 +300,45 @@ func (r *wazeroRuntime) contextData(ctx context.Context, m api.Module, stack []u
 func (r *wazeroRuntime) contextDataSize(ctx context.Context, stack []uint64) {
 	stack[0] = uint64(len(r.serverlessCtx.Data()))
 }
+
+func (r *wazeroRuntime) contextDataChunk(ctx context.Context, m api.Module, stack []uint64) {
+	offset := uint32(stack[0])
+	pointer := uint32(stack[1])
+	limit := uint32(stack[2])
+	chunk := contextDataChunk(r.serverlessCtx, offset, limit)
+	if len(chunk) == 0 {
+		stack[0] = 0
+		return
+	}
+	if ok := m.Memory().Write(pointer, chunk); !ok {
+		log.Printf("Memory.Write(%d, %d) out of range\n", pointer, len(chunk))
+		stack[0] = api.EncodeI32(ContextDataChunkError)
+		return
+	}
+	stack[0] = uint64(len(chunk))
+}
+
+func (r *wazeroRuntime) handlerError(ctx context.Context, m api.Module, stack []uint64) {
+	pointer := uint32(stack[0])
+	length := uint32(stack[1])
+	msg, ok := m.Memory().Read(pointer, length)
+	if !ok {
+		log.Printf("Memory.Read(%d, %d) out of range\n", pointer, length)
+		return
+	}
+	r.handlerErr = &HandlerError{Message: string(msg)}
+}
+
+func (r *wazeroRuntime) readInitConfig(ctx context.Context, m api.Module, stack []uint64) {
+	pointer := uint32(stack[0])
+	limit := uint32(stack[1])
+	config, size := initConfigChunk(r.initConfig, limit)
+	if len(config) > 0 {
+		if ok := m.Memory().Write(pointer, config); !ok {
+			log.Printf("Memory.Write(%d, %d) out of range\n", pointer, size)
+			stack[0] = 0
+			return
+		}
+	}
+	stack[0] = uint64(size)
+}
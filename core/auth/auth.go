@@ -22,6 +22,14 @@ type Authentication interface {
 	Name() string
 }
 
+// BinaryAuthentication is the Authentication that authenticates the binary credentials, such as signatures,
+// the payload is passed to it as is instead of being converted to a string.
+type BinaryAuthentication interface {
+	Authentication
+	// AuthenticateBytes authenticates client's binary credential.
+	AuthenticateBytes(payload []byte) (metadata.M, bool)
+}
+
 // Register register authentication
 func Register(authentication Authentication) {
 	auths[authentication.Name()] = authentication
@@ -36,7 +44,7 @@ func GetAuth(name string) (Authentication, bool) {
 // Credential client credential
 type Credential struct {
 	name    string
-	payload string
+	payload []byte
 }
 
 // NewCredential create client credential
@@ -48,14 +56,24 @@ func NewCredential(payload string) *Credential {
 		authPayload := payload[idx:]
 		return &Credential{
 			name:    authName,
-			payload: authPayload,
+			payload: []byte(authPayload),
 		}
 	}
 	return &Credential{name: "none"}
 }
 
+// NewBinaryCredential create client credential with the binary payload, such as a signature.
+func NewBinaryCredential(name string, payload []byte) *Credential {
+	return &Credential{name: name, payload: payload}
+}
+
 // Payload client credential payload
 func (c *Credential) Payload() string {
+	return string(c.payload)
+}
+
+// PayloadBytes client credential payload in bytes
+func (c *Credential) PayloadBytes() []byte {
 	return c.payload
 }
 
@@ -81,5 +99,8 @@ func Authenticate(auths map[string]Authentication, obj *frame.AuthenticationFram
 		return metadata.M{}, false
 	}
 
-	return auth.Authenticate(obj.AuthPayload)
+	if ba, ok := auth.(BinaryAuthentication); ok {
+		return ba.AuthenticateBytes(obj.AuthPayload)
+	}
+	return auth.Authenticate(obj.AuthPayloadString())
 }
@@ -1,6 +1,7 @@
 package auth
 
 import (
+	"bytes"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -18,6 +19,17 @@ func (auth mockAuth) Authenticate(payload string) (metadata.M, bool) {
 }
 func (auth mockAuth) Name() string { return "mock" }
 
+// mockBinaryAuth implement `BinaryAuthentication` interface,
+// AuthenticateBytes returns true if the payload is equal to the key.
+type mockBinaryAuth struct {
+	mockAuth
+	key []byte
+}
+
+func (auth mockBinaryAuth) AuthenticateBytes(payload []byte) (metadata.M, bool) {
+	return metadata.M{}, bytes.Equal(auth.key, payload)
+}
+
 func TestRegister(t *testing.T) {
 	expected := mockAuth{}
 
@@ -42,7 +54,7 @@ func TestAuthenticate(t *testing.T) {
 			name: "auths is nil",
 			args: args{
 				auths: nil,
-				obj:   &frame.AuthenticationFrame{AuthName: "mock", AuthPayload: "mock_payload"},
+				obj:   &frame.AuthenticationFrame{AuthName: "mock", AuthPayload: []byte("mock_payload")},
 			},
 			want: true,
 		},
@@ -58,7 +70,7 @@ func TestAuthenticate(t *testing.T) {
 			name: "auth obj not found",
 			args: args{
 				auths: map[string]Authentication{"mock": mockAuth{authed: true}},
-				obj:   &frame.AuthenticationFrame{AuthName: "mock_not_match", AuthPayload: "mock_payload"},
+				obj:   &frame.AuthenticationFrame{AuthName: "mock_not_match", AuthPayload: []byte("mock_payload")},
 			},
 			want: false,
 		},
@@ -66,7 +78,7 @@ func TestAuthenticate(t *testing.T) {
 			name: "auth success",
 			args: args{
 				auths: map[string]Authentication{"mock": mockAuth{authed: true}},
-				obj:   &frame.AuthenticationFrame{AuthName: "mock", AuthPayload: "mock_payload"},
+				obj:   &frame.AuthenticationFrame{AuthName: "mock", AuthPayload: []byte("mock_payload")},
 			},
 			want: true,
 		},
@@ -74,7 +86,23 @@ func TestAuthenticate(t *testing.T) {
 			name: "auth failed",
 			args: args{
 				auths: map[string]Authentication{"mock": mockAuth{authed: false}},
-				obj:   &frame.AuthenticationFrame{AuthName: "mock", AuthPayload: "mock_payload"},
+				obj:   &frame.AuthenticationFrame{AuthName: "mock", AuthPayload: []byte("mock_payload")},
+			},
+			want: false,
+		},
+		{
+			name: "binary auth success",
+			args: args{
+				auths: map[string]Authentication{"mock": mockBinaryAuth{key: []byte{0x00, 0xff}}},
+				obj:   &frame.AuthenticationFrame{AuthName: "mock", AuthPayload: []byte{0x00, 0xff}},
+			},
+			want: true,
+		},
+		{
+			name: "binary auth failed",
+			args: args{
+				auths: map[string]Authentication{"mock": mockBinaryAuth{mockAuth: mockAuth{authed: true}, key: []byte{0x00, 0xff}}},
+				obj:   &frame.AuthenticationFrame{AuthName: "mock", AuthPayload: []byte{0x00, 0xfe}},
 			},
 			want: false,
 		},
@@ -103,7 +131,7 @@ func TestNewCredential(t *testing.T) {
 			},
 			want: &Credential{
 				name:    "token",
-				payload: "the-token",
+				payload: []byte("the-token"),
 			},
 		},
 		{
@@ -113,7 +141,7 @@ func TestNewCredential(t *testing.T) {
 			},
 			want: &Credential{
 				name:    "none",
-				payload: "",
+				payload: nil,
 			},
 		},
 	}
@@ -0,0 +1,271 @@
+package core
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/yomorun/yomo/core/frame"
+)
+
+// MetadataBackflowTagKey is the key of the original tag of the backflow rerouted to the fallback tag,
+// see WithServerBackflowFallbackTag.
+const MetadataBackflowTagKey = "yomo-backflow-tag"
+
+// DefaultBackflowWindow is the time the zipper remembers the source that has disconnected,
+// the backflow to it in the window is handled by the BackflowPolicy.
+const DefaultBackflowWindow = 30 * time.Second
+
+// maxBackflowBuffered is the max number of the backflows buffered for a source, the later ones are dropped.
+const maxBackflowBuffered = 1024
+
+// BackflowPolicy decides what to do with the backflow to the source that has disconnected,
+// such as the results of the stream functions processing the data of a restarting source.
+type BackflowPolicy int
+
+const (
+	// BackflowPolicyDrop drops the backflow, it is counted by Server.StatsBackflowDroppedCounter.
+	BackflowPolicyDrop BackflowPolicy = iota
+	// BackflowPolicyBuffer buffers the backflow in the window, it is written to the source
+	// once the source reconnects with the same id and credential, or dropped when the window is over.
+	BackflowPolicyBuffer
+	// BackflowPolicyFallbackTag reroutes the backflow to the stream functions observing the fallback tag,
+	// the original tag is carried in the metadata, see MetadataBackflowTagKey.
+	BackflowPolicyFallbackTag
+)
+
+// String returns the string of the BackflowPolicy.
+func (p BackflowPolicy) String() string {
+	switch p {
+	case BackflowPolicyDrop:
+		return "drop"
+	case BackflowPolicyBuffer:
+		return "buffer"
+	case BackflowPolicyFallbackTag:
+		return "fallback_tag"
+	default:
+		return "unknown"
+	}
+}
+
+// departedSource is the source that has disconnected in the window.
+type departedSource struct {
+	tags     []frame.Tag
+	identity [sha256.Size]byte
+	frames   []*frame.BackflowFrame
+	timer    Timer
+}
+
+// streamIdentity returns the identity of the connection the stream belongs to,
+// it is zero if the stream is not accepted by a ServerControlStream.
+func streamIdentity(stream DataStream) [sha256.Size]byte {
+	ds, ok := stream.(*dataStream)
+	if !ok || ds.serverController == nil {
+		return [sha256.Size]byte{}
+	}
+	return ds.serverController.identity
+}
+
+// backflowKeeper remembers the sources those have disconnected in the window,
+// and handles the backflow to them by the policy.
+type backflowKeeper struct {
+	policy      BackflowPolicy
+	window      time.Duration
+	fallbackTag frame.Tag
+	clock       Clock
+
+	mu       sync.Mutex
+	departed map[string]*departedSource
+	dropped  atomic.Int64
+}
+
+func newBackflowKeeper(policy BackflowPolicy, window time.Duration, fallbackTag frame.Tag, clock Clock) *backflowKeeper {
+	if window <= 0 {
+		window = DefaultBackflowWindow
+	}
+	return &backflowKeeper{
+		policy:      policy,
+		window:      window,
+		fallbackTag: fallbackTag,
+		clock:       clock,
+		departed:    make(map[string]*departedSource),
+	}
+}
+
+// depart remembers the source that has disconnected for the window.
+func (k *backflowKeeper) depart(source DataStream) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	id := source.ID()
+	if d, ok := k.departed[id]; ok {
+		d.timer.Stop()
+		k.dropped.Add(int64(len(d.frames)))
+	}
+	d := &departedSource{tags: source.ObserveDataTags(), identity: streamIdentity(source)}
+	d.timer = k.clock.AfterFunc(k.window, func() { k.expire(id, d) })
+	k.departed[id] = d
+}
+
+// expire forgets the departed source when the window is over, the backflows buffered are dropped.
+func (k *backflowKeeper) expire(id string, d *departedSource) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if k.departed[id] != d {
+		return
+	}
+	delete(k.departed, id)
+	k.dropped.Add(int64(len(d.frames)))
+}
+
+// arrive forgets the source that reconnects, it returns the backflows buffered for it.
+// The backflows are dropped if the source is authenticated with another credential,
+// otherwise any client knowing the id could take them.
+func (k *backflowKeeper) arrive(source DataStream) []*frame.BackflowFrame {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	id := source.ID()
+	d, ok := k.departed[id]
+	if !ok {
+		return nil
+	}
+	d.timer.Stop()
+	delete(k.departed, id)
+
+	if d.identity != streamIdentity(source) {
+		k.dropped.Add(int64(len(d.frames)))
+		return nil
+	}
+	return d.frames
+}
+
+// buffer buffers a copy of the backflow for the departed source, it reports false
+// if the source is not departed or its buffer is full.
+func (k *backflowKeeper) buffer(sourceID string, bf *frame.BackflowFrame) bool {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	d, ok := k.departed[sourceID]
+	if !ok || len(d.frames) >= maxBackflowBuffered {
+		return false
+	}
+	// the frame read is released once it is handled, the buffer holds a copy.
+	d.frames = append(d.frames, &frame.BackflowFrame{
+		Tag:      bf.Tag,
+		Carriage: bytes.Clone(bf.Carriage),
+		Metadata: bytes.Clone(bf.Metadata),
+	})
+	return true
+}
+
+// wanted reports whether the backflow of the context is for a source that has departed in the window,
+// that is, the source observed the tag or waited for the reply of the request.
+func (k *backflowKeeper) wanted(c *Context, sourceID string) bool {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	d, ok := k.departed[sourceID]
+	if !ok {
+		return false
+	}
+	if _, ok := c.FrameMetadata.Get(MetadataRequestIDKey); ok {
+		return true
+	}
+	for _, tag := range d.tags {
+		if tag == c.Frame.Tag {
+			return true
+		}
+	}
+	return false
+}
+
+// handleDepartedBackflow handles the backflow to the source that has disconnected by the BackflowPolicy,
+// it is called if no source is found for the backflow written by a stream function.
+func (s *Server) handleDepartedBackflow(c *Context, sourceID string, bf *frame.BackflowFrame) {
+	k := s.backflow
+	if sourceID == "" || c.DataStream.StreamType() != StreamTypeStreamFunction || !k.wanted(c, sourceID) {
+		return
+	}
+	logger := c.Logger.With("source_conn_id", sourceID, "data_tag", c.Frame.Tag, "backflow_policy", k.policy.String())
+
+	switch k.policy {
+	case BackflowPolicyBuffer:
+		if k.buffer(sourceID, bf) {
+			logger.Info("backflow buffered for the disconnected source")
+			return
+		}
+	case BackflowPolicyFallbackTag:
+		if c.Frame.Tag != k.fallbackTag && s.rerouteBackflow(c, k.fallbackTag) {
+			logger.Info("backflow rerouted for the disconnected source", "fallback_tag", k.fallbackTag)
+			return
+		}
+	}
+	k.dropped.Add(1)
+	logger.Warn("backflow dropped for the disconnected source")
+}
+
+// sourceArrived writes the backflows buffered for the source that reconnects with the same id and credential.
+func (s *Server) sourceArrived(c *Context) {
+	frames := s.backflow.arrive(c.DataStream)
+	if len(frames) == 0 {
+		return
+	}
+	c.Logger.Info("write buffered backflow to the reconnected source", "backflow_num", len(frames))
+	for i, bf := range frames {
+		if err := c.DataStream.WriteFrame(bf); err != nil {
+			c.Logger.Error("failed to write buffered backflow to the source", "err", err)
+			s.backflow.dropped.Add(int64(len(frames) - i))
+			return
+		}
+	}
+}
+
+// sourceDeparted remembers the source that has disconnected, a stream migrated to another connection
+// is still connected.
+func (s *Server) sourceDeparted(source DataStream) {
+	if isMigrated(source) {
+		return
+	}
+	s.backflow.depart(source)
+}
+
+// rerouteBackflow writes a copy of the DataFrame of the context to the stream functions observing the tag,
+// it reports false if no stream function observes the tag.
+func (s *Server) rerouteBackflow(c *Context, tag frame.Tag) bool {
+	route := s.router.Route(c.FrameMetadata)
+	if route == nil {
+		return false
+	}
+	md := c.FrameMetadata.Clone()
+	md.Set(MetadataBackflowTagKey, strconv.FormatUint(uint64(c.Frame.Tag), 10))
+	b, err := md.EncodeWith(s.opts.metadataEncoding)
+	if err != nil {
+		c.Logger.Error("encode metadata error", "err", err)
+		return false
+	}
+	df := &frame.DataFrame{
+		Metadata: b,
+		Tag:      tag,
+		Payload:  c.Frame.Payload,
+		TTL:      c.Frame.TTL,
+	}
+
+	written := false
+	for _, id := range route.GetForwardRoutes(tag) {
+		stream, ok, err := s.connector.Get(id)
+		if err != nil || !ok {
+			continue
+		}
+		if err := s.writeToStream(stream, df); err != nil {
+			c.Logger.Error("failed to write frame for rerouting backflow", "err", err)
+			continue
+		}
+		written = true
+	}
+	return written
+}
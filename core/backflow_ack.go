@@ -0,0 +1,95 @@
+package core
+
+import (
+	"sync"
+
+	"github.com/yomorun/yomo/core/frame"
+	"github.com/yomorun/yomo/core/metadata"
+)
+
+const (
+	// MetadataBackflowAckIDKey is the key of the correlation id of the ack that the sfn asks for, the source
+	// acknowledges the BackflowFrame carries it with a BackflowAckFrame.
+	MetadataBackflowAckIDKey = "yomo-backflow-ack-id"
+	// MetadataBackflowAckStreamKey is the key of the id of the sfn stream that waits for the ack.
+	MetadataBackflowAckStreamKey = "yomo-backflow-ack-stream"
+)
+
+// backflowAcks tracks the backflow acks the sfn waits for, the acks those nobody waits for,
+// such as the late or the duplicated ones, are ignored.
+type backflowAcks struct {
+	mu      sync.Mutex
+	waiters map[string]chan struct{}
+}
+
+func newBackflowAcks() *backflowAcks {
+	return &backflowAcks{waiters: make(map[string]chan struct{})}
+}
+
+// expect registers the ack of the id, the channel returned is closed once the ack arrives,
+// the cancel func unregisters it.
+func (a *backflowAcks) expect(id string) (<-chan struct{}, func()) {
+	ch := make(chan struct{})
+
+	a.mu.Lock()
+	a.waiters[id] = ch
+	a.mu.Unlock()
+
+	return ch, func() {
+		a.mu.Lock()
+		delete(a.waiters, id)
+		a.mu.Unlock()
+	}
+}
+
+// resolve resolves the ack of the id, it reports false if nobody waits for it.
+func (a *backflowAcks) resolve(id string) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	ch, ok := a.waiters[id]
+	if !ok {
+		return false
+	}
+	delete(a.waiters, id)
+	close(ch)
+	return true
+}
+
+// ExpectBackflowAck registers the ack of the id, the channel returned is closed once the source acknowledges
+// the backflow carries the id in MetadataBackflowAckIDKey, the cancel func must be called if the caller gives up.
+func (c *Client) ExpectBackflowAck(id string) (<-chan struct{}, func()) {
+	return c.acks.expect(id)
+}
+
+// ackBackflow acknowledges the BackflowFrame if it asks for the ack.
+func (c *Client) ackBackflow(bf *frame.BackflowFrame) {
+	if len(bf.Metadata) == 0 {
+		return
+	}
+	md, err := metadata.Decode(bf.Metadata)
+	if err != nil {
+		return
+	}
+	ackID, ok := md.Get(MetadataBackflowAckIDKey)
+	if !ok {
+		return
+	}
+	streamID, _ := md.Get(MetadataBackflowAckStreamKey)
+
+	if err := c.WriteFrame(&frame.BackflowAckFrame{ID: ackID, StreamID: streamID}); err != nil {
+		c.logger.Debug("failed to ack backflow", "ack_id", ackID, "err", err)
+	}
+}
+
+// handleBackflowAckFrame forwards the BackflowAckFrame to the sfn stream that waits for it.
+func (s *Server) handleBackflowAckFrame(c *Context, ack *frame.BackflowAckFrame) {
+	stream, ok, err := s.connector.Get(ack.StreamID)
+	if err != nil || !ok || stream.StreamType() != StreamTypeStreamFunction {
+		c.Logger.Debug("drop backflow ack", "ack_id", ack.ID, "stream_id", ack.StreamID)
+		return
+	}
+	if err := s.writeToStream(stream, ack); err != nil {
+		c.Logger.Debug("failed to forward backflow ack", "ack_id", ack.ID, "stream_id", ack.StreamID, "err", err)
+	}
+}
@@ -0,0 +1,30 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackflowAcks(t *testing.T) {
+	acks := newBackflowAcks()
+
+	acked, cancel := acks.expect("ack-1")
+	defer cancel()
+
+	assert.False(t, acks.resolve("ack-2"), "nobody waits for ack-2")
+	assert.True(t, acks.resolve("ack-1"))
+
+	select {
+	case <-acked:
+	default:
+		t.Fatal("ack-1 should be acknowledged")
+	}
+	// the duplicated ack is ignored.
+	assert.False(t, acks.resolve("ack-1"))
+
+	// the canceled ack is ignored.
+	_, cancel2 := acks.expect("ack-3")
+	cancel2()
+	assert.False(t, acks.resolve("ack-3"))
+}
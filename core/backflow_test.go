@@ -0,0 +1,72 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yomorun/yomo/core/frame"
+	"github.com/yomorun/yomo/core/metadata"
+)
+
+func TestBackflowKeeper(t *testing.T) {
+	clock := NewManualClock(time.Now())
+	k := newBackflowKeeper(BackflowPolicyBuffer, time.Second, 0, clock)
+
+	newContext := func(tag frame.Tag) *Context {
+		return &Context{
+			Frame:         &frame.DataFrame{Tag: tag},
+			FrameMetadata: metadata.M{},
+			Logger:        discardingLogger,
+		}
+	}
+	source := newDataStream("source", "source-1", "", StreamTypeSource, metadata.M{}, []frame.Tag{1}, nil, nil, nil, clock)
+
+	assert.False(t, k.wanted(newContext(1), "source-1"))
+
+	k.depart(source)
+	assert.True(t, k.wanted(newContext(1), "source-1"))
+	assert.False(t, k.wanted(newContext(2), "source-1"))
+
+	// the reply of a request is wanted whatever its tag is.
+	c := newContext(2)
+	c.FrameMetadata.Set(MetadataRequestIDKey, "req-1")
+	assert.True(t, k.wanted(c, "source-1"))
+
+	carriage := []byte("result")
+	assert.True(t, k.buffer("source-1", &frame.BackflowFrame{Tag: 1, Carriage: carriage}))
+	carriage[0] = 'R'
+
+	frames := k.arrive(source)
+	assert.Len(t, frames, 1)
+	assert.Equal(t, "result", string(frames[0].Carriage))
+	assert.False(t, k.wanted(newContext(1), "source-1"))
+	assert.Equal(t, 0, clock.Timers())
+
+	// the backflows buffered are dropped when the window is over.
+	k.depart(source)
+	assert.True(t, k.buffer("source-1", &frame.BackflowFrame{Tag: 1}))
+	clock.Advance(time.Second)
+	assert.False(t, k.buffer("source-1", &frame.BackflowFrame{Tag: 1}))
+	assert.Nil(t, k.arrive(source))
+	assert.Equal(t, int64(1), k.dropped.Load())
+
+	// the backflows buffered are dropped if the source reconnects with another credential.
+	departed := newDataStream("source", "source-1", "", StreamTypeSource, metadata.M{}, []frame.Tag{1}, nil,
+		&ServerControlStream{identity: credentialIdentity(&frame.AuthenticationFrame{AuthName: "token", AuthPayload: []byte("a")})}, nil, clock)
+	k.depart(departed)
+	assert.True(t, k.buffer("source-1", &frame.BackflowFrame{Tag: 1}))
+
+	forged := newDataStream("source", "source-1", "", StreamTypeSource, metadata.M{}, []frame.Tag{1}, nil,
+		&ServerControlStream{identity: credentialIdentity(&frame.AuthenticationFrame{AuthName: "token", AuthPayload: []byte("b")})}, nil, clock)
+	assert.Nil(t, k.arrive(forged))
+	assert.Equal(t, int64(2), k.dropped.Load())
+	assert.False(t, k.wanted(newContext(1), "source-1"))
+
+	k.depart(departed)
+	assert.True(t, k.buffer("source-1", &frame.BackflowFrame{Tag: 1}))
+	reconnected := newDataStream("source", "source-1", "", StreamTypeSource, metadata.M{}, []frame.Tag{1}, nil,
+		&ServerControlStream{identity: credentialIdentity(&frame.AuthenticationFrame{AuthName: "token", AuthPayload: []byte("a")})}, nil, clock)
+	assert.Len(t, k.arrive(reconnected), 1)
+	assert.Equal(t, int64(2), k.dropped.Load())
+}
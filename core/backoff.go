@@ -0,0 +1,66 @@
+package core
+
+import (
+	"math/rand"
+	"time"
+)
+
+// Backoff describes how long the client waits between reconnect attempts.
+// The wait starts from Initial and doubles after every failed attempt until it reaches Max,
+// Jitter randomizes each wait by up to the given fraction, for example 0.2 means ±20%,
+// it is clamped to [0, 1] so the wait is never negative.
+type Backoff struct {
+	Initial time.Duration
+	Max     time.Duration
+	Jitter  float64
+}
+
+// DefaultBackoff is the default Backoff of the client.
+var DefaultBackoff = Backoff{
+	Initial: time.Second,
+	Max:     30 * time.Second,
+	Jitter:  0.2,
+}
+
+// backoffTimer calculates the wait of every attempt from a Backoff.
+type backoffTimer struct {
+	backoff Backoff
+	attempt int
+}
+
+func newBackoffTimer(b Backoff) *backoffTimer {
+	if b.Initial <= 0 {
+		b.Initial = DefaultBackoff.Initial
+	}
+	if b.Max < b.Initial {
+		b.Max = b.Initial
+	}
+	if b.Jitter < 0 {
+		b.Jitter = 0
+	}
+	if b.Jitter > 1 {
+		b.Jitter = 1
+	}
+	return &backoffTimer{backoff: b}
+}
+
+// next returns the wait before the next attempt.
+func (t *backoffTimer) next() time.Duration {
+	d := t.backoff.Initial
+	for i := 0; i < t.attempt && d < t.backoff.Max; i++ {
+		d *= 2
+	}
+	if d > t.backoff.Max {
+		d = t.backoff.Max
+	}
+	t.attempt++
+
+	if t.backoff.Jitter > 0 {
+		delta := float64(d) * t.backoff.Jitter
+		d += time.Duration(delta * (2*rand.Float64() - 1))
+	}
+	return d
+}
+
+// reset resets the timer after a successful attempt.
+func (t *backoffTimer) reset() { t.attempt = 0 }
@@ -0,0 +1,43 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackoffTimer(t *testing.T) {
+	timer := newBackoffTimer(Backoff{Initial: time.Second, Max: 5 * time.Second})
+
+	assert.Equal(t, time.Second, timer.next())
+	assert.Equal(t, 2*time.Second, timer.next())
+	assert.Equal(t, 4*time.Second, timer.next())
+	assert.Equal(t, 5*time.Second, timer.next())
+	assert.Equal(t, 5*time.Second, timer.next())
+
+	timer.reset()
+	assert.Equal(t, time.Second, timer.next())
+
+	t.Run("jitter", func(t *testing.T) {
+		timer := newBackoffTimer(Backoff{Initial: time.Second, Max: time.Second, Jitter: 0.5})
+		for i := 0; i < 10; i++ {
+			d := timer.next()
+			assert.GreaterOrEqual(t, d, 500*time.Millisecond)
+			assert.LessOrEqual(t, d, 1500*time.Millisecond)
+		}
+	})
+
+	t.Run("jitter clamped", func(t *testing.T) {
+		timer := newBackoffTimer(Backoff{Initial: time.Second, Max: time.Second, Jitter: 3})
+		assert.Equal(t, 1.0, timer.backoff.Jitter)
+		for i := 0; i < 100; i++ {
+			d := timer.next()
+			assert.GreaterOrEqual(t, d, time.Duration(0))
+			assert.LessOrEqual(t, d, 2*time.Second)
+		}
+
+		timer = newBackoffTimer(Backoff{Initial: time.Second, Max: time.Second, Jitter: -1})
+		assert.Equal(t, time.Second, timer.next())
+	})
+}
@@ -0,0 +1,182 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/yomorun/yomo/core/frame"
+	"github.com/yomorun/yomo/core/metadata"
+)
+
+const (
+	// MetadataChunkTransferIDKey is the key of the transfer id of the chunk in the metadata of the DataFrame,
+	// the zipper routes the chunks of a ChunkedDataFrame as the DataFrames carrying the chunk keys.
+	MetadataChunkTransferIDKey = "yomo-chunk-transfer-id"
+	// MetadataChunkIndexKey is the key of the index of the chunk in the metadata of the DataFrame.
+	MetadataChunkIndexKey = "yomo-chunk-index"
+	// MetadataChunkLastKey is the key that marks the last chunk in the metadata of the DataFrame.
+	MetadataChunkLastKey = "yomo-chunk-last"
+)
+
+const (
+	// DefaultMaxChunkedPayloadSize is the default max size of the payload reassembled from the chunks.
+	DefaultMaxChunkedPayloadSize = 64 * 1024 * 1024
+	// DefaultChunkReassemblyTimeout is the default time that the chunks of a transfer are reassembled in,
+	// the chunks of the transfer not completed in it are dropped.
+	DefaultChunkReassemblyTimeout = 30 * time.Second
+)
+
+// chunkBufferedPayloads is the count of the max size payloads the chunks of all the transfers being reassembled
+// are buffered up to, so the transfers started but never completed can not hold the memory without bound.
+const chunkBufferedPayloads = 4
+
+// ErrChunkedPayloadTooLarge is returned if the reassembled payload exceeds the max size,
+// the chunks of the transfer are dropped.
+var ErrChunkedPayloadTooLarge = errors.New("yomo: chunked payload is too large")
+
+// ErrChunkBufferFull is returned if the chunks buffered of all the transfers exceed the limit,
+// the chunks of the transfer are dropped.
+var ErrChunkBufferFull = errors.New("yomo: chunk buffer is full")
+
+// unchunkFrame converts the ChunkedDataFrame to the DataFrame carrying the chunk, the chunk is kept in the
+// metadata, so the chunks are routed like the DataFrames. Other frames are returned as is.
+func unchunkFrame(f frame.Frame) (frame.Frame, error) {
+	cf, ok := f.(*frame.ChunkedDataFrame)
+	if !ok {
+		return f, nil
+	}
+	md, err := metadata.Decode(cf.Metadata)
+	if err != nil {
+		return nil, err
+	}
+	md.Set(MetadataChunkTransferIDKey, cf.TransferID)
+	md.Set(MetadataChunkIndexKey, strconv.FormatUint(uint64(cf.Index), 10))
+	if cf.Last {
+		md.Set(MetadataChunkLastKey, "true")
+	}
+	b, err := md.EncodeWith(metadata.EncodingOf(cf.Metadata))
+	if err != nil {
+		return nil, err
+	}
+	return &frame.DataFrame{
+		Metadata: b,
+		Tag:      cf.Tag,
+		Payload:  cf.Payload,
+	}, nil
+}
+
+// chunkTransfer is a transfer being reassembled.
+type chunkTransfer struct {
+	next    uint32
+	payload []byte
+	timer   Timer
+}
+
+// chunkAssembler reassembles the chunks of the transfers into the DataFrames of the full payloads.
+type chunkAssembler struct {
+	maxSize     int
+	maxBuffered int
+	timeout     time.Duration
+	clock       Clock
+
+	mu        sync.Mutex
+	transfers map[string]*chunkTransfer
+	buffered  int
+}
+
+func newChunkAssembler(maxSize int, timeout time.Duration, clock Clock) *chunkAssembler {
+	return &chunkAssembler{
+		maxSize:     maxSize,
+		maxBuffered: chunkBufferedPayloads * maxSize,
+		timeout:     timeout,
+		clock:       clock,
+		transfers:   make(map[string]*chunkTransfer),
+	}
+}
+
+// add adds the DataFrame to the assembler, it returns the DataFrame as is if it is not a chunk,
+// or the DataFrame of the full payload once the last chunk is added, otherwise it returns nil.
+// The chunks must be added in order, the transfer is dropped with an error if a chunk is missing,
+// the payload exceeds the max size or the chunks buffered of all the transfers exceed the limit.
+// The transfer not completed in the timeout is dropped.
+func (a *chunkAssembler) add(df *frame.DataFrame) (*frame.DataFrame, error) {
+	transferID, ok := df.GetMetadata(MetadataChunkTransferIDKey)
+	if !ok {
+		return df, nil
+	}
+	indexString, _ := df.GetMetadata(MetadataChunkIndexKey)
+	index, err := strconv.ParseUint(indexString, 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("yomo: invalid chunk index of transfer %s: %w", transferID, err)
+	}
+	_, last := df.GetMetadata(MetadataChunkLastKey)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	t, ok := a.transfers[transferID]
+	if !ok {
+		t = &chunkTransfer{}
+		t.timer = a.clock.AfterFunc(a.timeout, func() { a.expire(transferID, t) })
+		a.transfers[transferID] = t
+	}
+	if uint32(index) != t.next {
+		a.drop(transferID, t)
+		return nil, fmt.Errorf("yomo: chunk %d of transfer %s is out of order, want %d", index, transferID, t.next)
+	}
+	if len(t.payload)+len(df.Payload) > a.maxSize {
+		a.drop(transferID, t)
+		return nil, ErrChunkedPayloadTooLarge
+	}
+	if a.buffered+len(df.Payload) > a.maxBuffered {
+		a.drop(transferID, t)
+		return nil, ErrChunkBufferFull
+	}
+	// the payload of the frame may be reused once the frame is handled.
+	t.payload = append(t.payload, df.Payload...)
+	a.buffered += len(df.Payload)
+	t.next++
+
+	if !last {
+		return nil, nil
+	}
+	a.drop(transferID, t)
+
+	md, err := metadata.Decode(df.Metadata)
+	if err != nil {
+		return nil, err
+	}
+	delete(md, MetadataChunkTransferIDKey)
+	delete(md, MetadataChunkIndexKey)
+	delete(md, MetadataChunkLastKey)
+	b, err := md.EncodeWith(metadata.EncodingOf(df.Metadata))
+	if err != nil {
+		return nil, err
+	}
+	return &frame.DataFrame{
+		Metadata: b,
+		Tag:      df.Tag,
+		Payload:  t.payload,
+		TTL:      df.TTL,
+	}, nil
+}
+
+// expire drops the transfer not completed in the timeout.
+func (a *chunkAssembler) expire(transferID string, t *chunkTransfer) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.transfers[transferID] == t {
+		a.drop(transferID, t)
+	}
+}
+
+// drop forgets the transfer, the caller must hold the mu.
+func (a *chunkAssembler) drop(transferID string, t *chunkTransfer) {
+	t.timer.Stop()
+	delete(a.transfers, transferID)
+	a.buffered -= len(t.payload)
+}
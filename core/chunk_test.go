@@ -0,0 +1,110 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yomorun/yomo/core/frame"
+	"github.com/yomorun/yomo/core/metadata"
+)
+
+func TestChunkAssembler(t *testing.T) {
+	md, _ := metadata.M{"foo": "bar"}.Encode()
+	chunk := func(transferID string, index uint32, last bool, payload string) *frame.DataFrame {
+		f, err := unchunkFrame(&frame.ChunkedDataFrame{
+			Metadata:   md,
+			Tag:        1,
+			TransferID: transferID,
+			Index:      index,
+			Last:       last,
+			Payload:    []byte(payload),
+		})
+		assert.NoError(t, err)
+		return f.(*frame.DataFrame)
+	}
+	clock := NewManualClock(time.Now())
+
+	t.Run("not chunk", func(t *testing.T) {
+		a := newChunkAssembler(10, time.Second, clock)
+
+		df := &frame.DataFrame{Tag: 1, Metadata: md, Payload: []byte("hello")}
+		got, err := a.add(df)
+		assert.NoError(t, err)
+		assert.Equal(t, df, got)
+	})
+
+	t.Run("reassemble", func(t *testing.T) {
+		a := newChunkAssembler(10, time.Second, clock)
+
+		got, err := a.add(chunk("a", 0, false, "hello"))
+		assert.NoError(t, err)
+		assert.Nil(t, got)
+
+		// the chunks of the transfers are interleaved.
+		got, err = a.add(chunk("b", 0, true, "yomo"))
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("yomo"), got.Payload)
+
+		got, err = a.add(chunk("a", 1, true, "world"))
+		assert.NoError(t, err)
+		assert.Equal(t, frame.Tag(1), got.Tag)
+		assert.Equal(t, []byte("helloworld"), got.Payload)
+		assert.Equal(t, md, got.Metadata)
+	})
+
+	t.Run("out of order", func(t *testing.T) {
+		a := newChunkAssembler(10, time.Second, clock)
+
+		_, err := a.add(chunk("a", 0, false, "hello"))
+		assert.NoError(t, err)
+		_, err = a.add(chunk("a", 2, true, "world"))
+		assert.EqualError(t, err, "yomo: chunk 2 of transfer a is out of order, want 1")
+		assert.Empty(t, a.transfers)
+	})
+
+	t.Run("too large", func(t *testing.T) {
+		a := newChunkAssembler(8, time.Second, clock)
+
+		_, err := a.add(chunk("a", 0, false, "hello"))
+		assert.NoError(t, err)
+		_, err = a.add(chunk("a", 1, true, "world"))
+		assert.ErrorIs(t, err, ErrChunkedPayloadTooLarge)
+		assert.Empty(t, a.transfers)
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		a := newChunkAssembler(10, time.Second, clock)
+
+		_, err := a.add(chunk("a", 0, false, "hello"))
+		assert.NoError(t, err)
+
+		// the transfer is dropped by the timer without the next chunk.
+		clock.Advance(2 * time.Second)
+		assert.Empty(t, a.transfers)
+		assert.Zero(t, a.buffered)
+
+		_, err = a.add(chunk("a", 1, true, "world"))
+		assert.Error(t, err)
+	})
+
+	t.Run("buffer full", func(t *testing.T) {
+		a := newChunkAssembler(4, time.Second, clock)
+
+		for _, id := range []string{"a", "b", "c", "d"} {
+			_, err := a.add(chunk(id, 0, false, "1234"))
+			assert.NoError(t, err)
+		}
+		_, err := a.add(chunk("e", 0, false, "1"))
+		assert.ErrorIs(t, err, ErrChunkBufferFull)
+		assert.Len(t, a.transfers, 4)
+
+		// the transfers completed free the buffer.
+		got, err := a.add(chunk("a", 1, true, ""))
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("1234"), got.Payload)
+		_, err = a.add(chunk("e", 0, false, "1"))
+		assert.NoError(t, err)
+		assert.Equal(t, 13, a.buffered)
+	})
+}
@@ -3,15 +3,19 @@ package core
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"io"
 	"reflect"
 	"runtime"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/quic-go/quic-go"
 	"github.com/yomorun/yomo/core/frame"
-	"github.com/yomorun/yomo/pkg/frame-codec/y3codec"
+	"github.com/yomorun/yomo/core/metadata"
 	"github.com/yomorun/yomo/pkg/id"
 	oteltrace "go.opentelemetry.io/otel/trace"
 	"golang.org/x/exp/slog"
@@ -26,6 +30,9 @@ type Client struct {
 	processor      func(*frame.DataFrame)     // function to invoke when data arrived
 	receiver       func(*frame.BackflowFrame) // function to invoke when data is processed
 	errorfn        func(error)                // function to invoke when error occured
+	goawayfn       func(string)               // function to invoke when GoawayFrame received
+	reconnectingfn func(int, error)           // function to invoke before every reconnect attempt
+	reconnectedfn  func()                     // function to invoke when client reconnected
 	opts           *clientOptions
 	logger         *slog.Logger
 	tracerProvider oteltrace.TracerProvider
@@ -35,6 +42,35 @@ type Client struct {
 	ctxCancel context.CancelCauseFunc
 
 	writeFrameChan chan frame.Frame
+
+	// draining is true once the client receives GoawayFrame,
+	// inflight tracks the handlers started by Go, they are waited during draining.
+	draining atomic.Bool
+	inflight sync.WaitGroup
+	// pendingWrites tracks the frames passed to WriteFrame those have not been written to the data stream,
+	// they are waited by CloseGracefully.
+	pendingWrites *pendingCounter
+
+	// mdMu protects md, md is the metadata of the data stream, it is sent with the HandshakeFrame.
+	mdMu sync.Mutex
+	md   metadata.M
+	// tagsMu protects the observeDataTags of opts, they can be changed by Observe and Unobserve.
+	tagsMu sync.RWMutex
+	// controlStream is the control stream currently connected.
+	controlStream atomic.Pointer[ClientControlStream]
+
+	// flow applies the FlowControlFrames from the zipper to the DataFrames written.
+	flow *flowController
+	// chunks reassembles the chunks of the ChunkedDataFrames before they are processed.
+	chunks *chunkAssembler
+	// readiness tracks the tags those have a stream function ready.
+	readiness *streamReadiness
+	// acks are the backflow acks the sfn waits for.
+	acks *backflowAcks
+	// controlHandlers are the handlers of the control frames, they are shared by the control streams of the reconnections.
+	controlHandlers *ControlFrameHandlers
+	// stats aggregates the processing of the DataFrames, it is nil unless the stats report is enabled.
+	stats *statsAggregator
 }
 
 // NewClient creates a new YoMo-Client.
@@ -52,8 +88,19 @@ func NewClient(appName string, connType ClientType, opts ...ClientOption) *Clien
 		logger.Info("use credential", "credential_name", option.credential.Name())
 	}
 
+	// the session tickets are kept across the reconnections for 0-RTT.
+	if option.enable0RTT && option.tlsConfig.ClientSessionCache == nil {
+		option.tlsConfig = option.tlsConfig.Clone()
+		option.tlsConfig.ClientSessionCache = tls.NewLRUClientSessionCache(0)
+	}
+
 	ctx, ctxCancel := context.WithCancelCause(context.Background())
 
+	var stats *statsAggregator
+	if option.statsReportInterval > 0 {
+		stats = newStatsAggregator()
+	}
+
 	return &Client{
 		name:           appName,
 		clientID:       clientID,
@@ -63,25 +110,40 @@ func NewClient(appName string, connType ClientType, opts ...ClientOption) *Clien
 		tracerProvider: option.tracerProvider,
 		errorfn:        func(err error) { logger.Error("client err", "err", err) },
 		writeFrameChan: make(chan frame.Frame),
+		flow:           newFlowController(option.maxPause, option.clock),
+		chunks:         newChunkAssembler(option.maxChunkedSize, option.chunkTimeout, option.clock),
+		stats:          stats,
 		ctx:            ctx,
 		ctxCancel:      ctxCancel,
+
+		readiness:       newStreamReadiness(),
+		acks:            newBackflowAcks(),
+		pendingWrites:   newPendingCounter(),
+		controlHandlers: NewControlFrameHandlers(),
 	}
 }
 
 // Connect connect client to server.
 func (c *Client) Connect(ctx context.Context, addr string) error {
-	if c.streamType == StreamTypeStreamFunction && len(c.opts.observeDataTags) == 0 {
+	if c.streamType == StreamTypeStreamFunction && len(c.observeDataTags()) == 0 {
 		return errors.New("yomo: streamFunction cannot observe data because the required tag has not been set")
 	}
+	if c.streamType == StreamTypeInspector && len(c.observeDataTags()) == 0 {
+		return errors.New("yomo: inspector cannot observe data because the required tag has not been set")
+	}
 
 	c.logger = c.logger.With("zipper_addr", addr)
 
+	backoff := newBackoffTimer(c.opts.reconnectBackoff)
+
 connect:
 	controlStream, dataStream, err := c.openStream(ctx, addr)
 	if err != nil {
-		if c.opts.connectUntilSucceed && !errors.As(err, new(ErrAuthenticateFailed)) {
+		if c.opts.connectUntilSucceed && !errors.As(err, new(ErrAuthenticateFailed)) && !errors.As(err, new(ErrVersionMismatch)) {
 			c.logger.Error("failed to connect to zipper, trying to reconnect", "err", err)
-			time.Sleep(time.Second)
+			if err := c.sleep(ctx, backoff.next()); err != nil {
+				return err
+			}
 			goto connect
 		}
 		c.logger.Error("can not connect to zipper", "error", err)
@@ -90,12 +152,49 @@ connect:
 	c.logger.Info("connected to zipper")
 
 	go c.runBackground(ctx, addr, controlStream, dataStream)
+	if c.stats != nil {
+		go c.reportStats(ctx)
+	}
 
 	return nil
 }
 
+// RecordProcessing records that a DataFrame is processed in the latency, failed marks the processing failed.
+// The stats are reported to the zipper if the client is created with WithStatsReport, otherwise it is a no-op.
+func (c *Client) RecordProcessing(latency time.Duration, failed bool) {
+	if c.stats == nil {
+		return
+	}
+	c.stats.record(latency, failed)
+}
+
+// reportStats reports the stats to the zipper at the interval until the client or the ctx is done.
+func (c *Client) reportStats(ctx context.Context) {
+	timer := c.opts.clock.NewTimer(c.opts.statsReportInterval)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-ctx.Done():
+			return
+		case <-timer.C():
+			timer.Reset(c.opts.statsReportInterval)
+			f := c.stats.report(c.clientID)
+			if f == nil {
+				continue
+			}
+			if err := c.controlStream.Load().ReportStats(f); err != nil {
+				c.logger.Debug("failed to report stats", "err", err)
+			}
+		}
+	}
+}
+
 func (c *Client) runBackground(ctx context.Context, addr string, controlStream *ClientControlStream, dataStream DataStream) {
-	reconnection := make(chan struct{})
+	reconnection := make(chan error)
+	backoff := newBackoffTimer(c.opts.reconnectBackoff)
 
 	go c.processStream(controlStream, dataStream, reconnection)
 
@@ -107,30 +206,114 @@ func (c *Client) runBackground(ctx context.Context, addr string, controlStream *
 		case <-ctx.Done():
 			c.cleanStream(controlStream, ctx.Err())
 			return
-		case <-reconnection:
+		case err := <-reconnection:
+			// the data stream is closed by the server for idle or missing heartbeats, but the connection
+			// is alive, so only the data stream is reopened on it.
+			if isStreamClosedSignal(err) {
+				reopened, rerr := c.reopenDataStream(ctx, controlStream)
+				if rerr == nil {
+					go c.processStream(controlStream, reopened, reconnection)
+					continue
+				}
+				c.logger.Debug("failed to reopen the data stream, reconnecting", "err", rerr)
+			}
+			// the old connection is closed before redialling, so that it is not leaked if it is still alive.
+			_ = controlStream.CloseWithError(err.Error())
+
+			backoff.reset()
+			attempt := 0
 		reconnect:
-			var err error
+			attempt++
+			if c.reconnectingfn != nil {
+				c.reconnectingfn(attempt, err)
+			}
+			// the first attempt is made immediately, the following ones wait for the backoff.
+			if attempt > 1 {
+				if err := c.sleep(ctx, backoff.next()); err != nil {
+					c.cleanStream(controlStream, err)
+					return
+				}
+			}
 			controlStream, dataStream, err = c.openStream(ctx, addr)
 			if err != nil {
-				if errors.As(err, new(ErrAuthenticateFailed)) {
+				if errors.As(err, new(ErrAuthenticateFailed)) || errors.As(err, new(ErrVersionMismatch)) {
 					c.cleanStream(controlStream, err)
 					return
 				}
-				c.logger.Error("reconnect error", "err", err)
-				time.Sleep(time.Second)
+				c.logger.Error("reconnect error", "err", err, "attempt", attempt)
+				// the connection is authenticated but the data stream fails to open.
+				if controlStream != nil {
+					_ = controlStream.CloseWithError(err.Error())
+				}
 				goto reconnect
 			}
+			c.logger.Info("reconnected to zipper", "attempt", attempt)
+			if c.reconnectedfn != nil {
+				c.reconnectedfn()
+			}
 			go c.processStream(controlStream, dataStream, reconnection)
 		}
 	}
 }
 
+// sleep waits for d, it returns the cause if the client or the ctx is done before d elapsed.
+func (c *Client) sleep(ctx context.Context, d time.Duration) error {
+	timer := c.opts.clock.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C():
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-c.ctx.Done():
+		return context.Cause(c.ctx)
+	}
+}
+
+// ErrClientDraining is returned by WriteFrame if the client is draining after receiving GoawayFrame.
+var ErrClientDraining = errors.New("yomo: client is draining because the server goaway")
+
 // WriteFrame write frame to client.
 func (c *Client) WriteFrame(f frame.Frame) error {
+	if c.draining.Load() {
+		return ErrClientDraining
+	}
+	switch ff := f.(type) {
+	case *frame.DataFrame:
+		c.waitStreamReady(ff.Tag)
+		if err := c.flow.wait(c.ctx, ff.Tag); err != nil {
+			return err
+		}
+	case *frame.ChunkedDataFrame:
+		c.waitStreamReady(ff.Tag)
+		if err := c.flow.wait(c.ctx, ff.Tag); err != nil {
+			return err
+		}
+	}
+	c.pendingWrites.add()
+	var err error
 	if c.opts.nonBlockWrite {
-		return c.nonBlockWriteFrame(f)
+		err = c.nonBlockWriteFrame(f)
+	} else {
+		err = c.blockWriteFrame(f)
+	}
+	// the frame passed to the data stream is done after it is written.
+	if err != nil {
+		c.pendingWrites.done()
+	}
+	return err
+}
+
+// waitStreamReady waits for a StreamFunction of the tag to be ready before the first write of the tag,
+// if the client is created with WithWaitStreamReady.
+func (c *Client) waitStreamReady(tag frame.Tag) {
+	if c.opts.streamReadyTimeout <= 0 {
+		return
+	}
+	if !c.readiness.waitFirst(c.ctx, c.opts.clock, tag, c.opts.streamReadyTimeout) {
+		c.logger.Debug("write before the stream function is ready", "data_tag", tag)
 	}
-	return c.blockWriteFrame(f)
 }
 
 // blockWriteFrame writes frames in block mode, guaranteeing that frames are not lost.
@@ -151,12 +334,16 @@ func (c *Client) nonBlockWriteFrame(f frame.Frame) error {
 	case c.writeFrameChan <- f:
 		return nil
 	default:
-		err := errors.New("yomo: client has lost connection")
+		err := ErrWriteBlocked
 		c.logger.Debug("failed to write frame", "frame_type", f.Type().String(), "error", err)
 		return err
 	}
 }
 
+// ErrWriteBlocked is returned by the non-blocking WriteFrame if the frame can not be written immediately,
+// It usually means that the client has lost connection and is reconnecting, the caller can retry later.
+var ErrWriteBlocked = errors.New("yomo: client has lost connection")
+
 func (c *Client) cleanStream(controlStream *ClientControlStream, err error) {
 	errString := ""
 	if err != nil {
@@ -180,16 +367,104 @@ func (c *Client) Close() error {
 	return nil
 }
 
+// ErrCloseTimeout is returned by CloseGracefully if the pending writes and the in-flight handlers
+// do not finish within the timeout, the client is closed anyway.
+var ErrCloseTimeout = errors.New("yomo: client closed before flushing the pending writes")
+
+// CloseGracefully closes the client after the frames passed to WriteFrame have been written to the data stream
+// and the in-flight handlers started by Go have finished, so the last results are not lost during the shutdown.
+// It waits up to the timeout, and returns ErrCloseTimeout if they do not finish in time.
+func (c *Client) CloseGracefully(timeout time.Duration) error {
+	done := make(chan struct{})
+	go func() {
+		c.inflight.Wait()
+		c.pendingWrites.wait()
+		close(done)
+	}()
+
+	var err error
+	select {
+	case <-done:
+	case <-c.ctx.Done():
+	case <-c.opts.clock.After(timeout):
+		err = ErrCloseTimeout
+		c.logger.Warn("pending writes do not finish before closing", "timeout", timeout)
+	}
+	c.Close()
+
+	return err
+}
+
+// pendingCounter counts the pending operations, and waits until there is none.
+type pendingCounter struct {
+	mu sync.Mutex
+	n  int
+	// idle is closed while there is no pending operation.
+	idle chan struct{}
+}
+
+func newPendingCounter() *pendingCounter {
+	idle := make(chan struct{})
+	close(idle)
+	return &pendingCounter{idle: idle}
+}
+
+func (p *pendingCounter) add() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.n == 0 {
+		p.idle = make(chan struct{})
+	}
+	p.n++
+}
+
+func (p *pendingCounter) done() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.n--
+	if p.n == 0 {
+		close(p.idle)
+	}
+}
+
+// wait waits until there is no pending operation.
+func (p *pendingCounter) wait() {
+	p.mu.Lock()
+	idle := p.idle
+	p.mu.Unlock()
+
+	<-idle
+}
+
 func (c *Client) openControlStream(ctx context.Context, addr string) (*ClientControlStream, error) {
-	controlStream, err := OpenClientControlStream(
+	controlStream, err := c.dialControlStream(ctx, addr, c.opts.enable0RTT)
+	// the 0-RTT is rejected if the session ticket is expired for example, it falls back to the full handshake.
+	if c.opts.enable0RTT && errors.Is(err, quic.Err0RTTRejected) {
+		c.logger.Debug("0-RTT rejected, fall back to the full handshake")
+		if controlStream != nil {
+			_ = controlStream.CloseWithError(err.Error())
+		}
+		return c.dialControlStream(ctx, addr, false)
+	}
+	return controlStream, err
+}
+
+func (c *Client) dialControlStream(ctx context.Context, addr string, early bool) (*ClientControlStream, error) {
+	controlStream, err := openClientControlStream(
 		ctx, addr,
-		c.opts.tlsConfig, c.opts.quicConfig,
-		y3codec.Codec(), y3codec.PacketReadWriter(),
-		c.logger,
+		tlsConfigWithALPN(c.opts.tlsConfig, c.opts.alpn),
+		quicConfigWithKeepAlive(c.opts.quicConfig, c.opts.keepAlivePeriod, c.opts.maxIdleTimeout),
+		early, c.opts.codec, c.opts.packetReadWriter,
+		c.logger, WithReadBufferSize(c.opts.readBufferSize), WithWriteQueue(c.opts.writeQueueSize),
 	)
 	if err != nil {
 		return controlStream, err
 	}
+	controlStream.versions = c.opts.versions
+	controlStream.labels = c.opts.labels
+	controlStream.handlers = c.controlHandlers
 
 	if err := controlStream.Authenticate(c.opts.credential); err != nil {
 		return controlStream, err
@@ -207,27 +482,93 @@ func (c *Client) openStream(ctx context.Context, addr string) (*ClientControlStr
 	if err != nil {
 		return controlStream, dataStream, err
 	}
+	c.controlStream.Store(controlStream)
+
+	// the stream function tells the sources that it is ready once its handler is installed.
+	if c.streamType == StreamTypeStreamFunction && c.processor != nil {
+		if err := controlStream.StreamReady(c.clientID); err != nil {
+			c.logger.Debug("failed to send stream ready", "err", err)
+		}
+	}
 
 	return controlStream, dataStream, nil
 }
 
+// reopenDataStream opens the data stream again on the connection, after the server closes the data stream.
+func (c *Client) reopenDataStream(ctx context.Context, controlStream *ClientControlStream) (DataStream, error) {
+	dataStream, err := c.openDataStream(ctx, controlStream)
+	if err != nil {
+		return nil, err
+	}
+	c.logger.Debug("data stream reopened")
+	if c.streamType == StreamTypeStreamFunction && c.processor != nil {
+		if err := controlStream.StreamReady(c.clientID); err != nil {
+			c.logger.Debug("failed to send stream ready", "err", err)
+		}
+	}
+	return dataStream, nil
+}
+
+// isStreamClosedSignal reports whether the err is the signal that the server closes the data stream
+// for idle or missing heartbeats, the connection is kept.
+func isStreamClosedSignal(err error) bool {
+	se := new(ErrControllSignal)
+	if !errors.As(err, &se) {
+		return false
+	}
+	code, ok := se.CloseCode()
+	return ok && (code == frame.CloseIdleTimeout || code == frame.CloseHeartbeatTimeout)
+}
+
 func (c *Client) openDataStream(ctx context.Context, controlStream *ClientControlStream) (DataStream, error) {
+	c.mdMu.Lock()
+	hmd := c.md
+	if c.opts.checksum {
+		hmd = c.md.Clone()
+		if hmd == nil {
+			hmd = metadata.M{}
+		}
+		hmd.Set(MetadataChecksumKey, frame.ChecksumCRC32C)
+	}
+	md, err := hmd.EncodeWith(c.opts.metadataEncoding)
+	c.mdMu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
 	handshakeFrame := &frame.HandshakeFrame{
-		Name:            c.name,
-		ID:              c.clientID,
-		StreamType:      byte(c.streamType),
-		ObserveDataTags: c.opts.observeDataTags,
+		Name:                 c.name,
+		ID:                   c.clientID,
+		StreamType:           byte(c.streamType),
+		ObserveDataTags:      c.observeDataTags(),
+		Metadata:             md,
+		InstanceKey:          c.opts.instanceKey,
+		ObserveTagPriorities: c.opts.tagPriorities,
 	}
 
-	err := controlStream.RequestStream(handshakeFrame)
+	err = controlStream.RequestStream(handshakeFrame)
 	if err != nil {
 		return nil, err
 	}
 
-	return controlStream.AcceptStream(ctx)
+	timeout := c.opts.handshakeAckTimeout
+	if timeout <= 0 {
+		return controlStream.AcceptStream(ctx)
+	}
+
+	actx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	dataStream, err := controlStream.AcceptStream(actx)
+	if err != nil && ctx.Err() == nil && errors.Is(err, context.DeadlineExceeded) {
+		err = ErrHandshakeAckTimeout{StreamID: handshakeFrame.ID, Timeout: timeout}
+		// abort the half-open stream.
+		_ = controlStream.CloseWithError(err.Error())
+	}
+	return dataStream, err
 }
 
-func (c *Client) processStream(controlStream *ClientControlStream, dataStream DataStream, reconnection chan<- struct{}) {
+func (c *Client) processStream(controlStream *ClientControlStream, dataStream DataStream, reconnection chan<- error) {
 	defer dataStream.Close()
 
 	readFrameChan := c.readFrame(dataStream)
@@ -239,6 +580,14 @@ func (c *Client) processStream(controlStream *ClientControlStream, dataStream Da
 				c.handleFrameError(err, reconnection)
 				return
 			}
+			// the heartbeat of the server is replied on the data stream.
+			if ping, ok := result.frame.(*frame.PingFrame); ok {
+				if err := dataStream.WriteFrame(&frame.PongFrame{Nonce: ping.Nonce}); err != nil {
+					c.handleFrameError(err, reconnection)
+					return
+				}
+				continue
+			}
 			func() {
 				defer func() {
 					if e := recover(); e != nil {
@@ -254,7 +603,9 @@ func (c *Client) processStream(controlStream *ClientControlStream, dataStream Da
 				c.handleFrame(result.frame)
 			}()
 		case f := <-c.writeFrameChan:
-			if err := dataStream.WriteFrame(f); err != nil {
+			err := dataStream.WriteFrame(f)
+			c.pendingWrites.done()
+			if err != nil {
 				c.handleFrameError(err, reconnection)
 				return
 			}
@@ -266,7 +617,7 @@ func (c *Client) processStream(controlStream *ClientControlStream, dataStream Da
 // Sending the error to the error function (errorfn).
 // Closing the client if the data stream has been closed.
 // Always attempting to reconnect if an error is encountered.
-func (c *Client) handleFrameError(err error, reconnection chan<- struct{}) {
+func (c *Client) handleFrameError(err error, reconnection chan<- error) {
 	if err == nil {
 		return
 	}
@@ -279,8 +630,30 @@ func (c *Client) handleFrameError(err error, reconnection chan<- struct{}) {
 		return
 	}
 
-	// If client accepts close signal from server, then exit client program.
+	// If client accepts close signal from server, then exit client program,
+	// except the data stream is closed for idle or missing heartbeats, it is reopened by reconnecting.
 	if se := new(ErrControllSignal); errors.As(err, &se) {
+		if isStreamClosedSignal(se) {
+			code, _ := se.CloseCode()
+			c.logger.Debug("data stream closed by the server, reopening", "close_code", code, "close_reason", se.Error())
+			select {
+			case reconnection <- err:
+			default:
+			}
+			return
+		}
+		// the connection is rotated, it is reopened and authenticated again by reconnecting.
+		if se.IsGoaway() && se.IsReconnect() {
+			c.logger.Info("connection rotated by the server, reconnecting", "message", se.Error())
+			select {
+			case reconnection <- err:
+			default:
+			}
+			return
+		}
+		if se.IsGoaway() {
+			c.drain(se.Error())
+		}
 		c.ctxCancel(fmt.Errorf("%s: remote shutdown", c.streamType.String()))
 		return
 	}
@@ -288,11 +661,44 @@ func (c *Client) handleFrameError(err error, reconnection chan<- struct{}) {
 	// always attempting to reconnect if an error is encountered,
 	// the error is mostly network error.
 	select {
-	case reconnection <- struct{}{}:
+	case reconnection <- err:
 	default:
 	}
 }
 
+// drain stops the client from writing new frames and waits for the in-flight handlers
+// to finish within the goaway grace period.
+func (c *Client) drain(message string) {
+	c.draining.Store(true)
+	c.logger.Info("client is draining because the server goaway", "message", message, "grace_period", c.opts.goawayGracePeriod)
+
+	if c.goawayfn != nil {
+		c.goawayfn(message)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		c.inflight.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-c.opts.clock.After(c.opts.goawayGracePeriod):
+		c.logger.Warn("in-flight handlers do not finish within the goaway grace period")
+	}
+}
+
+// Go runs fn in a new goroutine and tracks it as an in-flight handler,
+// the client waits for in-flight handlers before closing when it receives GoawayFrame.
+func (c *Client) Go(fn func()) {
+	c.inflight.Add(1)
+	go func() {
+		defer c.inflight.Done()
+		fn()
+	}()
+}
+
 // Wait waits client returning.
 func (c *Client) Wait() {
 	<-c.ctx.Done()
@@ -321,17 +727,42 @@ func (c *Client) readFrame(dataStream DataStream) chan readResult {
 func (c *Client) handleFrame(f frame.Frame) {
 	switch ff := f.(type) {
 	case *frame.DataFrame:
+		// the frames of the unobserved tag may be in flight when unobserving.
+		if !c.observes(ff.Tag) {
+			c.logger.Debug("drop data frame of unobserved tag", "data_tag", ff.Tag)
+			return
+		}
+		// the chunks are held until the last one arrives.
+		df, err := c.chunks.add(ff)
+		if err != nil {
+			c.logger.Warn("drop chunked data frame", "data_tag", ff.Tag, "err", err)
+			return
+		}
+		if df == nil {
+			return
+		}
 		if c.processor == nil {
 			c.logger.Warn("the processor has not been set")
 		} else {
-			c.processor(ff)
+			c.processor(df)
 		}
 	case *frame.BackflowFrame:
+		c.ackBackflow(ff)
 		if c.receiver == nil {
 			c.logger.Warn("the receiver has not been set")
 		} else {
 			c.receiver(ff)
 		}
+	case *frame.FlowControlFrame:
+		c.logger.Debug("flow control", "data_tag", ff.Tag, "pause", ff.Pause, "rate", ff.Rate)
+		c.flow.apply(ff)
+	case *frame.BackflowAckFrame:
+		if !c.acks.resolve(ff.ID) {
+			c.logger.Debug("ignore backflow ack", "ack_id", ff.ID)
+		}
+	case *frame.StreamReadyFrame:
+		c.logger.Debug("stream ready", "stream_id", ff.StreamID, "data_tags", ff.Tags)
+		c.readiness.markReady(ff.Tags)
 	default:
 		c.logger.Warn("data stream received unexpected frame", "frame_type", f.Type().String())
 	}
@@ -349,9 +780,83 @@ func (c *Client) SetBackflowFrameObserver(fn func(*frame.BackflowFrame)) {
 
 // SetObserveDataTags set the data tag list that will be observed.
 func (c *Client) SetObserveDataTags(tag ...frame.Tag) {
+	c.tagsMu.Lock()
+	defer c.tagsMu.Unlock()
+
 	c.opts.observeDataTags = tag
 }
 
+// Observe observes the DataFrames of the tag at runtime, the observed tags are kept across reconnections.
+func (c *Client) Observe(tag frame.Tag) error {
+	c.tagsMu.Lock()
+	for _, t := range c.opts.observeDataTags {
+		if t == tag {
+			c.tagsMu.Unlock()
+			return nil
+		}
+	}
+	c.opts.observeDataTags = append(c.observeDataTagsLocked(), tag)
+	c.tagsMu.Unlock()
+
+	controlStream := c.controlStream.Load()
+	if controlStream == nil {
+		// not connected yet, the tag will be sent with the HandshakeFrame.
+		return nil
+	}
+	return controlStream.ObserveTag(c.clientID, tag)
+}
+
+// Unobserve stops observing the DataFrames of the tag at runtime, no DataFrame of the tag
+// is delivered to the observer once Unobserve returns.
+func (c *Client) Unobserve(tag frame.Tag) error {
+	c.tagsMu.Lock()
+	observed := make([]frame.Tag, 0, len(c.opts.observeDataTags))
+	for _, t := range c.opts.observeDataTags {
+		if t != tag {
+			observed = append(observed, t)
+		}
+	}
+	c.opts.observeDataTags = observed
+	c.tagsMu.Unlock()
+
+	controlStream := c.controlStream.Load()
+	if controlStream == nil {
+		return nil
+	}
+	return controlStream.UnobserveTag(c.clientID, tag)
+}
+
+// observeDataTags returns a copy of the observed data tags.
+func (c *Client) observeDataTags() []frame.Tag {
+	c.tagsMu.RLock()
+	defer c.tagsMu.RUnlock()
+
+	return c.observeDataTagsLocked()
+}
+
+func (c *Client) observeDataTagsLocked() []frame.Tag {
+	tags := make([]frame.Tag, len(c.opts.observeDataTags))
+	copy(tags, c.opts.observeDataTags)
+	return tags
+}
+
+// observes reports whether the DataFrames of the tag are observed, only the StreamFunction and
+// the Inspector observe tags, the others receive all the DataFrames.
+func (c *Client) observes(tag frame.Tag) bool {
+	if c.streamType != StreamTypeStreamFunction && c.streamType != StreamTypeInspector {
+		return true
+	}
+	c.tagsMu.RLock()
+	defer c.tagsMu.RUnlock()
+
+	for _, t := range c.opts.observeDataTags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
 // Logger get client's logger instance, you can customize this using `yomo.WithLogger`
 func (c *Client) Logger() *slog.Logger {
 	return c.logger
@@ -363,6 +868,77 @@ func (c *Client) SetErrorHandler(fn func(err error)) {
 	c.logger.Debug("the error handler has been set")
 }
 
+// FlowControl asks the sources to pause, resume or slow down the DataFrames of the tag,
+// it is used by the StreamFunction when it is overloaded. See frame.FlowControlFrame.
+func (c *Client) FlowControl(tag frame.Tag, pause bool, rate uint32) error {
+	controlStream := c.controlStream.Load()
+	if controlStream == nil {
+		return ErrControllerClosed
+	}
+	return controlStream.FlowControl(&frame.FlowControlFrame{Tag: tag, Pause: pause, Rate: rate})
+}
+
+// ConnectionState returns the state of the QUIC connection to the zipper,
+// it returns false if the client is not connected.
+func (c *Client) ConnectionState() (quic.ConnectionState, bool) {
+	controlStream := c.controlStream.Load()
+	if controlStream == nil {
+		return quic.ConnectionState{}, false
+	}
+	return controlStream.ConnectionState()
+}
+
+// UpdateMetadata merges md into the metadata of the data stream, the zipper handles the following
+// frames of the data stream with the updated metadata. The metadata is kept across reconnections.
+func (c *Client) UpdateMetadata(md map[string]string) error {
+	c.mdMu.Lock()
+	if c.md == nil {
+		c.md = metadata.M{}
+	}
+	for k, v := range md {
+		c.md.Set(k, v)
+	}
+	c.mdMu.Unlock()
+
+	controlStream := c.controlStream.Load()
+	if controlStream == nil {
+		// not connected yet, the metadata will be sent with the HandshakeFrame.
+		return nil
+	}
+	b, err := metadata.M(md).EncodeWith(c.opts.metadataEncoding)
+	if err != nil {
+		return err
+	}
+	return controlStream.UpdateMetadata(c.clientID, b)
+}
+
+// ControlFrameHandlers returns the handlers of the control frames read from the zipper, such as GoawayFrame,
+// register the handlers before Connect to receive the AuthenticationAckFrame of the first connection.
+func (c *Client) ControlFrameHandlers() *ControlFrameHandlers { return c.controlHandlers }
+
+// SetGoawayHandler sets the handler that will be invoked with the message of GoawayFrame
+// when the server evicts the client.
+func (c *Client) SetGoawayHandler(fn func(message string)) {
+	c.goawayfn = fn
+	c.logger.Debug("the goaway handler has been set")
+}
+
+// SetReconnectingHandler sets the handler that will be invoked before every reconnect attempt,
+// attempt starts from 1, err is the error that caused the reconnection or failed the last attempt.
+func (c *Client) SetReconnectingHandler(fn func(attempt int, err error)) {
+	c.reconnectingfn = fn
+	c.logger.Debug("the reconnecting handler has been set")
+}
+
+// SetReconnectedHandler sets the handler that will be invoked when the client reconnected.
+func (c *Client) SetReconnectedHandler(fn func()) {
+	c.reconnectedfn = fn
+	c.logger.Debug("the reconnected handler has been set")
+}
+
+// MetadataEncoding returns the encoding of the metadata of DataFrames written by the client.
+func (c *Client) MetadataEncoding() metadata.Encoding { return c.opts.metadataEncoding }
+
 // ClientID returns the ID of client.
 func (c *Client) ClientID() string { return c.clientID }
 
@@ -7,7 +7,9 @@ import (
 	"github.com/quic-go/quic-go"
 	"github.com/yomorun/yomo/core/auth"
 	"github.com/yomorun/yomo/core/frame"
+	"github.com/yomorun/yomo/core/metadata"
 	"github.com/yomorun/yomo/core/ylog"
+	"github.com/yomorun/yomo/pkg/frame-codec/y3codec"
 	pkgtls "github.com/yomorun/yomo/pkg/tls"
 	"go.opentelemetry.io/otel/trace"
 	"golang.org/x/exp/slog"
@@ -19,11 +21,34 @@ type ClientOption func(*clientOptions)
 // clientOptions are the options for YoMo client.
 type clientOptions struct {
 	observeDataTags     []frame.Tag
+	tagPriorities       map[frame.Tag]uint8
 	quicConfig          *quic.Config
+	keepAlivePeriod     time.Duration
+	maxIdleTimeout      time.Duration
 	tlsConfig           *tls.Config
+	enable0RTT          bool
+	alpn                []string
 	credential          *auth.Credential
+	codec               frame.Codec
+	packetReadWriter    frame.PacketReadWriter
 	connectUntilSucceed bool
 	nonBlockWrite       bool
+	goawayGracePeriod   time.Duration
+	handshakeAckTimeout time.Duration
+	maxPause            time.Duration
+	reconnectBackoff    Backoff
+	readBufferSize      int
+	writeQueueSize      int
+	maxChunkedSize      int
+	chunkTimeout        time.Duration
+	statsReportInterval time.Duration
+	clock               Clock
+	instanceKey         string
+	labels              map[string]string
+	streamReadyTimeout  time.Duration
+	checksum            bool
+	versions            []frame.Version
+	metadataEncoding    metadata.Encoding
 	logger              *slog.Logger
 	tracerProvider      trace.TracerProvider
 }
@@ -44,11 +69,21 @@ func defaultClientOption() *clientOptions {
 	}
 
 	opts := &clientOptions{
-		observeDataTags: make([]frame.Tag, 0),
-		quicConfig:      defaultQuicConfig,
-		tlsConfig:       pkgtls.MustCreateClientTLSConfig(),
-		credential:      auth.NewCredential(""),
-		logger:          logger,
+		observeDataTags:     make([]frame.Tag, 0),
+		quicConfig:          defaultQuicConfig,
+		tlsConfig:           pkgtls.MustCreateClientTLSConfig(),
+		credential:          auth.NewCredential(""),
+		codec:               y3codec.Codec(),
+		packetReadWriter:    y3codec.PacketReadWriter(),
+		goawayGracePeriod:   DefaultGoawayGracePeriod,
+		handshakeAckTimeout: DefaultHandshakeAckTimeout,
+		reconnectBackoff:    DefaultBackoff,
+		readBufferSize:      DefaultReadBufferSize,
+		maxChunkedSize:      DefaultMaxChunkedPayloadSize,
+		chunkTimeout:        DefaultChunkReassemblyTimeout,
+		versions:            frame.SupportedVersions,
+		clock:               SystemClock,
+		logger:              logger,
 	}
 
 	return opts
@@ -61,6 +96,22 @@ func WithObserveDataTags(tags ...frame.Tag) ClientOption {
 	}
 }
 
+// WithVersions sets the protocol versions the client advertises, the server picks the highest one they have
+// in common. The default is frame.SupportedVersions.
+func WithVersions(versions ...frame.Version) ClientOption {
+	return func(o *clientOptions) {
+		o.versions = versions
+	}
+}
+
+// WithBinaryCredential sets the client credential with the binary payload, such as a signature,
+// the server authenticates it by the auth.BinaryAuthentication of the name.
+func WithBinaryCredential(name string, payload []byte) ClientOption {
+	return func(o *clientOptions) {
+		o.credential = auth.NewBinaryCredential(name, payload)
+	}
+}
+
 // WithCredential sets the client credential method (used by client).
 func WithCredential(payload string) ClientOption {
 	return func(o *clientOptions) {
@@ -68,7 +119,8 @@ func WithCredential(payload string) ClientOption {
 	}
 }
 
-// WithClientTLSConfig sets tls config for the client.
+// WithClientTLSConfig sets tls config for the client, for example, set Certificates and RootCAs
+// for mutual TLS. A nil config is ignored and the default config is used.
 func WithClientTLSConfig(tc *tls.Config) ClientOption {
 	return func(o *clientOptions) {
 		if tc != nil {
@@ -77,6 +129,24 @@ func WithClientTLSConfig(tc *tls.Config) ClientOption {
 	}
 }
 
+// WithClientALPN sets the application protocols that the client negotiates,
+// the default is the NextProtos of the TLS config, or "yomo" if it is empty.
+func WithClientALPN(protos ...string) ClientOption {
+	return func(o *clientOptions) {
+		o.alpn = protos
+	}
+}
+
+// WithClient0RTT makes the client send the AuthenticationFrame in 0-RTT when it reconnects with the TLS session
+// resumed, which saves a round trip. The session tickets are cached in the ClientSessionCache of the TLS config,
+// a cache is set if it is nil. It falls back to the full handshake if the server rejects the 0-RTT,
+// the server must enable it as well, see WithServer0RTT.
+func WithClient0RTT() ClientOption {
+	return func(o *clientOptions) {
+		o.enable0RTT = true
+	}
+}
+
 // WithClientQuicConfig sets quic config for the client.
 func WithClientQuicConfig(qc *quic.Config) ClientOption {
 	return func(o *clientOptions) {
@@ -84,6 +154,77 @@ func WithClientQuicConfig(qc *quic.Config) ClientOption {
 	}
 }
 
+// WithClientKeepAlivePeriod sets the period the client sends QUIC keep-alive packets, it overrides the KeepAlivePeriod
+// of the quic config. It should be less than the max idle timeout, so that the idle connections are not closed.
+func WithClientKeepAlivePeriod(d time.Duration) ClientOption {
+	return func(o *clientOptions) {
+		o.keepAlivePeriod = d
+	}
+}
+
+// WithClientMaxIdleTimeout sets the time the QUIC connection is closed after no packet is received,
+// it overrides the MaxIdleTimeout of the quic config. The keep-alive packets keep the connection alive,
+// see WithServerMaxIdleTimeout for how it interacts with the idle timeout of the data streams.
+func WithClientMaxIdleTimeout(d time.Duration) ClientOption {
+	return func(o *clientOptions) {
+		o.maxIdleTimeout = d
+	}
+}
+
+// WithClientWriteQueueSize enables the write queue of the streams of the client, the control frames
+// go ahead of the DataFrames waiting to be written, at most size DataFrames wait per stream, see WithWriteQueue.
+func WithClientWriteQueueSize(size int) ClientOption {
+	return func(o *clientOptions) {
+		o.writeQueueSize = size
+	}
+}
+
+// WithChunkReassembly sets the max size of the payload reassembled from the chunks of a transfer and
+// the time that the chunks are reassembled in, the transfers exceed them are dropped. The chunks buffered
+// of all the transfers are limited to 4 times the max size.
+func WithChunkReassembly(maxSize int, timeout time.Duration) ClientOption {
+	return func(o *clientOptions) {
+		o.maxChunkedSize = maxSize
+		o.chunkTimeout = timeout
+	}
+}
+
+// WithClientReadBufferSize sets the size of the buffer that the streams of the client read ahead into,
+// a larger buffer reduces the reads on the QUIC streams under high frame rates. A non-positive size disables it.
+func WithClientReadBufferSize(size int) ClientOption {
+	return func(o *clientOptions) {
+		o.readBufferSize = size
+	}
+}
+
+// WithCodec sets the codec that encodes and decodes frames for the client,
+// the codec must be the same as the server's. the default codec is y3codec.
+func WithCodec(codec frame.Codec) ClientOption {
+	return func(o *clientOptions) {
+		if codec != nil {
+			o.codec = codec
+		}
+	}
+}
+
+// WithPacketReadWriter sets the PacketReadWriter that reads and writes packets for the client,
+// the PacketReadWriter must be the same as the server's. the default PacketReadWriter is y3codec.
+func WithPacketReadWriter(prw frame.PacketReadWriter) ClientOption {
+	return func(o *clientOptions) {
+		if prw != nil {
+			o.packetReadWriter = prw
+		}
+	}
+}
+
+// WithChecksum asks the server to protect the packets of the data stream with CRC32 checksums, a corrupted packet
+// fails the read with frame.ErrChecksumMismatch. The data stream goes without checksums if the server does not support it.
+func WithChecksum() ClientOption {
+	return func(o *clientOptions) {
+		o.checksum = true
+	}
+}
+
 // WithConnectUntilSucceed makes client Connect until success.
 func WithConnectUntilSucceed() ClientOption {
 	return func(o *clientOptions) {
@@ -98,6 +239,56 @@ func WithNonBlockWrite() ClientOption {
 	}
 }
 
+// DefaultGoawayGracePeriod is the default grace period that client waits for in-flight handlers after receiving GoawayFrame.
+const DefaultGoawayGracePeriod = 5 * time.Second
+
+// WithMaxPause sets the max time the writes of a tag are paused by a FlowControlFrame, the default is DefaultMaxPause.
+func WithMaxPause(d time.Duration) ClientOption {
+	return func(o *clientOptions) {
+		o.maxPause = d
+	}
+}
+
+// DefaultHandshakeAckTimeout is the default time the client waits for the HandshakeAckFrame.
+const DefaultHandshakeAckTimeout = 10 * time.Second
+
+// WithHandshakeAckTimeout sets the time the client waits for the HandshakeAckFrame after sending a HandshakeFrame,
+// the client aborts the connection and returns ErrHandshakeAckTimeout if the ack does not arrive in time.
+// A non-positive timeout means waiting forever.
+func WithHandshakeAckTimeout(timeout time.Duration) ClientOption {
+	return func(o *clientOptions) {
+		o.handshakeAckTimeout = timeout
+	}
+}
+
+// WithGoawayGracePeriod sets the grace period that client waits for in-flight handlers
+// to finish after receiving GoawayFrame, the client will be closed once the period elapsed.
+func WithGoawayGracePeriod(d time.Duration) ClientOption {
+	return func(o *clientOptions) {
+		o.goawayGracePeriod = d
+	}
+}
+
+// WithReconnectBackoff sets the backoff between reconnect attempts,
+// it is also used between connect attempts if the client connects until succeed.
+func WithReconnectBackoff(initial, max time.Duration, jitter float64) ClientOption {
+	return func(o *clientOptions) {
+		o.reconnectBackoff = Backoff{
+			Initial: initial,
+			Max:     max,
+			Jitter:  jitter,
+		}
+	}
+}
+
+// WithMetadataEncoding sets the encoding of the metadata of DataFrames written by the client,
+// the default is msgpack, JSON is larger but can be inspected by standard tools.
+func WithMetadataEncoding(enc metadata.Encoding) ClientOption {
+	return func(o *clientOptions) {
+		o.metadataEncoding = enc
+	}
+}
+
 // WithLogger sets logger for the client.
 func WithLogger(logger *slog.Logger) ClientOption {
 	return func(o *clientOptions) {
@@ -124,4 +315,59 @@ const (
 
 	// ClientTypeStreamFunction is equal to StreamTypeStreamFunction.
 	ClientTypeStreamFunction ClientType = StreamTypeStreamFunction
+
+	// ClientTypeInspector is equal to StreamTypeInspector.
+	ClientTypeInspector ClientType = StreamTypeInspector
 )
+
+// WithStatsReport makes the client report the stats of processing the DataFrames to the zipper at the interval,
+// the stats are recorded by Client.RecordProcessing. See frame.StatsReportFrame.
+func WithStatsReport(interval time.Duration) ClientOption {
+	return func(o *clientOptions) {
+		o.statsReportInterval = interval
+	}
+}
+
+// WithClock sets the clock of the time-dependent features of the client, such as the reconnect backoff,
+// the flow control and the chunk reassembly. It is SystemClock by default, see ManualClock for the tests.
+func WithClock(clock Clock) ClientOption {
+	return func(o *clientOptions) {
+		o.clock = clock
+	}
+}
+
+// WithInstanceKey sets the instance key of the client, it should be stable per instance of the application,
+// such as the host name. The server closes the streams of the same instance once the client handshakes,
+// so the instance reconnecting after a blip does not observe the tags twice. See frame.HandshakeFrame.InstanceKey.
+func WithInstanceKey(key string) ClientOption {
+	return func(o *clientOptions) {
+		o.instanceKey = key
+	}
+}
+
+// WithLabels sets the labels of the connection, such as the region, the version or the canary, they are sent
+// with the authentication. The zipper logs them, and carries them in the metadata of the connection with the
+// MetadataLabelPrefix, so the router can route by them.
+func WithLabels(labels map[string]string) ClientOption {
+	return func(o *clientOptions) {
+		o.labels = labels
+	}
+}
+
+// WithWaitStreamReady makes the first write of every tag wait for a stream function observing the tag to be ready,
+// so the first DataFrames are not lost before the stream function installs its handler. The write goes on after
+// the timeout even if no stream function is ready. See frame.StreamReadyFrame.
+func WithWaitStreamReady(timeout time.Duration) ClientOption {
+	return func(o *clientOptions) {
+		o.streamReadyTimeout = timeout
+	}
+}
+
+// WithObserveTagPriorities sets the priorities of the tags observed, the zipper writes the backlogged data of
+// the higher priority tags first, the tags absent are of priority 0. The priorities only affect the order within
+// the write queue of the stream of the client on the zipper, which must enable it by WithServerWriteQueueSize.
+func WithObserveTagPriorities(priorities map[frame.Tag]uint8) ClientOption {
+	return func(o *clientOptions) {
+		o.tagPriorities = priorities
+	}
+}
@@ -3,9 +3,11 @@ package core
 import (
 	"bytes"
 	"context"
+	"io"
 	"net"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -15,7 +17,9 @@ import (
 	"github.com/yomorun/yomo/core/router"
 	"github.com/yomorun/yomo/core/ylog"
 	"github.com/yomorun/yomo/pkg/config"
+	"github.com/yomorun/yomo/pkg/frame-codec/compress"
 	"github.com/yomorun/yomo/pkg/frame-codec/y3codec"
+	pkgtls "github.com/yomorun/yomo/pkg/tls"
 )
 
 const testaddr = "127.0.0.1:19999"
@@ -267,3 +271,422 @@ func (w *frameWriterRecorder) dataFrameContent() (frame.Tag, metadata.M, []byte)
 	md, _ := metadata.Decode(dataFrame.Metadata)
 	return dataFrame.Tag, md, dataFrame.Payload
 }
+
+func TestStreamMigration(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	const addr = "127.0.0.1:19998"
+
+	var observedTag = frame.Tag(1)
+
+	server := NewServer("zipper",
+		WithAuth("token", "auth-token"),
+		WithServerVerifier("apikey", func(context.Context, *frame.AuthenticationFrame) (metadata.M, bool, error) {
+			return metadata.M{}, true, nil
+		}),
+		WithServerLogger(discardingLogger),
+	)
+	server.ConfigRouter(router.Default([]config.Function{{Name: "sfn-migration"}}))
+
+	go server.ListenAndServe(ctx, addr)
+	defer server.Close()
+
+	sfn := NewClient("sfn-migration", StreamTypeStreamFunction,
+		WithCredential("token:auth-token"), WithLogger(discardingLogger), WithConnectUntilSucceed())
+	sfn.SetObserveDataTags(observedTag)
+	sfn.SetDataFrameObserver(func(*frame.DataFrame) {})
+	defer sfn.Close()
+
+	err := sfn.Connect(ctx, addr)
+	assert.NoError(t, err)
+
+	old, ok, err := server.connector.Get(sfn.ClientID())
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	// the client handshakes again from a new connection, as if its network path changed.
+	controlStream, dataStream, err := sfn.openStream(ctx, addr)
+	assert.NoError(t, err)
+	defer controlStream.CloseWithError("")
+
+	assert.Equal(t, sfn.ClientID(), dataStream.ID())
+
+	// the old stream is closed, the stream id and the route are taken over by the new stream.
+	select {
+	case <-old.Context().Done():
+	case <-time.After(time.Second):
+		t.Fatal("the migrated stream should be closed")
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	current, ok, err := server.connector.Get(sfn.ClientID())
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.NotSame(t, old, current)
+
+	route := server.router.Route(metadata.M{})
+	assert.Equal(t, []string{sfn.ClientID()}, route.GetForwardRoutes(observedTag))
+
+	// a stream of another client can't reuse the stream id.
+	other := NewClient("sfn-migration", StreamTypeSource, WithCredential("token:auth-token"), WithLogger(discardingLogger))
+	other.clientID = sfn.ClientID()
+	err = other.Connect(ctx, addr)
+	assert.Error(t, err)
+
+	// a client authenticated with another valid credential can't take over the stream, even with the same name and type.
+	impostor := NewClient("sfn-migration", StreamTypeStreamFunction, WithCredential("apikey:impostor"), WithLogger(discardingLogger))
+	impostor.clientID = sfn.ClientID()
+	impostor.SetObserveDataTags(observedTag)
+	impostor.SetDataFrameObserver(func(*frame.DataFrame) {})
+	err = impostor.Connect(ctx, addr)
+	assert.Error(t, err)
+
+	stays, ok, err := server.connector.Get(sfn.ClientID())
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Same(t, current, stays)
+}
+
+func TestVersionMismatch(t *testing.T) {
+	ctx := context.Background()
+
+	const addr = "127.0.0.1:19997"
+
+	server := NewServer("zipper", WithServerVersions(frame.Version1), WithServerLogger(discardingLogger))
+	server.ConfigRouter(router.Default([]config.Function{}))
+
+	go server.ListenAndServe(ctx, addr)
+	defer server.Close()
+
+	source := NewClient("source", StreamTypeSource,
+		WithVersions(2), WithLogger(discardingLogger), WithConnectUntilSucceed())
+
+	err := source.Connect(ctx, addr)
+	assert.ErrorAs(t, err, new(ErrVersionMismatch))
+	assert.Equal(t, "protocol version mismatch: client supports [2], server supports [1]", err.Error())
+
+	source = NewClient("source", StreamTypeSource, WithVersions(frame.Version1, 2), WithLogger(discardingLogger))
+	defer source.Close()
+
+	err = source.Connect(ctx, addr)
+	assert.NoError(t, err)
+}
+
+func Test0RTT(t *testing.T) {
+	ctx := context.Background()
+
+	const addr = "127.0.0.1:19996"
+
+	server := NewServer("zipper", WithServer0RTT(), WithServerLogger(discardingLogger))
+	server.ConfigRouter(router.Default([]config.Function{}))
+
+	go server.ListenAndServe(ctx, addr)
+	defer server.Close()
+
+	// the clients share the session cache of the tls config.
+	tlsConfig := pkgtls.MustCreateClientTLSConfig()
+
+	used0RTT := func() bool {
+		source := NewClient("source", StreamTypeSource,
+			WithClientTLSConfig(tlsConfig), WithClient0RTT(), WithLogger(discardingLogger), WithConnectUntilSucceed())
+		defer source.Close()
+
+		assert.NoError(t, source.Connect(ctx, addr))
+
+		conn := source.controlStream.Load().conn.(*QuicConnection)
+		return conn.ConnectionState().Used0RTT
+	}
+
+	assert.False(t, used0RTT(), "the first connection has no session to resume")
+	assert.True(t, used0RTT(), "the session is resumed in 0-RTT")
+}
+
+func TestInstanceReplacement(t *testing.T) {
+	ctx := context.Background()
+
+	const addr = "127.0.0.1:19995"
+
+	server := NewServer("zipper", WithServerLogger(discardingLogger))
+	server.ConfigRouter(router.Default([]config.Function{}))
+
+	go server.ListenAndServe(ctx, addr)
+	defer server.Close()
+
+	newSource := func() *Client {
+		source := NewClient("source-instance", StreamTypeSource,
+			WithInstanceKey("host-1"), WithLogger(discardingLogger), WithConnectUntilSucceed())
+		assert.NoError(t, source.Connect(ctx, addr))
+		return source
+	}
+
+	source1 := newSource()
+	defer source1.Close()
+
+	old, ok, err := server.connector.Get(source1.ClientID())
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	// the instance reconnects as a new client while the old stream is still alive.
+	source2 := newSource()
+	defer source2.Close()
+
+	select {
+	case <-old.Context().Done():
+	case <-time.After(time.Second):
+		t.Fatal("the stream of the same instance should be closed")
+	}
+
+	_, ok, err = server.connector.Get(source1.ClientID())
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	_, ok, err = server.connector.Get(source2.ClientID())
+	assert.NoError(t, err)
+	assert.True(t, ok)
+}
+
+func TestServerDrain(t *testing.T) {
+	ctx := context.Background()
+
+	const addr = "127.0.0.1:19994"
+
+	server := NewServer("zipper", WithServerLogger(discardingLogger))
+	server.ConfigRouter(router.Default([]config.Function{}))
+
+	go server.ListenAndServe(ctx, addr)
+	defer server.Close()
+
+	source1 := NewClient("source-drain-1", StreamTypeSource, WithLogger(discardingLogger), WithConnectUntilSucceed())
+	assert.NoError(t, source1.Connect(ctx, addr))
+	defer source1.Close()
+
+	assert.False(t, server.Draining())
+	server.Drain()
+	assert.True(t, server.Draining())
+
+	// the new connection is rejected.
+	source2 := NewClient("source-drain-2", StreamTypeSource, WithLogger(discardingLogger))
+	assert.Error(t, source2.Connect(ctx, addr))
+
+	// the connection served keeps running.
+	_, ok, err := server.connector.Get(source1.ClientID())
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.NoError(t, source1.WriteFrame(&frame.DataFrame{Tag: 1, Payload: []byte("hello")}))
+}
+
+func TestServerHealthCheck(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	const addr = "127.0.0.1:19993"
+
+	var overloaded atomic.Bool
+	server := NewServer("zipper",
+		WithServerLogger(discardingLogger),
+		WithServerHealthCheckBeforeAuth(),
+		WithServerOverloaded(overloaded.Load),
+	)
+	server.ConfigRouter(router.Default([]config.Function{}))
+
+	go server.ListenAndServe(ctx, addr)
+	defer server.Close()
+
+	source := NewClient("source-health", StreamTypeSource, WithLogger(discardingLogger), WithConnectUntilSucceed())
+	assert.NoError(t, source.Connect(ctx, addr))
+	defer source.Close()
+
+	// the health check after the authentication.
+	status, err := source.controlStream.Load().HealthCheck(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, frame.HealthServing, status)
+
+	overloaded.Store(true)
+	status, err = source.controlStream.Load().HealthCheck(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, frame.HealthOverloaded, status)
+
+	// the health check before the authentication.
+	opts := defaultClientOption()
+	status, err = ProbeHealth(ctx, addr, opts.tlsConfig, opts.quicConfig, opts.codec, opts.packetReadWriter)
+	assert.NoError(t, err)
+	assert.Equal(t, frame.HealthOverloaded, status)
+
+	server.Drain()
+	status, err = ProbeHealth(ctx, addr, opts.tlsConfig, opts.quicConfig, opts.codec, opts.packetReadWriter)
+	assert.NoError(t, err)
+	assert.Equal(t, frame.HealthDraining, status)
+}
+
+func TestClientCloseGracefully(t *testing.T) {
+	client := NewClient("source-close", StreamTypeSource, WithLogger(discardingLogger))
+
+	// the client is not connected, the write is pending until the client is closed.
+	written := make(chan error)
+	go func() { written <- client.WriteFrame(&frame.DataFrame{Tag: 1, Payload: []byte("hello")}) }()
+	assert.Eventually(t, func() bool {
+		client.pendingWrites.mu.Lock()
+		defer client.pendingWrites.mu.Unlock()
+		return client.pendingWrites.n == 1
+	}, time.Second, time.Millisecond)
+
+	assert.ErrorIs(t, client.CloseGracefully(100*time.Millisecond), ErrCloseTimeout)
+	assert.Error(t, <-written)
+
+	// nothing is pending.
+	client = NewClient("source-close", StreamTypeSource, WithLogger(discardingLogger))
+	assert.NoError(t, client.CloseGracefully(time.Second))
+}
+
+func TestServerMaxConnectionLifetime(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	const addr = "127.0.0.1:19992"
+
+	server := NewServer("zipper",
+		WithServerLogger(discardingLogger),
+		WithServerMaxConnectionLifetime(300*time.Millisecond, 0.5),
+	)
+	server.ConfigRouter(router.Default([]config.Function{}))
+
+	go server.ListenAndServe(ctx, addr)
+	defer server.Close()
+
+	source := NewClient("source-rotation", StreamTypeSource, WithLogger(discardingLogger), WithConnectUntilSucceed())
+	var reconnected atomic.Int32
+	source.SetReconnectedHandler(func() { reconnected.Add(1) })
+	assert.NoError(t, source.Connect(ctx, addr))
+	defer source.Close()
+
+	// the connection is rotated, the client reconnects rather than quits.
+	assert.Eventually(t, func() bool { return reconnected.Load() >= 2 }, 5*time.Second, 20*time.Millisecond)
+	assert.NoError(t, source.ctx.Err(), context.Cause(source.ctx))
+}
+
+func TestJitterLifetime(t *testing.T) {
+	assert.Equal(t, time.Duration(0), jitterLifetime(0, 0.5))
+	assert.Equal(t, time.Minute, jitterLifetime(time.Minute, 0))
+
+	for i := 0; i < 100; i++ {
+		lifetime := jitterLifetime(time.Minute, 0.2)
+		assert.LessOrEqual(t, lifetime, time.Minute)
+		assert.GreaterOrEqual(t, lifetime, 48*time.Second)
+	}
+}
+
+func TestNegotiateDictionary(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	const addr = "127.0.0.1:19991"
+
+	dict := compress.Dictionary{ID: 3, Content: []byte(`{"device":"sensor","temperature":20}`)}
+	serverCodec, err := compress.NewCompressionCodec(y3codec.Codec(), compress.Zstd, 0, compress.WithDictionary(dict))
+	assert.NoError(t, err)
+
+	server := NewServer("zipper", WithServerLogger(discardingLogger), WithServerCodec(serverCodec))
+	server.ConfigRouter(router.Default([]config.Function{}))
+
+	go server.ListenAndServe(ctx, addr)
+	defer server.Close()
+
+	// the dictionary is shipped to the client that has none.
+	clientCodec, err := compress.NewCompressionCodec(y3codec.Codec(), compress.Zstd, 0)
+	assert.NoError(t, err)
+	source := NewClient("source-dictionary", StreamTypeSource, WithLogger(discardingLogger), WithCodec(clientCodec), WithConnectUntilSucceed())
+	assert.NoError(t, source.Connect(ctx, addr))
+	defer source.Close()
+
+	// the dictionary is loaded to the codec of the connection, the codec passed in is not changed.
+	assert.Equal(t, uint32(0), clientCodec.DictionaryID())
+	connCodec, ok := source.controlStream.Load().codec.(frame.DictionaryCodec)
+	assert.True(t, ok)
+	assert.Equal(t, uint32(3), connCodec.DictionaryID())
+	assert.NoError(t, source.WriteFrame(&frame.DataFrame{Tag: 1, Payload: []byte(`{"device":"sensor","temperature":21}`)}))
+}
+
+func TestClientReopenIdleDataStream(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	const addr = "127.0.0.1:19990"
+
+	server := NewServer("zipper", WithServerLogger(discardingLogger), WithServerIdleTimeout(200*time.Millisecond))
+	server.ConfigRouter(router.Default([]config.Function{}))
+
+	go server.ListenAndServe(ctx, addr)
+	defer server.Close()
+
+	source := NewClient("source-idle", StreamTypeSource, WithLogger(discardingLogger), WithConnectUntilSucceed())
+	var reconnecting atomic.Int32
+	source.SetReconnectingHandler(func(int, error) { reconnecting.Add(1) })
+	if !assert.NoError(t, source.Connect(ctx, addr)) {
+		return
+	}
+	defer source.Close()
+
+	controlStream := source.controlStream.Load()
+	time.Sleep(time.Second)
+
+	// the data stream is reopened on the same connection rather than reconnecting.
+	assert.Equal(t, int32(0), reconnecting.Load())
+	assert.Same(t, controlStream, source.controlStream.Load())
+	assert.NoError(t, source.ctx.Err(), context.Cause(source.ctx))
+	assert.Eventually(t, func() bool {
+		_, ok, _ := server.connector.Get(source.clientID)
+		return ok
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestForgedNamespace(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	const addr = "127.0.0.1:19989"
+
+	server := NewServer("zipper", WithServerLogger(discardingLogger), WithServerTagNamespaces())
+	server.ConfigRouter(router.Default([]config.Function{{Name: "sfn-forged"}}))
+
+	go server.ListenAndServe(ctx, addr)
+	defer server.Close()
+
+	// the verifier sets no namespace, the client claims the namespace 2 by itself.
+	forged := NewClient("sfn-forged", StreamTypeStreamFunction, WithLogger(discardingLogger))
+	forged.md = metadata.M{MetadataNamespaceKey: "2"}
+	forged.SetObserveDataTags(frame.NamespacedTag(2, 1))
+	forged.SetDataFrameObserver(func(*frame.DataFrame) {})
+	assert.Error(t, forged.Connect(ctx, addr))
+
+	sfn := NewClient("sfn-forged", StreamTypeStreamFunction, WithLogger(discardingLogger), WithConnectUntilSucceed())
+	sfn.md = metadata.M{MetadataNamespaceKey: "2"}
+	sfn.SetObserveDataTags(frame.NamespacedTag(frame.DefaultNamespace, 1))
+	sfn.SetDataFrameObserver(func(*frame.DataFrame) {})
+	if !assert.NoError(t, sfn.Connect(ctx, addr)) {
+		return
+	}
+	defer sfn.Close()
+
+	stream, ok, err := server.connector.Get(sfn.ClientID())
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, frame.DefaultNamespace, GetNamespaceFromMetadata(stream.Metadata()))
+
+	// nor by updating the metadata.
+	assert.NoError(t, sfn.UpdateMetadata(map[string]string{MetadataNamespaceKey: "2"}))
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, frame.DefaultNamespace, GetNamespaceFromMetadata(stream.Metadata()))
+	_, ok = stream.Metadata().Get(MetadataNamespaceKey)
+	assert.False(t, ok)
+}
+
+func TestIsStreamClosedSignal(t *testing.T) {
+	// the data streams closed for idle or missing heartbeats are reopened on the same connection.
+	for _, code := range []frame.CloseCode{frame.CloseIdleTimeout, frame.CloseHeartbeatTimeout} {
+		assert.True(t, isStreamClosedSignal(newCloseStreamSignal(&frame.CloseStreamFrame{Code: code})))
+	}
+	assert.False(t, isStreamClosedSignal(newCloseStreamSignal(&frame.CloseStreamFrame{Code: frame.CloseReplaced})))
+	assert.False(t, isStreamClosedSignal(newGoawaySignal(&frame.GoawayFrame{Message: "goaway"})))
+	assert.False(t, isStreamClosedSignal(io.EOF))
+}
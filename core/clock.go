@@ -0,0 +1,165 @@
+package core
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Clock tells the time to the time-dependent code, such as the idle timeout, the heartbeats
+// and the scheduled delivery. It is SystemClock by default, a ManualClock makes the tests of them deterministic.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+	// After waits for the duration to elapse and then sends the current time on the returned channel.
+	After(d time.Duration) <-chan time.Time
+	// NewTimer creates a Timer that sends the current time on its channel after at least the duration.
+	NewTimer(d time.Duration) Timer
+	// AfterFunc waits for the duration to elapse and then calls f in its own goroutine.
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// Timer is the timer created by a Clock, it is like time.Timer.
+type Timer interface {
+	// C returns the channel that the time is sent on, it is nil for the Timer created by AfterFunc.
+	C() <-chan time.Time
+	// Stop prevents the Timer from firing, it reports false if the Timer has already fired or been stopped.
+	Stop() bool
+	// Reset changes the Timer to fire after the duration, it reports whether the Timer had been active.
+	Reset(d time.Duration) bool
+}
+
+// SystemClock is the Clock of the system time.
+var SystemClock Clock = systemClock{}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time                         { return time.Now() }
+func (systemClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+func (systemClock) NewTimer(d time.Duration) Timer         { return systemTimer{time.NewTimer(d)} }
+func (systemClock) AfterFunc(d time.Duration, f func()) Timer {
+	return systemTimer{time.AfterFunc(d, f)}
+}
+
+type systemTimer struct{ *time.Timer }
+
+func (t systemTimer) C() <-chan time.Time { return t.Timer.C }
+
+// ManualClock is the Clock that only moves forward when it is advanced, the timers fire during Advance.
+// It is for the tests.
+type ManualClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*manualTimer
+}
+
+// NewManualClock returns a ManualClock starting from now.
+func NewManualClock(now time.Time) *ManualClock {
+	return &ManualClock{now: now}
+}
+
+// Now returns the current time of the clock.
+func (c *ManualClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+// After implements Clock.
+func (c *ManualClock) After(d time.Duration) <-chan time.Time {
+	return c.NewTimer(d).C()
+}
+
+// NewTimer implements Clock.
+func (c *ManualClock) NewTimer(d time.Duration) Timer {
+	t := &manualTimer{clock: c, c: make(chan time.Time, 1)}
+	t.Reset(d)
+	return t
+}
+
+// AfterFunc implements Clock, f is called by Advance in the goroutine of Advance.
+func (c *ManualClock) AfterFunc(d time.Duration, f func()) Timer {
+	t := &manualTimer{clock: c, f: f}
+	t.Reset(d)
+	return t
+}
+
+// Advance moves the clock forward by the duration, and fires the timers due in order.
+func (c *ManualClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	target := c.now.Add(d)
+	for {
+		sort.SliceStable(c.timers, func(i, j int) bool { return c.timers[i].when.Before(c.timers[j].when) })
+		if len(c.timers) == 0 || c.timers[0].when.After(target) {
+			break
+		}
+		t := c.timers[0]
+		c.timers = c.timers[1:]
+		c.now = t.when
+		// the timer may be reset by f, so the lock is released.
+		c.mu.Unlock()
+		t.fire(c.now)
+		c.mu.Lock()
+	}
+	c.now = target
+	c.mu.Unlock()
+}
+
+// Timers returns the number of the timers waiting to fire.
+func (c *ManualClock) Timers() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return len(c.timers)
+}
+
+// remove removes the timer, it reports whether the timer was waiting. The caller must hold the mu.
+func (c *ManualClock) remove(t *manualTimer) bool {
+	for i, v := range c.timers {
+		if v == t {
+			c.timers = append(c.timers[:i], c.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+type manualTimer struct {
+	clock *ManualClock
+	when  time.Time
+	c     chan time.Time
+	f     func()
+}
+
+func (t *manualTimer) C() <-chan time.Time { return t.c }
+
+func (t *manualTimer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+
+	return t.clock.remove(t)
+}
+
+func (t *manualTimer) Reset(d time.Duration) bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+
+	active := t.clock.remove(t)
+	t.when = t.clock.now.Add(d)
+	t.clock.timers = append(t.clock.timers, t)
+
+	return active
+}
+
+func (t *manualTimer) fire(now time.Time) {
+	if t.f != nil {
+		t.f()
+		return
+	}
+	// like time.Timer, the time is dropped if the last one has not been received.
+	select {
+	case t.c <- now:
+	default:
+	}
+}
@@ -0,0 +1,47 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestManualClock(t *testing.T) {
+	start := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := NewManualClock(start)
+
+	timer := clock.NewTimer(time.Second)
+	after := clock.After(2 * time.Second)
+
+	var fired []time.Time
+	clock.AfterFunc(1500*time.Millisecond, func() { fired = append(fired, clock.Now()) })
+	assert.Equal(t, 3, clock.Timers())
+
+	clock.Advance(999 * time.Millisecond)
+	assert.Empty(t, timer.C())
+	assert.Empty(t, fired)
+
+	clock.Advance(time.Millisecond)
+	assert.Equal(t, start.Add(time.Second), <-timer.C())
+
+	clock.Advance(time.Second)
+	assert.Equal(t, []time.Time{start.Add(1500 * time.Millisecond)}, fired)
+	assert.Equal(t, start.Add(2*time.Second), <-after)
+	assert.Equal(t, start.Add(2*time.Second), clock.Now())
+	assert.Equal(t, 0, clock.Timers())
+
+	t.Run("stop and reset", func(t *testing.T) {
+		timer := clock.NewTimer(time.Second)
+		assert.True(t, timer.Stop())
+		assert.False(t, timer.Stop())
+
+		assert.False(t, timer.Reset(time.Minute))
+		clock.Advance(time.Second)
+		assert.Empty(t, timer.C())
+
+		assert.True(t, timer.Reset(time.Second))
+		clock.Advance(time.Second)
+		assert.Len(t, timer.C(), 1)
+	})
+}
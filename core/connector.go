@@ -9,24 +9,85 @@ import (
 // ErrConnectorClosed will be returned if the Connector has been closed.
 var ErrConnectorClosed = errors.New("yomo: connector closed")
 
+// DefaultConnectorShards is the default number of the shards of the Connector.
+const DefaultConnectorShards = 32
+
 // Connector manages data streams and provides a centralized way for getting and setting streams.
+// The streams are kept in the shards by the hash of their ids, so the concurrent access to
+// the streams of different shards does not contend for a lock.
 type Connector struct {
 	// ctx and ctxCancel manage the lifescyle of Connector.
 	ctx       context.Context
 	ctxCancel context.CancelFunc
 
-	// streams stores data streams.
-	streams sync.Map
+	// shards stores data streams.
+	shards []*connectorShard
+}
+
+// connectorShard is a shard of the streams of the Connector.
+type connectorShard struct {
+	mu      sync.RWMutex
+	streams map[string]DataStream
+}
+
+// ConnectorOption is the option for Connector.
+type ConnectorOption func(*connectorOptions)
+
+type connectorOptions struct {
+	shards   int
+	capacity int
+}
+
+// WithConnectorShards sets the number of the shards of the Connector, more shards mean less lock contention
+// under lots of streams. A non-positive n means DefaultConnectorShards.
+func WithConnectorShards(n int) ConnectorOption {
+	return func(o *connectorOptions) {
+		o.shards = n
+	}
+}
+
+// WithConnectorCapacity pre-sizes the Connector for the number of the streams expected,
+// so the shards do not grow as the streams are stored.
+func WithConnectorCapacity(n int) ConnectorOption {
+	return func(o *connectorOptions) {
+		o.capacity = n
+	}
 }
 
 // NewConnector returns an initial Connector.
-func NewConnector(ctx context.Context) *Connector {
+func NewConnector(ctx context.Context, opts ...ConnectorOption) *Connector {
+	options := &connectorOptions{}
+	for _, o := range opts {
+		o(options)
+	}
+	if options.shards <= 0 {
+		options.shards = DefaultConnectorShards
+	}
+
+	shards := make([]*connectorShard, options.shards)
+	for i := range shards {
+		shards[i] = &connectorShard{
+			streams: make(map[string]DataStream, options.capacity/options.shards),
+		}
+	}
+
 	ctx, ctxCancel := context.WithCancel(ctx)
 
 	return &Connector{
 		ctx:       ctx,
 		ctxCancel: ctxCancel,
+		shards:    shards,
+	}
+}
+
+// shard returns the shard of the streamID by its FNV-1a hash.
+func (c *Connector) shard(streamID string) *connectorShard {
+	h := uint32(2166136261)
+	for i := 0; i < len(streamID); i++ {
+		h ^= uint32(streamID[i])
+		h *= 16777619
 	}
+	return c.shards[h%uint32(len(c.shards))]
 }
 
 // Store stores DataStream to Connector,
@@ -39,7 +100,10 @@ func (c *Connector) Store(streamID string, stream DataStream) error {
 	default:
 	}
 
-	c.streams.Store(streamID, stream)
+	s := c.shard(streamID)
+	s.mu.Lock()
+	s.streams[streamID] = stream
+	s.mu.Unlock()
 
 	return nil
 }
@@ -54,11 +118,36 @@ func (c *Connector) Delete(streamID string) error {
 	default:
 	}
 
-	c.streams.Delete(streamID)
+	s := c.shard(streamID)
+	s.mu.Lock()
+	delete(s.streams, streamID)
+	s.mu.Unlock()
 
 	return nil
 }
 
+// CompareAndDelete deletes the DataStream with the specified streamID only if it is the given stream,
+// it reports whether the stream was deleted. A stream replaced by a newer one with the same streamID is not deleted.
+// If Connector be closed, The function will return ErrConnectorClosed.
+func (c *Connector) CompareAndDelete(streamID string, stream DataStream) (bool, error) {
+	select {
+	case <-c.ctx.Done():
+		return false, ErrConnectorClosed
+	default:
+	}
+
+	s := c.shard(streamID)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if v, ok := s.streams[streamID]; !ok || v != stream {
+		return false, nil
+	}
+	delete(s.streams, streamID)
+
+	return true, nil
+}
+
 // Get retrieves the DataStream with the specified streamID.
 // If the Connector does not have a stream with the given streamID, return nil and false.
 // If Connector be closed, The function will return ErrConnectorClosed.
@@ -69,14 +158,33 @@ func (c *Connector) Get(streamID string) (DataStream, bool, error) {
 	default:
 	}
 
-	v, ok := c.streams.Load(streamID)
-	if !ok {
-		return nil, false, nil
+	s := c.shard(streamID)
+	s.mu.RLock()
+	stream, ok := s.streams[streamID]
+	s.mu.RUnlock()
+
+	return stream, ok, nil
+}
+
+// GetByName retrieves all the DataStreams with the specified name, there may be multiple instances
+// of an application connected with the same name. It returns false if there is no stream with the name
+// or the Connector has been closed.
+func (c *Connector) GetByName(name string) ([]DataStream, bool) {
+	select {
+	case <-c.ctx.Done():
+		return nil, false
+	default:
 	}
 
-	stream := v.(DataStream)
+	var streams []DataStream
+	c.rangeStreams(func(_ string, stream DataStream) bool {
+		if stream.Name() == name {
+			streams = append(streams, stream)
+		}
+		return true
+	})
 
-	return stream, true, nil
+	return streams, len(streams) > 0
 }
 
 // FindStreamFunc is used to search for a specific stream within the Connector.
@@ -92,9 +200,7 @@ func (c *Connector) Find(findFunc FindStreamFunc) ([]DataStream, error) {
 	}
 
 	streams := make([]DataStream, 0)
-	c.streams.Range(func(key interface{}, val interface{}) bool {
-		stream := val.(DataStream)
-
+	c.rangeStreams(func(_ string, stream DataStream) bool {
 		if findFunc(stream) {
 			streams = append(streams, stream)
 		}
@@ -104,17 +210,54 @@ func (c *Connector) Find(findFunc FindStreamFunc) ([]DataStream, error) {
 	return streams, nil
 }
 
+// Range calls f sequentially for each stream in the Connector, if f returns false, Range stops the iteration.
+// Range does not block Store and Delete, f may also call them, a stream stored or deleted
+// concurrently may or may not be visited.
+// If Connector be closed, The function will return ErrConnectorClosed.
+func (c *Connector) Range(f func(streamID string, stream DataStream) bool) error {
+	select {
+	case <-c.ctx.Done():
+		return ErrConnectorClosed
+	default:
+	}
+
+	c.rangeStreams(f)
+
+	return nil
+}
+
+// rangeStreams calls f for each stream shard by shard, the streams of a shard are copied before f is called,
+// so f is called without holding the lock of the shard.
+func (c *Connector) rangeStreams(f func(streamID string, stream DataStream) bool) {
+	type entry struct {
+		id     string
+		stream DataStream
+	}
+	var entries []entry
+
+	for _, s := range c.shards {
+		entries = entries[:0]
+		s.mu.RLock()
+		for id, stream := range s.streams {
+			entries = append(entries, entry{id, stream})
+		}
+		s.mu.RUnlock()
+
+		for _, e := range entries {
+			if !f(e.id, e.stream) {
+				return
+			}
+		}
+	}
+}
+
 // Snapshot returns a map that contains a snapshot of all streams.
 // The resulting map uses the streamID as the key and the stream name as the value.
 // This function is typically used to monitor the status of the Connector.
 func (c *Connector) Snapshot() map[string]string {
 	result := make(map[string]string)
 
-	c.streams.Range(func(key interface{}, val interface{}) bool {
-		var (
-			streamID = key.(string)
-			stream   = val.(DataStream)
-		)
+	c.rangeStreams(func(streamID string, stream DataStream) bool {
 		result[streamID] = stream.Name()
 		return true
 	})
@@ -134,10 +277,11 @@ func (c *Connector) Close() error {
 
 	c.ctxCancel()
 
-	c.streams.Range(func(key, value any) bool {
-		c.streams.Delete(key)
-		return true
-	})
+	for _, s := range c.shards {
+		s.mu.Lock()
+		s.streams = make(map[string]DataStream)
+		s.mu.Unlock()
+	}
 
 	return nil
 }
@@ -2,6 +2,8 @@ package core
 
 import (
 	"context"
+	"strconv"
+	"sync/atomic"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -75,6 +77,41 @@ func TestConnector(t *testing.T) {
 		assert.Equal(t, map[string]string{"id-1": "name-1", "id-2": "name-2"}, got)
 	})
 
+	t.Run("GetByName", func(t *testing.T) {
+		stream3 := mockDataStream("id-3", "name-2")
+		err := connector.Store(stream3.ID(), stream3)
+		assert.NoError(t, err)
+		defer connector.Delete(stream3.ID())
+
+		streams, ok := connector.GetByName("name-2")
+		assert.True(t, ok)
+		assert.ElementsMatch(t, []DataStream{mustGet(t, connector, "id-2"), stream3}, streams)
+
+		streams, ok = connector.GetByName("name-3")
+		assert.False(t, ok)
+		assert.Empty(t, streams)
+	})
+
+	t.Run("Range", func(t *testing.T) {
+		got := map[string]string{}
+		err := connector.Range(func(streamID string, stream DataStream) bool {
+			got[streamID] = stream.Name()
+			return true
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]string{"id-1": "name-1", "id-2": "name-2"}, got)
+
+		t.Run("stop early", func(t *testing.T) {
+			count := 0
+			err := connector.Range(func(streamID string, stream DataStream) bool {
+				count++
+				return false
+			})
+			assert.NoError(t, err)
+			assert.Equal(t, 1, count)
+		})
+	})
+
 	t.Run("Close", func(t *testing.T) {
 		connector := NewConnector(context.Background())
 
@@ -109,14 +146,68 @@ func TestConnector(t *testing.T) {
 			assert.Empty(t, ds)
 		})
 
+		t.Run("Range", func(t *testing.T) {
+			err := connector.Range(func(string, DataStream) bool { return true })
+			assert.ErrorIs(t, err, ErrConnectorClosed)
+		})
+
+		t.Run("GetByName", func(t *testing.T) {
+			streams, ok := connector.GetByName("name-1")
+			assert.False(t, ok)
+			assert.Empty(t, streams)
+		})
+
 		t.Run("Snapshot", func(t *testing.T) {
 			assert.Empty(t, connector.Snapshot())
 		})
 	})
 }
 
+func mustGet(t *testing.T, connector *Connector, streamID string) DataStream {
+	stream, ok, err := connector.Get(streamID)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	return stream
+}
+
 // mockDataStream returns a data stream that only includes an ID and a name.
 // This function is used for unit testing purposes.
 func mockDataStream(id, name string) DataStream {
-	return newDataStream(name, id, StreamType(0), nil, []frame.Tag{0}, nil, nil, nil)
+	return newDataStream(name, id, "", StreamType(0), nil, []frame.Tag{0}, nil, nil, nil, SystemClock)
+}
+
+func BenchmarkConnector(b *testing.B) {
+	const streams = 10000
+
+	ids := make([]string, streams)
+	for i := range ids {
+		ids[i] = "stream-" + strconv.Itoa(i)
+	}
+
+	for _, shards := range []int{1, DefaultConnectorShards} {
+		b.Run("shards-"+strconv.Itoa(shards), func(b *testing.B) {
+			connector := NewConnector(context.Background(), WithConnectorShards(shards), WithConnectorCapacity(streams))
+			for _, id := range ids {
+				_ = connector.Store(id, mockDataStream(id, id))
+			}
+
+			var seed atomic.Int64
+			b.RunParallel(func(pb *testing.PB) {
+				// the goroutines start from different streams, so they do not access the same one in step.
+				i := int(seed.Add(1)) * 7919
+				for pb.Next() {
+					id := ids[i%streams]
+					// one write in every four accesses, like the streams opening and closing under the routing.
+					if i%4 == 0 {
+						stream, _, _ := connector.Get(id)
+						_, _ = connector.CompareAndDelete(id, stream)
+						_ = connector.Store(id, stream)
+					} else {
+						_, _, _ = connector.Get(id)
+					}
+					i++
+				}
+			})
+		})
+	}
 }
@@ -1,6 +1,7 @@
 package core
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"sync"
@@ -16,6 +17,14 @@ var ctxPool sync.Pool
 
 // Context is context for stream handling.
 // Context is generated subsequent to the arrival of a dataStream and retains pertinent information derived from the dataStream. The lifespan of the Context should align with the lifespan of the Stream.
+//
+// The Context is owned by the goroutine that handles the stream: the Frame and the FrameMetadata are replaced
+// by the next frame, the Payload of the Frame may be reused once the frame is handled, and the Context is released
+// once the stream handler returns. Do not retain the Context or its Frame in other goroutines,
+// use Clone to get a copy that is owned by the goroutine.
+//
+// Context is a context.Context that is done once the stream is closed or the connection is lost, so it can be
+// passed to the calls those should be cancelled on disconnect, such as the database queries.
 type Context struct {
 	// DataStream is the stream used for reading and writing frames.
 	DataStream DataStream
@@ -33,6 +42,9 @@ type Context struct {
 	StreamLogger *slog.Logger
 	// Using Logger to log in stream handler scope, Logger is frame-level logger.
 	Logger *slog.Logger
+	// CorrelationID is shared by the logs of the stream lifecycle, both StreamLogger and Logger carry it.
+	// It is taken from the handshake metadata by MetadataCorrelationIDKey, or generated if it is absent.
+	CorrelationID string
 }
 
 // Set is used to store a new key/value pair exclusively for this context.
@@ -69,9 +81,39 @@ func (c *Context) Deadline() (deadline time.Time, ok bool) { return c.DataStream
 // Err returns nil when c.Request has no Context.
 func (c *Context) Err() error { return c.DataStream.Context().Err() }
 
+type (
+	correlationIDContextKey struct{}
+	metadataContextKey      struct{}
+)
+
+// CorrelationIDFromContext returns the correlation id of the stream carried by the ctx,
+// the ctx is the *Context or the one derived from it.
+func CorrelationIDFromContext(ctx context.Context) (string, bool) {
+	cid, ok := ctx.Value(correlationIDContextKey{}).(string)
+	return cid, ok && cid != ""
+}
+
+// MetadataFromContext returns the metadata carried by the ctx, the ctx is the *Context or the one derived from it.
+// The metadata is the metadata of the frame being handled, or the metadata of the stream if there is no frame.
+func MetadataFromContext(ctx context.Context) (metadata.M, bool) {
+	md, ok := ctx.Value(metadataContextKey{}).(metadata.M)
+	return md, ok && md != nil
+}
+
 // Value retrieves the value associated with the specified key within the context.
 // If no value is found, it returns nil. Subsequent invocations of "Value" with the same key yield identical outcomes.
+// The correlation id and the metadata are retrieved by CorrelationIDFromContext and MetadataFromContext.
 func (c *Context) Value(key any) any {
+	switch key.(type) {
+	case correlationIDContextKey:
+		return c.CorrelationID
+	case metadataContextKey:
+		if c.FrameMetadata != nil {
+			return c.FrameMetadata
+		}
+		return c.DataStream.Metadata()
+	}
+
 	c.mu.Lock()
 	if keyAsString, ok := key.(string); ok {
 		if val, exists := c.Keys[keyAsString]; exists {
@@ -154,6 +196,43 @@ func (c *Context) CloseWithError(errString string) {
 	c.Logger.Error("data stream close failed", "err", err)
 }
 
+// Clone returns a copy of the Context that is detached from the stream handler, it is safe to use in another
+// goroutine after the handler returns. The Frame, the FrameMetadata and the Keys are copied, the copy shares
+// the DataStream, the Route and the loggers with c, so it is done once the stream is closed.
+// The copy is owned by the caller, it can be released by Release when the caller finishes with it.
+func (c *Context) Clone() *Context {
+	cc := &Context{
+		DataStream:    c.DataStream,
+		Route:         c.Route,
+		StreamLogger:  c.StreamLogger,
+		Logger:        c.Logger,
+		CorrelationID: c.CorrelationID,
+	}
+	if c.Frame != nil {
+		cc.Frame = &frame.DataFrame{
+			Metadata: bytes.Clone(c.Frame.Metadata),
+			Tag:      c.Frame.Tag,
+			Payload:  bytes.Clone(c.Frame.Payload),
+			Seq:      c.Frame.Seq,
+			TTL:      c.Frame.TTL,
+		}
+	}
+	if c.FrameMetadata != nil {
+		cc.FrameMetadata = c.FrameMetadata.Clone()
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.Keys != nil {
+		cc.Keys = make(map[string]any, len(c.Keys))
+		for k, v := range c.Keys {
+			cc.Keys[k] = v
+		}
+	}
+	return cc
+}
+
 // Release release the Context, the Context which has been released will not be available.
 //
 // Warning: do not use any Context api after Release, It maybe cause an error.
@@ -170,6 +249,7 @@ func (c *Context) reset() {
 	c.FrameMetadata = nil
 	c.StreamLogger = nil
 	c.Logger = nil
+	c.CorrelationID = ""
 	for k := range c.Keys {
 		delete(c.Keys, k)
 	}
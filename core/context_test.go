@@ -0,0 +1,60 @@
+package core
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yomorun/yomo/core/frame"
+	"github.com/yomorun/yomo/core/metadata"
+	"github.com/yomorun/yomo/core/ylog"
+)
+
+func TestContextClone(t *testing.T) {
+	stream := newDataStream("source", "source-1", "", StreamTypeSource, metadata.M{}, nil, nil, nil, nil, SystemClock)
+	c := newContext(stream, nil, ylog.Default())
+	c.Frame = &frame.DataFrame{Tag: 1, Payload: []byte("hello"), Seq: 2, TTL: 3}
+	c.FrameMetadata = metadata.M{"foo": "bar"}
+	c.Set("key", "value")
+
+	cc := c.Clone()
+
+	// the parent reuses the payload and is released after the handler returns.
+	copy(c.Frame.Payload, "world")
+	c.FrameMetadata.Set("foo", "baz")
+	c.Release()
+
+	assert.Equal(t, stream, cc.DataStream)
+	assert.Equal(t, &frame.DataFrame{Tag: 1, Payload: []byte("hello"), Seq: 2, TTL: 3}, cc.Frame)
+	assert.Equal(t, metadata.M{"foo": "bar"}, cc.FrameMetadata)
+	value, ok := cc.Get("key")
+	assert.True(t, ok)
+	assert.Equal(t, "value", value)
+}
+
+func TestContextValues(t *testing.T) {
+	frameStream := NewFrameStream(newMemByteStream(nil), &byteCodec{}, &bytePacketReadWriter{})
+	stream := newDataStream("source", "source-1", "", StreamTypeSource, metadata.M{"stream": "foo"}, nil, frameStream, nil, nil, SystemClock)
+	c := newContext(stream, nil, ylog.Default())
+	defer c.Release()
+
+	_, ok := CorrelationIDFromContext(c)
+	assert.False(t, ok)
+	md, ok := MetadataFromContext(c)
+	assert.True(t, ok)
+	assert.Equal(t, metadata.M{"stream": "foo"}, md)
+
+	c.CorrelationID = "cid"
+	c.FrameMetadata = metadata.M{"frame": "bar"}
+
+	// the values are carried by the derived contexts.
+	ctx, cancel := context.WithCancel(c)
+	defer cancel()
+
+	cid, ok := CorrelationIDFromContext(ctx)
+	assert.True(t, ok)
+	assert.Equal(t, "cid", cid)
+	md, ok = MetadataFromContext(ctx)
+	assert.True(t, ok)
+	assert.Equal(t, metadata.M{"frame": "bar"}, md)
+}
@@ -0,0 +1,76 @@
+package core
+
+import (
+	"sync"
+
+	"github.com/yomorun/yomo/core/frame"
+)
+
+// ControlFrameHandlers routes the control frames read by the ClientControlStream to the handlers registered
+// by the frame type, such as GoawayFrame and RejectedFrame. The handlers are called in the read loop of
+// the control stream, before the frame is handled by the client, so they must return quickly.
+// The handlers are kept across the reconnections of the client.
+type ControlFrameHandlers struct {
+	mu       sync.RWMutex
+	handlers map[frame.Type]func(frame.Frame)
+}
+
+// NewControlFrameHandlers returns an empty ControlFrameHandlers.
+func NewControlFrameHandlers() *ControlFrameHandlers {
+	return &ControlFrameHandlers{handlers: make(map[frame.Type]func(frame.Frame))}
+}
+
+// Handle registers the handler of the frame type, it replaces the handler registered before,
+// a nil handler unregisters it. The frame of a type that the client does not expect on the control stream
+// is ignored unless it has a handler.
+func (h *ControlFrameHandlers) Handle(ftyp frame.Type, fn func(frame.Frame)) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if fn == nil {
+		delete(h.handlers, ftyp)
+		return
+	}
+	h.handlers[ftyp] = fn
+}
+
+// OnGoaway registers the handler of GoawayFrame.
+func (h *ControlFrameHandlers) OnGoaway(fn func(*frame.GoawayFrame)) {
+	h.Handle(frame.TypeGoawayFrame, func(f frame.Frame) { fn(f.(*frame.GoawayFrame)) })
+}
+
+// OnRejected registers the handler of RejectedFrame.
+func (h *ControlFrameHandlers) OnRejected(fn func(*frame.RejectedFrame)) {
+	h.Handle(frame.TypeRejectedFrame, func(f frame.Frame) { fn(f.(*frame.RejectedFrame)) })
+}
+
+// OnAuthAck registers the handler of AuthenticationAckFrame, it is called once the client is authenticated.
+func (h *ControlFrameHandlers) OnAuthAck(fn func(*frame.AuthenticationAckFrame)) {
+	h.Handle(frame.TypeAuthenticationAckFrame, func(f frame.Frame) { fn(f.(*frame.AuthenticationAckFrame)) })
+}
+
+// OnHandshakeRejected registers the handler of HandshakeRejectedFrame.
+func (h *ControlFrameHandlers) OnHandshakeRejected(fn func(*frame.HandshakeRejectedFrame)) {
+	h.Handle(frame.TypeHandshakeRejectedFrame, func(f frame.Frame) { fn(f.(*frame.HandshakeRejectedFrame)) })
+}
+
+// OnCloseStream registers the handler of CloseStreamFrame.
+func (h *ControlFrameHandlers) OnCloseStream(fn func(*frame.CloseStreamFrame)) {
+	h.Handle(frame.TypeCloseStreamFrame, func(f frame.Frame) { fn(f.(*frame.CloseStreamFrame)) })
+}
+
+// dispatch calls the handler of the frame, it reports false if the frame type has no handler.
+func (h *ControlFrameHandlers) dispatch(f frame.Frame) bool {
+	if h == nil {
+		return false
+	}
+	h.mu.RLock()
+	fn, ok := h.handlers[f.Type()]
+	h.mu.RUnlock()
+
+	if !ok {
+		return false
+	}
+	fn(f)
+	return true
+}
@@ -0,0 +1,34 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yomorun/yomo/core/frame"
+)
+
+func TestControlFrameHandlers(t *testing.T) {
+	var nilHandlers *ControlFrameHandlers
+	assert.False(t, nilHandlers.dispatch(&frame.GoawayFrame{}))
+
+	h := NewControlFrameHandlers()
+
+	var goaway *frame.GoawayFrame
+	h.OnGoaway(func(f *frame.GoawayFrame) { goaway = f })
+
+	var ack *frame.AuthenticationAckFrame
+	h.OnAuthAck(func(f *frame.AuthenticationAckFrame) { ack = f })
+
+	gf := &frame.GoawayFrame{Message: "bye"}
+	assert.True(t, h.dispatch(gf))
+	assert.Same(t, gf, goaway)
+
+	af := &frame.AuthenticationAckFrame{Version: frame.Version1}
+	assert.True(t, h.dispatch(af))
+	assert.Same(t, af, ack)
+
+	assert.False(t, h.dispatch(&frame.RejectedFrame{}), "no handler registered")
+
+	h.Handle(frame.TypeGoawayFrame, nil)
+	assert.False(t, h.dispatch(gf), "the handler is unregistered")
+}
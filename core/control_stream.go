@@ -3,18 +3,22 @@ package core
 
 import (
 	"context"
+	"crypto/sha256"
 	"crypto/tls"
 	"errors"
 	"fmt"
 	"io"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/quic-go/quic-go"
 	"github.com/yomorun/yomo/core/auth"
 	"github.com/yomorun/yomo/core/frame"
 	"github.com/yomorun/yomo/core/metadata"
+	"github.com/yomorun/yomo/core/yerr"
 	"github.com/yomorun/yomo/core/ylog"
+	"github.com/yomorun/yomo/pkg/id"
 	"golang.org/x/exp/slog"
 )
 
@@ -23,6 +27,7 @@ import (
 type ErrHandshakeRejected struct {
 	Message  string
 	StreamID string
+	Reason   frame.RejectCode
 }
 
 // Error returns a string that represents the ErrHandshakeRejected error for the implementation of the error interface.
@@ -30,6 +35,18 @@ func (e ErrHandshakeRejected) Error() string {
 	return fmt.Sprintf("yomo: handshake be rejected, streamID=%s, message=%s", e.StreamID, e.Message)
 }
 
+// ErrHandshakeAckTimeout be returned when the HandshakeAckFrame is not received in time after sending a handshake.
+// Unlike ErrHandshakeRejected, the server does not respond to the handshake, the connection is aborted.
+type ErrHandshakeAckTimeout struct {
+	StreamID string
+	Timeout  time.Duration
+}
+
+// Error returns a string that represents the ErrHandshakeAckTimeout error for the implementation of the error interface.
+func (e ErrHandshakeAckTimeout) Error() string {
+	return fmt.Sprintf("yomo: handshake ack timeout, streamID=%s, timeout=%s", e.StreamID, e.Timeout)
+}
+
 // ErrAuthenticateFailed be returned when client control stream authenticate failed.
 type ErrAuthenticateFailed struct {
 	ReasonFromeServer string
@@ -38,47 +55,122 @@ type ErrAuthenticateFailed struct {
 // Error returns a string that represents the ErrAuthenticateFailed error for the implementation of the error interface.
 func (e ErrAuthenticateFailed) Error() string { return e.ReasonFromeServer }
 
+// versionMismatchPrefix is the prefix of the error that the server closes the connection with
+// if the client and the server have no protocol version in common.
+const versionMismatchPrefix = "protocol version mismatch"
+
+// ErrVersionMismatch be returned when the client and the server have no protocol version in common,
+// retrying does not help until one of them is upgraded.
+type ErrVersionMismatch struct {
+	Message string
+}
+
+// Error returns a string that represents the ErrVersionMismatch error for the implementation of the error interface.
+func (e ErrVersionMismatch) Error() string { return e.Message }
+
+// handshakeRejectError is returned by HandshakeFunc to reject the handshake with the reason,
+// the other errors reject the handshake with frame.RejectInternal.
+type handshakeRejectError struct {
+	reason  frame.RejectCode
+	message string
+}
+
+func (e *handshakeRejectError) Error() string { return e.message }
+
+// handshakeRejectReason returns the reason that the handshake is rejected with for the error of HandshakeFunc.
+func handshakeRejectReason(err error) frame.RejectCode {
+	if re := new(handshakeRejectError); errors.As(err, &re) {
+		return re.reason
+	}
+	return frame.RejectInternal
+}
+
 // HandshakeFunc is used by server control stream to handle handshake.
 // The returned metadata will be set for the DataStream that is being opened.
 type HandshakeFunc func(*frame.HandshakeFrame) (metadata.M, error)
 
 // VerifyAuthenticationFunc is used by server control stream to verify authentication.
-type VerifyAuthenticationFunc func(*frame.AuthenticationFrame) (metadata.M, bool, error)
+// The ctx is cancelled once the server is closed, the verifier can derive a timeout from it for remote lookups.
+// The returned metadata is the initial metadata of the connection, it is merged into every DataStream of the connection.
+// With WithServer0RTT, it may run for the replayed AuthenticationFrames, so it must be replay-safe.
+type VerifyAuthenticationFunc func(context.Context, *frame.AuthenticationFrame) (metadata.M, bool, error)
+
+// LegacyVerifyAuthenticationFunc is the VerifyAuthenticationFunc without context.
+//
+// Deprecated: use VerifyAuthenticationFunc instead, LegacyVerifyAuthenticationFunc.WithContext converts it.
+type LegacyVerifyAuthenticationFunc func(*frame.AuthenticationFrame) (metadata.M, bool, error)
+
+// WithContext converts the LegacyVerifyAuthenticationFunc to VerifyAuthenticationFunc that ignores the context.
+func (fn LegacyVerifyAuthenticationFunc) WithContext() VerifyAuthenticationFunc {
+	return func(_ context.Context, f *frame.AuthenticationFrame) (metadata.M, bool, error) {
+		return fn(f)
+	}
+}
 
 // ServerControlStream defines the struct of server-side control stream.
 type ServerControlStream struct {
 	conn               Connection
 	stream             frame.ReadWriteCloser
 	handshakeFrameChan chan *frame.HandshakeFrame
-	codec              frame.Codec
-	packetReadWriter   frame.PacketReadWriter
-	logger             *slog.Logger
+	// controlFrameChan receives the control frames those are handled by StreamGroup,
+	// such as MetadataUpdateFrames, FlowControlFrames and ObserveTagFrames.
+	controlFrameChan chan frame.Frame
+	codec            frame.Codec
+	packetReadWriter frame.PacketReadWriter
+	// frameStreamOptions are applied to the FrameStreams of the control stream and the data streams.
+	frameStreamOptions []FrameStreamOption
+	// versions are the protocol versions the server supports, version is the one negotiated with the client.
+	versions []frame.Version
+	version  frame.Version
+	// labels are the labels of the connection sent with the authentication.
+	labels map[string]string
+	// identity is the digest of the credential the connection is authenticated with,
+	// only the streams of the same identity are allowed to take over each other.
+	identity [sha256.Size]byte
+	// clock is the clock of the data streams opened.
+	clock Clock
+	// health returns the health status replied to the HealthCheckFrames, healthCheckBeforeAuth makes
+	// the HealthCheckFrames accepted before the authentication.
+	health                func() frame.HealthStatus
+	healthCheckBeforeAuth bool
+	logger                *slog.Logger
 }
 
 // NewServerControlStream returns ServerControlStream from quic Connection and the first stream of this Connection.
 func NewServerControlStream(
 	conn Connection, stream ContextReadWriteCloser,
 	codec frame.Codec, packetReadWriter frame.PacketReadWriter,
-	logger *slog.Logger,
+	logger *slog.Logger, opts ...FrameStreamOption,
 ) *ServerControlStream {
 	if logger == nil {
 		logger = ylog.Default()
 	}
 	controlStream := &ServerControlStream{
 		conn:               conn,
-		stream:             NewFrameStream(stream, codec, packetReadWriter),
+		stream:             NewFrameStream(stream, codec, packetReadWriter, opts...),
 		handshakeFrameChan: make(chan *frame.HandshakeFrame, 10),
+		controlFrameChan:   make(chan frame.Frame, 10),
 		codec:              codec,
 		packetReadWriter:   packetReadWriter,
+		frameStreamOptions: opts,
+		versions:           frame.SupportedVersions,
+		clock:              SystemClock,
 		logger:             logger,
 	}
 
 	return controlStream
 }
 
+// Version returns the protocol version negotiated with the client, it is valid after the authentication.
+func (ss *ServerControlStream) Version() frame.Version { return ss.version }
+
+// Labels returns the labels of the connection sent with the authentication.
+func (ss *ServerControlStream) Labels() map[string]string { return ss.labels }
+
 func (ss *ServerControlStream) readFrameLoop() {
 	defer func() {
 		close(ss.handshakeFrameChan)
+		close(ss.controlFrameChan)
 	}()
 	for {
 		f, err := ss.stream.ReadFrame()
@@ -89,6 +181,15 @@ func (ss *ServerControlStream) readFrameLoop() {
 		switch ff := f.(type) {
 		case *frame.HandshakeFrame:
 			ss.handshakeFrameChan <- ff
+		case *frame.MetadataUpdateFrame, *frame.FlowControlFrame, *frame.ObserveTagFrame, *frame.UnobserveTagFrame,
+			*frame.CloseStreamFrame, *frame.StatsReportFrame, *frame.StreamReadyFrame:
+			ss.controlFrameChan <- ff
+		case *frame.PingFrame:
+			if err := ss.stream.WriteFrame(&frame.PongFrame{Nonce: ff.Nonce}); err != nil {
+				ss.logger.Debug("control stream failed to reply pong", "err", err)
+			}
+		case *frame.HealthCheckFrame:
+			ss.replyHealthCheck()
 		default:
 			ss.logger.Debug("control stream read unexpected frame", "frame_type", f.Type().String())
 		}
@@ -99,15 +200,22 @@ func (ss *ServerControlStream) readFrameLoop() {
 // if handler returns nil, will return a DataStream and nil,
 // if handler returns an error, will return nil and the error.
 func (ss *ServerControlStream) OpenStream(ctx context.Context, handshakeFunc HandshakeFunc) (DataStream, error) {
-	ff, ok := <-ss.handshakeFrameChan
-	if !ok {
-		return nil, io.EOF
+	var ff *frame.HandshakeFrame
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case f, ok := <-ss.handshakeFrameChan:
+		if !ok {
+			return nil, io.EOF
+		}
+		ff = f
 	}
 	md, err := handshakeFunc(ff)
 	if err != nil {
 		_ = ss.stream.WriteFrame(&frame.HandshakeRejectedFrame{
 			ID:      ff.ID,
 			Message: err.Error(),
+			Reason:  handshakeRejectReason(err),
 		})
 		return nil, err
 	}
@@ -116,8 +224,10 @@ func (ss *ServerControlStream) OpenStream(ctx context.Context, handshakeFunc Han
 	if err != nil {
 		return nil, err
 	}
+	checksum := checksumRequested(ff.Metadata)
 	b, err := ss.codec.Encode(&frame.HandshakeAckFrame{
 		StreamID: ff.ID,
+		Checksum: checksum,
 	})
 	if err != nil {
 		return nil, err
@@ -126,58 +236,151 @@ func (ss *ServerControlStream) OpenStream(ctx context.Context, handshakeFunc Han
 	if err != nil {
 		return nil, err
 	}
+	prw := ss.packetReadWriter
+	if checksum {
+		prw = frame.ChecksumPacketReadWriter(prw)
+	}
+	frameStream := NewFrameStream(stream, ss.codec, prw, ss.frameStreamOptions...)
+	frameStream.setTagPriorities(ff.ObserveTagPriorities)
+
 	dataStream := newDataStream(
 		ff.Name,
 		ff.ID,
+		ff.InstanceKey,
 		StreamType(ff.StreamType),
 		md,
 		ff.ObserveDataTags,
-		NewFrameStream(stream, ss.codec, ss.packetReadWriter),
+		frameStream,
 		ss,
 		nil,
+		ss.clock,
 	)
 	return dataStream, nil
 }
 
+// ControlFrames returns the channel that receives the control frames from the client,
+// the channel will be closed once the control stream is closed.
+func (ss *ServerControlStream) ControlFrames() <-chan frame.Frame {
+	return ss.controlFrameChan
+}
+
 // CloseWithError closes the server-side control stream.
 func (ss *ServerControlStream) CloseWithError(errString string) error {
 	return ss.conn.CloseWithError(errString)
 }
 
+// CloseStream tells the client that its DataStream with the streamID is closed by the server,
+// the code tells the client why, such as frame.CloseIdleTimeout.
+func (ss *ServerControlStream) CloseStream(streamID string, code frame.CloseCode, reason string) error {
+	return ss.stream.WriteFrame(&frame.CloseStreamFrame{
+		StreamID: streamID,
+		Reason:   reason,
+		Code:     code,
+	})
+}
+
+// CloseWithCode closes the server-side control stream with the yerr code,
+// the client translates it back by yerr.FromError.
+func (ss *ServerControlStream) CloseWithCode(code yerr.ErrorCode, errString string) error {
+	return closeWithCode(ss.conn, code, errString)
+}
+
 // Goaway tells client-side connection that the connection goaway and closes it.
 func (ss *ServerControlStream) Goaway(errString string) error {
+	return ss.goaway(&frame.GoawayFrame{Message: errString}, yerr.ErrorCodeGoaway)
+}
+
+// Rotate tells client-side connection to reconnect and closes it, the client authenticates again
+// with the new connection, see WithServerMaxConnectionLifetime. The connection is closed with
+// yerr.ErrorCodeRotated, so the client reconnects even if the GoawayFrame is lost in the race with the close.
+func (ss *ServerControlStream) Rotate(errString string) error {
+	return ss.goaway(&frame.GoawayFrame{Message: errString, Reconnect: true}, yerr.ErrorCodeRotated)
+}
+
+func (ss *ServerControlStream) goaway(f *frame.GoawayFrame, code yerr.ErrorCode) error {
 	// send GoawayFrame to client.
-	_ = ss.stream.WriteFrame(&frame.GoawayFrame{
-		Message: errString,
-	})
+	_ = ss.stream.WriteFrame(f)
 	// close the connection.
-	return ss.CloseWithError(errString)
+	return ss.CloseWithCode(code, f.Message)
+}
+
+// negotiateDictionary ships the dictionary of the codec to the client that accepts the dictionaries but has
+// a different version, the data streams fall back to the compression without the dictionary if the client does not
+// have the same version, see frame.DictionaryCodec.
+func (ss *ServerControlStream) negotiateDictionary(received *frame.AuthenticationFrame, ack *frame.AuthenticationAckFrame) {
+	dc, ok := ss.codec.(frame.DictionaryCodec)
+	if !ok || dc.DictionaryID() == 0 {
+		return
+	}
+	ack.DictionaryID = dc.DictionaryID()
+
+	peer := received.DictionaryID
+	if received.AcceptDictionary && peer != ack.DictionaryID {
+		ack.Dictionary = dc.Dictionary()
+		peer = ack.DictionaryID
+		ss.logger.Debug("ship the dictionary to the client", "dictionary_id", peer, "client_dictionary_id", received.DictionaryID)
+	}
+	ss.codec = dc.PeerCodec(peer)
+}
+
+// replyHealthCheck replies the HealthCheckFrame with the health status of the server.
+func (ss *ServerControlStream) replyHealthCheck() {
+	status := frame.HealthServing
+	if ss.health != nil {
+		status = ss.health()
+	}
+	if err := ss.stream.WriteFrame(&frame.HealthCheckAckFrame{Status: status}); err != nil {
+		ss.logger.Debug("control stream failed to reply health check", "err", err)
+	}
 }
 
 // VerifyAuthentication verify the Authentication from client side.
-func (ss *ServerControlStream) VerifyAuthentication(verifyFunc VerifyAuthenticationFunc) (metadata.M, error) {
+func (ss *ServerControlStream) VerifyAuthentication(ctx context.Context, verifyFunc VerifyAuthenticationFunc) (metadata.M, error) {
 	first, err := ss.stream.ReadFrame()
 	if err != nil {
 		return nil, err
 	}
+	// the probes can check the health before the authentication if it is allowed.
+	for ss.healthCheckBeforeAuth {
+		if _, ok := first.(*frame.HealthCheckFrame); !ok {
+			break
+		}
+		ss.replyHealthCheck()
+		if first, err = ss.stream.ReadFrame(); err != nil {
+			return nil, err
+		}
+	}
 
 	received, ok := first.(*frame.AuthenticationFrame)
 	if !ok {
 		errString := fmt.Sprintf("authentication failed: read unexcepted frame, frame read: %s", received.Type().String())
-		ss.CloseWithError(errString)
+		ss.CloseWithCode(yerr.ErrorCodeProtocol, errString)
+		return nil, errors.New(errString)
+	}
+
+	version, ok := frame.NegotiateVersion(ss.versions, received.Versions)
+	if !ok {
+		errString := fmt.Sprintf("%s: client supports %v, server supports %v", versionMismatchPrefix, received.Versions, ss.versions)
+		ss.CloseWithCode(yerr.ErrorCodeVersionMismatch, errString)
 		return nil, errors.New(errString)
 	}
+	ss.version = version
+	ss.labels = received.Labels
 
-	md, ok, err := verifyFunc(received)
+	md, ok, err := verifyFunc(ctx, received)
 	if err != nil {
+		ss.CloseWithCode(yerr.ErrorCodeAuthenticateFailed, fmt.Sprintf("authentication failed: %v", err))
 		return md, err
 	}
 	if !ok {
 		errString := fmt.Sprintf("authentication failed: client credential name is %s", received.AuthName)
-		ss.CloseWithError(errString)
+		ss.CloseWithCode(yerr.ErrorCodeAuthenticateFailed, errString)
 		return md, errors.New(errString)
 	}
-	if err := ss.stream.WriteFrame(&frame.AuthenticationAckFrame{}); err != nil {
+	ss.identity = credentialIdentity(received)
+	ack := &frame.AuthenticationAckFrame{Version: version}
+	ss.negotiateDictionary(received, ack)
+	if err := ss.stream.WriteFrame(ack); err != nil {
 		return md, err
 	}
 
@@ -187,6 +390,18 @@ func (ss *ServerControlStream) VerifyAuthentication(verifyFunc VerifyAuthenticat
 	return md, nil
 }
 
+// credentialIdentity digests the credential of the AuthenticationFrame, the credential itself is not kept.
+func credentialIdentity(f *frame.AuthenticationFrame) [sha256.Size]byte {
+	h := sha256.New()
+	h.Write([]byte(f.AuthName))
+	// the name is separated from the payload, so that the name can't borrow the bytes of the payload.
+	h.Write([]byte{0})
+	h.Write(f.AuthPayload)
+	var identity [sha256.Size]byte
+	h.Sum(identity[:0])
+	return identity
+}
+
 // ClientControlStream is the struct that defines the methods for client-side control stream.
 type ClientControlStream struct {
 	ctx    context.Context
@@ -196,15 +411,30 @@ type ClientControlStream struct {
 	// encode and decode the frame
 	codec            frame.Codec
 	packetReadWriter frame.PacketReadWriter
+	// frameStreamOptions are applied to the FrameStreams of the control stream and the data streams.
+	frameStreamOptions []FrameStreamOption
 
-	// mu protect handshakeFrames
+	// mu protect handshakeFrames and pings
 	mu              sync.Mutex
 	handshakeFrames map[string]*frame.HandshakeFrame
+	// pings stores the waiting channels of the PingFrames those have been sent, the key is the nonce.
+	pings map[string]chan struct{}
+	// healthChecks are the waiting channels of the HealthCheckFrames those have been sent,
+	// a HealthCheckAckFrame replies all of them.
+	healthChecks []chan frame.HealthStatus
 
 	handshakeRejectedFrameChan chan *frame.HandshakeRejectedFrame
 	acceptStreamResultChan     chan acceptStreamResult
 	logger                     *slog.Logger
 	signalChan                 chan frame.Frame
+
+	// versions are the protocol versions the client supports, version is the one negotiated with the server.
+	versions []frame.Version
+	version  frame.Version
+	// labels are the labels of the connection sent with the authentication.
+	labels map[string]string
+	// handlers are the handlers of the control frames registered by the user.
+	handlers *ControlFrameHandlers
 }
 
 // OpenClientControlStream opens ClientControlStream from addr.
@@ -212,42 +442,72 @@ func OpenClientControlStream(
 	ctx context.Context, addr string,
 	tlsConfig *tls.Config, quicConfig *quic.Config,
 	codec frame.Codec, packetReadWriter frame.PacketReadWriter,
-	logger *slog.Logger,
+	logger *slog.Logger, opts ...FrameStreamOption,
 ) (*ClientControlStream, error) {
+	return openClientControlStream(ctx, addr, tlsConfig, quicConfig, false, codec, packetReadWriter, logger, opts...)
+}
 
-	conn, err := quic.DialAddr(ctx, addr, tlsConfig, quicConfig)
+// openClientControlStream opens ClientControlStream from addr, if early is true, the frames are sent in 0-RTT
+// before the handshake completes if the TLS session is resumed.
+func openClientControlStream(
+	ctx context.Context, addr string,
+	tlsConfig *tls.Config, quicConfig *quic.Config, early bool,
+	codec frame.Codec, packetReadWriter frame.PacketReadWriter,
+	logger *slog.Logger, opts ...FrameStreamOption,
+) (*ClientControlStream, error) {
+	var (
+		conn quic.Connection
+		err  error
+	)
+	if early {
+		conn, err = quic.DialAddrEarly(ctx, addr, tlsConfig, quicConfig)
+	} else {
+		conn, err = quic.DialAddr(ctx, addr, tlsConfig, quicConfig)
+	}
 	if err != nil {
 		return nil, err
 	}
-	stream0, err := conn.OpenStream()
+	qconn := newQuicConnection(conn)
+
+	stream0, err := qconn.OpenStream()
 	if err != nil {
 		return nil, err
 	}
 
-	return NewClientControlStream(conn.Context(), &QuicConnection{conn}, stream0, codec, packetReadWriter, logger), nil
+	return NewClientControlStream(conn.Context(), qconn, stream0, codec, packetReadWriter, logger, opts...), nil
 }
 
 // NewClientControlStream returns ClientControlStream from quic Connection and the first stream form the Connection.
 func NewClientControlStream(
 	ctx context.Context, conn Connection, stream ContextReadWriteCloser,
-	codec frame.Codec, packetReadWriter frame.PacketReadWriter, logger *slog.Logger) *ClientControlStream {
+	codec frame.Codec, packetReadWriter frame.PacketReadWriter, logger *slog.Logger, opts ...FrameStreamOption) *ClientControlStream {
 
 	controlStream := &ClientControlStream{
 		ctx:                        ctx,
 		conn:                       conn,
-		stream:                     NewFrameStream(stream, codec, packetReadWriter),
+		stream:                     NewFrameStream(stream, codec, packetReadWriter, opts...),
 		codec:                      codec,
 		packetReadWriter:           packetReadWriter,
+		frameStreamOptions:         opts,
 		handshakeFrames:            make(map[string]*frame.HandshakeFrame),
+		pings:                      make(map[string]chan struct{}),
 		handshakeRejectedFrameChan: make(chan *frame.HandshakeRejectedFrame, 10),
 		acceptStreamResultChan:     make(chan acceptStreamResult, 10),
 		logger:                     logger,
 		signalChan:                 make(chan frame.Frame, 1),
+		versions:                   frame.SupportedVersions,
+		handlers:                   NewControlFrameHandlers(),
 	}
 
 	return controlStream
 }
 
+// Handlers returns the handlers of the control frames read by the control stream.
+func (cs *ClientControlStream) Handlers() *ControlFrameHandlers { return cs.handlers }
+
+// Version returns the protocol version negotiated with the server, it is valid after the authentication.
+func (cs *ClientControlStream) Version() frame.Version { return cs.version }
+
 func (cs *ClientControlStream) readFrameLoop() {
 	defer func() {
 		close(cs.handshakeRejectedFrameChan)
@@ -258,12 +518,21 @@ func (cs *ClientControlStream) readFrameLoop() {
 			cs.conn.CloseWithError(err.Error())
 			return
 		}
+		if !cs.handlers.dispatch(f) {
+			cs.logger.Debug("control frame has no handler", "frame_type", f.Type().String())
+		}
 		switch ff := f.(type) {
 
 		// stream level control signal.
 		case *frame.HandshakeRejectedFrame:
 			cs.handshakeRejectedFrameChan <- ff
 
+		// keepalive signal.
+		case *frame.PongFrame:
+			cs.handlePongFrame(ff)
+		case *frame.HealthCheckAckFrame:
+			cs.handleHealthCheckAckFrame(ff)
+
 		// connection level control signal.
 		case *frame.RejectedFrame:
 			select {
@@ -277,10 +546,14 @@ func (cs *ClientControlStream) readFrameLoop() {
 				return
 			default:
 			}
+		// stream level close signal, the connection is kept.
+		case *frame.CloseStreamFrame:
+			select {
+			case cs.signalChan <- f:
+			default:
+			}
 		default:
-			cs.logger.Warn("control stream read unexcepted frame", "frame_type", f.Type().String())
-			_ = cs.conn.CloseWithError("client read unexcepted frame")
-			return
+			// the frames of other types are left to the handlers.
 		}
 	}
 }
@@ -290,25 +563,56 @@ func (cs *ClientControlStream) readFrameLoop() {
 func (cs *ClientControlStream) Authenticate(cred *auth.Credential) error {
 	af := &frame.AuthenticationFrame{
 		AuthName:    cred.Name(),
-		AuthPayload: cred.Payload(),
+		AuthPayload: cred.PayloadBytes(),
+		Versions:    cs.versions,
+		Labels:      cs.labels,
+	}
+	dc, acceptDictionary := cs.codec.(frame.DictionaryCodec)
+	if acceptDictionary {
+		af.AcceptDictionary = true
+		af.DictionaryID = dc.DictionaryID()
 	}
 	if err := cs.stream.WriteFrame(af); err != nil {
 		return err
 	}
 	received, err := cs.stream.ReadFrame()
 	if err != nil {
-		if qerr := new(quic.ApplicationError); errors.As(err, &qerr) && strings.HasPrefix(qerr.ErrorMessage, "authentication failed") {
+		qerr := new(quic.ApplicationError)
+		if !errors.As(err, &qerr) {
+			return err
+		}
+		// the error code is checked first, the message is checked for the servers that do not send the codes.
+		code := yerr.Parse(qerr.ErrorCode)
+		if code == yerr.ErrorCodeAuthenticateFailed || strings.HasPrefix(qerr.ErrorMessage, "authentication failed") {
 			return &ErrAuthenticateFailed{qerr.ErrorMessage}
 		}
+		if code == yerr.ErrorCodeVersionMismatch || strings.HasPrefix(qerr.ErrorMessage, versionMismatchPrefix) {
+			return ErrVersionMismatch{qerr.ErrorMessage}
+		}
 		return err
 	}
-	_, ok := received.(*frame.AuthenticationAckFrame)
+	ack, ok := received.(*frame.AuthenticationAckFrame)
 	if !ok {
 		return fmt.Errorf(
 			"yomo: read unexpected frame during waiting authentication resp, frame read: %s",
 			received.Type().String(),
 		)
 	}
+	// the server that does not negotiate the version speaks Version1.
+	version := ack.Version
+	if version == 0 {
+		version = frame.Version1
+	}
+	if _, ok := frame.NegotiateVersion(cs.versions, []frame.Version{version}); !ok {
+		err := ErrVersionMismatch{fmt.Sprintf("%s: server picks %d, client supports %v", versionMismatchPrefix, version, cs.versions)}
+		_ = cs.conn.CloseWithError(err.Error())
+		return err
+	}
+	cs.version = version
+	if acceptDictionary {
+		cs.acceptDictionary(dc, ack)
+	}
+	cs.handlers.dispatch(ack)
 
 	// create a goroutinue to continuous read frame from server.
 	go cs.readFrameLoop()
@@ -318,35 +622,242 @@ func (cs *ClientControlStream) Authenticate(cred *auth.Credential) error {
 	return nil
 }
 
+// acceptDictionary loads the dictionary shipped by the server into the codec of the connection, the codec passed
+// in may be shared by other connections, so it is not changed. The data streams fall back to the compression
+// without the dictionary if the client does not have the same version as the server.
+func (cs *ClientControlStream) acceptDictionary(dc frame.DictionaryCodec, ack *frame.AuthenticationAckFrame) {
+	if len(ack.Dictionary) > 0 {
+		if loaded, err := dc.CloneWithDictionary(ack.Dictionary); err != nil {
+			cs.logger.Warn("failed to load the dictionary shipped by the server", "dictionary_id", ack.DictionaryID, "err", err)
+		} else {
+			dc = loaded
+			cs.logger.Info("load the dictionary shipped by the server", "dictionary_id", ack.DictionaryID)
+		}
+	}
+	cs.codec = dc.PeerCodec(ack.DictionaryID)
+}
+
 // ackDataStream drain HandshakeAckFrame from the Reader and return streamID and error.
-func ackDataStream(stream frame.Reader) (string, error) {
+func ackDataStream(stream frame.Reader) (*frame.HandshakeAckFrame, error) {
 	first, err := stream.ReadFrame()
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	f, ok := first.(*frame.HandshakeAckFrame)
 	if !ok {
-		return "", fmt.Errorf("yomo: data stream read first frame should be HandshakeAckFrame, but got %s", first.Type().String())
+		return nil, fmt.Errorf("yomo: data stream read first frame should be HandshakeAckFrame, but got %s", first.Type().String())
 	}
 
-	return f.StreamID, nil
+	return f, nil
 }
 
 // RequestStream sends a HandshakeFrame to the server's control stream to request a new data stream.
 // If the handshake is successful, a DataStream will be returned by the AcceptStream() method.
 func (cs *ClientControlStream) RequestStream(hf *frame.HandshakeFrame) error {
-	err := cs.stream.WriteFrame(hf)
+	// the handshake is recorded before it is sent, the server may open the stream before WriteFrame returns.
+	cs.mu.Lock()
+	cs.handshakeFrames[hf.ID] = hf
+	cs.mu.Unlock()
 
-	if err != nil {
+	if err := cs.stream.WriteFrame(hf); err != nil {
+		cs.mu.Lock()
+		delete(cs.handshakeFrames, hf.ID)
+		cs.mu.Unlock()
 		return err
 	}
 
+	return nil
+}
+
+// ConnectionState returns the state of the underlying QUIC connection,
+// it returns false if the connection is not a QUIC connection.
+func (cs *ClientControlStream) ConnectionState() (quic.ConnectionState, bool) {
+	qc, ok := cs.conn.(*QuicConnection)
+	if !ok {
+		return quic.ConnectionState{}, false
+	}
+	return qc.ConnectionState(), true
+}
+
+// UpdateMetadata sends a MetadataUpdateFrame to the server's control stream to merge md into
+// the metadata of the DataStream with the streamID.
+func (cs *ClientControlStream) UpdateMetadata(streamID string, md []byte) error {
+	return cs.stream.WriteFrame(&frame.MetadataUpdateFrame{
+		StreamID: streamID,
+		Metadata: md,
+	})
+}
+
+// ObserveTag sends an ObserveTagFrame to the server's control stream, the DataStream with the streamID
+// receives the DataFrames of the tag since then.
+func (cs *ClientControlStream) ObserveTag(streamID string, tag frame.Tag) error {
+	return cs.stream.WriteFrame(&frame.ObserveTagFrame{StreamID: streamID, Tag: tag})
+}
+
+// UnobserveTag sends an UnobserveTagFrame to the server's control stream, the DataStream with the streamID
+// does not receive the DataFrames of the tag since then.
+func (cs *ClientControlStream) UnobserveTag(streamID string, tag frame.Tag) error {
+	return cs.stream.WriteFrame(&frame.UnobserveTagFrame{StreamID: streamID, Tag: tag})
+}
+
+// CloseStream sends a CloseStreamFrame to the server's control stream to close the DataStream with the streamID,
+// the server logs the code and the reason of the close.
+func (cs *ClientControlStream) CloseStream(streamID string, code frame.CloseCode, reason string) error {
+	return cs.stream.WriteFrame(&frame.CloseStreamFrame{
+		StreamID: streamID,
+		Reason:   reason,
+		Code:     code,
+	})
+}
+
+// FlowControl sends a FlowControlFrame to the server's control stream,
+// the server forwards it to the sources.
+func (cs *ClientControlStream) FlowControl(f *frame.FlowControlFrame) error {
+	return cs.stream.WriteFrame(f)
+}
+
+// StreamReady sends a StreamReadyFrame to the server's control stream, it tells the server that
+// the handler of the stream is installed.
+func (cs *ClientControlStream) StreamReady(streamID string) error {
+	return cs.stream.WriteFrame(&frame.StreamReadyFrame{StreamID: streamID})
+}
+
+// ReportStats sends a StatsReportFrame to the server's control stream.
+func (cs *ClientControlStream) ReportStats(f *frame.StatsReportFrame) error {
+	return cs.stream.WriteFrame(f)
+}
+
+// Ping sends a PingFrame to the server's control stream and waits for the matching PongFrame,
+// it returns the round-trip time of the PingFrame.
+func (cs *ClientControlStream) Ping(ctx context.Context) (time.Duration, error) {
+	nonce := []byte(id.New())
+
+	pong := make(chan struct{})
 	cs.mu.Lock()
-	cs.handshakeFrames[hf.ID] = hf
+	cs.pings[string(nonce)] = pong
 	cs.mu.Unlock()
 
-	return nil
+	defer func() {
+		cs.mu.Lock()
+		delete(cs.pings, string(nonce))
+		cs.mu.Unlock()
+	}()
+
+	start := time.Now()
+	if err := cs.stream.WriteFrame(&frame.PingFrame{Nonce: nonce}); err != nil {
+		return 0, err
+	}
+
+	select {
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	case <-cs.ctx.Done():
+		return 0, ErrControllerClosed
+	case <-pong:
+		return time.Since(start), nil
+	}
+}
+
+// handlePongFrame wakes up the Ping that waits for the PongFrame,
+// the PongFrame that does not match any PingFrame will be ignored.
+func (cs *ClientControlStream) handlePongFrame(f *frame.PongFrame) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	pong, ok := cs.pings[string(f.Nonce)]
+	if !ok {
+		cs.logger.Debug("control stream read unmatched pong", "nonce", string(f.Nonce))
+		return
+	}
+	delete(cs.pings, string(f.Nonce))
+	close(pong)
+}
+
+// HealthCheck sends a HealthCheckFrame to the server's control stream after the authentication,
+// and returns the health status replied by the server.
+func (cs *ClientControlStream) HealthCheck(ctx context.Context) (frame.HealthStatus, error) {
+	ch := make(chan frame.HealthStatus, 1)
+	cs.mu.Lock()
+	cs.healthChecks = append(cs.healthChecks, ch)
+	cs.mu.Unlock()
+
+	defer func() {
+		cs.mu.Lock()
+		for i, c := range cs.healthChecks {
+			if c == ch {
+				cs.healthChecks = append(cs.healthChecks[:i], cs.healthChecks[i+1:]...)
+				break
+			}
+		}
+		cs.mu.Unlock()
+	}()
+
+	if err := cs.stream.WriteFrame(&frame.HealthCheckFrame{}); err != nil {
+		return 0, err
+	}
+
+	select {
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	case <-cs.ctx.Done():
+		return 0, ErrControllerClosed
+	case status := <-ch:
+		return status, nil
+	}
+}
+
+// handleHealthCheckAckFrame replies the HealthChecks those wait for the HealthCheckAckFrame.
+func (cs *ClientControlStream) handleHealthCheckAckFrame(f *frame.HealthCheckAckFrame) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	for _, ch := range cs.healthChecks {
+		ch <- f.Status
+	}
+	cs.healthChecks = nil
+}
+
+// ProbeHealth connects to the server at addr and checks its health before the authentication, the server must
+// accept the health checks before the authentication, see WithServerHealthCheckBeforeAuth. The connection is
+// closed after the check, so it is cheap enough for the load balancers to probe the server.
+func ProbeHealth(
+	ctx context.Context, addr string,
+	tlsConfig *tls.Config, quicConfig *quic.Config,
+	codec frame.Codec, packetReadWriter frame.PacketReadWriter,
+) (frame.HealthStatus, error) {
+	conn, err := quic.DialAddr(ctx, addr, tlsConfig, quicConfig)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.CloseWithError(0, "health checked")
+
+	stream0, err := conn.OpenStream()
+	if err != nil {
+		return 0, err
+	}
+	stream := NewFrameStream(stream0, codec, packetReadWriter)
+
+	if err := stream.WriteFrame(&frame.HealthCheckFrame{}); err != nil {
+		return 0, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = stream.SetReadDeadline(deadline)
+	}
+	f, err := stream.ReadFrame()
+	if err != nil {
+		// the draining server rejects the new connections.
+		qerr := new(quic.ApplicationError)
+		if errors.As(err, &qerr) && yerr.Parse(qerr.ErrorCode) == yerr.ErrorCodeRejected {
+			return frame.HealthDraining, nil
+		}
+		return 0, err
+	}
+	ack, ok := f.(*frame.HealthCheckAckFrame)
+	if !ok {
+		return 0, fmt.Errorf("yomo: read unexpected frame during health check, frame read: %s", f.Type().String())
+	}
+	return ack.Status, nil
 }
 
 // ErrControllerClosed return is the controller is closed.
@@ -375,6 +886,7 @@ func (cs *ClientControlStream) AcceptStream(ctx context.Context) (DataStream, er
 		return nil, ErrHandshakeRejected{
 			Message:  reject.Message,
 			StreamID: reject.ID,
+			Reason:   reject.Reason,
 		}
 	case result, ok := <-cs.acceptStreamResultChan:
 		if !ok {
@@ -413,12 +925,13 @@ func (cs *ClientControlStream) acceptStream(ctx context.Context) (DataStream, er
 		return nil, err
 	}
 
-	fs := NewFrameStream(quicStream, cs.codec, cs.packetReadWriter)
+	fs := NewFrameStream(quicStream, cs.codec, cs.packetReadWriter, cs.frameStreamOptions...)
 
-	streamID, err := ackDataStream(fs)
+	ack, err := ackDataStream(fs)
 	if err != nil {
 		return nil, err
 	}
+	streamID := ack.StreamID
 
 	cs.mu.Lock()
 	defer cs.mu.Unlock()
@@ -436,7 +949,12 @@ func (cs *ClientControlStream) acceptStream(ctx context.Context) (DataStream, er
 		return nil, err
 	}
 
-	return newDataStream(f.Name, f.ID, StreamType(f.StreamType), md, f.ObserveDataTags, fs, nil, cs.signalChan), nil
+	// the server agrees on the checksums, the packets following the ack carry them.
+	if ack.Checksum && checksumRequested(f.Metadata) {
+		fs.setPacketReadWriter(frame.ChecksumPacketReadWriter(cs.packetReadWriter))
+	}
+
+	return newDataStream(f.Name, f.ID, f.InstanceKey, StreamType(f.StreamType), md, f.ObserveDataTags, fs, nil, cs.signalChan, SystemClock), nil
 }
 
 // CloseWithError closes the client-side control stream.
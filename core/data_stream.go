@@ -3,11 +3,16 @@ package core
 import (
 	"context"
 	"errors"
+	"fmt"
 	"io"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/quic-go/quic-go"
 	"github.com/yomorun/yomo/core/frame"
 	"github.com/yomorun/yomo/core/metadata"
+	"github.com/yomorun/yomo/core/yerr"
 )
 
 // StreamInfo holds the information of DataStream.
@@ -32,104 +37,261 @@ type DataStream interface {
 	Context() context.Context
 	StreamInfo
 	frame.ReadWriteCloser
+	// WriteFrames writes the frames contiguously under a single lock acquisition,
+	// it is cheaper than writing them one by one with WriteFrame.
+	WriteFrames(frames ...frame.Frame) error
+	// LastActivity returns the time the stream last read or wrote a frame, it is the time the stream
+	// was opened if there is no frame yet. The idle timeout of the server is based on it.
+	LastActivity() time.Time
 }
 
 type dataStream struct {
 	name       string
 	id         string
 	streamType StreamType
-	metadata   metadata.M
+	// instanceKey is the instance key of the handshake, see frame.HandshakeFrame.InstanceKey.
+	instanceKey string
+	stream      *FrameStream
+
+	// observedMu protects observed, the observed is replaced rather than modified once it is updated.
+	observedMu sync.RWMutex
 	observed   []frame.Tag
-	stream     *FrameStream
+
+	// mdMu protects metadata, the metadata is replaced rather than modified once it is updated.
+	mdMu     sync.RWMutex
+	metadata metadata.M
 
 	serverController *ServerControlStream
 	clientSignalChan <-chan frame.Frame
+
+	clock Clock
+	// lastActivity is the unix nano time of the last frame read or written.
+	lastActivity atomic.Int64
+	// lastReadAt is the unix nano time of the last frame read.
+	lastReadAt atomic.Int64
+	// migrated is set when the stream is taken over by a stream with the same id on another connection.
+	migrated atomic.Bool
+	// ready is set once the StreamFunction sends the StreamReadyFrame.
+	ready atomic.Bool
 }
 
 // newDataStream constructures dataStream.
 func newDataStream(
 	name string,
 	id string,
+	instanceKey string,
 	streamType StreamType,
 	metadata metadata.M,
 	observed []frame.Tag,
 	stream *FrameStream,
 	serverController *ServerControlStream,
 	clientSignalChan <-chan frame.Frame,
+	clock Clock,
 ) DataStream {
-	return &dataStream{
-		name:       name,
-		id:         id,
-		streamType: streamType,
-		metadata:   metadata,
-		observed:   observed,
-		stream:     stream,
+	ds := &dataStream{
+		name:        name,
+		id:          id,
+		instanceKey: instanceKey,
+		streamType:  streamType,
+		metadata:    metadata,
+		observed:    observed,
+		stream:      stream,
 
 		serverController: serverController,
 		clientSignalChan: clientSignalChan,
+		clock:            clock,
 	}
+	ds.touch()
+	ds.touchRead()
+
+	return ds
 }
 
 // DataStream implements.
-func (s *dataStream) Context() context.Context     { return s.stream.Context() }
-func (s *dataStream) ID() string                   { return s.id }
-func (s *dataStream) Name() string                 { return s.name }
-func (s *dataStream) Metadata() metadata.M         { return s.metadata }
-func (s *dataStream) StreamType() StreamType       { return s.streamType }
-func (s *dataStream) ObserveDataTags() []frame.Tag { return s.observed }
-func (s *dataStream) Close() error                 { return s.stream.Close() }
+func (s *dataStream) Context() context.Context { return s.stream.Context() }
+func (s *dataStream) ID() string               { return s.id }
+func (s *dataStream) Name() string             { return s.name }
+func (s *dataStream) StreamType() StreamType   { return s.streamType }
+func (s *dataStream) Close() error             { return s.stream.Close() }
+
+func (s *dataStream) ObserveDataTags() []frame.Tag {
+	s.observedMu.RLock()
+	defer s.observedMu.RUnlock()
+
+	return s.observed
+}
+
+// observeTag adds the tag to the observed data tags of the stream, it reports whether the tags are changed.
+func (s *dataStream) observeTag(tag frame.Tag) bool {
+	s.observedMu.Lock()
+	defer s.observedMu.Unlock()
+
+	for _, t := range s.observed {
+		if t == tag {
+			return false
+		}
+	}
+	observed := make([]frame.Tag, len(s.observed), len(s.observed)+1)
+	copy(observed, s.observed)
+	s.observed = append(observed, tag)
+
+	return true
+}
+
+// unobserveTag removes the tag from the observed data tags of the stream, it reports whether the tags are changed.
+func (s *dataStream) unobserveTag(tag frame.Tag) bool {
+	s.observedMu.Lock()
+	defer s.observedMu.Unlock()
+
+	observed := make([]frame.Tag, 0, len(s.observed))
+	for _, t := range s.observed {
+		if t != tag {
+			observed = append(observed, t)
+		}
+	}
+	if len(observed) == len(s.observed) {
+		return false
+	}
+	s.observed = observed
+
+	return true
+}
+
+func (s *dataStream) Metadata() metadata.M {
+	s.mdMu.RLock()
+	defer s.mdMu.RUnlock()
+
+	return s.metadata
+}
+
+// updateMetadata merges md into the metadata of the stream, then the base is merged over it,
+// so the keys of the base can not be changed by md. The metadata is not changed if check fails on the merged one.
+func (s *dataStream) updateMetadata(md, base metadata.M, check func(metadata.M) error) error {
+	s.mdMu.Lock()
+	defer s.mdMu.Unlock()
+
+	merged := s.metadata.Clone()
+	if merged == nil {
+		merged = metadata.M{}
+	}
+	md.Range(func(k, v string) bool {
+		merged.Set(k, v)
+		return true
+	})
+	base.Range(func(k, v string) bool {
+		merged.Set(k, v)
+		return true
+	})
+	if err := check(merged); err != nil {
+		return err
+	}
+	s.metadata = merged
+	return nil
+}
+
+// touch records the frame activity of the stream.
+func (s *dataStream) touch() { s.lastActivity.Store(s.clock.Now().UnixNano()) }
+
+// touchRead records the frame read of the stream.
+func (s *dataStream) touchRead() { s.lastReadAt.Store(s.clock.Now().UnixNano()) }
+
+// lastRead returns the time of the last frame read.
+func (s *dataStream) lastRead() time.Time { return time.Unix(0, s.lastReadAt.Load()) }
+
+// LastActivity returns the time of the last frame read or written.
+func (s *dataStream) LastActivity() time.Time { return time.Unix(0, s.lastActivity.Load()) }
 
 func (s *dataStream) WriteFrame(f frame.Frame) error {
 	if err := readErrorFromController(s.stream, s.clientSignalChan); err != nil {
 		return err
 	}
-	return s.stream.WriteFrame(f)
+	if err := s.stream.WriteFrame(f); err != nil {
+		return err
+	}
+	s.touch()
+	return nil
+}
+
+// WriteFrames writes the frames like WriteFrame, but contiguously, see FrameStream.WriteFrames.
+func (s *dataStream) WriteFrames(frames ...frame.Frame) error {
+	if err := readErrorFromController(s.stream, s.clientSignalChan); err != nil {
+		return err
+	}
+	if err := s.stream.WriteFrames(frames...); err != nil {
+		return err
+	}
+	s.touch()
+	return nil
+}
+
+// WriteWithContext writes the frame like WriteFrame, but gives up once the ctx is done,
+// see FrameStream.WriteWithContext.
+func (s *dataStream) WriteWithContext(ctx context.Context, f frame.Frame) error {
+	if err := readErrorFromController(s.stream, s.clientSignalChan); err != nil {
+		return err
+	}
+	if err := s.stream.WriteWithContext(ctx, f); err != nil {
+		return err
+	}
+	s.touch()
+	return nil
 }
+
+// releaseFrame releases the last frame read, see FrameStream.Release.
+func (s *dataStream) releaseFrame() { s.stream.Release() }
+
+// signalGracePeriod is how long the read error waits for the signal of the control stream, the server sends
+// the signal before closing the stream, but the streams are independent, the signal may arrive after the close.
+const signalGracePeriod = 100 * time.Millisecond
+
 func (s *dataStream) ReadFrame() (frame.Frame, error) {
 	type outCh struct {
 		frame frame.Frame
 		err   error
 	}
 
-	out := make(chan outCh)
-	go func() {
-		for signal := range s.clientSignalChan {
-			switch ff := signal.(type) {
-			case *frame.GoawayFrame:
-				_ = s.stream.Close()
-				out <- outCh{
-					frame: nil,
-					err:   NewErrControllSignal(ff.Message),
-				}
-				return
-			case *frame.RejectedFrame:
-				_ = s.stream.Close()
-				out <- outCh{
-					frame: nil,
-					err:   NewErrControllSignal(ff.Message),
-				}
-				return
-			}
-		}
-	}()
+	// the channel is buffered, so the read goroutine exits even if the signal wins.
+	out := make(chan outCh, 1)
 	go func() {
 		f, err := s.stream.ReadFrame()
-		// return EOF if server-side control stream has been closed.
-		if IsYomoCloseError(err) {
-			out <- outCh{
-				frame: nil,
-				err:   io.EOF,
-			}
-			return
-		}
-		out <- outCh{
-			frame: f,
-			err:   err,
-		}
+		out <- outCh{frame: f, err: err}
 	}()
-	result := <-out
 
-	return result.frame, result.err
+	var result outCh
+	select {
+	case signal := <-s.clientSignalChan:
+		if err := signalError(s.stream, signal); err != nil {
+			return nil, err
+		}
+		result = <-out
+	case result = <-out:
+	}
+
+	err := result.err
+	if err == nil {
+		s.touch()
+		s.touchRead()
+		return result.frame, nil
+	}
+	// the signal that the server sends before closing the stream is preferred to the error of the close.
+	if s.clientSignalChan != nil {
+		select {
+		case signal := <-s.clientSignalChan:
+			if err := signalError(s.stream, signal); err != nil {
+				return nil, err
+			}
+		case <-s.clock.After(signalGracePeriod):
+		}
+	}
+	// the connection is rotated, the client reconnects.
+	if ye, ok := yerr.FromError(err); ok && ye.ErrorCode() == yerr.ErrorCodeRotated {
+		return nil, newGoawaySignal(&frame.GoawayFrame{Message: ye.Error(), Reconnect: true})
+	}
+	// return EOF if server-side control stream has been closed.
+	if IsYomoCloseError(err) {
+		return nil, io.EOF
+	}
+	return nil, err
 }
 
 const (
@@ -145,6 +307,11 @@ const (
 	// StreamTypeStreamFunction is stream type "Stream Function".
 	// "Stream Function" handles data from source.
 	StreamTypeStreamFunction StreamType = 0x5D
+
+	// StreamTypeInspector is stream type "Inspector".
+	// "Inspector" receives the copies of the DataFrames of its observed tags, such as for monitoring,
+	// it is not a processor, the frames written by it are ignored by routing.
+	StreamTypeInspector StreamType = 0x5C
 )
 
 // StreamType represents the stream type.
@@ -154,17 +321,24 @@ var streamTypeStringMap = map[StreamType]string{
 	StreamTypeSource:         "Source",
 	StreamTypeUpstreamZipper: "UpstreamZipper",
 	StreamTypeStreamFunction: "StreamFunction",
+	StreamTypeInspector:      "Inspector",
 }
 
-// String returns string for StreamType.
+// String returns string for StreamType, it is "UnknownStreamType(n)" for the unknown StreamType.
 func (c StreamType) String() string {
 	str, ok := streamTypeStringMap[c]
 	if !ok {
-		return "Unknown"
+		return fmt.Sprintf("UnknownStreamType(%d)", byte(c))
 	}
 	return str
 }
 
+// Valid reports whether the StreamType is one of the known stream types.
+func (c StreamType) Valid() bool {
+	_, ok := streamTypeStringMap[c]
+	return ok
+}
+
 // ContextReadWriteCloser represents a stream which its lifecycle managed by context.
 // The context should be closed when the stream is closed.
 type ContextReadWriteCloser interface {
@@ -177,6 +351,11 @@ type ContextReadWriteCloser interface {
 // ErrControllSignal represents the error of controll signal.
 type ErrControllSignal struct {
 	errString string
+	goaway    bool
+	reconnect bool
+	reason    frame.RejectCode
+	closed    bool
+	closeCode frame.CloseCode
 }
 
 // NewErrControllSignal constructs ErrControllSignal.
@@ -186,31 +365,93 @@ func NewErrControllSignal(errString string) *ErrControllSignal {
 	}
 }
 
+// newGoawaySignal constructs ErrControllSignal that caused by GoawayFrame.
+func newGoawaySignal(f *frame.GoawayFrame) *ErrControllSignal {
+	return &ErrControllSignal{
+		errString: f.Message,
+		goaway:    true,
+		reconnect: f.Reconnect,
+	}
+}
+
+// newRejectedSignal constructs ErrControllSignal that caused by RejectedFrame.
+func newRejectedSignal(f *frame.RejectedFrame) *ErrControllSignal {
+	return &ErrControllSignal{
+		errString: f.Message,
+		reason:    f.Reason,
+	}
+}
+
+// newCloseStreamSignal constructs ErrControllSignal that caused by CloseStreamFrame.
+func newCloseStreamSignal(f *frame.CloseStreamFrame) *ErrControllSignal {
+	return &ErrControllSignal{
+		errString: f.Reason,
+		closed:    true,
+		closeCode: f.Code,
+	}
+}
+
 // Error implements error interface.
 func (e *ErrControllSignal) Error() string {
 	return e.errString
 }
 
+// Reason returns the reject code if the signal is caused by RejectedFrame.
+func (e *ErrControllSignal) Reason() frame.RejectCode {
+	return e.reason
+}
+
+// IsGoaway reports whether the signal is caused by GoawayFrame, that means the server evicts the connection.
+func (e *ErrControllSignal) IsGoaway() bool {
+	return e.goaway
+}
+
+// IsReconnect reports whether the server asks the client to reconnect by the GoawayFrame,
+// that means the connection is rotated for reaching its max lifetime.
+func (e *ErrControllSignal) IsReconnect() bool {
+	return e.reconnect
+}
+
+// CloseCode returns the close code if the signal is caused by CloseStreamFrame,
+// that means the server closes the data stream, the ok is false otherwise.
+func (e *ErrControllSignal) CloseCode() (code frame.CloseCode, ok bool) {
+	return e.closeCode, e.closed
+}
+
 // readErrorFromController try to read error from controller,if there readan error
 // from the controller, the stream read function will return the error and the stream will be closed.
 func readErrorFromController(closer io.Closer, ch <-chan frame.Frame) error {
 	select {
 	case ex := <-ch:
-		switch ff := ex.(type) {
-		case *frame.GoawayFrame:
-			_ = closer.Close()
-			return NewErrControllSignal(ff.Message)
-		case *frame.RejectedFrame:
-			_ = closer.Close()
-			return NewErrControllSignal(ff.Message)
-		}
+		return signalError(closer, ex)
 	default:
 	}
 	return nil
 }
 
-// IsYomoCloseError checks if the error is yomo close error.
+// signalError closes the stream and returns the ErrControllSignal of the signal frame,
+// it returns nil if the frame is not a signal.
+func signalError(closer io.Closer, signal frame.Frame) error {
+	switch ff := signal.(type) {
+	case *frame.GoawayFrame:
+		_ = closer.Close()
+		return newGoawaySignal(ff)
+	case *frame.RejectedFrame:
+		_ = closer.Close()
+		return newRejectedSignal(ff)
+	case *frame.CloseStreamFrame:
+		_ = closer.Close()
+		return newCloseStreamSignal(ff)
+	}
+	return nil
+}
+
+// IsYomoCloseError checks if the error is yomo close error, that is, the connection is closed by yomo
+// with YomoCloseErrorCode or a code in the yerr registry.
 func IsYomoCloseError(err error) bool {
 	qerr := new(quic.ApplicationError)
-	return errors.As(err, &qerr) && qerr.ErrorCode == YomoCloseErrorCode
+	if !errors.As(err, &qerr) {
+		return false
+	}
+	return qerr.ErrorCode == YomoCloseErrorCode || yerr.Parse(qerr.ErrorCode).Registered()
 }
@@ -6,6 +6,7 @@ import (
 	"io"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/yomorun/yomo/core/frame"
@@ -28,7 +29,7 @@ func TestDataStream(t *testing.T) {
 	// create frame stream.
 	frameStream := NewFrameStream(mockStream, &byteCodec{}, &bytePacketReadWriter{})
 
-	stream := newDataStream(name, id, styp, md, observed, frameStream, nil, nil)
+	stream := newDataStream(name, id, "", styp, md, observed, frameStream, nil, nil, SystemClock)
 
 	t.Run("StreamInfo", func(t *testing.T) {
 		assert.Equal(t, id, stream.ID())
@@ -93,11 +94,50 @@ func TestDataStream(t *testing.T) {
 	})
 }
 
+func TestDataStreamLastActivity(t *testing.T) {
+	start := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := NewManualClock(start)
+
+	frameStream := NewFrameStream(newMemByteStream([]byte("a")), &byteCodec{}, &bytePacketReadWriter{})
+	stream := newDataStream("test-data-stream", "123456", "", StreamTypeSource, nil, nil, frameStream, nil, nil, clock)
+
+	// it is the time the stream opened before any frame.
+	assert.Equal(t, start, stream.LastActivity().UTC())
+
+	clock.Advance(time.Second)
+	_, err := stream.ReadFrame()
+	assert.NoError(t, err)
+	assert.Equal(t, start.Add(time.Second), stream.LastActivity().UTC())
+
+	clock.Advance(time.Second)
+	assert.NoError(t, stream.WriteFrame(&frame.DataFrame{Payload: []byte("b")}))
+	assert.Equal(t, start.Add(2*time.Second), stream.LastActivity().UTC())
+
+	// the failed write is not an activity.
+	clock.Advance(time.Second)
+	assert.NoError(t, stream.Close())
+	assert.Error(t, stream.WriteFrame(&frame.DataFrame{Payload: []byte("c")}))
+	assert.Equal(t, start.Add(2*time.Second), stream.LastActivity().UTC())
+}
+
 func TestStreamTypeString(t *testing.T) {
 	assert.Equal(t, StreamTypeSource.String(), "Source")
 	assert.Equal(t, StreamTypeStreamFunction.String(), "StreamFunction")
 	assert.Equal(t, StreamTypeUpstreamZipper.String(), "UpstreamZipper")
-	assert.Equal(t, StreamType(0).String(), "Unknown")
+	assert.Equal(t, StreamTypeInspector.String(), "Inspector")
+	assert.Equal(t, StreamType(0).String(), "UnknownStreamType(0)")
+	assert.Equal(t, StreamType(0x5B).String(), "UnknownStreamType(91)")
+	assert.Equal(t, StreamType(0x60).String(), "UnknownStreamType(96)")
+	assert.Equal(t, StreamType(0xFF).String(), "UnknownStreamType(255)")
+}
+
+func TestStreamTypeValid(t *testing.T) {
+	for _, st := range []StreamType{StreamTypeSource, StreamTypeUpstreamZipper, StreamTypeStreamFunction, StreamTypeInspector} {
+		assert.True(t, st.Valid(), st.String())
+	}
+	for _, st := range []StreamType{0x00, 0x5B, 0x60, 0xFF} {
+		assert.False(t, st.Valid(), st.String())
+	}
 }
 
 // byteFrame implements frame.Frame interface for unittest.
@@ -0,0 +1,58 @@
+package core
+
+import (
+	"strconv"
+	"sync/atomic"
+
+	"github.com/yomorun/yomo/core/frame"
+)
+
+// DeadLetterReasonNoObserver is the dead-letter reason of the DataFrames that no stream observes.
+const DeadLetterReasonNoObserver = "no observer"
+
+// deadLetterLogEvery samples the logs of the dead-lettered DataFrames, the first one and every
+// deadLetterLogEvery-th one are logged, so that the dead letters do not flood the logs.
+const deadLetterLogEvery = 100
+
+// deadLetter routes the DataFrames those no stream processes to the dead-letter tag,
+// the original tag and the reason are carried in the metadata.
+type deadLetter struct {
+	enabled bool
+	tag     frame.Tag
+	count   atomic.Int64
+}
+
+// newDeadLetter returns the deadLetter, the dead letters are dropped if the tag is nil.
+func newDeadLetter(tag *frame.Tag) *deadLetter {
+	if tag == nil {
+		return &deadLetter{}
+	}
+	return &deadLetter{enabled: true, tag: *tag}
+}
+
+// reroute moves the DataFrame of the context to the dead-letter tag, it reports false if the DataFrame
+// should be dropped, that is, the dead-letter tag is not set or the DataFrame is a dead letter already.
+func (d *deadLetter) reroute(c *Context, reason string) bool {
+	n := d.count.Add(1)
+	if n == 1 || n%deadLetterLogEvery == 0 {
+		c.Logger.Warn("dead letter", "data_tag", c.Frame.Tag, "reason", reason, "dead_letter_num", n)
+	}
+	if !d.enabled || c.Frame.Tag == d.tag {
+		return false
+	}
+	c.FrameMetadata.Set(MetadataDeadLetterTagKey, strconv.FormatUint(uint64(c.Frame.Tag), 10))
+	c.FrameMetadata.Set(MetadataDeadLetterReasonKey, reason)
+	c.Frame.Tag = d.tag
+
+	return true
+}
+
+// observed reports whether the DataFrame of the context is delivered to other than the stream functions,
+// that is, it is backflowed to the source or broadcast to the downstreams.
+func (s *Server) observed(c *Context) bool {
+	if c.DataStream.StreamType() == StreamTypeSource && GetBroadcastFromMetadata(c.FrameMetadata) && len(s.downstreams) > 0 {
+		return true
+	}
+	sources, err := s.connector.Find(backflowFindStreamFunc(c))
+	return err != nil || len(sources) > 0
+}
@@ -0,0 +1,46 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yomorun/yomo/core/frame"
+	"github.com/yomorun/yomo/core/metadata"
+)
+
+func TestDeadLetter(t *testing.T) {
+	newContext := func(tag frame.Tag) *Context {
+		return &Context{
+			Frame:         &frame.DataFrame{Tag: tag},
+			FrameMetadata: metadata.M{},
+			Logger:        discardingLogger,
+		}
+	}
+
+	t.Run("reroute to dead-letter tag", func(t *testing.T) {
+		dlq := frame.Tag(0xE001)
+		d := newDeadLetter(&dlq)
+
+		c := newContext(7)
+		assert.True(t, d.reroute(c, DeadLetterReasonNoObserver))
+		assert.Equal(t, dlq, c.Frame.Tag)
+
+		tag, _ := c.FrameMetadata.Get(MetadataDeadLetterTagKey)
+		assert.Equal(t, "7", tag)
+		reason, _ := c.FrameMetadata.Get(MetadataDeadLetterReasonKey)
+		assert.Equal(t, DeadLetterReasonNoObserver, reason)
+
+		// the dead letter of the dead-letter tag is dropped.
+		assert.False(t, d.reroute(newContext(dlq), DeadLetterReasonNoObserver))
+		assert.Equal(t, int64(2), d.count.Load())
+	})
+
+	t.Run("dropped without dead-letter tag", func(t *testing.T) {
+		d := newDeadLetter(nil)
+
+		c := newContext(7)
+		assert.False(t, d.reroute(c, "handler error"))
+		assert.Equal(t, frame.Tag(7), c.Frame.Tag)
+		assert.Equal(t, int64(1), d.count.Load())
+	})
+}
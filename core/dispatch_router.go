@@ -0,0 +1,69 @@
+package core
+
+import (
+	"hash/fnv"
+
+	"github.com/yomorun/yomo/core/frame"
+	"github.com/yomorun/yomo/core/metadata"
+)
+
+// DispatchRouter chooses the streams that a DataFrame is dispatched to, among the candidates
+// those observe the tag of the DataFrame. The candidates are sorted by the stream id.
+// It is invoked for every DataFrame, so it should be fast and safe for concurrent use.
+//
+// Unlike router.Router which maintains the observers of the tags, DispatchRouter implements
+// the dispatching strategies, such as load balancing the instances of a StreamFunction.
+type DispatchRouter interface {
+	Route(tag frame.Tag, md metadata.M, candidates []DataStream) []DataStream
+}
+
+// DispatchRouterFunc is an adapter to allow the use of ordinary functions as DispatchRouter.
+type DispatchRouterFunc func(tag frame.Tag, md metadata.M, candidates []DataStream) []DataStream
+
+// Route calls f(tag, md, candidates).
+func (f DispatchRouterFunc) Route(tag frame.Tag, md metadata.M, candidates []DataStream) []DataStream {
+	return f(tag, md, candidates)
+}
+
+// BroadcastRouter dispatches the DataFrame to all the candidates, it is the default DispatchRouter.
+var BroadcastRouter DispatchRouter = DispatchRouterFunc(
+	func(_ frame.Tag, _ metadata.M, candidates []DataStream) []DataStream { return candidates },
+)
+
+// AffinityRouter returns a DispatchRouter that dispatches the DataFrame to one of the candidates
+// by the hash of the metadata value of the key, so the DataFrames with the same value stick to
+// the same stream while the candidates are unchanged. The DataFrames without the key are broadcast.
+func AffinityRouter(key string) DispatchRouter {
+	return DispatchRouterFunc(func(_ frame.Tag, md metadata.M, candidates []DataStream) []DataStream {
+		value, ok := md.Get(key)
+		if !ok || len(candidates) == 0 {
+			return candidates
+		}
+		h := fnv.New32a()
+		_, _ = h.Write([]byte(value))
+
+		i := h.Sum32() % uint32(len(candidates))
+		return candidates[i : i+1]
+	})
+}
+
+// UnicastFallback decides what to do with the DataFrame unicast to the stream that is gone,
+// or that does not observe the tag of the DataFrame.
+type UnicastFallback int
+
+const (
+	// UnicastFallbackBroadcast dispatches the DataFrame by the DispatchRouter as if it is not unicast.
+	UnicastFallbackBroadcast UnicastFallback = iota
+	// UnicastFallbackDrop drops the DataFrame.
+	UnicastFallbackDrop
+)
+
+// unicast returns the candidate whose id is the target, it returns false if there is no such candidate.
+func unicast(target string, candidates []DataStream) ([]DataStream, bool) {
+	for i, stream := range candidates {
+		if stream.ID() == target {
+			return candidates[i : i+1], true
+		}
+	}
+	return nil, false
+}
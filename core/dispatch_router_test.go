@@ -0,0 +1,44 @@
+package core
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yomorun/yomo/core/metadata"
+)
+
+func TestAffinityRouter(t *testing.T) {
+	candidates := []DataStream{
+		mockDataStream("sfn-1", "sfn"),
+		mockDataStream("sfn-2", "sfn"),
+		mockDataStream("sfn-3", "sfn"),
+	}
+	r := AffinityRouter("user")
+
+	t.Run("sticky", func(t *testing.T) {
+		chosen := make(map[string]bool)
+		for i := 0; i < 100; i++ {
+			md := metadata.M{"user": "user-" + strconv.Itoa(i)}
+
+			got := r.Route(1, md, candidates)
+			assert.Len(t, got, 1)
+			// the DataFrames with the same value stick to the same stream.
+			for j := 0; j < 3; j++ {
+				assert.Equal(t, got, r.Route(1, md, candidates))
+			}
+			chosen[got[0].ID()] = true
+		}
+		// the values are spread over the candidates.
+		assert.Len(t, chosen, len(candidates))
+	})
+
+	t.Run("broadcast without the key", func(t *testing.T) {
+		assert.Equal(t, candidates, r.Route(1, metadata.M{"other": "v"}, candidates))
+		assert.Equal(t, candidates, BroadcastRouter.Route(1, metadata.M{"user": "v"}, candidates))
+	})
+
+	t.Run("no candidate", func(t *testing.T) {
+		assert.Empty(t, r.Route(1, metadata.M{"user": "v"}, nil))
+	})
+}
@@ -0,0 +1,129 @@
+package core
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/yomorun/yomo/core/frame"
+)
+
+// DefaultMaxPause is the default max time the writes of a tag are paused by a FlowControlFrame,
+// the writes are resumed automatically after it, so a dead StreamFunction can not pause the sources forever.
+const DefaultMaxPause = 30 * time.Second
+
+// flowController applies the FlowControlFrames received by the client to the writes of DataFrames.
+type flowController struct {
+	maxPause time.Duration
+	clock    Clock
+
+	mu   sync.Mutex
+	tags map[frame.Tag]*tagFlow
+}
+
+// tagFlow is the flow control state of a tag.
+type tagFlow struct {
+	// resumed is closed once the tag is resumed, it is nil if the tag is not paused.
+	resumed chan struct{}
+	// timer resumes the tag after the max pause time.
+	timer Timer
+	// bucket limits the rate of the tag, it is nil if the rate is unlimited.
+	bucket *tokenBucket
+}
+
+func newFlowController(maxPause time.Duration, clock Clock) *flowController {
+	if maxPause <= 0 {
+		maxPause = DefaultMaxPause
+	}
+	return &flowController{
+		maxPause: maxPause,
+		clock:    clock,
+		tags:     make(map[frame.Tag]*tagFlow),
+	}
+}
+
+// apply applies the FlowControlFrame.
+func (fc *flowController) apply(f *frame.FlowControlFrame) {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+
+	flow, ok := fc.tags[f.Tag]
+	if !ok {
+		flow = &tagFlow{}
+		fc.tags[f.Tag] = flow
+	}
+
+	if f.Pause {
+		if flow.resumed == nil {
+			flow.resumed = make(chan struct{})
+			flow.timer = fc.clock.AfterFunc(fc.maxPause, func() { fc.resume(f.Tag) })
+		}
+		return
+	}
+
+	fc.resumeLocked(flow)
+	if f.Rate == 0 {
+		flow.bucket = nil
+	} else {
+		flow.bucket = newTokenBucket(RateLimit{Rate: float64(f.Rate), Burst: int(f.Rate)})
+	}
+}
+
+func (fc *flowController) resume(tag frame.Tag) {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+
+	if flow, ok := fc.tags[tag]; ok {
+		fc.resumeLocked(flow)
+	}
+}
+
+func (fc *flowController) resumeLocked(flow *tagFlow) {
+	if flow.resumed == nil {
+		return
+	}
+	flow.timer.Stop()
+	close(flow.resumed)
+	flow.resumed = nil
+}
+
+// wait blocks until the DataFrame of the tag is allowed to be written or the ctx is done.
+func (fc *flowController) wait(ctx context.Context, tag frame.Tag) error {
+	fc.mu.Lock()
+	flow, ok := fc.tags[tag]
+	var (
+		resumed <-chan struct{}
+		bucket  *tokenBucket
+	)
+	if ok {
+		resumed, bucket = flow.resumed, flow.bucket
+	}
+	fc.mu.Unlock()
+
+	if resumed != nil {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-resumed:
+		}
+		// the rate may be changed by the resuming frame.
+		return fc.wait(ctx, tag)
+	}
+	if bucket == nil {
+		return nil
+	}
+
+	d := bucket.take(fc.clock.Now(), true)
+	if d <= 0 {
+		return nil
+	}
+	timer := fc.clock.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C():
+		return nil
+	}
+}
@@ -0,0 +1,75 @@
+package frame
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+)
+
+// ErrChecksumMismatch is returned by the PacketReadWriter created by ChecksumPacketReadWriter
+// when the checksum of a packet does not match its content, the packet is corrupted in transit.
+var ErrChecksumMismatch = errors.New("yomo: packet checksum mismatch")
+
+// ChecksumCRC32C is the checksum algorithm of ChecksumPacketReadWriter.
+const ChecksumCRC32C = "crc32c"
+
+var crc32cTable = crc32.MakeTable(crc32.Castagnoli)
+
+// ChecksumPacketReadWriter wraps the PacketReadWriter, every packet is followed by a 4-byte big-endian
+// CRC32 (Castagnoli) trailer of the packet, the trailer is computed on write and verified on read.
+// The packets returned by ReadPacket of the wrapped PacketReadWriter must be the ones passed to WritePacket.
+// Both sides of a stream must agree on it, see the Checksum of HandshakeAckFrame.
+func ChecksumPacketReadWriter(prw PacketReadWriter) PacketReadWriter {
+	return &checksumPacketReadWriter{prw: prw}
+}
+
+type checksumPacketReadWriter struct {
+	prw PacketReadWriter
+}
+
+func (c *checksumPacketReadWriter) ReadPacket(r io.Reader) (Type, []byte, error) {
+	ftyp, packet, err := c.prw.ReadPacket(r)
+	if err != nil {
+		return ftyp, packet, err
+	}
+	var trailer [4]byte
+	if _, err := io.ReadFull(r, trailer[:]); err != nil {
+		c.Free(packet)
+		return 0, nil, err
+	}
+	if crc32.Checksum(packet, crc32cTable) != binary.BigEndian.Uint32(trailer[:]) {
+		c.Free(packet)
+		return 0, nil, ErrChecksumMismatch
+	}
+	return ftyp, packet, nil
+}
+
+func (c *checksumPacketReadWriter) WritePacket(w io.Writer, ftyp Type, data []byte) error {
+	if err := c.prw.WritePacket(w, ftyp, data); err != nil {
+		return err
+	}
+	var trailer [4]byte
+	binary.BigEndian.PutUint32(trailer[:], crc32.Checksum(data, crc32cTable))
+	_, err := w.Write(trailer[:])
+	return err
+}
+
+// ForStream wraps the PacketReadWriter of the stream from the wrapped PacketReadWriter.
+func (c *checksumPacketReadWriter) ForStream() PacketReadWriter {
+	return &checksumPacketReadWriter{prw: PacketReadWriterForStream(c.prw)}
+}
+
+// Discard discards the packet read in part by the wrapped PacketReadWriter of the stream, if it keeps one.
+func (c *checksumPacketReadWriter) Discard() {
+	if discarder, ok := c.prw.(interface{ Discard() }); ok {
+		discarder.Discard()
+	}
+}
+
+// Free returns the packet to the wrapped PacketReadWriter if it is a PacketFreer.
+func (c *checksumPacketReadWriter) Free(packet []byte) {
+	if freer, ok := c.prw.(PacketFreer); ok {
+		freer.Free(packet)
+	}
+}
@@ -0,0 +1,129 @@
+package frame
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/yomorun/yomo/core/metadata"
+)
+
+// DefaultMaxPayloadSize is the default max size of the payload of the DataFrame built by NewDataFrame,
+// the larger data should be written in chunks.
+const DefaultMaxPayloadSize = 64 << 20
+
+var (
+	// ErrInvalidTag is returned by NewDataFrame if the tag is not allowed, see WithTagRange.
+	ErrInvalidTag = errors.New("frame: invalid tag")
+	// ErrPayloadTooLarge is returned by NewDataFrame if the payload exceeds the max size, see WithMaxPayloadSize.
+	ErrPayloadTooLarge = errors.New("frame: payload is too large")
+)
+
+// DataFrameOption is the option for NewDataFrame.
+type DataFrameOption func(*dataFrameOptions)
+
+type dataFrameOptions struct {
+	md             map[string]string
+	encodedMD      []byte
+	encoding       metadata.Encoding
+	ttl            uint8
+	seq            uint64
+	minTag         Tag
+	maxTag         Tag
+	maxPayloadSize int
+}
+
+// WithMetadata sets the metadata of the DataFrame, it is merged into the metadata set by WithEncodedMetadata.
+func WithMetadata(md map[string]string) DataFrameOption {
+	return func(o *dataFrameOptions) {
+		o.md = md
+	}
+}
+
+// WithEncodedMetadata sets the encoded metadata of the DataFrame, such as the one of the DataFrame being handled.
+func WithEncodedMetadata(md []byte) DataFrameOption {
+	return func(o *dataFrameOptions) {
+		o.encodedMD = md
+		o.encoding = metadata.EncodingOf(md)
+	}
+}
+
+// WithMetadataEncoding sets the encoding of the metadata, the default is the encoding of the metadata
+// set by WithEncodedMetadata, or metadata.EncodingMsgpack.
+func WithMetadataEncoding(enc metadata.Encoding) DataFrameOption {
+	return func(o *dataFrameOptions) {
+		o.encoding = enc
+	}
+}
+
+// WithTTL sets the TTL of the DataFrame, the default is DefaultTTL.
+func WithTTL(ttl uint8) DataFrameOption {
+	return func(o *dataFrameOptions) {
+		o.ttl = ttl
+	}
+}
+
+// WithSeq sets the sequence number of the DataFrame within its tag.
+func WithSeq(seq uint64) DataFrameOption {
+	return func(o *dataFrameOptions) {
+		o.seq = seq
+	}
+}
+
+// WithTagRange sets the range of the tags allowed, both ends are included.
+// By default, the tags other than zero and TagFirehose are allowed.
+func WithTagRange(min, max Tag) DataFrameOption {
+	return func(o *dataFrameOptions) {
+		o.minTag = min
+		o.maxTag = max
+	}
+}
+
+// WithMaxPayloadSize sets the max size of the payload, the default is DefaultMaxPayloadSize.
+func WithMaxPayloadSize(size int) DataFrameOption {
+	return func(o *dataFrameOptions) {
+		o.maxPayloadSize = size
+	}
+}
+
+// NewDataFrame returns a DataFrame of the tag and the payload, it is the validated way to build a DataFrame.
+// It returns ErrInvalidTag if the tag is not allowed, and ErrPayloadTooLarge if the payload exceeds the max size.
+func NewDataFrame(tag Tag, payload []byte, opts ...DataFrameOption) (*DataFrame, error) {
+	o := &dataFrameOptions{
+		ttl:            DefaultTTL,
+		minTag:         1,
+		maxTag:         TagFirehose - 1,
+		maxPayloadSize: DefaultMaxPayloadSize,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	if tag < o.minTag || tag > o.maxTag {
+		return nil, fmt.Errorf("%w: %#x is not in [%#x, %#x]", ErrInvalidTag, tag, o.minTag, o.maxTag)
+	}
+	if len(payload) > o.maxPayloadSize {
+		return nil, fmt.Errorf("%w: %d bytes exceeds %d bytes", ErrPayloadTooLarge, len(payload), o.maxPayloadSize)
+	}
+
+	md := o.encodedMD
+	if len(o.md) > 0 {
+		m, err := metadata.Decode(md)
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range o.md {
+			m.Set(k, v)
+		}
+		if md, err = m.EncodeWith(o.encoding); err != nil {
+			return nil, err
+		}
+	}
+
+	return &DataFrame{
+		Metadata: md,
+		Tag:      tag,
+		Payload:  payload,
+		Seq:      o.seq,
+		TTL:      o.ttl,
+	}, nil
+}
@@ -0,0 +1,73 @@
+package frame
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yomorun/yomo/core/metadata"
+)
+
+func TestNewDataFrame(t *testing.T) {
+	t.Run("validation", func(t *testing.T) {
+		_, err := NewDataFrame(0, []byte("hello"))
+		assert.ErrorIs(t, err, ErrInvalidTag)
+
+		_, err = NewDataFrame(TagFirehose, []byte("hello"))
+		assert.ErrorIs(t, err, ErrInvalidTag)
+
+		_, err = NewDataFrame(0x11, []byte("hello"), WithTagRange(0x20, 0x2F))
+		assert.ErrorIs(t, err, ErrInvalidTag)
+
+		_, err = NewDataFrame(0x21, []byte("hello"), WithMaxPayloadSize(4))
+		assert.ErrorIs(t, err, ErrPayloadTooLarge)
+	})
+
+	t.Run("metadata", func(t *testing.T) {
+		md, err := metadata.M{"a": "1", "b": "2"}.EncodeWith(metadata.EncodingJSON)
+		assert.NoError(t, err)
+
+		f, err := NewDataFrame(0x21, []byte("hello"), WithEncodedMetadata(md), WithMetadata(map[string]string{"b": "3"}), WithSeq(7))
+		assert.NoError(t, err)
+		assert.Equal(t, Tag(0x21), f.Tag)
+		assert.Equal(t, "hello", string(f.Payload))
+		assert.Equal(t, uint64(7), f.Seq)
+		assert.Equal(t, DefaultTTL, f.TTL)
+		assert.Equal(t, metadata.EncodingJSON, metadata.EncodingOf(f.Metadata))
+
+		m, err := metadata.Decode(f.Metadata)
+		assert.NoError(t, err)
+		assert.Equal(t, metadata.M{"a": "1", "b": "3"}, m)
+
+		// the encoded metadata is kept as is.
+		f, err = NewDataFrame(0x21, nil, WithEncodedMetadata(md))
+		assert.NoError(t, err)
+		assert.Equal(t, md, f.Metadata)
+
+		f, err = NewDataFrame(0x21, nil, WithTTL(3))
+		assert.NoError(t, err)
+		assert.Empty(t, f.Metadata)
+		assert.Equal(t, uint8(3), f.TTL)
+	})
+}
+
+func TestNamespacedTag(t *testing.T) {
+	tag := NamespacedTag(3, 0x33)
+	assert.Equal(t, Tag(0x03000033), tag)
+
+	ns, local := SplitTag(tag)
+	assert.Equal(t, Namespace(3), ns)
+	assert.Equal(t, Tag(0x33), local)
+
+	// the flat tags are in the default namespace.
+	assert.Equal(t, DefaultNamespace, TagNamespace(0x33))
+	assert.Equal(t, ReservedNamespace, TagNamespace(TagFirehose))
+
+	_, err := NewDataFrame(tag, []byte("hello"), WithTagNamespace(3))
+	assert.NoError(t, err)
+
+	_, err = NewDataFrame(0x33, []byte("hello"), WithTagNamespace(3))
+	assert.ErrorIs(t, err, ErrInvalidTag)
+
+	_, err = NewDataFrame(0, []byte("hello"), WithTagNamespace(DefaultNamespace))
+	assert.ErrorIs(t, err, ErrInvalidTag)
+}
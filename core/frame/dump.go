@@ -0,0 +1,81 @@
+package frame
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// DumpPayloadLimit is the max count of the payload bytes printed by Dump, the rest are truncated.
+var DumpPayloadLimit = 64
+
+// Dump decodes the raw packet to a human-readable description of the frame, it is useful for
+// triaging the captured packets and logging at debug level.
+// The packetReadWriter and codec are the ones the packet is written by, such as y3codec.PacketReadWriter()
+// and y3codec.Codec(). The packet of an unknown frame type is described as UnknownFrame with the raw bytes.
+func Dump(packet []byte, packetReadWriter PacketReadWriter, codec Codec) (string, error) {
+	ftyp, b, err := packetReadWriter.ReadPacket(bytes.NewReader(packet))
+	if err != nil {
+		return "", err
+	}
+
+	f, err := NewFrame(ftyp)
+	if err != nil {
+		return fmt.Sprintf("UnknownFrame(0x%02x)\n%s", byte(ftyp), hex.Dump(packet)), nil
+	}
+	if err := codec.Decode(b, f); err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	sb.WriteString(ftyp.String())
+	dumpFields(&sb, reflect.ValueOf(f).Elem(), "  ")
+
+	return sb.String(), nil
+}
+
+func dumpFields(sb *strings.Builder, v reflect.Value, indent string) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		fv := v.Field(i)
+		sb.WriteString("\n" + indent + field.Name + ": ")
+
+		switch {
+		case field.Name == "Metadata" && fv.Kind() == reflect.Slice && fv.Type().Elem().Kind() == reflect.Uint8:
+			dumpMetadata(sb, fv.Bytes(), indent+"  ")
+		case fv.Kind() == reflect.Slice && fv.Type().Elem().Kind() == reflect.Uint8:
+			dumpPayload(sb, fv.Bytes())
+		case fv.Kind() == reflect.Slice && fv.Type().Elem().Kind() == reflect.Pointer:
+			fmt.Fprintf(sb, "%d items", fv.Len())
+			for j := 0; j < fv.Len(); j++ {
+				fmt.Fprintf(sb, "\n%s  [%d]", indent, j)
+				dumpFields(sb, fv.Index(j).Elem(), indent+"    ")
+			}
+		default:
+			fmt.Fprintf(sb, "%v", fv.Interface())
+		}
+	}
+}
+
+func dumpMetadata(sb *strings.Builder, md []byte, indent string) {
+	fmt.Fprintf(sb, "%d bytes", len(md))
+	for _, line := range strings.Split(strings.TrimSuffix(hex.Dump(md), "\n"), "\n") {
+		if line != "" {
+			sb.WriteString("\n" + indent + line)
+		}
+	}
+}
+
+func dumpPayload(sb *strings.Builder, payload []byte) {
+	if len(payload) <= DumpPayloadLimit {
+		fmt.Fprintf(sb, "%x (%d bytes)", payload, len(payload))
+		return
+	}
+	fmt.Fprintf(sb, "%x... (%d bytes, truncated)", payload[:DumpPayloadLimit], len(payload))
+}
@@ -4,6 +4,10 @@ package frame
 import (
 	"fmt"
 	"io"
+	"sync"
+	"time"
+
+	"github.com/yomorun/yomo/core/metadata"
 )
 
 // Frame is the minimum unit required for Yomo to run.
@@ -18,6 +22,21 @@ import (
 //  6. HandshakeAckFrame
 //  7. RejectedFrame
 //  8. BackflowFrame
+//  9. GoawayFrame
+//  10. PingFrame
+//  11. PongFrame
+//  12. BatchDataFrame
+//  13. MetadataUpdateFrame
+//  14. FlowControlFrame
+//  15. ObserveTagFrame
+//  16. UnobserveTagFrame
+//  17. CloseStreamFrame
+//  18. ChunkedDataFrame
+//  19. StatsReportFrame
+//  20. StreamReadyFrame
+//  21. BackflowAckFrame
+//  22. HealthCheckFrame
+//  23. HealthCheckAckFrame
 //
 // Read frame comments to understand the role of the frame.
 type Frame interface {
@@ -37,21 +56,77 @@ type Type byte
 type AuthenticationFrame struct {
 	// AuthName.
 	AuthName string
-	// AuthPayload.
-	AuthPayload string
+	// AuthPayload is the credential, it is binary safe.
+	AuthPayload []byte
+	// Versions are the protocol versions the client supports, empty means Version1 only.
+	Versions []Version
+	// Labels are the labels of the connection defined by the operator, such as the region, the version
+	// or the canary, they are distinct from the metadata derived from the authentication.
+	Labels map[string]string
+	// AcceptDictionary tells the server that the client compresses the frames with a dictionary,
+	// DictionaryID is the version of the dictionary the client has, zero means none, see DictionaryCodec.
+	AcceptDictionary bool
+	DictionaryID     uint32
 }
 
+// AuthPayloadString returns the AuthPayload as a string, for the credentials in text.
+func (f *AuthenticationFrame) AuthPayloadString() string { return string(f.AuthPayload) }
+
 // Type returns the type of AuthenticationFrame.
 func (f *AuthenticationFrame) Type() Type { return TypeAuthenticationFrame }
 
 // AuthenticationAckFrame is used to confirm that the client is authorized to access the requested DataStream from
 // ControlStream, AuthenticationAckFrame is transmit on ControlStream.
 // If the client-side receives this frame, it indicates that authentication was successful.
-type AuthenticationAckFrame struct{}
+type AuthenticationAckFrame struct {
+	// Version is the protocol version the server picks, zero means Version1.
+	Version Version
+	// DictionaryID is the version of the dictionary the server compresses the frames with, zero means none.
+	// Dictionary is the encoded dictionary shipped to the client that accepts the dictionaries but has
+	// a different version, it is empty otherwise.
+	DictionaryID uint32
+	Dictionary   []byte
+}
 
 // Type returns the type of AuthenticationAckFrame.
 func (f *AuthenticationAckFrame) Type() Type { return TypeAuthenticationAckFrame }
 
+// Version is the version of the protocol, it decides how the frames are encoded and which features are enabled.
+// The client advertises the versions it supports in the AuthenticationFrame, the server picks the highest
+// version both sides support and confirms it in the AuthenticationAckFrame.
+type Version uint8
+
+const (
+	// Version1 is the first version of the protocol, the peers that do not negotiate the version speak it.
+	Version1 Version = 1
+)
+
+// SupportedVersions are the protocol versions this implementation supports.
+var SupportedVersions = []Version{Version1}
+
+// NegotiateVersion returns the highest version in both local and remote, it returns false if there is none.
+// The empty remote means the peer speaks Version1 only.
+func NegotiateVersion(local, remote []Version) (Version, bool) {
+	if len(remote) == 0 {
+		remote = []Version{Version1}
+	}
+	var (
+		picked Version
+		ok     bool
+	)
+	for _, l := range local {
+		for _, r := range remote {
+			if l == r && (!ok || l > picked) {
+				picked, ok = l, true
+			}
+		}
+	}
+	return picked, ok
+}
+
+// DefaultTTL is the TTL of the DataFrames written by sources.
+const DefaultTTL uint8 = 16
+
 // DataFrame carries tagged data to transmit across DataStream.
 type DataFrame struct {
 	// Metadata stores additional data beyond the Payload,
@@ -61,11 +136,94 @@ type DataFrame struct {
 	Tag Tag
 	// Payload is the data to transmit.
 	Payload []byte
+	// Seq is the optional sequence number of the DataFrame within its Tag, it starts from 1,
+	// a zero Seq means the DataFrame is not sequenced.
+	Seq uint64
+	// TTL is the max count of the hops the DataFrame can be forwarded, every zipper decrements it,
+	// the DataFrame is dropped once it reaches zero, so a misconfigured mesh can not loop it forever.
+	// A zero TTL means it is not set, the zipper takes it as DefaultTTL.
+	TTL uint8
+
+	// md caches the decoded Metadata, mdRaw is the Metadata that md decoded from.
+	md    metadata.M
+	mdRaw []byte
+}
+
+// GetMetadata returns the value of the key in the Metadata,
+// the Metadata is decoded at the first call and the decoded form is cached.
+func (f *DataFrame) GetMetadata(key string) (string, bool) {
+	md, err := f.decodedMetadata()
+	if err != nil {
+		return "", false
+	}
+	return md.Get(key)
+}
+
+// SetMetadata sets the key to the value in the Metadata and re-encodes the Metadata.
+// The Metadata is re-encoded in the same encoding as it was.
+func (f *DataFrame) SetMetadata(key, value string) error {
+	md, err := f.decodedMetadata()
+	if err != nil {
+		return err
+	}
+	// clone before modifying, the cached map may be shared with a copy of the frame.
+	md = md.Clone()
+	md.Set(key, value)
+
+	b, err := md.EncodeWith(metadata.EncodingOf(f.Metadata))
+	if err != nil {
+		return err
+	}
+	f.Metadata = b
+	f.md, f.mdRaw = md, b
+
+	return nil
+}
+
+func (f *DataFrame) decodedMetadata() (metadata.M, error) {
+	if f.md != nil && sameBytes(f.Metadata, f.mdRaw) {
+		return f.md, nil
+	}
+	md, err := metadata.Decode(f.Metadata)
+	if err != nil {
+		return nil, err
+	}
+	f.md, f.mdRaw = md, f.Metadata
+
+	return md, nil
+}
+
+// sameBytes reports whether a and b are the same slice, the contents are not compared.
+func sameBytes(a, b []byte) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	return len(a) == 0 || &a[0] == &b[0]
 }
 
 // Type returns the type of DataFrame.
 func (f *DataFrame) Type() Type { return TypeDataFrame }
 
+// BatchDataFrame carries multiple tagged payloads in a single frame,
+// the entries share the Metadata and are dispatched as separate DataFrames.
+type BatchDataFrame struct {
+	// Metadata stores additional data beyond the entries, it is shared by all entries.
+	Metadata []byte
+	// Entries are the tagged payloads in the batch.
+	Entries []BatchEntry
+}
+
+// BatchEntry is a tagged payload in BatchDataFrame.
+type BatchEntry struct {
+	// Tag is used for data router.
+	Tag Tag
+	// Payload is the data to transmit.
+	Payload []byte
+}
+
+// Type returns the type of BatchDataFrame.
+func (f *BatchDataFrame) Type() Type { return TypeBatchDataFrame }
+
 // The HandshakeFrame is the frame through which the client obtains a new data stream from the server.
 // It include essential details required for the creation of a fresh DataStream.
 // The server then generates the DataStream utilizing this provided information.
@@ -80,6 +238,14 @@ type HandshakeFrame struct {
 	ObserveDataTags []Tag
 	// Metadata is the Metadata of the dataStream that will be created.
 	Metadata []byte
+	// InstanceKey is stable per instance of the application, such as the host name, it is empty if not set.
+	// The server closes the streams of the same instance with a CloseStreamFrame of CloseDuplicateInstance,
+	// so an instance reconnecting after a blip does not process the data twice.
+	InstanceKey string
+	// ObserveTagPriorities is the optional priorities of the ObserveDataTags, the tags absent are of priority 0.
+	// The DataFrames of the higher priority tags go ahead of the ones of lower priority tags waiting to be
+	// written to the stream, it only affects the order within the queue of the stream, see core.WithServerWriteQueueSize.
+	ObserveTagPriorities map[Tag]uint8
 }
 
 // Type returns the type of HandshakeFrame.
@@ -89,6 +255,9 @@ func (f *HandshakeFrame) Type() Type { return TypeHandshakeFrame }
 // send HandshakeAckFrame to the new DataStream, That means the initial frame received by the new DataStream must be the HandshakeAckFrame.
 type HandshakeAckFrame struct {
 	StreamID string
+	// Checksum reports whether the packets of the data stream are followed by checksums,
+	// it is true if the client asks for it and the server supports it, see ChecksumPacketReadWriter.
+	Checksum bool
 }
 
 // Type returns the type of HandshakeAckFrame.
@@ -100,6 +269,8 @@ type HandshakeRejectedFrame struct {
 	ID string
 	// Message contains the reason why the handshake was not successful.
 	Message string
+	// Reason is the machine-readable reason code of the rejection.
+	Reason RejectCode
 }
 
 // Type returns the type of HandshakeRejectedFrame.
@@ -112,6 +283,8 @@ type BackflowFrame struct {
 	Tag Tag
 	// Carriage is the data to transmit.
 	Carriage []byte
+	// Metadata is the metadata of the DataFrame that carried the result, it may be empty.
+	Metadata []byte
 }
 
 // Type returns the type of BackflowFrame.
@@ -121,20 +294,290 @@ func (f *BackflowFrame) Type() Type { return TypeBackflowFrame }
 type RejectedFrame struct {
 	// Message encapsulates the rationale behind the rejection of the request.
 	Message string
+	// Reason is the machine-readable reason code of the rejection.
+	Reason RejectCode
 }
 
 // Type returns the type of RejectedFrame.
 func (f *RejectedFrame) Type() Type { return TypeRejectedFrame }
 
+// RejectCode is the machine-readable reason code carried by RejectedFrame and HandshakeRejectedFrame.
+type RejectCode byte
+
+const (
+	RejectUnspecified RejectCode = 0x00 // RejectUnspecified is the code of the peer that does not send a reason.
+	RejectAuthFailed  RejectCode = 0x01 // RejectAuthFailed means the authentication failed.
+	RejectRateLimited RejectCode = 0x02 // RejectRateLimited means the request exceeds the rate limit.
+	RejectUnknownTag  RejectCode = 0x03 // RejectUnknownTag means the tag is unknown by the server.
+	RejectInternal    RejectCode = 0x04 // RejectInternal means the server failed to handle the request.
+	RejectForbidden   RejectCode = 0x05 // RejectForbidden means the request is not allowed.
+	RejectInvalid     RejectCode = 0x06 // RejectInvalid means the request is malformed, such as an unknown stream type.
+)
+
+var rejectCodeStringMap = map[RejectCode]string{
+	RejectUnspecified: "Unspecified",
+	RejectAuthFailed:  "AuthFailed",
+	RejectRateLimited: "RateLimited",
+	RejectUnknownTag:  "UnknownTag",
+	RejectInternal:    "Internal",
+	RejectForbidden:   "Forbidden",
+	RejectInvalid:     "Invalid",
+}
+
+// String returns a human-readable string which represents the reject code.
+func (c RejectCode) String() string {
+	if str, ok := rejectCodeStringMap[c]; ok {
+		return str
+	}
+	return fmt.Sprintf("RejectCode(%d)", c)
+}
+
+// Temporary reports whether the rejection is temporary, the request may succeed if it is retried later.
+func (c RejectCode) Temporary() bool {
+	return c == RejectRateLimited || c == RejectInternal
+}
+
 // GoawayFrame is is used by server to evict a connection.
 type GoawayFrame struct {
 	// Message contains the reason why the connection be evicted.
 	Message string
+	// Reconnect tells the client to reconnect and authenticate again rather than quit,
+	// it is set when the connection is rotated for reaching its max lifetime.
+	Reconnect bool
 }
 
 // Type returns the type of GoawayFrame.
 func (f *GoawayFrame) Type() Type { return TypeGoawayFrame }
 
+// PingFrame is used to check the liveness of the peer at the application layer,
+// the peer must reply a PongFrame that echoes the Nonce back once it receives the PingFrame.
+// PingFrame is transmit on ControlStream, or on DataStream as the heartbeat of the server.
+type PingFrame struct {
+	// Nonce is an opaque byte array, it is used to match the PongFrame.
+	Nonce []byte
+}
+
+// Type returns the type of PingFrame.
+func (f *PingFrame) Type() Type { return TypePingFrame }
+
+// PongFrame is the reply of PingFrame, it echoes the Nonce of the PingFrame back.
+// PongFrame is transmit on the stream that the PingFrame is received from.
+type PongFrame struct {
+	// Nonce is the Nonce of the PingFrame that be replied.
+	Nonce []byte
+}
+
+// Type returns the type of PongFrame.
+func (f *PongFrame) Type() Type { return TypePongFrame }
+
+// MetadataUpdateFrame is used by client to update the metadata of a DataStream after handshake,
+// the Metadata is merged into the metadata of the DataStream.
+// MetadataUpdateFrame is transmit on ControlStream.
+type MetadataUpdateFrame struct {
+	// StreamID is the ID of the DataStream to be updated.
+	StreamID string
+	// Metadata is the encoded metadata to be merged.
+	Metadata []byte
+}
+
+// Type returns the type of MetadataUpdateFrame.
+func (f *MetadataUpdateFrame) Type() Type { return TypeMetadataUpdateFrame }
+
+// FlowControlFrame is sent by an overloaded StreamFunction to ask the sources to slow down the DataFrames of a Tag.
+// If Pause is true, the sources block the writes of the Tag until a FlowControlFrame resumes it or
+// the max pause time elapses. Otherwise the writes are resumed, and limited to Rate DataFrames per second
+// if Rate is not zero. FlowControlFrame is transmit on ControlStream from StreamFunction to zipper,
+// and on DataStream from zipper to sources.
+type FlowControlFrame struct {
+	// Tag is the tag of the DataFrames to be controlled.
+	Tag Tag
+	// Pause pauses the writes of the Tag if it is true, otherwise resumes them.
+	Pause bool
+	// Rate is the suggested max number of DataFrames per second after resuming, zero means unlimited.
+	Rate uint32
+}
+
+// Type returns the type of FlowControlFrame.
+func (f *FlowControlFrame) Type() Type { return TypeFlowControlFrame }
+
+// StatsReportFrame is sent by the StreamFunction periodically to report the stats of processing the DataFrames
+// since the last report, the zipper feeds them into its metrics. The latencies are in microseconds.
+// StatsReportFrame is transmit on ControlStream from StreamFunction to zipper.
+type StatsReportFrame struct {
+	// StreamID is the id of the DataStream that processes the DataFrames.
+	StreamID string
+	// Processed is the number of the DataFrames processed.
+	Processed uint64
+	// Errors is the number of the DataFrames failed to process, they are counted in Processed as well.
+	Errors uint64
+	// P50Latency is the median latency of processing a DataFrame.
+	P50Latency uint64
+	// P99Latency is the 99th percentile latency of processing a DataFrame.
+	P99Latency uint64
+}
+
+// Type returns the type of StatsReportFrame.
+func (f *StatsReportFrame) Type() Type { return TypeStatsReportFrame }
+
+// StreamReadyFrame is sent by the StreamFunction once its handler is installed, the zipper forwards it to the sources
+// with the tags the StreamFunction observes, so the sources can wait for it before writing the first DataFrames.
+// StreamReadyFrame is transmit on ControlStream from StreamFunction to zipper, and on DataStream from zipper to Source.
+type StreamReadyFrame struct {
+	// StreamID is the id of the DataStream that is ready.
+	StreamID string
+	// Tags are the tags observed by the DataStream, they are filled by the zipper.
+	Tags []Tag
+}
+
+// Type returns the type of StreamReadyFrame.
+func (f *StreamReadyFrame) Type() Type { return TypeStreamReadyFrame }
+
+// BackflowAckFrame acknowledges the BackflowFrame that asks for the ack, the StreamFunction asks for it by
+// the metadata of the data it writes, and waits for it when it needs the delivery confirmation.
+// BackflowAckFrame is transmit on DataStream from Source to zipper, and from zipper to StreamFunction.
+type BackflowAckFrame struct {
+	// ID is the correlation id of the BackflowFrame acknowledged.
+	ID string
+	// StreamID is the id of the DataStream of the StreamFunction that waits for the ack.
+	StreamID string
+}
+
+// Type returns the type of BackflowAckFrame.
+func (f *BackflowAckFrame) Type() Type { return TypeBackflowAckFrame }
+
+// HealthCheckFrame is sent by the probe to verify the server is responsive, the server replies HealthCheckAckFrame.
+// It is sent after the authentication, or before it if the server accepts the health checks before the authentication.
+// HealthCheckFrame is transmit on ControlStream from client to zipper.
+type HealthCheckFrame struct{}
+
+// Type returns the type of HealthCheckFrame.
+func (f *HealthCheckFrame) Type() Type { return TypeHealthCheckFrame }
+
+// HealthCheckAckFrame replies HealthCheckFrame with the health status of the server.
+// HealthCheckAckFrame is transmit on ControlStream from zipper to client.
+type HealthCheckAckFrame struct {
+	// Status is the health status of the server.
+	Status HealthStatus
+}
+
+// Type returns the type of HealthCheckAckFrame.
+func (f *HealthCheckAckFrame) Type() Type { return TypeHealthCheckAckFrame }
+
+// HealthStatus is the health status of the server carried by HealthCheckAckFrame.
+type HealthStatus byte
+
+const (
+	HealthServing    HealthStatus = 0x00 // HealthServing means the server is serving.
+	HealthDraining   HealthStatus = 0x01 // HealthDraining means the server is draining, it rejects the new connections.
+	HealthOverloaded HealthStatus = 0x02 // HealthOverloaded means the server is overloaded.
+)
+
+var healthStatusStringMap = map[HealthStatus]string{
+	HealthServing:    "Serving",
+	HealthDraining:   "Draining",
+	HealthOverloaded: "Overloaded",
+}
+
+// String returns a human-readable string which represents the health status.
+func (s HealthStatus) String() string {
+	if str, ok := healthStatusStringMap[s]; ok {
+		return str
+	}
+	return fmt.Sprintf("HealthStatus(%d)", s)
+}
+
+// ObserveTagFrame is used by client to observe the DataFrames of the Tag after handshake.
+// ObserveTagFrame is transmit on ControlStream.
+type ObserveTagFrame struct {
+	// StreamID is the ID of the DataStream that observes the Tag.
+	StreamID string
+	// Tag is the tag to be observed.
+	Tag Tag
+}
+
+// Type returns the type of ObserveTagFrame.
+func (f *ObserveTagFrame) Type() Type { return TypeObserveTagFrame }
+
+// UnobserveTagFrame is used by client to stop observing the DataFrames of the Tag.
+// UnobserveTagFrame is transmit on ControlStream.
+type UnobserveTagFrame struct {
+	// StreamID is the ID of the DataStream that unobserves the Tag.
+	StreamID string
+	// Tag is the tag to be unobserved.
+	Tag Tag
+}
+
+// Type returns the type of UnobserveTagFrame.
+func (f *UnobserveTagFrame) Type() Type { return TypeUnobserveTagFrame }
+
+// CloseStreamFrame closes a DataStream without closing the connection, it is sent by client to close
+// its DataStream, or by server to tell the client why its DataStream is closed.
+// CloseStreamFrame is transmit on ControlStream.
+type CloseStreamFrame struct {
+	// StreamID is the ID of the DataStream to be closed.
+	StreamID string
+	// Reason is the human-readable reason of the close.
+	Reason string
+	// Code is the machine-readable reason code of the close.
+	Code CloseCode
+}
+
+// Type returns the type of CloseStreamFrame.
+func (f *CloseStreamFrame) Type() Type { return TypeCloseStreamFrame }
+
+// ChunkedDataFrame carries a chunk of a large payload, the payload is split into the chunks of a transfer,
+// they are written in order and reassembled by the StreamFunction before it is handled,
+// so the whole payload does not have to be in a single frame.
+type ChunkedDataFrame struct {
+	// Metadata stores additional data beyond the payload, it is the same for all chunks of a transfer.
+	Metadata []byte
+	// Tag is used for data router.
+	Tag Tag
+	// TransferID is the id of the transfer that the chunk belongs to.
+	TransferID string
+	// Index is the index of the chunk in the transfer, it starts from 0.
+	Index uint32
+	// Last reports whether the chunk is the last one of the transfer.
+	Last bool
+	// Payload is the bytes of the chunk.
+	Payload []byte
+}
+
+// Type returns the type of ChunkedDataFrame.
+func (f *ChunkedDataFrame) Type() Type { return TypeChunkedDataFrame }
+
+// CloseCode is the machine-readable reason code carried by CloseStreamFrame.
+type CloseCode byte
+
+const (
+	CloseNormal      CloseCode = 0x00 // CloseNormal means the stream is closed normally.
+	CloseError       CloseCode = 0x01 // CloseError means the stream is closed because of an error.
+	CloseReplaced    CloseCode = 0x02 // CloseReplaced means the stream is replaced by a new stream with the same name.
+	CloseIdleTimeout CloseCode = 0x03 // CloseIdleTimeout means the stream is closed because it is idle for too long.
+	// CloseHeartbeatTimeout means the stream is closed because it misses the heartbeats.
+	CloseHeartbeatTimeout CloseCode = 0x04
+	// CloseDuplicateInstance means the stream is replaced by a new stream of the same instance, see HandshakeFrame.InstanceKey.
+	CloseDuplicateInstance CloseCode = 0x05
+)
+
+var closeCodeStringMap = map[CloseCode]string{
+	CloseNormal:      "Normal",
+	CloseError:       "Error",
+	CloseReplaced:    "Replaced",
+	CloseIdleTimeout: "IdleTimeout",
+
+	CloseHeartbeatTimeout:  "HeartbeatTimeout",
+	CloseDuplicateInstance: "DuplicateInstance",
+}
+
+// String returns a human-readable string which represents the close code.
+func (c CloseCode) String() string {
+	if str, ok := closeCodeStringMap[c]; ok {
+		return str
+	}
+	return fmt.Sprintf("CloseCode(%d)", c)
+}
+
 const (
 	TypeAuthenticationFrame    Type = 0x03 // TypeAuthenticationFrame is the type of AuthenticationFrame.
 	TypeAuthenticationAckFrame Type = 0x11 // TypeAuthenticationAckFrame is the type of AuthenticationAckFrame.
@@ -145,6 +588,20 @@ const (
 	TypeRejectedFrame          Type = 0x39 // TypeRejectedFrame is the type of RejectedFrame.
 	TypeBackflowFrame          Type = 0x2D // TypeBackflowFrame is the type of BackflowFrame.
 	TypeGoawayFrame            Type = 0x2E // TypeGoawayFrame is the type of GoawayFrame.
+	TypePingFrame              Type = 0x3A // TypePingFrame is the type of PingFrame.
+	TypePongFrame              Type = 0x3B // TypePongFrame is the type of PongFrame.
+	TypeBatchDataFrame         Type = 0x3C // TypeBatchDataFrame is the type of BatchDataFrame.
+	TypeMetadataUpdateFrame    Type = 0x3D // TypeMetadataUpdateFrame is the type of MetadataUpdateFrame.
+	TypeFlowControlFrame       Type = 0x3E // TypeFlowControlFrame is the type of FlowControlFrame.
+	TypeObserveTagFrame        Type = 0x32 // TypeObserveTagFrame is the type of ObserveTagFrame.
+	TypeUnobserveTagFrame      Type = 0x33 // TypeUnobserveTagFrame is the type of UnobserveTagFrame.
+	TypeCloseStreamFrame       Type = 0x34 // TypeCloseStreamFrame is the type of CloseStreamFrame.
+	TypeChunkedDataFrame       Type = 0x35 // TypeChunkedDataFrame is the type of ChunkedDataFrame.
+	TypeStatsReportFrame       Type = 0x36 // TypeStatsReportFrame is the type of StatsReportFrame.
+	TypeStreamReadyFrame       Type = 0x37 // TypeStreamReadyFrame is the type of StreamReadyFrame.
+	TypeBackflowAckFrame       Type = 0x38 // TypeBackflowAckFrame is the type of BackflowAckFrame.
+	TypeHealthCheckFrame       Type = 0x2F // TypeHealthCheckFrame is the type of HealthCheckFrame.
+	TypeHealthCheckAckFrame    Type = 0x30 // TypeHealthCheckAckFrame is the type of HealthCheckAckFrame.
 )
 
 var frameTypeStringMap = map[Type]string{
@@ -157,12 +614,28 @@ var frameTypeStringMap = map[Type]string{
 	TypeRejectedFrame:          "RejectedFrame",
 	TypeBackflowFrame:          "BackflowFrame",
 	TypeGoawayFrame:            "GoawayFrame",
+	TypePingFrame:              "PingFrame",
+	TypePongFrame:              "PongFrame",
+	TypeBatchDataFrame:         "BatchDataFrame",
+	TypeMetadataUpdateFrame:    "MetadataUpdateFrame",
+	TypeFlowControlFrame:       "FlowControlFrame",
+	TypeObserveTagFrame:        "ObserveTagFrame",
+	TypeUnobserveTagFrame:      "UnobserveTagFrame",
+	TypeCloseStreamFrame:       "CloseStreamFrame",
+	TypeChunkedDataFrame:       "ChunkedDataFrame",
+	TypeStatsReportFrame:       "StatsReportFrame",
+	TypeStreamReadyFrame:       "StreamReadyFrame",
+	TypeBackflowAckFrame:       "BackflowAckFrame",
+	TypeHealthCheckFrame:       "HealthCheckFrame",
+	TypeHealthCheckAckFrame:    "HealthCheckAckFrame",
 }
 
 // String returns a human-readable string which represents the frame type.
 // The string can be used for debugging or logging purposes.
 func (f Type) String() string {
+	frameTypeMu.RLock()
 	frameString, ok := frameTypeStringMap[f]
+	frameTypeMu.RUnlock()
 	if ok {
 		return frameString
 	}
@@ -179,11 +652,52 @@ var frameTypeNewFuncMap = map[Type]func() Frame{
 	TypeRejectedFrame:          func() Frame { return new(RejectedFrame) },
 	TypeBackflowFrame:          func() Frame { return new(BackflowFrame) },
 	TypeGoawayFrame:            func() Frame { return new(GoawayFrame) },
+	TypePingFrame:              func() Frame { return new(PingFrame) },
+	TypePongFrame:              func() Frame { return new(PongFrame) },
+	TypeBatchDataFrame:         func() Frame { return new(BatchDataFrame) },
+	TypeMetadataUpdateFrame:    func() Frame { return new(MetadataUpdateFrame) },
+	TypeFlowControlFrame:       func() Frame { return new(FlowControlFrame) },
+	TypeObserveTagFrame:        func() Frame { return new(ObserveTagFrame) },
+	TypeUnobserveTagFrame:      func() Frame { return new(UnobserveTagFrame) },
+	TypeCloseStreamFrame:       func() Frame { return new(CloseStreamFrame) },
+	TypeChunkedDataFrame:       func() Frame { return new(ChunkedDataFrame) },
+	TypeStatsReportFrame:       func() Frame { return new(StatsReportFrame) },
+	TypeStreamReadyFrame:       func() Frame { return new(StreamReadyFrame) },
+	TypeBackflowAckFrame:       func() Frame { return new(BackflowAckFrame) },
+	TypeHealthCheckFrame:       func() Frame { return new(HealthCheckFrame) },
+	TypeHealthCheckAckFrame:    func() Frame { return new(HealthCheckAckFrame) },
+}
+
+// frameTypeMu protects frameTypeStringMap and frameTypeNewFuncMap, they are changed by RegisterFrameType.
+var frameTypeMu sync.RWMutex
+
+// RegisterFrameType registers a custom frame type, so that NewFrame creates the frame of the type by newFunc,
+// and the String of the type returns the name. It is intended to be called in init, the frame codec must know
+// how to encode the custom frame, see y3codec for example.
+// It panics if the type collides with a built-in or an already registered type, if the high bit of the type is set,
+// which the tags of y3 can not carry, or if newFunc is nil.
+func RegisterFrameType(t Type, name string, newFunc func() Frame) {
+	if newFunc == nil {
+		panic("frame: RegisterFrameType newFunc is nil")
+	}
+	if t&0x80 != 0 {
+		panic(fmt.Sprintf("frame: RegisterFrameType called with type 0x%x, the high bit must not be set", byte(t)))
+	}
+	frameTypeMu.Lock()
+	defer frameTypeMu.Unlock()
+
+	if registered, ok := frameTypeStringMap[t]; ok {
+		panic(fmt.Sprintf("frame: RegisterFrameType called twice for type 0x%x, it is %s", byte(t), registered))
+	}
+	frameTypeStringMap[t] = name
+	frameTypeNewFuncMap[t] = newFunc
 }
 
 // NewFrame creates a new frame from Type.
 func NewFrame(f Type) (Frame, error) {
+	frameTypeMu.RLock()
 	newFunc, ok := frameTypeNewFuncMap[f]
+	frameTypeMu.RUnlock()
 	if ok {
 		return newFunc(), nil
 	}
@@ -197,6 +711,30 @@ type PacketReadWriter interface {
 	WritePacket(io.Writer, Type, []byte) error
 }
 
+// PacketFreer is implemented by the PacketReadWriter which reads packets into pooled buffers.
+// Free returns a packet returned by ReadPacket to the pool, the packets those are not from the pool,
+// such as the ones returned by Codec.Encode, must not be freed. The packet and the frame decoded from it
+// must not be used after Free.
+type PacketFreer interface {
+	Free(packet []byte)
+}
+
+// StreamPacketReadWriter is implemented by the PacketReadWriter which keeps the state between the reads,
+// such as the packet read in part. ForStream returns the PacketReadWriter that keeps the state of one stream,
+// the reads of the stream go through it only, so the streams do not share the state.
+type StreamPacketReadWriter interface {
+	ForStream() PacketReadWriter
+}
+
+// PacketReadWriterForStream returns the PacketReadWriter of a stream from the prw,
+// it is the prw itself if the prw is not a StreamPacketReadWriter.
+func PacketReadWriterForStream(prw PacketReadWriter) PacketReadWriter {
+	if sprw, ok := prw.(StreamPacketReadWriter); ok {
+		return sprw.ForStream()
+	}
+	return prw
+}
+
 // Codec encodes and decodes byte array to frame.
 type Codec interface {
 	// Decode decodes byte array to frame.
@@ -205,9 +743,40 @@ type Codec interface {
 	Encode(Frame) ([]byte, error)
 }
 
+// CompressionEncoder is the Codec that compresses the frames, such as the codec of the pkg/frame-codec/compress package,
+// the connections count the bytes before and after the compression in their stats by it.
+type CompressionEncoder interface {
+	Codec
+	// EncodeCompressed encodes the frame like Encode, and reports the size of the compressed fields
+	// before and after the compression, they are 0 if the frame has no field compressed.
+	EncodeCompressed(Frame) (b []byte, original int, compressed int, err error)
+}
+
+// DictionaryCodec is the Codec that compresses the frames with a shared dictionary, such as the codec of the
+// pkg/frame-codec/compress package. The client and the server negotiate the dictionary in the authentication:
+// the server ships its dictionary to the client of a different version, and the connections whose sides do not
+// have the same version fall back to the compression without the dictionary.
+type DictionaryCodec interface {
+	Codec
+	// DictionaryID returns the version of the current dictionary, zero means no dictionary.
+	DictionaryID() uint32
+	// Dictionary returns the current dictionary encoded to be shipped to the peer, it is nil if no dictionary.
+	Dictionary() []byte
+	// CloneWithDictionary returns a copy of the codec whose current dictionary is the one shipped by the peer,
+	// the codec itself is not changed, so the dictionary shipped stays in the connection it is shipped to.
+	CloneWithDictionary(b []byte) (DictionaryCodec, error)
+	// PeerCodec returns the Codec for the peer that has the dictionary of the version, it compresses
+	// the frames with the current dictionary only if it is the same version.
+	PeerCodec(dictionaryID uint32) Codec
+}
+
 // Tag tags data and can be used for data routing.
 type Tag = uint32
 
+// TagFirehose is the tag that observes the DataFrames of all the tags, it is for the stream functions
+// like auditing and archiving. Observing it must be allowed by the authorizer of the server.
+const TagFirehose Tag = 0xFFFFFFFF
+
 // ReadWriteCloser is the interface that groups the ReadFrame, WriteFrame and Close methods.
 type ReadWriteCloser interface {
 	Reader
@@ -228,6 +797,14 @@ type Writer interface {
 	WriteFrame(Frame) error
 }
 
+// Deadliner is implemented by the ReadWriter whose reads and writes can be given up by the deadlines,
+// such as the one over a QUIC stream. The ReadFrame and WriteFrame return an error wrapping os.ErrDeadlineExceeded
+// once the deadline passes, so the callers can implement their own timeouts. A zero time means no deadline.
+type Deadliner interface {
+	SetReadDeadline(t time.Time) error
+	SetWriteDeadline(t time.Time) error
+}
+
 // Reader reads frame from underlying stream.
 type Reader interface {
 	// ReadFrame reads a frame, if an error occurs, the returned error will not be empty,
@@ -0,0 +1,87 @@
+package frame
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yomorun/yomo/core/metadata"
+)
+
+func TestDataFrameMetadata(t *testing.T) {
+	b, err := metadata.M{"a": "1"}.Encode()
+	assert.NoError(t, err)
+
+	f := &DataFrame{Tag: 1, Metadata: b}
+
+	v, ok := f.GetMetadata("a")
+	assert.True(t, ok)
+	assert.Equal(t, "1", v)
+
+	_, ok = f.GetMetadata("b")
+	assert.False(t, ok)
+
+	// the copy does not see the change.
+	copied := *f
+
+	assert.NoError(t, f.SetMetadata("b", "2"))
+
+	md, err := metadata.Decode(f.Metadata)
+	assert.NoError(t, err)
+	assert.Equal(t, metadata.M{"a": "1", "b": "2"}, md)
+
+	_, ok = copied.GetMetadata("b")
+	assert.False(t, ok)
+
+	// the cache is dropped once the Metadata is replaced.
+	f.Metadata = nil
+	_, ok = f.GetMetadata("a")
+	assert.False(t, ok)
+}
+
+func TestNegotiateVersion(t *testing.T) {
+	v, ok := NegotiateVersion([]Version{1, 2, 3}, []Version{2, 3, 4})
+	assert.True(t, ok)
+	assert.Equal(t, Version(3), v)
+
+	// the legacy peer speaks Version1.
+	v, ok = NegotiateVersion([]Version{1, 2}, nil)
+	assert.True(t, ok)
+	assert.Equal(t, Version1, v)
+
+	_, ok = NegotiateVersion([]Version{2}, nil)
+	assert.False(t, ok)
+}
+
+type customFrame struct{}
+
+func (f *customFrame) Type() Type { return 0x70 }
+
+// unregisterFrameType removes the custom frame type registered by RegisterFrameType,
+// so the tests registering it can run more than once.
+func unregisterFrameType(t Type) {
+	frameTypeMu.Lock()
+	defer frameTypeMu.Unlock()
+
+	delete(frameTypeStringMap, t)
+	delete(frameTypeNewFuncMap, t)
+}
+
+func TestRegisterFrameType(t *testing.T) {
+	RegisterFrameType(0x70, "CustomFrame", func() Frame { return new(customFrame) })
+	t.Cleanup(func() { unregisterFrameType(0x70) })
+
+	f, err := NewFrame(0x70)
+	assert.NoError(t, err)
+	assert.IsType(t, &customFrame{}, f)
+	assert.Equal(t, "CustomFrame", Type(0x70).String())
+
+	assert.Panics(t, func() {
+		RegisterFrameType(TypeDataFrame, "MyDataFrame", func() Frame { return new(customFrame) })
+	}, "the built-in type can not be overridden")
+	assert.Panics(t, func() {
+		RegisterFrameType(0x70, "CustomFrame", func() Frame { return new(customFrame) })
+	}, "the type can not be registered twice")
+	assert.Panics(t, func() {
+		RegisterFrameType(0x80, "HighBitFrame", func() Frame { return new(customFrame) })
+	}, "the type with the high bit set can not be carried by y3")
+}
@@ -0,0 +1,150 @@
+package frametest
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/yomorun/yomo/core/frame"
+)
+
+// NoopCodec is the frame.Codec that treats the DataFrames as already serialized, the payload of
+// the DataFrame is the packet as is, so a pre-encoded payload is passed through without any copy.
+// The tag and the metadata are not carried, the decoded DataFrames are tagged with Tag.
+// The frames other than the DataFrame are not supported.
+type NoopCodec struct {
+	// Tag is the tag of the decoded DataFrames.
+	Tag frame.Tag
+}
+
+var _ frame.Codec = &NoopCodec{}
+
+// Encode implements frame.Codec.
+func (c *NoopCodec) Encode(f frame.Frame) ([]byte, error) {
+	df, ok := f.(*frame.DataFrame)
+	if !ok {
+		return nil, fmt.Errorf("frametest: NoopCodec can't encode %s", f.Type())
+	}
+	return df.Payload, nil
+}
+
+// Decode implements frame.Codec.
+func (c *NoopCodec) Decode(data []byte, f frame.Frame) error {
+	df, ok := f.(*frame.DataFrame)
+	if !ok {
+		return fmt.Errorf("frametest: NoopCodec can't decode %s", f.Type())
+	}
+	df.Tag = c.Tag
+	df.Payload = data
+	return nil
+}
+
+// packetHeaderSize is the size of the header of the packets, it is 1 byte of the type
+// and 4 bytes of the length in big endian.
+const packetHeaderSize = 5
+
+// PacketReadWriter is the frame.PacketReadWriter that passes the packets through behind a fixed-size header,
+// there is no parsing other than the header.
+type PacketReadWriter struct{}
+
+var _ frame.PacketReadWriter = &PacketReadWriter{}
+
+// WritePacket implements frame.PacketReadWriter.
+func (*PacketReadWriter) WritePacket(w io.Writer, t frame.Type, data []byte) error {
+	var header [packetHeaderSize]byte
+	header[0] = byte(t)
+	binary.BigEndian.PutUint32(header[1:], uint32(len(data)))
+	if _, err := w.Write(header[:]); err != nil {
+		return err
+	}
+	_, err := w.Write(data)
+	return err
+}
+
+// ReadPacket implements frame.PacketReadWriter.
+func (*PacketReadWriter) ReadPacket(r io.Reader) (frame.Type, []byte, error) {
+	var header [packetHeaderSize]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return 0, nil, err
+	}
+	data := make([]byte, binary.BigEndian.Uint32(header[1:]))
+	if _, err := io.ReadFull(r, data); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return 0, nil, err
+	}
+	return frame.Type(header[0]), data, nil
+}
+
+// Stream is the in-memory stream, it reads what is written to it like a bytes.Buffer.
+// It implements the core.ContextReadWriteCloser, so that it underlies the core.FrameStream.
+// The reads return io.EOF once the stream is drained or closed.
+type Stream struct {
+	ctx     context.Context
+	cancel  context.CancelFunc
+	mu      sync.Mutex
+	buf     bytes.Buffer
+	discard bool
+}
+
+// NewStream returns the Stream that reads the init bytes first.
+func NewStream(init []byte) *Stream {
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &Stream{ctx: ctx, cancel: cancel}
+	s.buf.Write(init)
+	return s
+}
+
+// NewDiscardStream returns the Stream that discards the bytes written, it is for the
+// benchmarks those write a lot and never read.
+func NewDiscardStream() *Stream {
+	s := NewStream(nil)
+	s.discard = true
+	return s
+}
+
+// Context returns the context of the stream, it is done once the stream is closed.
+func (s *Stream) Context() context.Context { return s.ctx }
+
+// Read reads the bytes written.
+func (s *Stream) Read(p []byte) (int, error) {
+	if s.ctx.Err() != nil {
+		return 0, io.EOF
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.buf.Read(p)
+}
+
+// Write writes the bytes to be read, they are dropped if the stream discards.
+func (s *Stream) Write(p []byte) (int, error) {
+	if s.ctx.Err() != nil {
+		return 0, io.ErrClosedPipe
+	}
+	if s.discard {
+		return len(p), nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.buf.Write(p)
+}
+
+// Len returns the count of the bytes not read yet.
+func (s *Stream) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.buf.Len()
+}
+
+// Close closes the stream.
+func (s *Stream) Close() error {
+	s.cancel()
+	return nil
+}
@@ -0,0 +1,40 @@
+package frametest
+
+import (
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yomorun/yomo/core/frame"
+)
+
+func TestPassthrough(t *testing.T) {
+	codec, prw := &NoopCodec{Tag: 7}, &PacketReadWriter{}
+	stream := NewStream(nil)
+
+	b, err := codec.Encode(&frame.DataFrame{Tag: 1, Payload: []byte("pre-encoded")})
+	assert.NoError(t, err)
+	assert.NoError(t, prw.WritePacket(stream, frame.TypeDataFrame, b))
+
+	typ, b, err := prw.ReadPacket(stream)
+	assert.NoError(t, err)
+	assert.Equal(t, frame.TypeDataFrame, typ)
+
+	df := new(frame.DataFrame)
+	assert.NoError(t, codec.Decode(b, df))
+	assert.Equal(t, &frame.DataFrame{Tag: 7, Payload: []byte("pre-encoded")}, df)
+
+	_, _, err = prw.ReadPacket(stream)
+	assert.ErrorIs(t, err, io.EOF)
+
+	_, err = codec.Encode(&frame.PongFrame{})
+	assert.Error(t, err)
+
+	discard := NewDiscardStream()
+	assert.NoError(t, prw.WritePacket(discard, frame.TypeDataFrame, b))
+	assert.Equal(t, 0, discard.Len())
+
+	assert.NoError(t, stream.Close())
+	_, err = stream.Write(b)
+	assert.ErrorIs(t, err, io.ErrClosedPipe)
+}
@@ -0,0 +1,80 @@
+// Package frametest provides an in-memory frame transport for testing, and the codec and the transport
+// those cost almost nothing, they are for benchmarking the dispatch path of the zipper in isolation
+// from the serialization and QUIC.
+package frametest
+
+import (
+	"context"
+	"io"
+	"sync"
+
+	"github.com/yomorun/yomo/core/frame"
+)
+
+// Pipe creates a synchronous, in-memory, frame-aware pipe, it is like net.Pipe but transmits frames.
+// Every WriteFrame on one end blocks until the frame is read by ReadFrame on the other end, the frame is
+// passed as is, so the frame boundaries are always kept. Closing either end closes the pipe, the ReadFrame
+// and WriteFrame of both ends return io.EOF and io.ErrClosedPipe after that, it simulates a disconnect.
+func Pipe() (*Conn, *Conn) {
+	var (
+		ab = make(chan frame.Frame)
+		ba = make(chan frame.Frame)
+		p  = newPipe()
+	)
+	return &Conn{r: ba, w: ab, pipe: p}, &Conn{r: ab, w: ba, pipe: p}
+}
+
+// pipe is the state shared by the two ends.
+type pipe struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	once   sync.Once
+}
+
+func newPipe() *pipe {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &pipe{ctx: ctx, cancel: cancel}
+}
+
+// Conn is an end of the pipe, it implements frame.ReadWriteCloser.
+type Conn struct {
+	r    <-chan frame.Frame
+	w    chan<- frame.Frame
+	pipe *pipe
+}
+
+var _ frame.ReadWriteCloser = (*Conn)(nil)
+
+// Context returns the context that is done once the pipe is closed.
+func (c *Conn) Context() context.Context { return c.pipe.ctx }
+
+// ReadFrame reads the next frame written by the other end.
+func (c *Conn) ReadFrame() (frame.Frame, error) {
+	select {
+	case <-c.pipe.ctx.Done():
+		return nil, io.EOF
+	case f := <-c.r:
+		return f, nil
+	}
+}
+
+// WriteFrame writes a frame to the other end, it blocks until the frame is read.
+func (c *Conn) WriteFrame(f frame.Frame) error {
+	select {
+	case <-c.pipe.ctx.Done():
+		return io.ErrClosedPipe
+	default:
+	}
+	select {
+	case <-c.pipe.ctx.Done():
+		return io.ErrClosedPipe
+	case c.w <- f:
+		return nil
+	}
+}
+
+// Close closes the pipe, calling Close multiple times has no effect.
+func (c *Conn) Close() error {
+	c.pipe.once.Do(c.pipe.cancel)
+	return nil
+}
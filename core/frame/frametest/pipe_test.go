@@ -0,0 +1,38 @@
+package frametest
+
+import (
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yomorun/yomo/core/frame"
+)
+
+func TestPipe(t *testing.T) {
+	a, b := Pipe()
+
+	go func() {
+		_ = a.WriteFrame(&frame.HandshakeFrame{Name: "sfn"})
+		_ = a.WriteFrame(&frame.DataFrame{Tag: 1, Payload: []byte("yomo")})
+	}()
+
+	f, err := b.ReadFrame()
+	assert.NoError(t, err)
+	assert.Equal(t, &frame.HandshakeFrame{Name: "sfn"}, f)
+
+	f, err = b.ReadFrame()
+	assert.NoError(t, err)
+	assert.Equal(t, &frame.DataFrame{Tag: 1, Payload: []byte("yomo")}, f)
+
+	t.Run("close", func(t *testing.T) {
+		assert.NoError(t, b.Close())
+		assert.NoError(t, b.Close())
+
+		_, err := a.ReadFrame()
+		assert.Equal(t, io.EOF, err)
+		assert.Equal(t, io.ErrClosedPipe, a.WriteFrame(&frame.DataFrame{}))
+		assert.Equal(t, io.ErrClosedPipe, b.WriteFrame(&frame.DataFrame{}))
+
+		<-a.Context().Done()
+	})
+}
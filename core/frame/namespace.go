@@ -0,0 +1,52 @@
+package frame
+
+// Namespace scopes the tags, it is encoded in the high bits of the Tag, so the tags of the different
+// namespaces never match each other in the router.
+//
+// The flat tags of the existing deployments are in the DefaultNamespace as long as they are below
+// 1<<TagNamespaceShift, so the namespaces are opt-in: a deployment that never composes a namespaced tag
+// keeps working as is. The flat tags at or above 1<<TagNamespaceShift fall into the non-zero namespaces,
+// they must be moved below it before the namespaces are enforced.
+type Namespace = uint8
+
+const (
+	// TagNamespaceShift is the number of the low bits of the Tag those hold the local tag.
+	TagNamespaceShift = 24
+	// MaxLocalTag is the largest local tag in a namespace.
+	MaxLocalTag Tag = 1<<TagNamespaceShift - 1
+	// DefaultNamespace is the namespace of the flat tags.
+	DefaultNamespace Namespace = 0
+	// ReservedNamespace is reserved for the tags of the system, such as TagFirehose.
+	ReservedNamespace Namespace = 0xFF
+)
+
+// NamespacedTag composes the tag of the local tag in the namespace,
+// the bits of the local tag beyond MaxLocalTag are dropped.
+func NamespacedTag(ns Namespace, local Tag) Tag {
+	return Tag(ns)<<TagNamespaceShift | local&MaxLocalTag
+}
+
+// SplitTag decomposes the tag to its namespace and its local tag.
+func SplitTag(tag Tag) (Namespace, Tag) {
+	return TagNamespace(tag), tag & MaxLocalTag
+}
+
+// TagNamespace returns the namespace of the tag.
+func TagNamespace(tag Tag) Namespace {
+	return Namespace(tag >> TagNamespaceShift)
+}
+
+// WithTagNamespace limits the tags allowed to the ones in the namespace,
+// the local tag zero is not allowed in the DefaultNamespace, as the tag zero is invalid.
+func WithTagNamespace(ns Namespace) DataFrameOption {
+	return func(o *dataFrameOptions) {
+		o.minTag = NamespacedTag(ns, 0)
+		if ns == DefaultNamespace {
+			o.minTag = 1
+		}
+		o.maxTag = NamespacedTag(ns, MaxLocalTag)
+		if ns == ReservedNamespace {
+			o.maxTag = TagFirehose - 1
+		}
+	}
+}
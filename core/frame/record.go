@@ -0,0 +1,209 @@
+package frame
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// Direction is the direction of a recorded frame.
+type Direction byte
+
+const (
+	// DirectionRead means the frame is read from the ReadWriteCloser.
+	DirectionRead Direction = 0x01
+	// DirectionWrite means the frame is written to the ReadWriteCloser.
+	DirectionWrite Direction = 0x02
+)
+
+// String returns the string of the Direction.
+func (d Direction) String() string {
+	switch d {
+	case DirectionRead:
+		return "Read"
+	case DirectionWrite:
+		return "Write"
+	default:
+		return fmt.Sprintf("Direction(%d)", byte(d))
+	}
+}
+
+// Record is a frame recorded by RecordingReadWriter.
+type Record struct {
+	Direction Direction
+	Time      time.Time
+	Frame     Frame
+}
+
+// recordHeaderSize is the size of the header of a record, the record is laid out as:
+// direction(1 byte) | frame type(1 byte) | unix nano time(8 bytes) | length(4 bytes) | encoded frame.
+// The integers are in big endian.
+const recordHeaderSize = 14
+
+// RecordingReadWriter wraps a ReadWriteCloser and records every frame read and written to the io.Writer,
+// the records can be read back by ReplayReader. The frames are encoded by the codec.
+// A failure of recording does not fail the reads and writes, it is reported by Err.
+type RecordingReadWriter struct {
+	rw    ReadWriteCloser
+	codec Codec
+
+	mu  sync.Mutex
+	w   io.Writer
+	buf []byte
+	err error
+}
+
+var _ ReadWriteCloser = (*RecordingReadWriter)(nil)
+
+// NewRecordingReadWriter returns a RecordingReadWriter that records the frames of rw to w.
+func NewRecordingReadWriter(rw ReadWriteCloser, w io.Writer, codec Codec) *RecordingReadWriter {
+	return &RecordingReadWriter{
+		rw:    rw,
+		codec: codec,
+		w:     w,
+	}
+}
+
+// ReadFrame reads a frame from the wrapped ReadWriteCloser and records it.
+func (r *RecordingReadWriter) ReadFrame() (Frame, error) {
+	f, err := r.rw.ReadFrame()
+	if err != nil {
+		return f, err
+	}
+	r.record(DirectionRead, f)
+	return f, nil
+}
+
+// WriteFrame writes the frame to the wrapped ReadWriteCloser and records it.
+func (r *RecordingReadWriter) WriteFrame(f Frame) error {
+	if err := r.rw.WriteFrame(f); err != nil {
+		return err
+	}
+	r.record(DirectionWrite, f)
+	return nil
+}
+
+// Close closes the wrapped ReadWriteCloser.
+func (r *RecordingReadWriter) Close() error {
+	return r.rw.Close()
+}
+
+// Err returns the first error of recording, the frames after it are not recorded.
+func (r *RecordingReadWriter) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.err
+}
+
+func (r *RecordingReadWriter) record(direction Direction, f Frame) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.err != nil {
+		return
+	}
+	data, err := r.codec.Encode(f)
+	if err != nil {
+		r.err = err
+		return
+	}
+
+	r.buf = append(r.buf[:0], byte(direction), byte(f.Type()))
+	r.buf = binary.BigEndian.AppendUint64(r.buf, uint64(time.Now().UnixNano()))
+	r.buf = binary.BigEndian.AppendUint32(r.buf, uint32(len(data)))
+	r.buf = append(r.buf, data...)
+
+	_, r.err = r.w.Write(r.buf)
+}
+
+// ReplayOption is the option for ReplayReader.
+type ReplayOption func(*ReplayReader)
+
+// WithReplayTiming makes ReplayReader wait between the frames as long as they were recorded apart,
+// so the frames are replayed at the recorded pace.
+func WithReplayTiming() ReplayOption {
+	return func(r *ReplayReader) {
+		r.timing = true
+	}
+}
+
+// WithReplayDirection sets the direction of the frames returned by ReadFrame, the default is DirectionRead,
+// that is, the frames the recorded connection received.
+func WithReplayDirection(d Direction) ReplayOption {
+	return func(r *ReplayReader) {
+		r.direction = d
+	}
+}
+
+// ReplayReader reads the records written by RecordingReadWriter, it is a Reader that replays the recorded frames.
+type ReplayReader struct {
+	r         io.Reader
+	codec     Codec
+	direction Direction
+	timing    bool
+	last      time.Time
+}
+
+var _ Reader = (*ReplayReader)(nil)
+
+// NewReplayReader returns a ReplayReader that reads the records from r, the frames are decoded by the codec
+// that they are recorded with.
+func NewReplayReader(r io.Reader, codec Codec, opts ...ReplayOption) *ReplayReader {
+	rr := &ReplayReader{
+		r:         r,
+		codec:     codec,
+		direction: DirectionRead,
+	}
+	for _, o := range opts {
+		o(rr)
+	}
+	return rr
+}
+
+// ReadRecord reads the next record of either direction, it returns io.EOF at the end of the records.
+func (r *ReplayReader) ReadRecord() (*Record, error) {
+	var header [recordHeaderSize]byte
+	if _, err := io.ReadFull(r.r, header[:]); err != nil {
+		return nil, err
+	}
+	data := make([]byte, binary.BigEndian.Uint32(header[10:]))
+	if _, err := io.ReadFull(r.r, data); err != nil {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	f, err := NewFrame(Type(header[1]))
+	if err != nil {
+		return nil, err
+	}
+	if err := r.codec.Decode(data, f); err != nil {
+		return nil, err
+	}
+
+	return &Record{
+		Direction: Direction(header[0]),
+		Time:      time.Unix(0, int64(binary.BigEndian.Uint64(header[2:]))),
+		Frame:     f,
+	}, nil
+}
+
+// ReadFrame reads the next frame of the direction of the ReplayReader, it returns io.EOF at the end of the records.
+func (r *ReplayReader) ReadFrame() (Frame, error) {
+	for {
+		record, err := r.ReadRecord()
+		if err != nil {
+			return nil, err
+		}
+		if record.Direction != r.direction {
+			continue
+		}
+		if r.timing && !r.last.IsZero() {
+			time.Sleep(record.Time.Sub(r.last))
+		}
+		r.last = record.Time
+
+		return record.Frame, nil
+	}
+}
@@ -1,9 +1,13 @@
 package core
 
 import (
+	"bufio"
 	"context"
+	"errors"
 	"io"
-	"sync"
+	"os"
+	"sync/atomic"
+	"time"
 
 	"github.com/yomorun/yomo/core/frame"
 )
@@ -13,21 +17,126 @@ type FrameStream struct {
 	codec            frame.Codec
 	packetReadWriter frame.PacketReadWriter
 
-	// mu protected stream write and close
+	// sem protected stream write and close
 	// because of stream write and close is not goroutinue-safely.
-	mu         sync.Mutex
+	// it is a semaphore rather than a mutex, so that WriteWithContext can give up waiting for it.
+	sem        chan struct{}
 	underlying ContextReadWriteCloser
+	// queue replaces the sem if the write queue is enabled, see WithWriteQueue.
+	queue *writeQueue
+	// tagPriorities ranks the DataFrames waiting in the queue by their tags, see setTagPriorities.
+	tagPriorities map[frame.Tag]uint8
+
+	// reader reads ahead the underlying stream into its buffer, so that the small reads of the
+	// packet header do not hit the underlying stream. It is the underlying stream if the buffer is disabled.
+	reader         io.Reader
+	readBufferSize int
+	// counter counts the bytes of the frame being read, and writeCounter the frame being written,
+	// so a frame given up by the deadline in part can be told.
+	counter      countingReader
+	writeCounter countingWriter
+	// writeDeadline is the deadline set by SetWriteDeadline, WriteWithContext restores it after the write.
+	writeDeadline atomic.Pointer[time.Time]
+
+	// packet is the packet of the last frame read, it is freed by Release.
+	packet []byte
+
+	// compression is set if the codec compresses the frames and the underlying stream records the compression,
+	// it is nil otherwise, so the frames are encoded as usual.
+	compression *compressionStats
+}
+
+// compressionStats records the compression of the frames written by the CompressionEncoder.
+type compressionStats struct {
+	encoder  frame.CompressionEncoder
+	recorder compressionRecorder
+}
+
+var _ frame.Deadliner = (*FrameStream)(nil)
+
+// ErrDeadlineNotSupported is returned by setting the deadlines of the FrameStream
+// whose underlying stream does not support the deadlines.
+var ErrDeadlineNotSupported = errors.New("yomo: the stream does not support deadlines")
+
+// DefaultReadBufferSize is the default size of the read buffer of FrameStream.
+const DefaultReadBufferSize = 4096
+
+// FrameStreamOption is the option for FrameStream.
+type FrameStreamOption func(*FrameStream)
+
+// WithReadBufferSize sets the size of the buffer that FrameStream reads ahead the underlying stream into,
+// the frames straddling the buffer boundaries are read across the refills. A non-positive size disables the buffer.
+func WithReadBufferSize(size int) FrameStreamOption {
+	return func(fs *FrameStream) {
+		fs.readBufferSize = size
+	}
+}
+
+// WithWriteQueue makes the writes waiting for each other be handed over by the priority of their frames,
+// so the control frames go ahead of a backlog of DataFrames. At most size DataFrames wait,
+// a DataFrame written to the full queue is dropped with ErrWriteQueueFull. A non-positive size disables it.
+func WithWriteQueue(size int) FrameStreamOption {
+	return func(fs *FrameStream) {
+		if size > 0 {
+			fs.queue = newWriteQueue(size)
+		}
+	}
 }
 
 // NewFrameStream creates a new FrameStream.
 func NewFrameStream(
-	stream ContextReadWriteCloser, codec frame.Codec, packetReadWriter frame.PacketReadWriter,
+	stream ContextReadWriteCloser, codec frame.Codec, packetReadWriter frame.PacketReadWriter, opts ...FrameStreamOption,
 ) *FrameStream {
-	return &FrameStream{
+	fs := &FrameStream{
 		underlying:       stream,
 		codec:            codec,
-		packetReadWriter: packetReadWriter,
+		packetReadWriter: frame.PacketReadWriterForStream(packetReadWriter),
+		readBufferSize:   DefaultReadBufferSize,
+		sem:              make(chan struct{}, 1),
+	}
+	for _, o := range opts {
+		o(fs)
 	}
+
+	encoder, isEncoder := codec.(frame.CompressionEncoder)
+	recorder, isRecorder := stream.(compressionRecorder)
+	if isEncoder && isRecorder {
+		fs.compression = &compressionStats{encoder: encoder, recorder: recorder}
+	}
+
+	fs.counter.r = stream
+	if fs.readBufferSize > 0 {
+		fs.counter.r = bufio.NewReaderSize(stream, fs.readBufferSize)
+	}
+	fs.reader = &fs.counter
+	fs.writeCounter.w = stream
+
+	return fs
+}
+
+// setPacketReadWriter replaces the PacketReadWriter, it must be called before the FrameStream is used concurrently.
+func (fs *FrameStream) setPacketReadWriter(prw frame.PacketReadWriter) {
+	fs.packetReadWriter = frame.PacketReadWriterForStream(prw)
+}
+
+// setTagPriorities sets the priorities of the tags, the DataFrames of the higher priority tags go ahead of
+// the ones of lower priority tags waiting in the write queue. It must be called before the FrameStream is used concurrently.
+func (fs *FrameStream) setTagPriorities(priorities map[frame.Tag]uint8) {
+	fs.tagPriorities = priorities
+}
+
+// rankOf returns the rank of the frame in the write queue, it is the priority of the tag of the DataFrame.
+func (fs *FrameStream) rankOf(f frame.Frame) uint8 {
+	if len(fs.tagPriorities) == 0 {
+		return 0
+	}
+	switch ff := f.(type) {
+	case *frame.DataFrame:
+		return fs.tagPriorities[ff.Tag]
+	case *frame.ChunkedDataFrame:
+		return fs.tagPriorities[ff.Tag]
+	}
+	return 0
 }
 
 // Context returns the context of the FrameStream.
@@ -39,12 +148,23 @@ func (fs *FrameStream) Context() context.Context {
 func (fs *FrameStream) ReadFrame() (frame.Frame, error) {
 	select {
 	case <-fs.underlying.Context().Done():
+		fs.discardPartial()
 		return nil, io.EOF
 	default:
 	}
 
-	fType, b, err := fs.packetReadWriter.ReadPacket(fs.underlying)
+	fs.counter.n = 0
+	fType, b, err := fs.packetReadWriter.ReadPacket(fs.reader)
 	if err != nil {
+		// the rest of the frame given up in part can not be told from the next frame,
+		// unless the PacketReadWriter resumes the frame by the next read.
+		var resumed partialResumer
+		if fs.counter.n > 0 && errors.Is(err, os.ErrDeadlineExceeded) && !(errors.As(err, &resumed) && resumed.Resumable()) {
+			_ = fs.underlying.Close()
+		}
+		if fs.underlying.Context().Err() != nil {
+			fs.discardPartial()
+		}
 		return nil, err
 	}
 
@@ -56,10 +176,24 @@ func (fs *FrameStream) ReadFrame() (frame.Frame, error) {
 	if err := fs.codec.Decode(b, f); err != nil {
 		return nil, err
 	}
+	fs.packet = b
+
+	if recorder, ok := fs.underlying.(frameReadRecorder); ok {
+		recorder.recordRead(fType, len(b))
+	}
 
 	return f, nil
 }
 
+// Release returns the packet of the last frame read to the pool of the PacketReadWriter if it has one.
+// It must be called by the reader after the frame is fully consumed, the frame must not be used after Release.
+func (fs *FrameStream) Release() {
+	if freer, ok := fs.packetReadWriter.(frame.PacketFreer); ok && fs.packet != nil {
+		freer.Free(fs.packet)
+	}
+	fs.packet = nil
+}
+
 // WriteFrame writes a frame into underlying stream.
 func (fs *FrameStream) WriteFrame(f frame.Frame) error {
 	select {
@@ -68,21 +202,298 @@ func (fs *FrameStream) WriteFrame(f frame.Frame) error {
 	default:
 	}
 
-	fs.mu.Lock()
-	defer fs.mu.Unlock()
+	if err := fs.acquire(writePriorityOf(f.Type()), fs.rankOf(f), nil); err != nil {
+		return err
+	}
+	defer fs.release()
+
+	return fs.writeFrame(f)
+}
+
+// acquire acquires the write lock with the priority and the rank, it gives up once the stream or the ctx is done.
+// A nil ctx does not give up.
+func (fs *FrameStream) acquire(priority writePriority, rank uint8, ctx context.Context) error {
+	streamDone := fs.underlying.Context().Done()
+	if fs.queue != nil {
+		return fs.queue.acquireRanked(priority, rank, streamDone, ctx)
+	}
+	var ctxDone <-chan struct{}
+	if ctx != nil {
+		ctxDone = ctx.Done()
+	}
+	select {
+	case <-streamDone:
+		return io.EOF
+	case <-ctxDone:
+		return ctx.Err()
+	case fs.sem <- struct{}{}:
+		return nil
+	}
+}
+
+// release releases the write lock.
+func (fs *FrameStream) release() {
+	if fs.queue != nil {
+		fs.queue.release()
+		return
+	}
+	<-fs.sem
+}
+
+// WriteFrames writes the frames into underlying stream under a single acquisition of the write lock,
+// so the frames are written contiguously, the other writes are not interleaved with them.
+// The frames are encoded before any of them is written, nothing is written if one of them fails to encode.
+func (fs *FrameStream) WriteFrames(frames ...frame.Frame) error {
+	select {
+	case <-fs.underlying.Context().Done():
+		return io.EOF
+	default:
+	}
+
+	packets := make([][]byte, 0, len(frames))
+	priority := writePriorityBulk
+	var rank uint8
+	var original, compressed int
+	for _, f := range frames {
+		b, o, c, err := fs.encode(f)
+		if err != nil {
+			return err
+		}
+		packets = append(packets, b)
+		original, compressed = original+o, compressed+c
+		if p := writePriorityOf(f.Type()); p > priority {
+			priority = p
+		}
+		if r := fs.rankOf(f); r > rank {
+			rank = r
+		}
+	}
+
+	if err := fs.acquire(priority, rank, nil); err != nil {
+		return err
+	}
+	defer fs.release()
+
+	for i, f := range frames {
+		if err := fs.writePacket(f.Type(), packets[i]); err != nil {
+			return err
+		}
+	}
+	fs.recordCompression(original, compressed)
+	return nil
+}
+
+// writeDeadliner is implemented by the stream that supports write deadline, such as quic.Stream.
+type writeDeadliner interface {
+	SetWriteDeadline(t time.Time) error
+}
+
+func writeDeadlinerOf(stream ContextReadWriteCloser) (writeDeadliner, bool) {
+	if ss, ok := stream.(*statsStream); ok {
+		stream = ss.ContextReadWriteCloser
+	}
+	wd, ok := stream.(writeDeadliner)
+	return wd, ok
+}
+
+// readDeadliner is implemented by the stream that supports read deadline, such as quic.Stream.
+type readDeadliner interface {
+	SetReadDeadline(t time.Time) error
+}
+
+func readDeadlinerOf(stream ContextReadWriteCloser) (readDeadliner, bool) {
+	if ss, ok := stream.(*statsStream); ok {
+		stream = ss.ContextReadWriteCloser
+	}
+	rd, ok := stream.(readDeadliner)
+	return rd, ok
+}
+
+// SetReadDeadline sets the deadline of ReadFrame, ReadFrame returns an error wrapping os.ErrDeadlineExceeded
+// once the deadline passes. If the deadline passes in the middle of a frame, the stream is closed,
+// because the rest of the frame can not be read correctly, unless the PacketReadWriter resumes the frame
+// by the next ReadFrame like the y3codec one does. A zero time means no deadline.
+func (fs *FrameStream) SetReadDeadline(t time.Time) error {
+	rd, ok := readDeadlinerOf(fs.underlying)
+	if !ok {
+		return ErrDeadlineNotSupported
+	}
+	return rd.SetReadDeadline(t)
+}
+
+// SetWriteDeadline sets the deadline of the writes, the writes return an error wrapping os.ErrDeadlineExceeded
+// once the deadline passes. If the deadline passes in the middle of a frame, the stream is closed like
+// WriteWithContext does. A zero time means no deadline.
+func (fs *FrameStream) SetWriteDeadline(t time.Time) error {
+	wd, ok := writeDeadlinerOf(fs.underlying)
+	if !ok {
+		return ErrDeadlineNotSupported
+	}
+	fs.writeDeadline.Store(&t)
+	return wd.SetWriteDeadline(t)
+}
+
+// userWriteDeadline returns the deadline set by SetWriteDeadline.
+func (fs *FrameStream) userWriteDeadline() time.Time {
+	if t := fs.writeDeadline.Load(); t != nil {
+		return *t
+	}
+	return time.Time{}
+}
+
+// WriteWithContext writes a frame into underlying stream like WriteFrame, but it gives up and returns
+// the ctx.Err() once the ctx is done, either waiting for the other writes or blocking on the flow control
+// of the peer. So a slow consumer does not block the writer forever.
+// The stream is closed if the write is interrupted after part of the frame has been written,
+// because the following frames can not be read correctly by the peer.
+func (fs *FrameStream) WriteWithContext(ctx context.Context, f frame.Frame) error {
+	if err := fs.acquire(writePriorityOf(f.Type()), fs.rankOf(f), ctx); err != nil {
+		return err
+	}
+	defer fs.release()
+
+	wd, ok := writeDeadlinerOf(fs.underlying)
+	if !ok {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		return fs.writeFrame(f)
+	}
+
+	userDeadline := fs.userWriteDeadline()
+	if deadline, ok := ctx.Deadline(); ok && (userDeadline.IsZero() || deadline.Before(userDeadline)) {
+		_ = wd.SetWriteDeadline(deadline)
+	}
+	done, exited := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(exited)
+		select {
+		case <-ctx.Done():
+			// unblock the write at once.
+			_ = wd.SetWriteDeadline(time.Unix(1, 0))
+		case <-done:
+		}
+	}()
+
+	err := fs.writeFrame(f)
+	close(done)
+	<-exited
+
+	if ctxErr := ctx.Err(); err != nil && ctxErr != nil {
+		_ = fs.underlying.Close()
+		return ctxErr
+	}
+	// restore the deadline for the following writes.
+	_ = wd.SetWriteDeadline(userDeadline)
+
+	return err
+}
 
-	b, err := fs.codec.Encode(f)
+// writeFrame writes the frame, the caller must hold the sem.
+func (fs *FrameStream) writeFrame(f frame.Frame) error {
+	b, original, compressed, err := fs.encode(f)
 	if err != nil {
 		return err
 	}
+	if err := fs.writePacket(f.Type(), b); err != nil {
+		return err
+	}
+	fs.recordCompression(original, compressed)
+	return nil
+}
+
+// encode encodes the frame, it reports the bytes before and after the compression if the compression is recorded.
+func (fs *FrameStream) encode(f frame.Frame) (b []byte, original int, compressed int, err error) {
+	if fs.compression == nil {
+		b, err = fs.codec.Encode(f)
+		return b, 0, 0, err
+	}
+	return fs.compression.encoder.EncodeCompressed(f)
+}
+
+// recordCompression records the compression of the frames written.
+func (fs *FrameStream) recordCompression(original, compressed int) {
+	if compressed > 0 {
+		fs.compression.recorder.recordCompression(original, compressed)
+	}
+}
+
+// writePacket writes the encoded frame, the caller must hold the sem.
+func (fs *FrameStream) writePacket(ftyp frame.Type, b []byte) error {
+	fs.writeCounter.n = 0
+	if err := fs.packetReadWriter.WritePacket(&fs.writeCounter, ftyp, b); err != nil {
+		// the peer can not tell the rest of the frame given up in part from the next frame.
+		if fs.writeCounter.n > 0 && errors.Is(err, os.ErrDeadlineExceeded) {
+			_ = fs.underlying.Close()
+		}
+		return err
+	}
 
-	return fs.packetReadWriter.WritePacket(fs.underlying, f.Type(), b)
+	if recorder, ok := fs.underlying.(frameWriteRecorder); ok {
+		recorder.recordWrite(ftyp, len(b))
+	}
+	// the encoded packet is not freed, it is not from the pool: the codec may return
+	// the buffer of the caller as is, such as the payload of the DataFrame.
+	return nil
+}
+
+// closeLock acquires the write lock for Close, it waits even if the stream is done.
+func (fs *FrameStream) closeLock() {
+	if fs.queue != nil {
+		_ = fs.queue.acquire(writePriorityControl, nil, nil)
+		return
+	}
+	fs.sem <- struct{}{}
 }
 
 // Close closes the FrameStream and returns an error if any.
 func (fs *FrameStream) Close() error {
-	fs.mu.Lock()
-	defer fs.mu.Unlock()
+	fs.closeLock()
+	defer fs.release()
 
 	return fs.underlying.Close()
 }
+
+// discardPartial discards the packet read in part once the stream is closed. It is called by the reader,
+// not by Close, because the packet may still be read into by the reader when the stream is being closed.
+func (fs *FrameStream) discardPartial() {
+	if discarder, ok := fs.packetReadWriter.(partialDiscarder); ok {
+		discarder.Discard()
+	}
+}
+
+// partialResumer is implemented by the error of the PacketReadWriter that has read a packet in part,
+// it is resumable if the next read resumes the packet.
+type partialResumer interface {
+	Resumable() bool
+}
+
+// partialDiscarder is implemented by the PacketReadWriter of a stream that keeps the packet read in part,
+// it is discarded by the reader once the stream is closed.
+type partialDiscarder interface {
+	Discard()
+}
+
+// countingReader counts the bytes read.
+type countingReader struct {
+	r io.Reader
+	n int
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.n += n
+	return n, err
+}
+
+// countingWriter counts the bytes written.
+type countingWriter struct {
+	w io.Writer
+	n int
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.n += n
+	return n, err
+}
@@ -0,0 +1,353 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yomorun/yomo/core/frame"
+	"github.com/yomorun/yomo/pkg/frame-codec/y3codec"
+)
+
+func TestFrameStreamReadBuffer(t *testing.T) {
+	codec, prw := y3codec.Codec(), y3codec.PacketReadWriter()
+
+	var (
+		frames []*frame.DataFrame
+		raw    []byte
+	)
+	for i := 0; i < 10; i++ {
+		f := &frame.DataFrame{Tag: frame.Tag(i), Payload: bytes.Repeat([]byte{byte(i)}, i*7)}
+		b, err := codec.Encode(f)
+		assert.NoError(t, err)
+		frames = append(frames, f)
+		raw = append(raw, b...)
+	}
+
+	for _, size := range []int{0, 16, 64, DefaultReadBufferSize} {
+		// the small buffer makes the frames straddle the buffer boundaries.
+		fs := NewFrameStream(newMemByteStream(raw), codec, prw, WithReadBufferSize(size))
+		for _, want := range frames {
+			got, err := fs.ReadFrame()
+			assert.NoError(t, err)
+			assert.Equal(t, want.Tag, got.(*frame.DataFrame).Tag)
+			assert.Equal(t, len(want.Payload), len(got.(*frame.DataFrame).Payload))
+		}
+		_, err := fs.ReadFrame()
+		assert.Equal(t, io.EOF, err)
+	}
+
+	// the partial frame at the end of the stream surfaces io.ErrUnexpectedEOF.
+	fs := NewFrameStream(newMemByteStream(raw[:len(raw)-1]), codec, prw, WithReadBufferSize(16))
+	for i := 0; i < len(frames)-1; i++ {
+		_, err := fs.ReadFrame()
+		assert.NoError(t, err)
+	}
+	_, err := fs.ReadFrame()
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+}
+
+// blockingStream blocks the writes until the write deadline is exceeded, like a stream whose peer stops reading.
+type blockingStream struct {
+	*memByteStream
+	deadline chan time.Time
+}
+
+func (s *blockingStream) SetWriteDeadline(t time.Time) error {
+	s.deadline <- t
+	return nil
+}
+
+func (s *blockingStream) Write(p []byte) (int, error) {
+	for t := range s.deadline {
+		if !t.IsZero() && !t.After(time.Now()) {
+			return 0, os.ErrDeadlineExceeded
+		}
+	}
+	return 0, io.EOF
+}
+
+func TestFrameStreamWriteWithContext(t *testing.T) {
+	stream := &blockingStream{newMemByteStream(nil), make(chan time.Time, 10)}
+	fs := NewFrameStream(stream, &byteCodec{}, &bytePacketReadWriter{})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err := fs.WriteWithContext(ctx, byteFrame('a'))
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), time.Second)
+
+	// the interrupted stream is closed.
+	assert.Error(t, stream.Context().Err())
+}
+
+// expiringStream reads the bytes given, then the reads fail as the read deadline is exceeded.
+type expiringStream struct {
+	*memByteStream
+	deadline time.Time
+}
+
+func (s *expiringStream) SetReadDeadline(t time.Time) error {
+	s.deadline = t
+	return nil
+}
+
+func (s *expiringStream) Read(p []byte) (int, error) {
+	n, err := s.memByteStream.Read(p)
+	if err == io.EOF && !s.deadline.IsZero() {
+		return n, os.ErrDeadlineExceeded
+	}
+	return n, err
+}
+
+func TestFrameStreamReadDeadline(t *testing.T) {
+	codec, prw := y3codec.Codec(), y3codec.PacketReadWriter()
+
+	fs := NewFrameStream(newMemByteStream(nil), codec, prw)
+	assert.ErrorIs(t, fs.SetReadDeadline(time.Now()), ErrDeadlineNotSupported)
+
+	b, err := codec.Encode(&frame.DataFrame{Tag: 1, Payload: []byte("hello")})
+	assert.NoError(t, err)
+
+	stream := &expiringStream{memByteStream: newMemByteStream(b)}
+	fs = NewFrameStream(stream, codec, prw)
+	assert.NoError(t, fs.SetReadDeadline(time.Now()))
+
+	_, err = fs.ReadFrame()
+	assert.NoError(t, err)
+
+	// the deadline between the frames keeps the stream.
+	_, err = fs.ReadFrame()
+	assert.ErrorIs(t, err, os.ErrDeadlineExceeded)
+	assert.NoError(t, stream.Context().Err())
+
+	// the deadline in the middle of a frame keeps the stream, the frame is resumed by the next read.
+	stream = &expiringStream{memByteStream: newMemByteStream(b[:len(b)-1])}
+	fs = NewFrameStream(stream, codec, prw)
+	assert.NoError(t, fs.SetReadDeadline(time.Now()))
+
+	_, err = fs.ReadFrame()
+	assert.ErrorIs(t, err, os.ErrDeadlineExceeded)
+	assert.ErrorIs(t, err, y3codec.ErrShortRead)
+	assert.NoError(t, stream.Context().Err())
+
+	stream.mutex.Lock()
+	stream.readBuf.Write(b[len(b)-1:])
+	stream.mutex.Unlock()
+
+	f, err := fs.ReadFrame()
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("hello"), f.(*frame.DataFrame).Payload)
+}
+
+func TestFrameStreamWriteFrames(t *testing.T) {
+	codec, prw := y3codec.Codec(), y3codec.PacketReadWriter()
+
+	const (
+		writers   = 4
+		batches   = 10
+		batchSize = 8
+	)
+
+	stream := newMemByteStream(nil)
+	fs := NewFrameStream(stream, codec, prw)
+
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < batches; i++ {
+				frames := make([]frame.Frame, batchSize)
+				for j := range frames {
+					frames[j] = &frame.DataFrame{Tag: frame.Tag(w), Payload: []byte{byte(j)}}
+				}
+				assert.NoError(t, fs.WriteFrames(frames...))
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	// the frames of a batch are not interleaved with the frames of the other batches.
+	reader := NewFrameStream(newMemByteStream(stream.GetReadBytes()), codec, prw)
+	for i := 0; i < writers*batches; i++ {
+		first, err := reader.ReadFrame()
+		assert.NoError(t, err)
+		tag := first.(*frame.DataFrame).Tag
+		assert.Equal(t, []byte{0}, first.(*frame.DataFrame).Payload)
+		for j := 1; j < batchSize; j++ {
+			f, err := reader.ReadFrame()
+			assert.NoError(t, err)
+			assert.Equal(t, tag, f.(*frame.DataFrame).Tag)
+			assert.Equal(t, []byte{byte(j)}, f.(*frame.DataFrame).Payload)
+		}
+	}
+	_, err := reader.ReadFrame()
+	assert.Equal(t, io.EOF, err)
+}
+
+// discardStream discards the bytes written, it is used to benchmark the writes.
+type discardStream struct {
+	*memByteStream
+}
+
+func (discardStream) Write(p []byte) (int, error) { return len(p), nil }
+
+func benchmarkFrames(n int) []frame.Frame {
+	frames := make([]frame.Frame, n)
+	for i := range frames {
+		frames[i] = &frame.DataFrame{Tag: 1, Payload: bytes.Repeat([]byte{'a'}, 128)}
+	}
+	return frames
+}
+
+func BenchmarkFrameStreamWriteFrame(b *testing.B) {
+	fs := NewFrameStream(discardStream{newMemByteStream(nil)}, y3codec.Codec(), y3codec.PacketReadWriter())
+	frames := benchmarkFrames(16)
+
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			for _, f := range frames {
+				if err := fs.WriteFrame(f); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
+}
+
+func BenchmarkFrameStreamWriteFrames(b *testing.B) {
+	fs := NewFrameStream(discardStream{newMemByteStream(nil)}, y3codec.Codec(), y3codec.PacketReadWriter())
+	frames := benchmarkFrames(16)
+
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if err := fs.WriteFrames(frames...); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func TestWriteQueue(t *testing.T) {
+	q := newWriteQueue(1)
+	assert.NoError(t, q.acquire(writePriorityBulk, nil, nil))
+
+	order := make(chan writePriority, 2)
+	waitQueued := func(control, bulk int) {
+		assert.Eventually(t, func() bool {
+			q.mu.Lock()
+			defer q.mu.Unlock()
+			return len(q.control) == control && len(q.bulk) == bulk
+		}, time.Second, time.Millisecond)
+	}
+
+	go func() {
+		assert.NoError(t, q.acquire(writePriorityBulk, nil, nil))
+		order <- writePriorityBulk
+		q.release()
+	}()
+	waitQueued(0, 1)
+
+	// the bulk waiters are bounded, the control ones are not.
+	assert.ErrorIs(t, q.acquire(writePriorityBulk, nil, nil), ErrWriteQueueFull)
+
+	go func() {
+		assert.NoError(t, q.acquire(writePriorityControl, nil, nil))
+		order <- writePriorityControl
+		q.release()
+	}()
+	waitQueued(1, 1)
+
+	// the waiter gives up once the ctx is done.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, q.acquire(writePriorityControl, nil, ctx), context.DeadlineExceeded)
+	waitQueued(1, 1)
+
+	q.release()
+	assert.Equal(t, writePriorityControl, <-order)
+	assert.Equal(t, writePriorityBulk, <-order)
+
+	assert.NoError(t, q.acquire(writePriorityBulk, nil, nil))
+	q.release()
+}
+
+func TestWriteQueueRank(t *testing.T) {
+	q := newWriteQueue(10)
+	assert.NoError(t, q.acquire(writePriorityBulk, nil, nil))
+
+	order := make(chan uint8, 4)
+	waitQueued := func(n int) {
+		assert.Eventually(t, func() bool {
+			q.mu.Lock()
+			defer q.mu.Unlock()
+			return len(q.bulk) == n
+		}, time.Second, time.Millisecond)
+	}
+
+	// the waiters of the same rank are in FIFO order, the higher ranks go ahead.
+	for i, rank := range []uint8{0, 2, 0, 1} {
+		go func(rank uint8) {
+			assert.NoError(t, q.acquireRanked(writePriorityBulk, rank, nil, nil))
+			order <- rank
+			q.release()
+		}(rank)
+		waitQueued(i + 1)
+	}
+
+	q.release()
+	var got []uint8
+	for i := 0; i < 4; i++ {
+		got = append(got, <-order)
+	}
+	assert.Equal(t, []uint8{2, 1, 0, 0}, got)
+}
+
+// freeRecorder records the packets freed.
+type freeRecorder struct {
+	bytePacketReadWriter
+	freed [][]byte
+}
+
+func (r *freeRecorder) Free(packet []byte) { r.freed = append(r.freed, packet) }
+
+func TestFrameStreamNotFreeEncoded(t *testing.T) {
+	prw := &freeRecorder{}
+	fs := NewFrameStream(newMemByteStream(nil), &byteCodec{}, prw)
+
+	// the byteCodec returns the payload of the caller as is, it must not go to the pool.
+	assert.NoError(t, fs.WriteFrame(&frame.DataFrame{Payload: []byte("a")}))
+	assert.NoError(t, fs.WriteFrames(&frame.DataFrame{Payload: []byte("b")}, &frame.DataFrame{Payload: []byte("c")}))
+	assert.Empty(t, prw.freed)
+}
+
+// discardRecorder records the discards of the packet read in part.
+type discardRecorder struct {
+	bytePacketReadWriter
+	discarded int
+}
+
+func (r *discardRecorder) Discard() { r.discarded++ }
+
+func TestFrameStreamDiscardByReader(t *testing.T) {
+	prw := &discardRecorder{}
+	fs := NewFrameStream(newMemByteStream(nil), &byteCodec{}, prw)
+
+	// the reader may be reading into the packet when the stream is being closed, so Close does not discard it.
+	assert.NoError(t, fs.Close())
+	assert.Equal(t, 0, prw.discarded)
+
+	_, err := fs.ReadFrame()
+	assert.Equal(t, io.EOF, err)
+	assert.Equal(t, 1, prw.discarded)
+}
@@ -0,0 +1,18 @@
+package core
+
+import (
+	"math/rand"
+	"time"
+)
+
+// jitterLifetime shortens the lifetime by up to the fraction of the jitter at random, so that the connections
+// opened at the same time are not rotated at the same time. The lifetime is never lengthened, it is the max.
+func jitterLifetime(lifetime time.Duration, jitter float64) time.Duration {
+	if lifetime <= 0 || jitter <= 0 {
+		return lifetime
+	}
+	if jitter > 1 {
+		jitter = 1
+	}
+	return lifetime - time.Duration(float64(lifetime)*jitter*rand.Float64())
+}
@@ -3,6 +3,8 @@ package core
 import (
 	"context"
 	"net"
+
+	"github.com/yomorun/yomo/core/yerr"
 )
 
 // A Listener for incoming connections
@@ -28,4 +30,20 @@ type Connection interface {
 	AcceptStream(context.Context) (ContextReadWriteCloser, error)
 	// CloseWithError closes the connection with an error.
 	CloseWithError(string) error
+	// Stats returns the stats of frames written to the connection.
+	Stats() ConnectionStats
+}
+
+// codeCloser is the Connection that closes with the yerr code, see QuicConnection.CloseWithCode.
+type codeCloser interface {
+	CloseWithCode(code yerr.ErrorCode, errString string) error
+}
+
+// closeWithCode closes the connection with the yerr code if it supports,
+// otherwise it closes the connection with the error string only.
+func closeWithCode(conn Connection, code yerr.ErrorCode, errString string) error {
+	if cc, ok := conn.(codeCloser); ok {
+		return cc.CloseWithCode(code, errString)
+	}
+	return conn.CloseWithError(errString)
 }
@@ -1,6 +1,11 @@
 package core
 
 import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/yomorun/yomo/core/frame"
 	"github.com/yomorun/yomo/core/metadata"
 	"golang.org/x/exp/slog"
 )
@@ -11,8 +16,42 @@ const (
 	MetadataTIDKey       = "yomo-tid"
 	MetadataSIDKey       = "yomo-sid"
 	MetaTraced           = "yomo-traced"
+	// MetadataCorrelationIDKey is the key of the correlation id in the handshake metadata,
+	// the logs of the stream share the correlation id.
+	MetadataCorrelationIDKey = "yomo-correlation-id"
+	// MetadataChecksumKey is the key of the checksum algorithm that the client asks for in the handshake metadata,
+	// the only algorithm is frame.ChecksumCRC32C.
+	MetadataChecksumKey = "yomo-checksum"
+	// MetadataDeadLetterKey is the key of the reason why the sfn gives up the DataFrame, the DataFrame carries it
+	// is routed to the dead-letter tag by the zipper.
+	MetadataDeadLetterKey = "yomo-dead-letter"
+	// MetadataDeadLetterTagKey is the key of the original tag of the DataFrame routed to the dead-letter tag.
+	MetadataDeadLetterTagKey = "yomo-dead-letter-tag"
+	// MetadataDeadLetterReasonKey is the key of the reason why the DataFrame is routed to the dead-letter tag.
+	MetadataDeadLetterReasonKey = "yomo-dead-letter-reason"
+	// MetadataRequestIDKey is the key of the id of the request written by Source.Request, the sfn echoes it
+	// in the metadata of the reply, and the reply is backflowed to the requesting source by it.
+	MetadataRequestIDKey = "yomo-request-id"
+	// MetadataTargetStreamIDKey is the key of the id of the stream that the DataFrame is unicast to,
+	// the zipper delivers the DataFrame to that stream only if it observes the tag.
+	MetadataTargetStreamIDKey = "yomo-target-stream-id"
 )
 
+// DefaultMaxMetadataSize is the default max size of the encoded metadata of the handshakes and the DataFrames.
+const DefaultMaxMetadataSize = 4 * 1024
+
+// ErrMetadataTooLarge is returned if the encoded metadata exceeds the max size.
+var ErrMetadataTooLarge = errors.New("yomo: metadata is too large")
+
+// checkMetadataSize returns ErrMetadataTooLarge if the encoded metadata exceeds the max size,
+// a non-positive max size means unlimited.
+func checkMetadataSize(md []byte, max int) error {
+	if max > 0 && len(md) > max {
+		return fmt.Errorf("%w: %d bytes, the max is %d", ErrMetadataTooLarge, len(md), max)
+	}
+	return nil
+}
+
 // NewDefaultMetadata returns a default metadata.
 func NewDefaultMetadata(sourceID string, broadcast bool, tid string, sid string, traced bool) metadata.M {
 	broadcastString := "false"
@@ -32,6 +71,22 @@ func NewDefaultMetadata(sourceID string, broadcast bool, tid string, sid string,
 	}
 }
 
+// MetadataLabelPrefix is the key prefix of the labels of the connection in the metadata, the labels are sent
+// with the authentication, the zipper carries them in the metadata of the connection, see WithLabels.
+const MetadataLabelPrefix = "yomo-label-"
+
+// GetLabelsFromMetadata gets the labels of the connection from metadata.
+func GetLabelsFromMetadata(m metadata.M) map[string]string {
+	labels := make(map[string]string)
+	m.Range(func(k, v string) bool {
+		if strings.HasPrefix(k, MetadataLabelPrefix) {
+			labels[strings.TrimPrefix(k, MetadataLabelPrefix)] = v
+		}
+		return true
+	})
+	return labels
+}
+
 // GetSourceIDFromMetadata gets sourceID from metadata.
 func GetSourceIDFromMetadata(m metadata.M) string {
 	sourceID, _ := m.Get(MetadataSourceIDKey)
@@ -56,6 +111,18 @@ func GetSIDFromMetadata(m metadata.M) string {
 	return sid
 }
 
+// GetTargetStreamIDFromMetadata gets the id of the stream that the DataFrame is unicast to from metadata.
+func GetTargetStreamIDFromMetadata(m metadata.M) string {
+	target, _ := m.Get(MetadataTargetStreamIDKey)
+	return target
+}
+
+// GetCorrelationIDFromMetadata gets the correlation id from metadata.
+func GetCorrelationIDFromMetadata(m metadata.M) string {
+	cid, _ := m.Get(MetadataCorrelationIDKey)
+	return cid
+}
+
 // GetTracedFromMetadata gets traced from metadata.
 func GetTracedFromMetadata(m metadata.M) bool {
 	traced, _ := m.Get(MetaTraced)
@@ -94,3 +161,13 @@ func MetadataSlogAttr(md metadata.M) slog.Attr {
 
 	return slog.Group("metadata", kvStrings...)
 }
+
+// checksumRequested reports whether the handshake metadata asks for the packet checksums.
+func checksumRequested(md []byte) bool {
+	m, err := metadata.Decode(md)
+	if err != nil {
+		return false
+	}
+	algorithm, _ := m.Get(MetadataChecksumKey)
+	return algorithm == frame.ChecksumCRC32C
+}
@@ -1,9 +1,7 @@
 // Package metadata defines Metadata of the DataFrame.
 package metadata
 
-import (
-	"github.com/vmihailenco/msgpack/v5"
-)
+import "sort"
 
 // M stores additional information about the application.
 //
@@ -13,6 +11,9 @@ import (
 //	 3. Metadata from the DataFrame, This is frame-level metadata.
 //
 // the main responsibility of Metadata is to route messages to stream functions.
+//
+// The values are strings generally, the values of other types are set by SetValue and read by the typed getters,
+// such as GetInt and GetMap, they are kept in msgpack, so the wire format stays self-describing.
 type M map[string]string
 
 // New creates an M from a given key-values map.
@@ -26,19 +27,60 @@ func New(mds ...map[string]string) M {
 	return m
 }
 
-// Decode decodes a byte array to M.
+// Encoding is the wire format of M.
+type Encoding byte
+
+const (
+	// EncodingMsgpack encodes M in msgpack, it is the default encoding.
+	EncodingMsgpack Encoding = iota
+	// EncodingJSON encodes M in JSON, it is larger than msgpack but can be inspected by standard tools.
+	EncodingJSON
+)
+
+// String returns the name of the encoding.
+func (e Encoding) String() string {
+	switch e {
+	case EncodingMsgpack:
+		return "msgpack"
+	case EncodingJSON:
+		return "json"
+	default:
+		return "unknown"
+	}
+}
+
+// EncodingOf sniffs the encoding of the encoded metadata by the first byte,
+// a JSON object starts with '{', which is never the first byte of a msgpack map.
+func EncodingOf(data []byte) Encoding {
+	if len(data) > 0 && data[0] == '{' {
+		return EncodingJSON
+	}
+	return EncodingMsgpack
+}
+
+// Decode decodes a byte array to M, the byte array can be encoded in either msgpack or JSON.
 func Decode(data []byte) (M, error) {
 	m := M{}
 	if len(data) == 0 {
 		return m, nil
 	}
-	return m, msgpack.Unmarshal(data, &m)
+	if EncodingOf(data) == EncodingJSON {
+		return m, decodeJSON(data, m)
+	}
+	return m, decodeMsgpack(data, m)
 }
 
-// Get returns the value of the given key.
+// Get returns the string value of the given key, it returns false if the value is not a string,
+// see GetValue for the values of other types.
 func (m M) Get(k string) (string, bool) {
 	v, ok := m[k]
-	return v, ok
+	if !ok {
+		return "", false
+	}
+	if _, typed := typedValue(v); typed {
+		return "", false
+	}
+	return v, true
 }
 
 // Set sets the value of the given key. if the key is empty, it will do nothing.
@@ -49,7 +91,8 @@ func (m M) Set(k, v string) {
 	m[k] = v
 }
 
-// Range iterates over all keys and values.
+// Range iterates over all keys and values, the values that are not strings are passed in their raw form,
+// which are set back by Set as is.
 func (m M) Range(f func(k, v string) bool) {
 	for k, v := range m {
 		if !f(k, v) {
@@ -58,6 +101,16 @@ func (m M) Range(f func(k, v string) bool) {
 	}
 }
 
+// Keys returns the keys of the metadata in sorted order.
+func (m M) Keys() []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
 // Clone clones the metadata.
 func (m M) Clone() M {
 	if m == nil {
@@ -73,10 +126,18 @@ func (m M) Clone() M {
 	return m2
 }
 
-// Encode encodes the metadata to byte array.
+// Encode encodes the metadata to byte array in msgpack.
 func (m M) Encode() ([]byte, error) {
+	return m.EncodeWith(EncodingMsgpack)
+}
+
+// EncodeWith encodes the metadata to byte array in the given encoding.
+func (m M) EncodeWith(enc Encoding) ([]byte, error) {
 	if len(m) == 0 {
 		return nil, nil
 	}
-	return msgpack.Marshal(m)
+	if enc == EncodingJSON {
+		return encodeJSON(m)
+	}
+	return encodeMsgpack(m)
 }
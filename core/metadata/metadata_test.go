@@ -57,6 +57,11 @@ func Test(t *testing.T) {
 		assert.Equal(t, "bbb", got)
 	})
 
+	t.Run("Keys", func(t *testing.T) {
+		assert.Equal(t, []string{"aaa", "ccc"}, md.Keys())
+		assert.Equal(t, []string{}, M(nil).Keys())
+	})
+
 	t.Run("Clone", func(t *testing.T) {
 		md2 := md.Clone()
 		assert.Equal(t, md, md2)
@@ -91,4 +96,18 @@ func Test(t *testing.T) {
 			assert.Equal(t, []byte(nil), b)
 		})
 	})
+
+	t.Run("Encode Decode JSON", func(t *testing.T) {
+		b, err := md.EncodeWith(EncodingJSON)
+		assert.NoError(t, err)
+		assert.Equal(t, EncodingJSON, EncodingOf(b))
+
+		md2, err := Decode(b)
+		assert.NoError(t, err)
+		assert.Equal(t, md, md2)
+
+		b, err = md.Encode()
+		assert.NoError(t, err)
+		assert.Equal(t, EncodingMsgpack, EncodingOf(b))
+	})
 }
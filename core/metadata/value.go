@@ -0,0 +1,291 @@
+package metadata
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/vmihailenco/msgpack/v5"
+	"github.com/vmihailenco/msgpack/v5/msgpcode"
+)
+
+// typedPrefix marks the value of M that is not a string, the value is kept in its msgpack encoding after it,
+// so the typed values go through the zipper untouched. 0xc1 is never used by msgpack and never starts
+// a valid UTF-8 string.
+const typedPrefix = "\xc1"
+
+// ErrKeyNotFound is returned by the typed getters of M if the key is absent.
+var ErrKeyNotFound = errors.New("metadata: key not found")
+
+// TypeError is returned by the typed getters of M if the value of the key is not of the requested type.
+type TypeError struct {
+	Key  string
+	Want string
+	Got  string
+}
+
+// Error implements error.
+func (e *TypeError) Error() string {
+	return fmt.Sprintf("metadata: the value of key %q is %s, not %s", e.Key, e.Got, e.Want)
+}
+
+// SetValue sets the value of the given key, the value is encoded in msgpack, so it can be any value
+// that msgpack encodes, such as numbers, bools, slices and maps. A string value is set like Set.
+func (m M) SetValue(k string, v any) error {
+	if s, ok := v.(string); ok {
+		m.Set(k, s)
+		return nil
+	}
+	b, err := msgpack.Marshal(v)
+	if err != nil {
+		return err
+	}
+	m.Set(k, typedPrefix+string(b))
+	return nil
+}
+
+// GetValue returns the value of the given key, the integers are int64 or uint64, the floats are float64,
+// and the maps are map[string]any.
+func (m M) GetValue(k string) (any, error) {
+	v, ok := m[k]
+	if !ok {
+		return nil, ErrKeyNotFound
+	}
+	raw, ok := typedValue(v)
+	if !ok {
+		return v, nil
+	}
+	return decodeTypedValue(raw)
+}
+
+// GetString returns the string value of the given key.
+func (m M) GetString(k string) (string, error) {
+	v, err := m.GetValue(k)
+	if err != nil {
+		return "", err
+	}
+	s, ok := v.(string)
+	if !ok {
+		return "", &TypeError{Key: k, Want: "string", Got: typeName(v)}
+	}
+	return s, nil
+}
+
+// GetInt returns the integer value of the given key.
+func (m M) GetInt(k string) (int64, error) {
+	v, err := m.GetValue(k)
+	if err != nil {
+		return 0, err
+	}
+	switch n := v.(type) {
+	case int64:
+		return n, nil
+	case uint64:
+		if n <= math.MaxInt64 {
+			return int64(n), nil
+		}
+	}
+	return 0, &TypeError{Key: k, Want: "int", Got: typeName(v)}
+}
+
+// GetFloat returns the float value of the given key, the integer is accepted as well.
+func (m M) GetFloat(k string) (float64, error) {
+	v, err := m.GetValue(k)
+	if err != nil {
+		return 0, err
+	}
+	switch n := v.(type) {
+	case float64:
+		return n, nil
+	case int64:
+		return float64(n), nil
+	case uint64:
+		return float64(n), nil
+	}
+	return 0, &TypeError{Key: k, Want: "float", Got: typeName(v)}
+}
+
+// GetBool returns the bool value of the given key.
+func (m M) GetBool(k string) (bool, error) {
+	v, err := m.GetValue(k)
+	if err != nil {
+		return false, err
+	}
+	b, ok := v.(bool)
+	if !ok {
+		return false, &TypeError{Key: k, Want: "bool", Got: typeName(v)}
+	}
+	return b, nil
+}
+
+// GetMap returns the map value of the given key.
+func (m M) GetMap(k string) (map[string]any, error) {
+	v, err := m.GetValue(k)
+	if err != nil {
+		return nil, err
+	}
+	mv, ok := v.(map[string]any)
+	if !ok {
+		return nil, &TypeError{Key: k, Want: "map", Got: typeName(v)}
+	}
+	return mv, nil
+}
+
+func typedValue(v string) ([]byte, bool) {
+	if len(v) <= len(typedPrefix) || v[:len(typedPrefix)] != typedPrefix {
+		return nil, false
+	}
+	return []byte(v[len(typedPrefix):]), true
+}
+
+func decodeTypedValue(raw []byte) (any, error) {
+	dec := msgpack.NewDecoder(bytes.NewReader(raw))
+	dec.UseLooseInterfaceDecoding(true)
+	return dec.DecodeInterfaceLoose()
+}
+
+func typeName(v any) string {
+	switch v.(type) {
+	case nil:
+		return "nil"
+	case string:
+		return "string"
+	case int64, uint64:
+		return "int"
+	case float64:
+		return "float"
+	case bool:
+		return "bool"
+	case map[string]any:
+		return "map"
+	case []any:
+		return "slice"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
+
+// isMsgpackString reports whether the encoded msgpack value is a string, a binary or nil,
+// which are decoded into the string values of M.
+func isMsgpackString(raw []byte) bool {
+	if len(raw) == 0 {
+		return false
+	}
+	c := raw[0]
+	return msgpcode.IsFixedString(c) || c == msgpcode.Nil ||
+		c == msgpcode.Str8 || c == msgpcode.Str16 || c == msgpcode.Str32 ||
+		c == msgpcode.Bin8 || c == msgpcode.Bin16 || c == msgpcode.Bin32
+}
+
+func decodeMsgpack(data []byte, m M) error {
+	var raws map[string]msgpack.RawMessage
+	if err := msgpack.Unmarshal(data, &raws); err != nil {
+		return err
+	}
+	for k, raw := range raws {
+		if !isMsgpackString(raw) {
+			m[k] = typedPrefix + string(raw)
+			continue
+		}
+		var s string
+		if err := msgpack.Unmarshal(raw, &s); err != nil {
+			return err
+		}
+		m[k] = s
+	}
+	return nil
+}
+
+func decodeJSON(data []byte, m M) error {
+	var raws map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raws); err != nil {
+		return err
+	}
+	for k, raw := range raws {
+		dec := json.NewDecoder(bytes.NewReader(raw))
+		dec.UseNumber()
+		var v any
+		if err := dec.Decode(&v); err != nil {
+			return err
+		}
+		switch vv := jsonNumbers(v).(type) {
+		case nil:
+			m[k] = ""
+		case string:
+			m[k] = vv
+		default:
+			if err := m.SetValue(k, vv); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// jsonNumbers converts the json.Numbers in v to int64 or float64, like the numbers decoded from msgpack.
+func jsonNumbers(v any) any {
+	switch vv := v.(type) {
+	case json.Number:
+		if n, err := vv.Int64(); err == nil {
+			return n
+		}
+		n, _ := vv.Float64()
+		return n
+	case map[string]any:
+		for k, e := range vv {
+			vv[k] = jsonNumbers(e)
+		}
+	case []any:
+		for i, e := range vv {
+			vv[i] = jsonNumbers(e)
+		}
+	}
+	return v
+}
+
+// hasTypedValue reports whether the metadata has any value that is not a string.
+func (m M) hasTypedValue() bool {
+	for _, v := range m {
+		if _, ok := typedValue(v); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func encodeMsgpack(m M) ([]byte, error) {
+	if !m.hasTypedValue() {
+		return msgpack.Marshal(map[string]string(m))
+	}
+	values := make(map[string]any, len(m))
+	for k, v := range m {
+		if raw, ok := typedValue(v); ok {
+			values[k] = msgpack.RawMessage(raw)
+		} else {
+			values[k] = v
+		}
+	}
+	return msgpack.Marshal(values)
+}
+
+func encodeJSON(m M) ([]byte, error) {
+	if !m.hasTypedValue() {
+		return json.Marshal(map[string]string(m))
+	}
+	values := make(map[string]any, len(m))
+	for k, v := range m {
+		raw, ok := typedValue(v)
+		if !ok {
+			values[k] = v
+			continue
+		}
+		tv, err := decodeTypedValue(raw)
+		if err != nil {
+			return nil, err
+		}
+		values[k] = tv
+	}
+	return json.Marshal(values)
+}
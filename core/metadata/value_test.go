@@ -0,0 +1,66 @@
+package metadata
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTypedValues(t *testing.T) {
+	md := M{"name": "yomo"}
+	assert.NoError(t, md.SetValue("count", 42))
+	assert.NoError(t, md.SetValue("ratio", 0.5))
+	assert.NoError(t, md.SetValue("ok", true))
+	assert.NoError(t, md.SetValue("nested", map[string]any{"a": 1}))
+
+	for _, enc := range []Encoding{EncodingMsgpack, EncodingJSON} {
+		t.Run(enc.String(), func(t *testing.T) {
+			b, err := md.EncodeWith(enc)
+			assert.NoError(t, err)
+
+			got, err := Decode(b)
+			assert.NoError(t, err)
+
+			name, err := got.GetString("name")
+			assert.NoError(t, err)
+			assert.Equal(t, "yomo", name)
+
+			count, err := got.GetInt("count")
+			assert.NoError(t, err)
+			assert.Equal(t, int64(42), count)
+
+			ratio, err := got.GetFloat("ratio")
+			assert.NoError(t, err)
+			assert.Equal(t, 0.5, ratio)
+
+			ok, err := got.GetBool("ok")
+			assert.NoError(t, err)
+			assert.True(t, ok)
+
+			nested, err := got.GetMap("nested")
+			assert.NoError(t, err)
+			assert.EqualValues(t, 1, nested["a"])
+
+			_, typed := got.Get("count")
+			assert.False(t, typed)
+
+			_, err = got.GetInt("name")
+			assert.Equal(t, &TypeError{Key: "name", Want: "int", Got: "string"}, err)
+
+			_, err = got.GetString("count")
+			assert.Equal(t, &TypeError{Key: "count", Want: "string", Got: "int"}, err)
+
+			_, err = got.GetBool("missing")
+			assert.ErrorIs(t, err, ErrKeyNotFound)
+		})
+	}
+
+	t.Run("string only", func(t *testing.T) {
+		b, err := M{"aaa": "bbb"}.Encode()
+		assert.NoError(t, err)
+
+		got, err := Decode(b)
+		assert.NoError(t, err)
+		assert.Equal(t, M{"aaa": "bbb"}, got)
+	})
+}
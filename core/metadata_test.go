@@ -5,6 +5,7 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/yomorun/yomo/core/frame"
 	"github.com/yomorun/yomo/core/metadata"
 	"golang.org/x/exp/slog"
 )
@@ -51,3 +52,30 @@ func TestMetadataSlogAttr(t *testing.T) {
 
 	assert.Equal(t, "level=DEBUG msg=\"test metadata\" metadata.aaaa=bbbb\n", buf.String())
 }
+
+func TestCheckMetadataSize(t *testing.T) {
+	md := bytes.Repeat([]byte{'a'}, DefaultMaxMetadataSize)
+
+	assert.NoError(t, checkMetadataSize(md, DefaultMaxMetadataSize))
+	assert.NoError(t, checkMetadataSize(append(md, 'a'), 0))
+
+	err := checkMetadataSize(append(md, 'a'), DefaultMaxMetadataSize)
+	assert.ErrorIs(t, err, ErrMetadataTooLarge)
+	assert.EqualError(t, err, "yomo: metadata is too large: 4097 bytes, the max is 4096")
+}
+
+func TestNamespaceObserveTagAuthorizer(t *testing.T) {
+	md := metadata.M{}
+	assert.Equal(t, frame.DefaultNamespace, GetNamespaceFromMetadata(md))
+	assert.True(t, NamespaceObserveTagAuthorizer(md, 0x33))
+	assert.False(t, NamespaceObserveTagAuthorizer(md, frame.NamespacedTag(2, 0x33)))
+	assert.False(t, NamespaceObserveTagAuthorizer(md, frame.TagFirehose))
+
+	SetNamespaceToMetadata(md, 2)
+	assert.Equal(t, frame.Namespace(2), GetNamespaceFromMetadata(md))
+	assert.True(t, NamespaceObserveTagAuthorizer(md, frame.NamespacedTag(2, 0x33)))
+	assert.False(t, NamespaceObserveTagAuthorizer(md, 0x33))
+
+	md.Set(MetadataNamespaceKey, "team-a")
+	assert.Equal(t, frame.DefaultNamespace, GetNamespaceFromMetadata(md))
+}
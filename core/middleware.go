@@ -0,0 +1,18 @@
+package core
+
+// FrameMiddleware wraps the FrameHandler that handles the frames read from the data streams,
+// it runs before the frame is dispatched. A middleware may:
+//   - pass the frame through by calling next,
+//   - modify the frame, such as the c.Frame and the c.FrameMetadata, then call next,
+//   - drop the frame by returning nil without calling next,
+//   - reject the frame by returning an error, the data stream is closed with the error.
+type FrameMiddleware func(next FrameHandler) FrameHandler
+
+// chainFrameMiddlewares wraps the handler with the middlewares, the first middleware is the outermost,
+// so the frame goes through the middlewares in order.
+func chainFrameMiddlewares(handler FrameHandler, middlewares []FrameMiddleware) FrameHandler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](handler)
+	}
+	return handler
+}
@@ -0,0 +1,52 @@
+package core
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChainFrameMiddlewares(t *testing.T) {
+	var calls []string
+	record := func(name string) FrameMiddleware {
+		return func(next FrameHandler) FrameHandler {
+			return func(c *Context) error {
+				calls = append(calls, name)
+				return next(c)
+			}
+		}
+	}
+	handler := func(c *Context) error {
+		calls = append(calls, "handler")
+		return nil
+	}
+
+	t.Run("in order", func(t *testing.T) {
+		calls = nil
+		h := chainFrameMiddlewares(handler, []FrameMiddleware{record("a"), record("b")})
+
+		assert.NoError(t, h(&Context{}))
+		assert.Equal(t, []string{"a", "b", "handler"}, calls)
+	})
+
+	t.Run("short circuit", func(t *testing.T) {
+		calls = nil
+		errRejected := errors.New("rejected")
+		reject := func(next FrameHandler) FrameHandler {
+			return func(c *Context) error { return errRejected }
+		}
+		h := chainFrameMiddlewares(handler, []FrameMiddleware{record("a"), reject, record("b")})
+
+		assert.ErrorIs(t, h(&Context{}), errRejected)
+		assert.Equal(t, []string{"a"}, calls)
+	})
+
+	t.Run("no middleware", func(t *testing.T) {
+		calls = nil
+		h := chainFrameMiddlewares(handler, nil)
+
+		assert.NoError(t, h(&Context{}))
+		assert.Equal(t, []string{"handler"}, calls)
+	})
+}
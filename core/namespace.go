@@ -0,0 +1,50 @@
+package core
+
+import (
+	"strconv"
+
+	"github.com/yomorun/yomo/core/frame"
+	"github.com/yomorun/yomo/core/metadata"
+)
+
+// MetadataNamespaceKey is the key of the tag namespace of the connection in the metadata, the verifier of the
+// authentication sets it, see VerifyAuthenticationFunc, the one sent by the client is dropped.
+// The connections without it are in frame.DefaultNamespace.
+const MetadataNamespaceKey = "yomo-namespace"
+
+// GetNamespaceFromMetadata gets the tag namespace from metadata,
+// it returns frame.DefaultNamespace if the namespace is absent or invalid.
+func GetNamespaceFromMetadata(m metadata.M) frame.Namespace {
+	v, ok := m.Get(MetadataNamespaceKey)
+	if !ok {
+		return frame.DefaultNamespace
+	}
+	ns, err := strconv.ParseUint(v, 10, 8)
+	if err != nil {
+		return frame.DefaultNamespace
+	}
+	return frame.Namespace(ns)
+}
+
+// SetNamespaceToMetadata sets the tag namespace to metadata.
+func SetNamespaceToMetadata(m metadata.M, ns frame.Namespace) {
+	m.Set(MetadataNamespaceKey, strconv.FormatUint(uint64(ns), 10))
+}
+
+// NamespaceObserveTagAuthorizer is the ObserveTagAuthorizer that allows the stream to observe
+// the tags in its own namespace only, frame.TagFirehose is denied.
+func NamespaceObserveTagAuthorizer(md metadata.M, tag frame.Tag) bool {
+	return tag != frame.TagFirehose && frame.TagNamespace(tag) == GetNamespaceFromMetadata(md)
+}
+
+// stripNamespace removes the tag namespace set by the client itself from the metadata of the handshake or
+// the metadata update, the namespace is set by the verifier of the authentication only.
+func stripNamespace(md metadata.M) {
+	delete(md, MetadataNamespaceKey)
+}
+
+// authorizeNamespace reports whether the stream is allowed to write the DataFrame of the tag,
+// the stream writes the tags in its own namespace only.
+func authorizeNamespace(md metadata.M, tag frame.Tag) bool {
+	return frame.TagNamespace(tag) == GetNamespaceFromMetadata(md)
+}
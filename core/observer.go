@@ -0,0 +1,49 @@
+package core
+
+import "github.com/yomorun/yomo/core/frame"
+
+// ServerObserver observes the lifecycle of the connections and the data streams of the server,
+// and the frames read and written by them. It is the hook for exporting metrics, see the pkg/metrics package.
+// The methods are called on the paths of reading and writing frames, they must be safe for concurrent use
+// and return quickly.
+type ServerObserver interface {
+	// ConnectionOpened is called when a connection is authenticated.
+	ConnectionOpened()
+	// ConnectionClosed is called when an authenticated connection is closed.
+	ConnectionClosed()
+	// StreamOpened is called when a data stream is opened by the handshake.
+	StreamOpened(streamType StreamType)
+	// StreamClosed is called when a data stream is closed.
+	StreamClosed(streamType StreamType)
+	// FrameRead is called when a frame is read, n is the size of the encoded frame.
+	FrameRead(ftyp frame.Type, n int)
+	// FrameWritten is called when a frame is written, n is the size of the encoded frame.
+	FrameWritten(ftyp frame.Type, n int)
+	// HandshakeRejected is called when a handshake is rejected with the reason.
+	HandshakeRejected(reason frame.RejectCode)
+}
+
+// ConnectionLabelsObserver is the ServerObserver that observes the labels of the connections as well,
+// the server calls it with the labels sent with the authentication, see WithLabels.
+type ConnectionLabelsObserver interface {
+	// LabeledConnectionOpened is called when a connection is authenticated.
+	LabeledConnectionOpened(labels map[string]string)
+	// LabeledConnectionClosed is called when an authenticated connection is closed.
+	LabeledConnectionClosed(labels map[string]string)
+}
+
+type nopServerObserver struct{}
+
+func (nopServerObserver) ConnectionOpened()                  {}
+func (nopServerObserver) ConnectionClosed()                  {}
+func (nopServerObserver) StreamOpened(StreamType)            {}
+func (nopServerObserver) StreamClosed(StreamType)            {}
+func (nopServerObserver) FrameRead(frame.Type, int)          {}
+func (nopServerObserver) FrameWritten(frame.Type, int)       {}
+func (nopServerObserver) HandshakeRejected(frame.RejectCode) {}
+
+// observableConnection is the connection that reports the frames read and written by its streams
+// to the ServerObserver, the server sets the observer once the connection is accepted.
+type observableConnection interface {
+	setObserver(observer ServerObserver)
+}
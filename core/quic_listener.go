@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/quic-go/quic-go"
+	"github.com/yomorun/yomo/core/yerr"
 	pkgtls "github.com/yomorun/yomo/pkg/tls"
 	"golang.org/x/exp/slog"
 )
@@ -26,7 +27,26 @@ func (ql *quicListener) Accept(ctx context.Context) (Connection, error) {
 		return nil, err
 	}
 
-	return &QuicConnection{qconn}, nil
+	return newQuicConnection(qconn), nil
+}
+
+// quicEarlyListener implements Listener interface, it accepts the connections before their handshakes complete,
+// so the clients can send 0-RTT data.
+type quicEarlyListener struct {
+	underlying *quic.EarlyListener
+}
+
+var _ Listener = (*quicEarlyListener)(nil)
+
+func (ql *quicEarlyListener) Addr() net.Addr { return ql.underlying.Addr() }
+func (ql *quicEarlyListener) Close() error   { return ql.underlying.Close() }
+func (ql *quicEarlyListener) Accept(ctx context.Context) (Connection, error) {
+	qconn, err := ql.underlying.Accept(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return newQuicConnection(qconn), nil
 }
 
 // DefalutQuicConfig be used when `quicConfig` is nil.
@@ -42,8 +62,49 @@ var DefalutQuicConfig = &quic.Config{
 	// DisablePathMTUDiscovery:        true,
 }
 
+// DefaultALPN is the application protocol negotiated by default.
+const DefaultALPN = "yomo"
+
+// tlsConfigWithALPN returns a copy of the tls config that negotiates the alpn,
+// if the alpn is empty, the NextProtos of the config is kept, or DefaultALPN if it is empty too.
+func tlsConfigWithALPN(tc *tls.Config, alpn []string) *tls.Config {
+	tc = tc.Clone()
+	if len(alpn) > 0 {
+		tc.NextProtos = alpn
+	} else if len(tc.NextProtos) == 0 {
+		tc.NextProtos = []string{DefaultALPN}
+	}
+	return tc
+}
+
+// quicConfigWithKeepAlive returns a copy of the quic config that overrides the KeepAlivePeriod and
+// the MaxIdleTimeout by the non-zero ones.
+func quicConfigWithKeepAlive(qc *quic.Config, keepAlivePeriod, maxIdleTimeout time.Duration) *quic.Config {
+	if keepAlivePeriod == 0 && maxIdleTimeout == 0 {
+		return qc
+	}
+	if qc == nil {
+		qc = &quic.Config{}
+	}
+	qc = qc.Clone()
+	if keepAlivePeriod != 0 {
+		qc.KeepAlivePeriod = keepAlivePeriod
+	}
+	if maxIdleTimeout != 0 {
+		qc.MaxIdleTimeout = maxIdleTimeout
+	}
+	return qc
+}
+
 // NewQuicListener returns quic Listener.
 func NewQuicListener(conn net.PacketConn, tlsConfig *tls.Config, quicConfig *quic.Config, logger *slog.Logger) (Listener, error) {
+	return newQuicListener(conn, tlsConfig, nil, quicConfig, false, logger)
+}
+
+// newQuicListener returns the quic Listener, if allow0RTT is true, it accepts the 0-RTT connections.
+func newQuicListener(
+	conn net.PacketConn, tlsConfig *tls.Config, alpn []string, quicConfig *quic.Config, allow0RTT bool, logger *slog.Logger,
+) (Listener, error) {
 	if tlsConfig == nil {
 		tc, err := pkgtls.CreateServerTLSConfig(conn.LocalAddr().String())
 		if err != nil {
@@ -57,7 +118,15 @@ func NewQuicListener(conn net.PacketConn, tlsConfig *tls.Config, quicConfig *qui
 		quicConfig = DefalutQuicConfig
 	}
 
-	ql, err := quic.Listen(conn, tlsConfig, quicConfig)
+	if allow0RTT {
+		quicConfig = quicConfig.Clone()
+		quicConfig.Allow0RTT = true
+
+		ql, err := quic.ListenEarly(conn, tlsConfigWithALPN(tlsConfig, alpn), quicConfig)
+		return &quicEarlyListener{ql}, err
+	}
+
+	ql, err := quic.Listen(conn, tlsConfigWithALPN(tlsConfig, alpn), quicConfig)
 	if err != nil {
 		return &quicListener{ql}, err
 	}
@@ -67,7 +136,15 @@ func NewQuicListener(conn net.PacketConn, tlsConfig *tls.Config, quicConfig *qui
 
 // QuicConnection implements Connection interface.
 type QuicConnection struct {
-	conn quic.Connection
+	conn  quic.Connection
+	stats *statsRecorder
+}
+
+func newQuicConnection(conn quic.Connection) *QuicConnection {
+	return &QuicConnection{
+		conn:  conn,
+		stats: new(statsRecorder),
+	}
 }
 
 // YomoCloseErrorCode is the error code for close quic Connection for yomo.
@@ -86,15 +163,61 @@ func (qc *QuicConnection) RemoteAddr() string {
 
 // OpenStream opens a new bidirectional QUIC stream.
 func (qc *QuicConnection) OpenStream() (ContextReadWriteCloser, error) {
-	return qc.conn.OpenStream()
+	stream, err := qc.conn.OpenStream()
+	if err != nil {
+		return nil, err
+	}
+	return &statsStream{stream, qc.stats}, nil
 }
 
 // AcceptStream returns the next stream opened by the peer, blocking until one is available.
 func (qc *QuicConnection) AcceptStream(ctx context.Context) (ContextReadWriteCloser, error) {
-	return qc.conn.AcceptStream(ctx)
+	stream, err := qc.conn.AcceptStream(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &statsStream{stream, qc.stats}, nil
 }
 
 // CloseWithError closes the connection with an error string.
 func (qc *QuicConnection) CloseWithError(errString string) error {
 	return qc.conn.CloseWithError(YomoCloseErrorCode, errString)
 }
+
+// CloseWithCode closes the connection with the yerr code as the QUIC application error code,
+// the peer translates it back by yerr.FromError.
+func (qc *QuicConnection) CloseWithCode(code yerr.ErrorCode, errString string) error {
+	return qc.conn.CloseWithError(code.To(), errString)
+}
+
+// Stats returns the stats of frames written to the connection.
+func (qc *QuicConnection) Stats() ConnectionStats {
+	return qc.stats.snapshot()
+}
+
+func (qc *QuicConnection) recordDataStream(delta int64) { qc.stats.recordDataStream(delta) }
+
+func (qc *QuicConnection) setObserver(observer ServerObserver) { qc.stats.observer = observer }
+
+// waitHandshakeComplete waits for the handshake of the connection to complete, the data received before it
+// may be the 0-RTT data that can be replayed. It returns an error if the connection is closed or the ctx is done.
+func (qc *QuicConnection) waitHandshakeComplete(ctx context.Context) error {
+	ec, ok := qc.conn.(quic.EarlyConnection)
+	if !ok {
+		return nil
+	}
+	select {
+	case <-ec.HandshakeComplete():
+		return nil
+	case <-qc.conn.Context().Done():
+		return context.Cause(qc.conn.Context())
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// ConnectionState returns the state of the underlying QUIC connection, such as the negotiated ALPN,
+// the TLS version and whether 0-RTT was used. It is read-only and safe to call at any time.
+func (qc *QuicConnection) ConnectionState() quic.ConnectionState {
+	return qc.conn.ConnectionState()
+}
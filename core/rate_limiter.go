@@ -0,0 +1,139 @@
+package core
+
+import (
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/yomorun/yomo/core/frame"
+)
+
+// RateLimit is the rate of a token bucket, Rate is the number of DataFrames allowed per second
+// and Burst is the max number of DataFrames allowed at once. A zero Rate means unlimited.
+type RateLimit struct {
+	Rate  float64
+	Burst int
+}
+
+// RateLimitPolicy decides what to do with a DataFrame that exceeds the rate limit.
+type RateLimitPolicy int
+
+const (
+	// RateLimitDrop drops the DataFrame that exceeds the rate limit.
+	RateLimitDrop RateLimitPolicy = iota
+	// RateLimitDelay delays the DataFrame until the rate limit allows it,
+	// the data stream that sends the DataFrame is blocked during the delay.
+	// The tokens reserved ahead are bounded by a second of the Rate or the Burst, whichever is larger,
+	// the DataFrame that would be delayed beyond it is dropped.
+	RateLimitDelay
+)
+
+// tagRateLimiter limits the rate of DataFrames by tag, every tag has its own token bucket.
+type tagRateLimiter struct {
+	defaultLimit RateLimit
+	limits       map[frame.Tag]RateLimit
+	policy       RateLimitPolicy
+	dropped      atomic.Int64
+
+	mu      sync.Mutex
+	buckets map[frame.Tag]*tokenBucket
+}
+
+func newTagRateLimiter(defaultLimit RateLimit, limits map[frame.Tag]RateLimit, policy RateLimitPolicy) *tagRateLimiter {
+	return &tagRateLimiter{
+		defaultLimit: defaultLimit,
+		limits:       limits,
+		policy:       policy,
+		buckets:      make(map[frame.Tag]*tokenBucket),
+	}
+}
+
+// wait returns how long the DataFrame with the tag should be delayed,
+// it returns false if the DataFrame should be dropped.
+func (l *tagRateLimiter) wait(tag frame.Tag, now time.Time) (time.Duration, bool) {
+	bucket := l.bucket(tag)
+	if bucket == nil {
+		return 0, true
+	}
+
+	d := bucket.take(now, l.policy == RateLimitDelay)
+	if d < 0 {
+		l.dropped.Add(1)
+		return 0, false
+	}
+	return d, true
+}
+
+func (l *tagRateLimiter) bucket(tag frame.Tag) *tokenBucket {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if bucket, ok := l.buckets[tag]; ok {
+		return bucket
+	}
+
+	limit, ok := l.limits[tag]
+	if !ok {
+		limit = l.defaultLimit
+	}
+	if limit.Rate <= 0 {
+		l.buckets[tag] = nil
+		return nil
+	}
+
+	bucket := newTokenBucket(limit)
+	bucket.maxDebt = math.Max(bucket.burst, limit.Rate)
+	l.buckets[tag] = bucket
+
+	return bucket
+}
+
+// tokenBucket is a token bucket that refills at a constant rate.
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+	// maxDebt is the max number of the tokens reserved ahead, zero means unlimited.
+	maxDebt float64
+}
+
+func newTokenBucket(limit RateLimit) *tokenBucket {
+	burst := float64(limit.Burst)
+	if burst < 1 {
+		burst = 1
+	}
+	return &tokenBucket{
+		rate:   limit.Rate,
+		burst:  burst,
+		tokens: burst,
+	}
+}
+
+// take takes a token from the bucket. If there is no token, it returns -1 when reserve is false or
+// the maxDebt is reached, otherwise it reserves a future token and returns how long to wait for it.
+func (b *tokenBucket) take(now time.Time, reserve bool) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.last.IsZero() {
+		b.tokens += now.Sub(b.last).Seconds() * b.rate
+		if b.tokens > b.burst {
+			b.tokens = b.burst
+		}
+	}
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0
+	}
+	if !reserve || b.maxDebt > 0 && b.tokens-1 < -b.maxDebt {
+		return -1
+	}
+	b.tokens--
+
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
@@ -0,0 +1,69 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yomorun/yomo/core/frame"
+)
+
+func TestTagRateLimiter(t *testing.T) {
+	now := time.Now()
+
+	t.Run("drop", func(t *testing.T) {
+		limiter := newTagRateLimiter(RateLimit{Rate: 1, Burst: 2}, map[frame.Tag]RateLimit{2: {}}, RateLimitDrop)
+
+		for i := 0; i < 2; i++ {
+			_, ok := limiter.wait(1, now)
+			assert.True(t, ok)
+		}
+		_, ok := limiter.wait(1, now)
+		assert.False(t, ok)
+		assert.Equal(t, int64(1), limiter.dropped.Load())
+
+		// refilled after a second.
+		_, ok = limiter.wait(1, now.Add(time.Second))
+		assert.True(t, ok)
+
+		// tag 2 is unlimited.
+		for i := 0; i < 10; i++ {
+			_, ok := limiter.wait(2, now)
+			assert.True(t, ok)
+		}
+	})
+
+	t.Run("delay", func(t *testing.T) {
+		limiter := newTagRateLimiter(RateLimit{Rate: 10, Burst: 1}, nil, RateLimitDelay)
+
+		d, ok := limiter.wait(1, now)
+		assert.True(t, ok)
+		assert.Equal(t, time.Duration(0), d)
+
+		d, ok = limiter.wait(1, now)
+		assert.True(t, ok)
+		assert.Equal(t, 100*time.Millisecond, d)
+
+		d, ok = limiter.wait(1, now)
+		assert.True(t, ok)
+		assert.Equal(t, 200*time.Millisecond, d)
+		assert.Equal(t, int64(0), limiter.dropped.Load())
+	})
+
+	t.Run("delay bounded", func(t *testing.T) {
+		limiter := newTagRateLimiter(RateLimit{Rate: 10, Burst: 1}, nil, RateLimitDelay)
+
+		_, ok := limiter.wait(1, now)
+		assert.True(t, ok)
+
+		// a second of the rate is reserved ahead at most.
+		for i := 1; i <= 10; i++ {
+			d, ok := limiter.wait(1, now)
+			assert.True(t, ok)
+			assert.Equal(t, time.Duration(i)*100*time.Millisecond, d)
+		}
+		_, ok = limiter.wait(1, now)
+		assert.False(t, ok)
+		assert.Equal(t, int64(1), limiter.dropped.Load())
+	})
+}
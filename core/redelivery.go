@@ -0,0 +1,99 @@
+package core
+
+import (
+	"bytes"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/yomorun/yomo/core/frame"
+	"github.com/yomorun/yomo/core/metadata"
+)
+
+// MetadataDeliveryAttemptKey is the key of the delivery attempt of the DataFrame in the metadata, it starts from 1,
+// the stream function can tell the redelivered DataFrames by it, see WithServerRedelivery.
+const MetadataDeliveryAttemptKey = "yomo-delivery-attempt"
+
+// GetDeliveryAttemptFromMetadata gets the delivery attempt of the DataFrame from metadata, it returns 1 if there is no valid attempt.
+func GetDeliveryAttemptFromMetadata(m metadata.M) int {
+	v, _ := m.Get(MetadataDeliveryAttemptKey)
+	attempt, err := strconv.Atoi(v)
+	if err != nil || attempt < 1 {
+		return 1
+	}
+	return attempt
+}
+
+// SetDeliveryAttemptToMetadata sets the delivery attempt of the DataFrame to metadata.
+func SetDeliveryAttemptToMetadata(m metadata.M, attempt int) {
+	m.Set(MetadataDeliveryAttemptKey, strconv.Itoa(attempt))
+}
+
+// redelivery re-delivers the DataFrames given up by the stream functions after a backoff,
+// until the DataFrame has been delivered for maxAttempts times, then it is dead-lettered.
+type redelivery struct {
+	maxAttempts int
+	backoff     Backoff
+	count       atomic.Int64
+}
+
+// newRedelivery returns a redelivery, it returns nil if maxAttempts is not greater than 1,
+// which means the redelivery is disabled.
+func newRedelivery(maxAttempts int, backoff Backoff) *redelivery {
+	if maxAttempts <= 1 {
+		return nil
+	}
+	return &redelivery{maxAttempts: maxAttempts, backoff: backoff}
+}
+
+// wait returns the backoff before the given attempt, the backoff before the second attempt is the initial one.
+func (r *redelivery) wait(attempt int) time.Duration {
+	t := newBackoffTimer(r.backoff)
+	t.attempt = attempt - 2
+	return t.next()
+}
+
+// redeliver re-delivers a copy of the DataFrame given up by the stream function of the context to it after the backoff,
+// the delivery attempt is increased in the metadata. It reports false if the DataFrame has run out of the attempts,
+// or the DataFrame is not given up by a stream function, the DataFrame should be dead-lettered then.
+// The redelivery does not block the frames handled meanwhile.
+func (s *Server) redeliver(c *Context) bool {
+	if s.redelivery == nil || c.DataStream.StreamType() != StreamTypeStreamFunction {
+		return false
+	}
+	attempt := GetDeliveryAttemptFromMetadata(c.FrameMetadata)
+	if attempt >= s.redelivery.maxAttempts {
+		return false
+	}
+	SetDeliveryAttemptToMetadata(c.FrameMetadata, attempt+1)
+	md, err := c.FrameMetadata.EncodeWith(metadata.EncodingOf(c.Frame.Metadata))
+	if err != nil {
+		c.Logger.Error("encode metadata error", "err", err)
+		return false
+	}
+	// the frame read is released once it is handled, the redelivery holds a copy.
+	df := &frame.DataFrame{
+		Metadata: md,
+		Tag:      c.Frame.Tag,
+		Payload:  bytes.Clone(c.Frame.Payload),
+		TTL:      c.Frame.TTL,
+	}
+	stream := c.DataStream
+	wait := s.redelivery.wait(attempt + 1)
+	s.redelivery.count.Add(1)
+
+	c.Logger.Debug("redeliver data frame", "data_tag", df.Tag, "attempt", attempt+1, "wait", wait)
+	s.opts.clock.AfterFunc(wait, func() {
+		select {
+		case <-stream.Context().Done():
+			s.logger.Debug("redelivery dropped, the stream is closed", "data_tag", df.Tag, "stream_id", stream.ID())
+			return
+		default:
+		}
+		if err := s.writeToStream(stream, df); err != nil {
+			s.logger.Error("failed to redeliver data frame", "err", err, "stream_id", stream.ID())
+		}
+	})
+
+	return true
+}
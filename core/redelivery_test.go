@@ -0,0 +1,32 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yomorun/yomo/core/metadata"
+)
+
+func TestDeliveryAttemptMetadata(t *testing.T) {
+	md := metadata.M{}
+	assert.Equal(t, 1, GetDeliveryAttemptFromMetadata(md))
+
+	SetDeliveryAttemptToMetadata(md, 3)
+	assert.Equal(t, 3, GetDeliveryAttemptFromMetadata(md))
+
+	md.Set(MetadataDeliveryAttemptKey, "bad")
+	assert.Equal(t, 1, GetDeliveryAttemptFromMetadata(md))
+}
+
+func TestRedelivery(t *testing.T) {
+	assert.Nil(t, newRedelivery(0, Backoff{}))
+	assert.Nil(t, newRedelivery(1, Backoff{}))
+
+	r := newRedelivery(5, Backoff{Initial: 10 * time.Millisecond, Max: 30 * time.Millisecond})
+
+	assert.Equal(t, 10*time.Millisecond, r.wait(2))
+	assert.Equal(t, 20*time.Millisecond, r.wait(3))
+	assert.Equal(t, 30*time.Millisecond, r.wait(4))
+	assert.Equal(t, 30*time.Millisecond, r.wait(5))
+}
@@ -0,0 +1,228 @@
+package core
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/yomorun/yomo/core/frame"
+)
+
+// ReorderWindow configures the reorder buffer that delivers the sequenced DataFrames in sequence order within a tag.
+// Size is the max number of out-of-order DataFrames held for a tag, and Hold is the max time a DataFrame
+// is held waiting for the missing ones. Once either one is exceeded, the buffer skips the missing DataFrames.
+// A non-positive Size disables the reorder buffer.
+//
+// The sequences are counted by each stream, so the sources sequence the DataFrames of a tag independently,
+// and a source starts over once its stream is closed.
+type ReorderWindow struct {
+	Size int
+	Hold time.Duration
+}
+
+// reorderEntry is a DataFrame held by the reorder buffer, with the stream it comes from.
+type reorderEntry struct {
+	stream   DataStream
+	frame    *frame.DataFrame
+	received time.Time
+}
+
+// tagReorder is the reorder state of a tag of a stream.
+type tagReorder struct {
+	// mu is held while delivering, so the DataFrames of the tag are delivered one by one.
+	mu      sync.Mutex
+	next    uint64
+	pending map[uint64]reorderEntry
+	// timer flushes the held DataFrames once the oldest one has been held for the Hold,
+	// so they are delivered even if no more DataFrame of the tag arrives.
+	timer Timer
+	// forgotten is set once the stream is closed, the timer fired late does nothing then.
+	forgotten bool
+}
+
+// reorderBuffer reorders the sequenced DataFrames by stream and tag, the DataFrames without sequence are delivered directly.
+type reorderBuffer struct {
+	window  ReorderWindow
+	clock   Clock
+	dropped atomic.Int64
+	// release delivers the DataFrames flushed by the timers, out of the push of their streams.
+	release func(DataStream, *frame.DataFrame)
+
+	mu      sync.Mutex
+	streams map[DataStream]map[frame.Tag]*tagReorder
+}
+
+func newReorderBuffer(window ReorderWindow, clock Clock, release func(DataStream, *frame.DataFrame)) *reorderBuffer {
+	if window.Size <= 0 {
+		return nil
+	}
+	return &reorderBuffer{
+		window:  window,
+		clock:   clock,
+		release: release,
+		streams: make(map[DataStream]map[frame.Tag]*tagReorder),
+	}
+}
+
+// push pushes the DataFrame to the buffer and calls deliver with the DataFrames those are ready in sequence order,
+// the DataFrame arrives after the window has advanced past it is dropped.
+func (b *reorderBuffer) push(stream DataStream, f *frame.DataFrame, now time.Time, deliver func(DataStream, *frame.DataFrame)) {
+	if b == nil || f.Seq == 0 {
+		deliver(stream, f)
+		return
+	}
+
+	t := b.tag(stream, f.Tag)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.next == 0 {
+		t.next = f.Seq
+	}
+	if f.Seq < t.next {
+		b.dropped.Add(1)
+		return
+	}
+	if _, ok := t.pending[f.Seq]; ok {
+		// duplicated.
+		b.dropped.Add(1)
+		return
+	}
+	t.pending[f.Seq] = reorderEntry{stream: stream, frame: f, received: now}
+
+	b.deliver(t, now, deliver)
+}
+
+// deliver delivers the DataFrames those are ready in sequence order, and arms the timer for the ones still held.
+// The caller must hold the mu of t.
+func (b *reorderBuffer) deliver(t *tagReorder, now time.Time, deliver func(DataStream, *frame.DataFrame)) {
+	for {
+		// deliver the DataFrames in sequence.
+		for {
+			entry, ok := t.pending[t.next]
+			if !ok {
+				break
+			}
+			delete(t.pending, t.next)
+			t.next++
+			deliver(entry.stream, entry.frame)
+		}
+		if !b.overflow(t, now) {
+			break
+		}
+		// skip the missing DataFrames, advance to the smallest held one.
+		t.next = t.smallest()
+	}
+	b.arm(t, now)
+}
+
+// arm arms the timer of t to fire once the oldest DataFrame held reaches the Hold. The caller must hold the mu of t.
+func (b *reorderBuffer) arm(t *tagReorder, now time.Time) {
+	if b.window.Hold <= 0 {
+		return
+	}
+	if len(t.pending) == 0 {
+		if t.timer != nil {
+			t.timer.Stop()
+		}
+		return
+	}
+	var oldest time.Time
+	for _, entry := range t.pending {
+		if oldest.IsZero() || entry.received.Before(oldest) {
+			oldest = entry.received
+		}
+	}
+	d := oldest.Add(b.window.Hold).Sub(now)
+	if t.timer == nil {
+		t.timer = b.clock.AfterFunc(d, func() { b.flush(t) })
+		return
+	}
+	t.timer.Reset(d)
+}
+
+// flush delivers the DataFrames held for the Hold, it is called by the timer of t.
+func (b *reorderBuffer) flush(t *tagReorder) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.forgotten {
+		return
+	}
+	b.deliver(t, b.clock.Now(), b.release)
+}
+
+// forget drops the reorder state of the stream once it is closed, the DataFrames held are delivered
+// as the missing ones never arrive, so the stream of the same source starts over from any sequence.
+func (b *reorderBuffer) forget(stream DataStream) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	tags := b.streams[stream]
+	delete(b.streams, stream)
+	b.mu.Unlock()
+
+	for _, t := range tags {
+		t.mu.Lock()
+		t.forgotten = true
+		if t.timer != nil {
+			t.timer.Stop()
+		}
+		for len(t.pending) > 0 {
+			t.next = t.smallest()
+			entry := t.pending[t.next]
+			delete(t.pending, t.next)
+			b.release(entry.stream, entry.frame)
+		}
+		t.mu.Unlock()
+	}
+}
+
+// overflow reports whether the held DataFrames of the tag exceed the window.
+func (b *reorderBuffer) overflow(t *tagReorder, now time.Time) bool {
+	if len(t.pending) == 0 {
+		return false
+	}
+	if len(t.pending) > b.window.Size {
+		return true
+	}
+	if b.window.Hold <= 0 {
+		return false
+	}
+	for _, entry := range t.pending {
+		if now.Sub(entry.received) >= b.window.Hold {
+			return true
+		}
+	}
+	return false
+}
+
+// smallest returns the smallest sequence held, the pending must not be empty.
+func (t *tagReorder) smallest() uint64 {
+	var min uint64
+	for seq := range t.pending {
+		if min == 0 || seq < min {
+			min = seq
+		}
+	}
+	return min
+}
+
+func (b *reorderBuffer) tag(stream DataStream, tag frame.Tag) *tagReorder {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	tags, ok := b.streams[stream]
+	if !ok {
+		tags = make(map[frame.Tag]*tagReorder)
+		b.streams[stream] = tags
+	}
+	t, ok := tags[tag]
+	if !ok {
+		t = &tagReorder{pending: make(map[uint64]reorderEntry)}
+		tags[tag] = t
+	}
+	return t
+}
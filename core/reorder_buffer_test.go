@@ -0,0 +1,101 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yomorun/yomo/core/frame"
+)
+
+func TestReorderBuffer(t *testing.T) {
+	now := time.Now()
+
+	pushFrom := func(b *reorderBuffer, stream DataStream, seqs ...uint64) []uint64 {
+		got := []uint64{}
+		for _, seq := range seqs {
+			b.push(stream, &frame.DataFrame{Tag: 1, Seq: seq}, b.clock.Now(), func(_ DataStream, f *frame.DataFrame) {
+				got = append(got, f.Seq)
+			})
+		}
+		return got
+	}
+	push := func(b *reorderBuffer, seqs ...uint64) []uint64 {
+		return pushFrom(b, nil, seqs...)
+	}
+	newBuffer := func(window ReorderWindow) (*reorderBuffer, *ManualClock, *[]uint64) {
+		clock := NewManualClock(now)
+		released := []uint64{}
+		b := newReorderBuffer(window, clock, func(_ DataStream, f *frame.DataFrame) {
+			released = append(released, f.Seq)
+		})
+		return b, clock, &released
+	}
+
+	t.Run("in order", func(t *testing.T) {
+		b, _, _ := newBuffer(ReorderWindow{Size: 2})
+		assert.Equal(t, []uint64{1, 2, 3, 4}, push(b, 1, 3, 2, 4))
+		assert.Equal(t, int64(0), b.dropped.Load())
+	})
+
+	t.Run("window advanced", func(t *testing.T) {
+		b, _, _ := newBuffer(ReorderWindow{Size: 2})
+		assert.Equal(t, []uint64{1, 3, 4, 5}, push(b, 1, 3, 4, 5))
+		// 2 arrives after the window has advanced past it.
+		assert.Equal(t, []uint64{}, push(b, 2))
+		assert.Equal(t, int64(1), b.dropped.Load())
+	})
+
+	t.Run("hold timeout", func(t *testing.T) {
+		b, clock, released := newBuffer(ReorderWindow{Size: 10, Hold: time.Second})
+		assert.Equal(t, []uint64{1}, push(b, 1, 3))
+
+		clock.Advance(2 * time.Second)
+		assert.Equal(t, []uint64{3}, *released)
+		assert.Equal(t, []uint64{4}, push(b, 4))
+	})
+
+	t.Run("hold timeout of a quiet tag", func(t *testing.T) {
+		b, clock, released := newBuffer(ReorderWindow{Size: 10, Hold: time.Second})
+		assert.Equal(t, []uint64{1}, push(b, 1, 3))
+
+		clock.Advance(500 * time.Millisecond)
+		assert.Equal(t, []uint64{}, push(b, 5))
+		assert.Empty(t, *released)
+
+		// no more DataFrame of the tag arrives, the held ones are flushed once they reach the Hold.
+		clock.Advance(500 * time.Millisecond)
+		assert.Equal(t, []uint64{3}, *released)
+		clock.Advance(500 * time.Millisecond)
+		assert.Equal(t, []uint64{3, 5}, *released)
+		assert.Equal(t, 0, clock.Timers())
+	})
+
+	t.Run("sequenced by each stream", func(t *testing.T) {
+		b, _, _ := newBuffer(ReorderWindow{Size: 2})
+		s1, s2 := &dataStream{id: "s1"}, &dataStream{id: "s2"}
+		assert.Equal(t, []uint64{1, 2, 3}, pushFrom(b, s1, 1, 2, 3))
+		assert.Equal(t, []uint64{1, 2}, pushFrom(b, s2, 1, 2))
+		assert.Equal(t, int64(0), b.dropped.Load())
+	})
+
+	t.Run("restarting sequence", func(t *testing.T) {
+		b, _, released := newBuffer(ReorderWindow{Size: 2, Hold: time.Second})
+		s1 := &dataStream{id: "source"}
+		assert.Equal(t, []uint64{1, 2}, pushFrom(b, s1, 1, 2, 4))
+
+		// the stream is closed, the held DataFrames are released.
+		b.forget(s1)
+		assert.Equal(t, []uint64{4}, *released)
+
+		// the source reconnects with the same id and starts over from 1.
+		s2 := &dataStream{id: "source"}
+		assert.Equal(t, []uint64{1, 2, 3}, pushFrom(b, s2, 1, 2, 3))
+		assert.Equal(t, int64(0), b.dropped.Load())
+	})
+
+	t.Run("not sequenced", func(t *testing.T) {
+		b, _, _ := newBuffer(ReorderWindow{Size: 2})
+		assert.Equal(t, []uint64{0, 0}, push(b, 0, 0))
+	})
+}
@@ -67,15 +67,20 @@ func (r *defaultRoute) Add(connID string, name string, observeDataTags []frame.T
 
 LOOP:
 	for _, conns := range r.data {
-		for connID, n := range conns {
-			if n == name {
-				err = yerr.NewDuplicateNameError(connID, fmt.Errorf("SFN[%s] is already linked to another stream", name))
-				delete(conns, connID)
+		for id, n := range conns {
+			if n == name && id != connID {
+				err = yerr.NewDuplicateNameError(id, fmt.Errorf("SFN[%s] is already linked to another stream", name))
+				delete(conns, id)
 				break LOOP
 			}
 		}
 	}
 
+	// the stream is added again to update its observed data tags.
+	for _, conns := range r.data {
+		delete(conns, connID)
+	}
+
 	for _, tag := range observeDataTags {
 		conns := r.data[tag]
 		if conns == nil {
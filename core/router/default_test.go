@@ -39,3 +39,18 @@ func TestRouter(t *testing.T) {
 	ids = route.GetForwardRoutes(frame.Tag(1))
 	assert.Equal(t, []string(nil), ids)
 }
+
+func TestRouteUpdateTags(t *testing.T) {
+	route := Default([]config.Function{{Name: "sfn-1"}}).Route(metadata.M{})
+
+	err := route.Add("conn-1", "sfn-1", []frame.Tag{frame.Tag(1), frame.Tag(2)})
+	assert.NoError(t, err)
+
+	// adding the same stream again replaces its observed tags.
+	err = route.Add("conn-1", "sfn-1", []frame.Tag{frame.Tag(2), frame.Tag(3)})
+	assert.NoError(t, err)
+
+	assert.Equal(t, []string(nil), route.GetForwardRoutes(frame.Tag(1)))
+	assert.Equal(t, []string{"conn-1"}, route.GetForwardRoutes(frame.Tag(2)))
+	assert.Equal(t, []string{"conn-1"}, route.GetForwardRoutes(frame.Tag(3)))
+}
@@ -16,7 +16,7 @@ type Router interface {
 
 // Route manages data subscribers according to their observed data tags.
 type Route interface {
-	// Add a route.
+	// Add a route, adding a stream that has been added replaces its observed data tags.
 	Add(streamID string, name string, observeDataTags []frame.Tag) error
 	// Remove a route.
 	Remove(streamID string) error
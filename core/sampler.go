@@ -0,0 +1,52 @@
+package core
+
+import (
+	"hash/fnv"
+	"math/rand"
+	"sync/atomic"
+
+	"github.com/yomorun/yomo/core/metadata"
+)
+
+// Sampling samples the DataFrames delivered to a stream, 1 in Rate DataFrames is delivered,
+// so a stream function gets a sample of a high-volume tag while the others get the full stream.
+type Sampling struct {
+	// Rate is the N of the 1-in-N sampling, the Rate less than 2 delivers all the DataFrames.
+	Rate uint32
+	// Key is the metadata key that the sampling is deterministic by, the DataFrames with the same value
+	// of the key are all delivered or all sampled out. An empty Key, or a DataFrame without the key,
+	// is sampled probabilistically.
+	Key string
+}
+
+// streamSampler samples the DataFrames by the names of the streams they are delivered to.
+type streamSampler struct {
+	samplings  map[string]Sampling
+	sampledOut atomic.Int64
+}
+
+func newStreamSampler(samplings map[string]Sampling) *streamSampler {
+	return &streamSampler{samplings: samplings}
+}
+
+// keep reports whether the DataFrame with the metadata is delivered to the stream of the name,
+// the DataFrames sampled out are counted.
+func (s *streamSampler) keep(streamName string, md metadata.M) bool {
+	sampling, ok := s.samplings[streamName]
+	if !ok || sampling.Rate < 2 {
+		return true
+	}
+
+	var kept bool
+	if v, ok := md.Get(sampling.Key); ok && sampling.Key != "" {
+		h := fnv.New32a()
+		h.Write([]byte(v))
+		kept = h.Sum32()%sampling.Rate == 0
+	} else {
+		kept = rand.Int63n(int64(sampling.Rate)) == 0
+	}
+	if !kept {
+		s.sampledOut.Add(1)
+	}
+	return kept
+}
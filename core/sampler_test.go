@@ -0,0 +1,42 @@
+package core
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yomorun/yomo/core/metadata"
+)
+
+func TestStreamSampler(t *testing.T) {
+	s := newStreamSampler(map[string]Sampling{
+		"sfn-hashed": {Rate: 4, Key: "user"},
+		"sfn-random": {Rate: 4},
+	})
+
+	const n = 4000
+	var hashed, random, full int
+	for i := 0; i < n; i++ {
+		md := metadata.M{"user": strconv.Itoa(i)}
+		if s.keep("sfn-hashed", md) {
+			hashed++
+		}
+		if s.keep("sfn-random", md) {
+			random++
+		}
+		if s.keep("sfn-full", md) {
+			full++
+		}
+	}
+	assert.Equal(t, n, full)
+	assert.InDelta(t, n/4, hashed, n/10)
+	assert.InDelta(t, n/4, random, n/10)
+	assert.Equal(t, int64(2*n-hashed-random), s.sampledOut.Load())
+
+	// the sampling by the key is deterministic.
+	md := metadata.M{"user": "alice"}
+	kept := s.keep("sfn-hashed", md)
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, kept, s.keep("sfn-hashed", md))
+	}
+}
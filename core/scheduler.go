@@ -0,0 +1,121 @@
+package core
+
+import (
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/yomorun/yomo/core/frame"
+	"github.com/yomorun/yomo/core/metadata"
+)
+
+// MetadataDeliverAfterKey is the key of the delay in milliseconds of the DataFrame in the metadata,
+// the zipper holds the DataFrame for the delay before dispatching it, see WithServerScheduledDelivery.
+const MetadataDeliverAfterKey = "yomo-deliver-after"
+
+// GetDeliverAfterFromMetadata gets the delay of the DataFrame from metadata, it returns 0 if there is no valid delay.
+func GetDeliverAfterFromMetadata(m metadata.M) time.Duration {
+	v, _ := m.Get(MetadataDeliverAfterKey)
+	ms, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || ms <= 0 {
+		return 0
+	}
+	return time.Duration(ms) * time.Millisecond
+}
+
+// SetDeliverAfterToMetadata sets the delay of the DataFrame to metadata, it is in milliseconds on the wire.
+func SetDeliverAfterToMetadata(m metadata.M, d time.Duration) {
+	m.Set(MetadataDeliverAfterKey, strconv.FormatInt(d.Milliseconds(), 10))
+}
+
+// scheduledFrame is a DataFrame held by the frameScheduler.
+type scheduledFrame struct {
+	timer Timer
+}
+
+// frameScheduler holds the DataFrames until they are due, the frames of a data stream are dropped
+// once the stream is closed, such as its connection is closed.
+type frameScheduler struct {
+	max     int
+	clock   Clock
+	held    atomic.Int64
+	dropped atomic.Int64
+
+	mu     sync.Mutex
+	frames map[DataStream]map[*scheduledFrame]struct{}
+}
+
+// newFrameScheduler returns a frameScheduler that holds up to max frames, it returns nil if max is not positive,
+// which means the scheduled delivery is disabled.
+func newFrameScheduler(max int, clock Clock) *frameScheduler {
+	if max <= 0 {
+		return nil
+	}
+	return &frameScheduler{
+		max:    max,
+		clock:  clock,
+		frames: make(map[DataStream]map[*scheduledFrame]struct{}),
+	}
+}
+
+// schedule holds the DataFrame of the stream for the delay and delivers it then, the frame is owned
+// by the scheduler. It returns false if the scheduler is full, the frame is dropped.
+func (s *frameScheduler) schedule(stream DataStream, f *frame.DataFrame, delay time.Duration, deliver func(DataStream, *frame.DataFrame)) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.held.Load() >= int64(s.max) {
+		s.dropped.Add(1)
+		return false
+	}
+	frames, ok := s.frames[stream]
+	if !ok {
+		frames = make(map[*scheduledFrame]struct{})
+		s.frames[stream] = frames
+		go s.dropOnClose(stream)
+	}
+
+	sf := &scheduledFrame{}
+	sf.timer = s.clock.AfterFunc(delay, func() {
+		if s.remove(stream, sf) {
+			deliver(stream, f)
+		}
+	})
+	frames[sf] = struct{}{}
+	s.held.Add(1)
+
+	return true
+}
+
+// remove removes the frame from the scheduler, it returns false if the frame has been dropped.
+func (s *frameScheduler) remove(stream DataStream, sf *scheduledFrame) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	frames, ok := s.frames[stream]
+	if !ok {
+		return false
+	}
+	if _, ok := frames[sf]; !ok {
+		return false
+	}
+	delete(frames, sf)
+	s.held.Add(-1)
+	return true
+}
+
+// dropOnClose drops the frames of the stream once it is closed.
+func (s *frameScheduler) dropOnClose(stream DataStream) {
+	<-stream.Context().Done()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for sf := range s.frames[stream] {
+		sf.timer.Stop()
+		s.held.Add(-1)
+		s.dropped.Add(1)
+	}
+	delete(s.frames, stream)
+}
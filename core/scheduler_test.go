@@ -0,0 +1,68 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yomorun/yomo/core/frame"
+	"github.com/yomorun/yomo/core/metadata"
+)
+
+func TestDeliverAfterMetadata(t *testing.T) {
+	md := metadata.M{}
+	assert.Equal(t, time.Duration(0), GetDeliverAfterFromMetadata(md))
+
+	SetDeliverAfterToMetadata(md, 1500*time.Millisecond)
+	assert.Equal(t, 1500*time.Millisecond, GetDeliverAfterFromMetadata(md))
+
+	md.Set(MetadataDeliverAfterKey, "invalid")
+	assert.Equal(t, time.Duration(0), GetDeliverAfterFromMetadata(md))
+}
+
+func TestFrameScheduler(t *testing.T) {
+	assert.Nil(t, newFrameScheduler(0, SystemClock))
+
+	newStream := func() DataStream {
+		frameStream := NewFrameStream(newMemByteStream(nil), &byteCodec{}, &bytePacketReadWriter{})
+		return newDataStream("source", "source-1", "", StreamTypeSource, metadata.M{}, nil, frameStream, nil, nil, SystemClock)
+	}
+
+	t.Run("deliver", func(t *testing.T) {
+		clock := NewManualClock(time.Now())
+		s := newFrameScheduler(1, clock)
+		stream := newStream()
+		defer stream.Close()
+
+		var delivered *frame.DataFrame
+		deliver := func(_ DataStream, f *frame.DataFrame) { delivered = f }
+
+		f := &frame.DataFrame{Tag: 1}
+		assert.True(t, s.schedule(stream, f, 10*time.Millisecond, deliver))
+		assert.False(t, s.schedule(stream, &frame.DataFrame{Tag: 2}, time.Hour, deliver), "the scheduler is full")
+		assert.Equal(t, int64(1), s.held.Load())
+		assert.Equal(t, int64(1), s.dropped.Load())
+
+		clock.Advance(9 * time.Millisecond)
+		assert.Nil(t, delivered)
+
+		clock.Advance(time.Millisecond)
+		assert.Equal(t, f, delivered)
+		assert.Equal(t, int64(0), s.held.Load())
+	})
+
+	t.Run("drop on close", func(t *testing.T) {
+		clock := NewManualClock(time.Now())
+		s := newFrameScheduler(10, clock)
+		stream := newStream()
+
+		deliver := func(DataStream, *frame.DataFrame) { t.Error("the frame of the closed stream is delivered") }
+		assert.True(t, s.schedule(stream, &frame.DataFrame{Tag: 1}, 50*time.Millisecond, deliver))
+
+		stream.Close()
+		assert.Eventually(t, func() bool { return s.dropped.Load() == 1 }, time.Second, time.Millisecond)
+		assert.Equal(t, int64(0), s.held.Load())
+
+		clock.Advance(time.Hour)
+	})
+}
@@ -1,6 +1,7 @@
 package core
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
@@ -8,8 +9,10 @@ import (
 	"net"
 	"os"
 	"reflect"
+	"sort"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/quic-go/quic-go"
 	"github.com/yomorun/yomo/core/auth"
@@ -17,11 +20,11 @@ import (
 	"github.com/yomorun/yomo/core/metadata"
 	"github.com/yomorun/yomo/core/router"
 	"github.com/yomorun/yomo/core/yerr"
+	"golang.org/x/exp/slices"
 	"golang.org/x/exp/slog"
 
 	// authentication implements, Currently, only token authentication is implemented
 	_ "github.com/yomorun/yomo/pkg/auth"
-	"github.com/yomorun/yomo/pkg/frame-codec/y3codec"
 	"github.com/yomorun/yomo/pkg/id"
 	"github.com/yomorun/yomo/pkg/trace"
 	oteltrace "go.opentelemetry.io/otel/trace"
@@ -33,6 +36,11 @@ var ErrServerClosed = errors.New("yomo: Server closed")
 // FrameHandler is the handler for frame.
 type FrameHandler func(c *Context) error
 
+// earlyDataConnection is the connection that may receive the 0-RTT data before its handshake completes.
+type earlyDataConnection interface {
+	waitHandshakeComplete(ctx context.Context) error
+}
+
 // ConnectionHandler is the handler for quic connection
 type ConnectionHandler func(conn quic.Connection)
 
@@ -46,12 +54,22 @@ type Server struct {
 	codec                   frame.Codec
 	packetReadWriter        frame.PacketReadWriter
 	counterOfDataFrame      int64
+	counterOfExpiredFrame   int64
+	deadLetter              *deadLetter
+	rateLimiter             *tagRateLimiter
+	sampler                 *streamSampler
+	reorder                 *reorderBuffer
+	scheduler               *frameScheduler
+	redelivery              *redelivery
+	backflow                *backflowKeeper
+	draining                atomic.Bool
 	downstreams             map[string]FrameWriterConnection
 	mu                      sync.Mutex
 	opts                    *serverOptions
 	startHandlers           []FrameHandler
 	beforeHandlers          []FrameHandler
 	afterHandlers           []FrameHandler
+	frameHandler            FrameHandler
 	connectionCloseHandlers []ConnectionHandler
 	listener                Listener
 	logger                  *slog.Logger
@@ -65,6 +83,9 @@ func NewServer(name string, opts ...ServerOption) *Server {
 	for _, o := range opts {
 		o(options)
 	}
+	if options.tagNamespaces && options.observeTagAuthorizer == nil {
+		options.observeTagAuthorizer = NamespaceObserveTagAuthorizer
+	}
 
 	logger := options.logger.With("component", "zipper", "zipper_name", name)
 
@@ -77,10 +98,18 @@ func NewServer(name string, opts ...ServerOption) *Server {
 		downstreams:      make(map[string]FrameWriterConnection),
 		logger:           logger,
 		tracerProvider:   options.tracerProvider,
-		codec:            y3codec.Codec(),
-		packetReadWriter: y3codec.PacketReadWriter(),
+		codec:            options.codec,
+		packetReadWriter: options.packetReadWriter,
 		opts:             options,
+		rateLimiter:      newTagRateLimiter(options.rateLimit, options.tagRateLimits, options.rateLimitPolicy),
+		sampler:          newStreamSampler(options.streamSamplings),
+		deadLetter:       newDeadLetter(options.deadLetterTag),
+		scheduler:        newFrameScheduler(options.maxScheduledFrames, options.clock),
+		redelivery:       newRedelivery(options.maxDeliveryAttempts, options.redeliveryBackoff),
+		backflow:         newBackflowKeeper(options.backflowPolicy, options.backflowWindow, options.backflowFallbackTag, options.clock),
 	}
+	s.reorder = newReorderBuffer(options.reorderWindow, options.clock, s.handleReleasedFrame)
+	s.frameHandler = chainFrameMiddlewares(s.dispatchFrame, options.frameMiddlewares)
 
 	return s
 }
@@ -112,10 +141,11 @@ func (s *Server) Serve(ctx context.Context, conn net.PacketConn) error {
 		return err
 	}
 
-	s.connector = NewConnector(ctx)
+	s.connector = NewConnector(ctx, s.opts.connectorOptions...)
 
 	// listen the address
-	listener, err := NewQuicListener(conn, s.opts.tlsConfig, s.opts.quicConfig, s.logger)
+	quicConfig := quicConfigWithKeepAlive(s.opts.quicConfig, s.opts.keepAlivePeriod, s.opts.maxIdleTimeout)
+	listener, err := newQuicListener(conn, s.opts.tlsConfig, s.opts.alpn, quicConfig, s.opts.allow0RTT, s.logger)
 	if err != nil {
 		s.logger.Error("failed to listen on quic", "err", err)
 		return err
@@ -126,8 +156,23 @@ func (s *Server) Serve(ctx context.Context, conn net.PacketConn) error {
 
 	defer closeServer(s.downstreams, s.connector, s.listener, s.router)
 
+	// the extra listeners, such as the WebTransport one, share the connector and router with the quic listener.
+	for _, ln := range s.opts.listeners {
+		defer ln.Close()
+		go func(ln Listener) {
+			if err := s.serveListener(ctx, ln); err != nil && err != ErrServerClosed {
+				s.logger.Error("extra listener stopped", "addr", ln.Addr(), "err", err)
+			}
+		}(ln)
+	}
+
+	return s.serveListener(ctx, s.listener)
+}
+
+// serveListener accepts the connections from the listener and serves them until the server is closed.
+func (s *Server) serveListener(ctx context.Context, listener Listener) error {
 	for {
-		conn, err := s.listener.Accept(s.ctx)
+		conn, err := listener.Accept(s.ctx)
 		if err != nil {
 			if err == s.ctx.Err() {
 				return ErrServerClosed
@@ -137,41 +182,91 @@ func (s *Server) Serve(ctx context.Context, conn net.PacketConn) error {
 		}
 		logger := s.logger.With("remote_addr", conn.RemoteAddr(), "local_addr", conn.LocalAddr())
 
-		stream0, err := conn.AcceptStream(ctx)
-		if err != nil {
+		if s.draining.Load() {
+			rejectDraining(conn, logger)
 			continue
 		}
 
-		controlStream := NewServerControlStream(conn, stream0, s.codec, s.packetReadWriter, logger)
+		// the authentication may look up a remote service, so it does not block accepting other connections.
+		go s.serveConnection(ctx, conn, logger)
+	}
+}
 
-		// Auth accepts a AuthenticationFrame from client. The first frame from client must be
-		// AuthenticationFrame, It returns true if auth successful otherwise return false.
-		// It response to client a AuthenticationAckFrame.
-		md, err := controlStream.VerifyAuthentication(s.handleAuthenticationFrame)
-		if err != nil {
-			continue
+func (s *Server) serveConnection(ctx context.Context, conn Connection, logger *slog.Logger) {
+	if oc, ok := conn.(observableConnection); ok {
+		oc.setObserver(s.opts.observer)
+	}
+
+	stream0, err := conn.AcceptStream(ctx)
+	if err != nil {
+		return
+	}
+
+	controlStream := NewServerControlStream(conn, stream0, s.codec, s.packetReadWriter, logger,
+		WithReadBufferSize(s.opts.readBufferSize), WithWriteQueue(s.opts.writeQueueSize),
+	)
+	controlStream.versions = s.opts.versions
+	controlStream.clock = s.opts.clock
+	controlStream.health = s.health
+	controlStream.healthCheckBeforeAuth = s.opts.healthBeforeAuth
+
+	// Auth accepts a AuthenticationFrame from client. The first frame from client must be
+	// AuthenticationFrame, It returns true if auth successful otherwise return false.
+	// It response to client a AuthenticationAckFrame.
+	md, err := controlStream.VerifyAuthentication(ctx, s.verifyAuthentication())
+	if err != nil {
+		return
+	}
+
+	// the AuthenticationFrame is the only frame accepted in 0-RTT. A replayed one runs the verifier again, that is
+	// why the verifiers must be replay-safe, see WithServer0RTT, but the connection of it never completes
+	// the handshake, so it goes no further. Other frames are handled after the handshake.
+	if ec, ok := conn.(earlyDataConnection); ok {
+		if err := ec.waitHandshakeComplete(ctx); err != nil {
+			logger.Debug("handshake not completed", "err", err)
+			return
 		}
+	}
 
-		go func(conn Connection) {
-			streamGroup := NewStreamGroup(ctx, md, controlStream, s.connector, s.router, logger)
+	// the labels are carried in the metadata of the connection, they override the ones of the handshakes.
+	if labels := controlStream.Labels(); len(labels) > 0 {
+		if md == nil {
+			md = metadata.M{}
+		}
+		for k, v := range labels {
+			md.Set(MetadataLabelPrefix+k, v)
+		}
+		logger = logger.With("labels", labels)
+	}
 
-			defer streamGroup.Wait()
-			defer logger.Debug("quic connection closed")
+	s.opts.observer.ConnectionOpened()
+	defer s.opts.observer.ConnectionClosed()
+	if observer, ok := s.opts.observer.(ConnectionLabelsObserver); ok {
+		observer.LabeledConnectionOpened(controlStream.Labels())
+		defer observer.LabeledConnectionClosed(controlStream.Labels())
+	}
 
-			select {
-			case <-ctx.Done():
-				return
-			case <-s.runWithStreamGroup(streamGroup, logger):
-			}
-		}(conn)
+	streamGroup := NewStreamGroup(ctx, md, controlStream, s.connector, s.router, logger, s.streamGroupOptions())
+
+	defer streamGroup.Wait()
+	defer logger.Debug("quic connection closed")
+
+	if lifetime := jitterLifetime(s.opts.maxConnLifetime, s.opts.connLifetimeJitter); lifetime > 0 {
+		rotation := s.opts.clock.AfterFunc(lifetime, func() {
+			logger.Info("connection rotated for reaching its max lifetime", "lifetime", lifetime)
+			_ = controlStream.Rotate("yomo: connection reached its max lifetime")
+		})
+		defer rotation.Stop()
 	}
+
+	<-s.runWithStreamGroup(ctx, streamGroup, logger)
 }
 
-func (s *Server) runWithStreamGroup(group *StreamGroup, logger *slog.Logger) <-chan struct{} {
+func (s *Server) runWithStreamGroup(ctx context.Context, group *StreamGroup, logger *slog.Logger) <-chan struct{} {
 	done := make(chan struct{})
 
 	go func() {
-		if err := group.Run(s.handleStreamContext); err != nil {
+		if err := group.Run(ctx, s.handleStreamContext); err != nil && err != ctx.Err() {
 			logger.Error("connection closed", "err", err)
 		}
 		done <- struct{}{}
@@ -183,6 +278,44 @@ func (s *Server) runWithStreamGroup(group *StreamGroup, logger *slog.Logger) <-c
 // Logger returns the logger of server.
 func (s *Server) Logger() *slog.Logger { return s.logger }
 
+// Drain makes the server reject the new connections, the connections served keep running until they are closed,
+// so the server can be taken down without interrupting them, such as in a rolling deploy.
+// The clients rejected reconnect by their reconnect backoff, hopefully to another server.
+// A draining server can not be undrained.
+func (s *Server) Drain() {
+	if s.draining.CompareAndSwap(false, true) {
+		s.logger.Info("zipper is draining, new connections are rejected")
+	}
+}
+
+// Draining reports whether the server is draining, see Drain. A health check can report not-ready by it.
+func (s *Server) Draining() bool {
+	return s.draining.Load()
+}
+
+// health returns the health status of the server.
+func (s *Server) health() frame.HealthStatus {
+	if s.Draining() {
+		return frame.HealthDraining
+	}
+	if s.opts.overloaded != nil && s.opts.overloaded() {
+		return frame.HealthOverloaded
+	}
+	return frame.HealthServing
+}
+
+// rejectDraining closes the new connection accepted by the draining server.
+func rejectDraining(conn Connection, logger *slog.Logger) {
+	const errString = "yomo: server is draining"
+
+	logger.Debug("reject connection, the server is draining")
+	if cc, ok := conn.(codeCloser); ok {
+		_ = cc.CloseWithCode(yerr.ErrorCodeRejected, errString)
+		return
+	}
+	_ = conn.CloseWithError(errString)
+}
+
 // Close will shutdown the server.
 func (s *Server) Close() error {
 	s.ctxCancel()
@@ -220,6 +353,13 @@ func (s *Server) handleStreamContext(c *Context) {
 		}
 	}
 
+	if c.DataStream.StreamType() == StreamTypeSource {
+		s.sourceArrived(c)
+		defer s.sourceDeparted(c.DataStream)
+	}
+	// the sequences of the stream are not continued by another stream.
+	defer s.reorder.forget(c.DataStream)
+
 	// check update for stream
 	for {
 		f, err := c.DataStream.ReadFrame()
@@ -252,35 +392,169 @@ func (s *Server) handleStreamContext(c *Context) {
 			break
 		}
 
-		// add frame to context
-		if err := c.WithFrame(f); err != nil {
-			c.CloseWithError(err.Error())
-			break
+		// the PongFrame replies the heartbeat, reading it keeps the stream alive.
+		if _, ok := f.(*frame.PongFrame); ok {
+			if ds, ok := c.DataStream.(*dataStream); ok {
+				ds.releaseFrame()
+			}
+			continue
 		}
 
-		// before frame handlers
-		for _, handler := range s.beforeHandlers {
-			if err := handler(c); err != nil {
-				c.Logger.Error("encountered an error in the before handler", "err", err)
-				c.CloseWithError(err.Error())
-				return
+		// the BackflowAckFrame from the source is forwarded to the sfn waits for it.
+		if ack, ok := f.(*frame.BackflowAckFrame); ok {
+			s.handleBackflowAckFrame(c, ack)
+			if ds, ok := c.DataStream.(*dataStream); ok {
+				ds.releaseFrame()
 			}
+			continue
 		}
-		// main handler
-		if err := s.mainFrameHandler(c); err != nil {
-			c.Logger.Error("encountered an error in the main handler", "err", err)
+
+		// the chunk of a ChunkedDataFrame is routed as a DataFrame, the sfn reassembles the chunks.
+		f, err = unchunkFrame(f)
+		if err != nil {
 			c.CloseWithError(err.Error())
-			return
+			break
 		}
-		// after frame handler
-		for _, handler := range s.afterHandlers {
-			if err := handler(c); err != nil {
-				c.Logger.Error("encountered an error in the after handler", "err", err)
-				c.CloseWithError(err.Error())
+		// the entries of a BatchDataFrame are handled as separate DataFrames.
+		for _, f := range unbatchFrame(f) {
+			if !s.handleOrderedFrame(c, f) {
 				return
 			}
 		}
+		// the frames dispatched to downstreams are written asynchronously and
+		// the frames held by the reorder buffer are handled later, so they can not be released.
+		if ds, ok := c.DataStream.(*dataStream); ok && len(s.downstreams) == 0 && s.reorder == nil {
+			ds.releaseFrame()
+		}
+	}
+}
+
+// handleOrderedFrame handles the sequenced DataFrame in sequence order within its tag of the stream,
+// the frames held by the reorder buffer are handled once the missing ones arrive or the window is exceeded.
+// It returns false if the data stream has been closed because of an error.
+func (s *Server) handleOrderedFrame(c *Context, f frame.Frame) bool {
+	df, ok := f.(*frame.DataFrame)
+	if !ok || s.reorder == nil || df.Seq == 0 {
+		return s.handleFrame(c, f)
+	}
+
+	alive := true
+	s.reorder.push(c.DataStream, df, s.opts.clock.Now(), func(_ DataStream, df *frame.DataFrame) {
+		alive = s.handleFrame(c, df) && alive
+	})
+	return alive
+}
+
+// handleReleasedFrame handles the DataFrame released by the reorder buffer out of the handling of its own frames,
+// such as the one held for the Hold of the ReorderWindow, it is handled with a Context of its stream.
+func (s *Server) handleReleasedFrame(stream DataStream, df *frame.DataFrame) {
+	c := newContext(stream, nil, s.logger)
+	defer c.Release()
+
+	s.handleFrame(c, df)
+}
+
+// handleFrame runs the frame handlers with the frame,
+// it returns false if the data stream has been closed because of an error.
+func (s *Server) handleFrame(c *Context, f frame.Frame) bool {
+	// the metadata is checked before decoding, it is decoded for every frame on the routing path.
+	if df, ok := f.(*frame.DataFrame); ok {
+		if err := checkMetadataSize(df.Metadata, s.opts.maxMetadataSize); err != nil {
+			c.CloseWithError(err.Error())
+			return false
+		}
+	}
+	// add frame to context
+	if err := c.WithFrame(f); err != nil {
+		c.CloseWithError(err.Error())
+		return false
+	}
+
+	// the DataFrame with a delay is held until it is due, then it goes through the handlers.
+	if df, ok := f.(*frame.DataFrame); ok && s.scheduler != nil {
+		if delay := GetDeliverAfterFromMetadata(c.FrameMetadata); delay > 0 {
+			return s.scheduleFrame(c, df, delay)
+		}
+	}
+
+	// the frame goes through the middlewares before being dispatched.
+	if err := s.frameHandler(c); err != nil {
+		c.CloseWithError(err.Error())
+		return false
+	}
+	return true
+}
+
+// scheduleFrame holds a copy of the DataFrame until the delay is due, the delay is removed from the copy,
+// so it is handled like other DataFrames then. It returns false if the data stream has been closed because of an error.
+func (s *Server) scheduleFrame(c *Context, df *frame.DataFrame, delay time.Duration) bool {
+	delete(c.FrameMetadata, MetadataDeliverAfterKey)
+	md, err := c.FrameMetadata.EncodeWith(metadata.EncodingOf(df.Metadata))
+	if err != nil {
+		c.CloseWithError(err.Error())
+		return false
+	}
+	// the frame read is released once it is handled, the scheduler holds a copy.
+	scheduled := &frame.DataFrame{
+		Metadata: md,
+		Tag:      df.Tag,
+		Payload:  bytes.Clone(df.Payload),
+		Seq:      df.Seq,
+		TTL:      df.TTL,
+	}
+	ok := s.scheduler.schedule(c.DataStream, scheduled, delay, func(stream DataStream, df *frame.DataFrame) {
+		oc := newContext(stream, nil, s.logger)
+		defer oc.Release()
+
+		s.handleFrame(oc, df)
+	})
+	if !ok {
+		c.Logger.Warn("data frame dropped as too many frames are scheduled", "data_tag", df.Tag)
+	}
+	return true
+}
+
+// dispatchFrame runs the before handlers, the main handler and the after handlers with the frame,
+// it is the innermost FrameHandler of the frame middlewares.
+func (s *Server) dispatchFrame(c *Context) error {
+	// before frame handlers
+	for _, handler := range s.beforeHandlers {
+		if err := handler(c); err != nil {
+			c.Logger.Error("encountered an error in the before handler", "err", err)
+			return err
+		}
 	}
+	// main handler
+	if err := s.mainFrameHandler(c); err != nil {
+		c.Logger.Error("encountered an error in the main handler", "err", err)
+		return err
+	}
+	// after frame handler
+	for _, handler := range s.afterHandlers {
+		if err := handler(c); err != nil {
+			c.Logger.Error("encountered an error in the after handler", "err", err)
+			return err
+		}
+	}
+	return nil
+}
+
+// unbatchFrame splits a BatchDataFrame to DataFrames those share the metadata of the batch,
+// other frames are returned as is.
+func unbatchFrame(f frame.Frame) []frame.Frame {
+	bf, ok := f.(*frame.BatchDataFrame)
+	if !ok {
+		return []frame.Frame{f}
+	}
+	frames := make([]frame.Frame, 0, len(bf.Entries))
+	for _, entry := range bf.Entries {
+		frames = append(frames, &frame.DataFrame{
+			Metadata: bf.Metadata,
+			Tag:      entry.Tag,
+			Payload:  entry.Payload,
+		})
+	}
+	return frames
 }
 
 func (s *Server) mainFrameHandler(c *Context) error {
@@ -288,6 +562,15 @@ func (s *Server) mainFrameHandler(c *Context) error {
 
 	switch frameType {
 	case frame.TypeDataFrame:
+		// inspectors only observe the data flow, the frames written by them are not routed.
+		if c.DataStream.StreamType() == StreamTypeInspector {
+			c.Logger.Debug("ignore data frame from inspector", "data_tag", c.Frame.Tag)
+			return nil
+		}
+		if s.expireDataFrame(c.Frame) {
+			c.Logger.Warn("data frame dropped as ttl expired", "data_tag", c.Frame.Tag)
+			return nil
+		}
 		if err := s.handleDataFrame(c); err != nil {
 			c.CloseWithError(fmt.Sprintf("handle dataFrame err: %v", err))
 		} else {
@@ -302,6 +585,84 @@ func (s *Server) mainFrameHandler(c *Context) error {
 	return nil
 }
 
+// delayDataFrame waits for the delay of the rate limit, it reports false if the data stream or the server
+// is closed before the delay elapses.
+func (s *Server) delayDataFrame(c *Context, wait time.Duration) bool {
+	timer := s.opts.clock.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C():
+		return true
+	case <-c.Done():
+		return false
+	case <-s.ctx.Done():
+		return false
+	}
+}
+
+// expireDataFrame decrements the TTL of the DataFrame for this hop, it reports whether the TTL is
+// expired, the expired DataFrame should be dropped rather than being forwarded.
+func (s *Server) expireDataFrame(f *frame.DataFrame) bool {
+	ttl := f.TTL
+	if ttl == 0 {
+		ttl = frame.DefaultTTL
+	}
+	ttl--
+	if ttl == 0 {
+		atomic.AddInt64(&s.counterOfExpiredFrame, 1)
+		return true
+	}
+	f.TTL = ttl
+	return false
+}
+
+// streamGroupOptions returns the options of the StreamGroups of the connections.
+func (s *Server) streamGroupOptions() streamGroupOptions {
+	return streamGroupOptions{
+		panicHandler:        s.opts.panicHandler,
+		maxDataStreams:      s.opts.maxDataStreams,
+		idleTimeout:         s.opts.idleTimeout,
+		heartbeatInterval:   s.opts.heartbeatInterval,
+		heartbeatMisses:     s.opts.heartbeatMisses,
+		maxMetadataSize:     s.opts.maxMetadataSize,
+		authorizeObserveTag: s.opts.observeTagAuthorizer,
+		observeTagDeny:      s.opts.observeTagDenyPolicy,
+		observer:            s.opts.observer,
+		clock:               s.opts.clock,
+		tp:                  s.tracerProvider,
+	}
+}
+
+// verifyAuthentication returns the VerifyAuthenticationFunc set by WithServerVerifyAuthentication,
+// or the one that verifies by the registered verifiers and auths if it is not set.
+func (s *Server) verifyAuthentication() VerifyAuthenticationFunc {
+	if s.opts.verifyAuthentication != nil {
+		return s.opts.verifyAuthentication
+	}
+	verifyAuths := LegacyVerifyAuthenticationFunc(s.handleAuthenticationFrame).WithContext()
+	if len(s.opts.verifiers) == 0 {
+		return verifyAuths
+	}
+	return dispatchVerifyAuthentication(s.opts.verifiers, s.opts.auths, verifyAuths)
+}
+
+// dispatchVerifyAuthentication returns the VerifyAuthenticationFunc that dispatches the AuthenticationFrame
+// to the verifier of its AuthName, the frames of the auths are verified by verifyAuths.
+func dispatchVerifyAuthentication(
+	verifiers map[string]VerifyAuthenticationFunc, auths map[string]auth.Authentication, verifyAuths VerifyAuthenticationFunc,
+) VerifyAuthenticationFunc {
+	return func(ctx context.Context, f *frame.AuthenticationFrame) (metadata.M, bool, error) {
+		if verify, ok := verifiers[f.AuthName]; ok {
+			return verify(ctx, f)
+		}
+		if _, ok := auths[f.AuthName]; ok {
+			return verifyAuths(ctx, f)
+		}
+		return nil, false, fmt.Errorf("yomo: no verifier for the credential name %q", f.AuthName)
+	}
+}
+
 func (s *Server) handleAuthenticationFrame(f *frame.AuthenticationFrame) (metadata.M, bool, error) {
 	md, ok := auth.Authenticate(s.opts.auths, f)
 
@@ -319,6 +680,10 @@ func (s *Server) handleDataFrame(c *Context) error {
 	atomic.AddInt64(&s.counterOfDataFrame, 1)
 
 	from := c.DataStream
+	if s.opts.tagNamespaces && !authorizeNamespace(from.Metadata(), c.Frame.Tag) {
+		c.Logger.Warn("data frame dropped out of the namespace", "data_tag", c.Frame.Tag)
+		return nil
+	}
 	tid := GetTIDFromMetadata(c.FrameMetadata)
 	sid := GetSIDFromMetadata(c.FrameMetadata)
 	parentTraced := GetTracedFromMetadata(c.FrameMetadata)
@@ -330,10 +695,11 @@ func (s *Server) handleDataFrame(c *Context) error {
 		var span oteltrace.Span
 		var err error
 		// set parent span, if not traced, use empty string
+		attrs := trace.FrameAttrs(c.Frame.Tag, from.ID())
 		if parentTraced {
-			span, err = trace.NewSpan(tp, "zipper", "handle DataFrame", tid, sid)
+			span, err = trace.NewSpanWithAttrs(tp, "zipper", "handle DataFrame", tid, sid, false, attrs)
 		} else {
-			span, err = trace.NewSpan(tp, "zipper", "handle DataFrame", "", "")
+			span, err = trace.NewSpanWithAttrs(tp, "zipper", "handle DataFrame", "", "", false, attrs)
 		}
 		if err != nil {
 			s.logger.Error("zipper trace error", "err", err)
@@ -356,13 +722,18 @@ func (s *Server) handleDataFrame(c *Context) error {
 	SetTIDToMetadata(c.FrameMetadata, tid)
 	SetSIDToMetadata(c.FrameMetadata, sid)
 	SetTracedToMetadata(c.FrameMetadata, traced || parentTraced)
-	md, err := c.FrameMetadata.Encode()
-	if err != nil {
-		s.logger.Error("encode metadata error", "err", err)
-		return err
+
+	// the sfn gives up the DataFrame, it is re-delivered to the sfn until it runs out of the attempts.
+	if reason, ok := c.FrameMetadata.Get(MetadataDeadLetterKey); ok {
+		delete(c.FrameMetadata, MetadataDeadLetterKey)
+		if s.redeliver(c) {
+			return nil
+		}
+		if !s.deadLetter.reroute(c, reason) {
+			return nil
+		}
 	}
-	c.Frame.Metadata = md
-	s.logger.Debug("zipper metadata", "tid", tid, "sid", sid, "parentTraced", parentTraced, "traced", traced, "frome_stream_name", from.Name())
+
 	// route
 	route := s.router.Route(c.FrameMetadata)
 	if route == nil {
@@ -373,9 +744,43 @@ func (s *Server) handleDataFrame(c *Context) error {
 
 	// find stream function ids from the route.
 	streamIDs := route.GetForwardRoutes(c.Frame.Tag)
+	if len(streamIDs) == 0 && !s.observed(c) && s.deadLetter.reroute(c, DeadLetterReasonNoObserver) {
+		streamIDs = route.GetForwardRoutes(c.Frame.Tag)
+	}
+	streamIDs = withFirehoseRoutes(route, streamIDs)
+
+	// the target is for this hop only, the DataFrames written by the target do not inherit it.
+	target := GetTargetStreamIDFromMetadata(c.FrameMetadata)
+	delete(c.FrameMetadata, MetadataTargetStreamIDKey)
+	// so is the delivery attempt, the DataFrames written by the sfn are delivered for the first time.
+	delete(c.FrameMetadata, MetadataDeliveryAttemptKey)
+
+	md, err := c.FrameMetadata.EncodeWith(s.opts.metadataEncoding)
+	if err != nil {
+		s.logger.Error("encode metadata error", "err", err)
+		return err
+	}
+	c.Frame.Metadata = md
+	s.logger.Debug("zipper metadata", "tid", tid, "sid", sid, "parentTraced", parentTraced, "traced", traced, "frome_stream_name", from.Name())
+
+	// rate limit before dispatching to stream functions.
+	if len(streamIDs) > 0 {
+		wait, ok := s.rateLimiter.wait(c.Frame.Tag, s.opts.clock.Now())
+		if !ok {
+			c.Logger.Debug("data frame dropped by rate limit", "data_tag", c.Frame.Tag)
+			return nil
+		}
+		if wait > 0 && !s.delayDataFrame(c, wait) {
+			c.Logger.Debug("delayed data frame dropped as the stream is closed", "data_tag", c.Frame.Tag)
+			return nil
+		}
+	}
 
 	c.Logger.Debug("sfn routing", "data_tag", c.Frame.Tag, "sfn_stream_ids", streamIDs, "connector", s.connector.Snapshot())
 
+	// sort the ids, so that the DispatchRouter sees the candidates in a stable order.
+	sort.Strings(streamIDs)
+	candidates := make([]DataStream, 0, len(streamIDs))
 	for _, toID := range streamIDs {
 		stream, ok, err := s.connector.Get(toID)
 		if err != nil {
@@ -385,35 +790,127 @@ func (s *Server) handleDataFrame(c *Context) error {
 			c.Logger.Error("can't find forward stream", "err", "route sfn error", "forward_stream_id", toID)
 			continue
 		}
+		candidates = append(candidates, stream)
+	}
 
+	for _, stream := range s.dispatchTargets(c, target, candidates) {
+		if !s.sampler.keep(stream.Name(), c.FrameMetadata) {
+			c.Logger.Debug("data frame sampled out", "data_tag", c.Frame.Tag, "to_stream_name", stream.Name())
+			continue
+		}
 		c.Logger.Info(
 			"routing data frame",
 			"from_stream_name", from.Name(),
 			"from_stream_id", from.ID(),
 			"to_stream_name", stream.Name(),
-			"to_stream_id", toID,
+			"to_stream_id", stream.ID(),
 		)
 
 		// write data frame to stream
-		if err := stream.WriteFrame(c.Frame); err != nil {
+		if err := s.writeToStream(stream, c.Frame); err != nil {
 			c.Logger.Error("failed to write frame for routing data", "err", err)
 		}
 	}
 
+	s.dispatchToInspectors(c)
+
 	return nil
 }
 
+// withFirehoseRoutes adds the ids of the streams those observe frame.TagFirehose to the streamIDs,
+// the ids are sorted and a stream observes both the tag and the firehose is delivered once.
+func withFirehoseRoutes(route router.Route, streamIDs []string) []string {
+	firehose := route.GetForwardRoutes(frame.TagFirehose)
+	if len(firehose) == 0 {
+		return streamIDs
+	}
+	streamIDs = append(streamIDs, firehose...)
+	sort.Strings(streamIDs)
+
+	return slices.Compact(streamIDs)
+}
+
+// dispatchTargets returns the streams that the DataFrame is dispatched to among the candidates,
+// the DataFrame is unicast to the target stream if the target is not empty.
+func (s *Server) dispatchTargets(c *Context, target string, candidates []DataStream) []DataStream {
+	if target == "" {
+		return s.opts.dispatchRouter.Route(c.Frame.Tag, c.FrameMetadata, candidates)
+	}
+	if streams, ok := unicast(target, candidates); ok {
+		return streams
+	}
+	if s.opts.unicastFallback == UnicastFallbackDrop {
+		c.Logger.Debug("data frame dropped, the unicast target is gone", "data_tag", c.Frame.Tag, "target_stream_id", target)
+		return nil
+	}
+	return s.opts.dispatchRouter.Route(c.Frame.Tag, c.FrameMetadata, candidates)
+}
+
+// dispatchToInspectors writes the copies of the DataFrame to the inspectors that observe its tag,
+// the inspectors are not processors, they are not routed by the router and not rate limited.
+func (s *Server) dispatchToInspectors(c *Context) {
+	inspectors, err := s.connector.Find(inspectorTagFindStreamFunc(c.Frame.Tag))
+	if err != nil {
+		return
+	}
+	for _, inspector := range inspectors {
+		if err := s.writeToStream(inspector, c.Frame); err != nil {
+			c.Logger.Debug("failed to write frame to inspector", "inspector_stream_id", inspector.ID(), "err", err)
+		}
+	}
+}
+
+// contextWriter is implemented by the DataStream that can give up writing once the context is done.
+type contextWriter interface {
+	WriteWithContext(ctx context.Context, f frame.Frame) error
+}
+
+// writeToStream writes the frame to the stream, the write gives up after the write timeout of the server,
+// so that a slow consumer does not block the dispatching to the others.
+func (s *Server) writeToStream(stream DataStream, f frame.Frame) error {
+	cw, ok := stream.(contextWriter)
+	if s.opts.writeTimeout <= 0 || !ok {
+		return stream.WriteFrame(f)
+	}
+	ctx, cancel := context.WithTimeout(s.ctx, s.opts.writeTimeout)
+	defer cancel()
+
+	return cw.WriteWithContext(ctx, f)
+}
+
+// inspectorTagFindStreamFunc creates a FindStreamFunc that finds the inspectors observing the tag.
+func inspectorTagFindStreamFunc(tag frame.Tag) FindStreamFunc {
+	return func(stream StreamInfo) bool {
+		if stream.StreamType() != StreamTypeInspector {
+			return false
+		}
+		for _, v := range stream.ObserveDataTags() {
+			if v == tag {
+				return true
+			}
+		}
+		return false
+	}
+}
+
 func (s *Server) handleBackflowFrame(c *Context) error {
 	sourceID := GetSourceIDFromMetadata(c.FrameMetadata)
 	// write to source with BackflowFrame
 	bf := &frame.BackflowFrame{
 		Tag:      c.Frame.Tag,
 		Carriage: c.Frame.Payload,
+		Metadata: c.Frame.Metadata,
 	}
-	sourceStreams, err := s.connector.Find(sourceIDTagFindStreamFunc(sourceID, c.Frame.Tag))
+	sourceStreams, err := s.connector.Find(backflowFindStreamFunc(c))
 	if err != nil {
 		return err
 	}
+	if len(sourceStreams) == 0 {
+		if _, ok, _ := s.connector.Get(sourceID); !ok {
+			s.handleDepartedBackflow(c, sourceID, bf)
+		}
+		return nil
+	}
 	for _, source := range sourceStreams {
 		if source != nil {
 			c.Logger.Info("backflow to source", "source_conn_id", sourceID)
@@ -426,6 +923,19 @@ func (s *Server) handleBackflowFrame(c *Context) error {
 	return nil
 }
 
+// backflowFindStreamFunc creates a FindStreamFunc that finds the sources the DataFrame of the context is backflowed to,
+// they are the source that writes the DataFrame and observes its tag, or the source that waits for the reply of the request.
+func backflowFindStreamFunc(c *Context) FindStreamFunc {
+	sourceID := GetSourceIDFromMetadata(c.FrameMetadata)
+	// the reply of a request is backflowed to the requesting source whatever its tag is.
+	if _, ok := c.FrameMetadata.Get(MetadataRequestIDKey); ok && c.DataStream.StreamType() == StreamTypeStreamFunction {
+		return func(stream StreamInfo) bool {
+			return stream.StreamType() == StreamTypeSource && stream.ID() == sourceID
+		}
+	}
+	return sourceIDTagFindStreamFunc(sourceID, c.Frame.Tag)
+}
+
 // sourceIDTagFindStreamFunc creates a FindStreamFunc that finds a source type stream matching the specified sourceID and tag.
 func sourceIDTagFindStreamFunc(sourceID string, tag frame.Tag) FindStreamFunc {
 	return func(stream StreamInfo) bool {
@@ -450,6 +960,67 @@ func (s *Server) StatsCounter() int64 {
 	return atomic.LoadInt64(&s.counterOfDataFrame)
 }
 
+// StatsExpiredCounter returns how many DataFrames have been dropped as their TTL expired.
+func (s *Server) StatsExpiredCounter() int64 {
+	return atomic.LoadInt64(&s.counterOfExpiredFrame)
+}
+
+// StatsDeadLetterCounter returns how many DataFrames have been dead-lettered, including the ones dropped
+// as the dead-letter tag is not set.
+func (s *Server) StatsDeadLetterCounter() int64 {
+	return s.deadLetter.count.Load()
+}
+
+// StatsDroppedCounter returns how many DataFrames have been dropped by the rate limit.
+func (s *Server) StatsDroppedCounter() int64 {
+	return s.rateLimiter.dropped.Load()
+}
+
+// StatsRedeliveredCounter returns how many times the DataFrames given up by the stream functions have been re-delivered.
+func (s *Server) StatsRedeliveredCounter() int64 {
+	if s.redelivery == nil {
+		return 0
+	}
+	return s.redelivery.count.Load()
+}
+
+// StatsBackflowDroppedCounter returns how many backflows to the disconnected sources have been dropped,
+// see BackflowPolicy.
+func (s *Server) StatsBackflowDroppedCounter() int64 {
+	return s.backflow.dropped.Load()
+}
+
+// StatsScheduledCounter returns how many DataFrames are held by the scheduled delivery.
+func (s *Server) StatsScheduledCounter() int64 {
+	if s.scheduler == nil {
+		return 0
+	}
+	return s.scheduler.held.Load()
+}
+
+// StatsScheduledDroppedCounter returns how many scheduled DataFrames have been dropped, because the scheduled
+// delivery is full or their data streams are closed before they are due.
+func (s *Server) StatsScheduledDroppedCounter() int64 {
+	if s.scheduler == nil {
+		return 0
+	}
+	return s.scheduler.dropped.Load()
+}
+
+// StatsSampledOutCounter returns how many DataFrames have been sampled out, see WithServerStreamSampling.
+func (s *Server) StatsSampledOutCounter() int64 {
+	return s.sampler.sampledOut.Load()
+}
+
+// StatsReorderDroppedCounter returns how many sequenced DataFrames have been dropped
+// because they arrive after the reorder window has advanced past them.
+func (s *Server) StatsReorderDroppedCounter() int64 {
+	if s.reorder == nil {
+		return 0
+	}
+	return s.reorder.dropped.Load()
+}
+
 // Downstreams return all the downstream servers.
 func (s *Server) Downstreams() map[string]string {
 	s.mu.Lock()
@@ -487,7 +1058,7 @@ func (s *Server) dispatchToDownstreams(c *Context) {
 			sid       = GetSIDFromMetadata(c.FrameMetadata)
 		)
 		if broadcast {
-			mdBytes, err := c.FrameMetadata.Encode()
+			mdBytes, err := c.FrameMetadata.EncodeWith(s.opts.metadataEncoding)
 			if err != nil {
 				c.Logger.Error("failed to dispatch to downstream", "err", err)
 				return
@@ -2,10 +2,14 @@ package core
 
 import (
 	"crypto/tls"
+	"time"
 
 	"github.com/quic-go/quic-go"
 	"github.com/yomorun/yomo/core/auth"
+	"github.com/yomorun/yomo/core/frame"
+	"github.com/yomorun/yomo/core/metadata"
 	"github.com/yomorun/yomo/core/ylog"
+	"github.com/yomorun/yomo/pkg/frame-codec/y3codec"
 	oteltrace "go.opentelemetry.io/otel/trace"
 	"golang.org/x/exp/slog"
 )
@@ -16,21 +20,74 @@ type ServerOption func(*serverOptions)
 // ServerOptions are the options for YoMo server.
 // TODO: quic alpn function.
 type serverOptions struct {
-	quicConfig     *quic.Config
-	tlsConfig      *tls.Config
-	auths          map[string]auth.Authentication
-	logger         *slog.Logger
-	tracerProvider oteltrace.TracerProvider
+	quicConfig           *quic.Config
+	keepAlivePeriod      time.Duration
+	maxIdleTimeout       time.Duration
+	tlsConfig            *tls.Config
+	allow0RTT            bool
+	alpn                 []string
+	auths                map[string]auth.Authentication
+	verifyAuthentication VerifyAuthenticationFunc
+	verifiers            map[string]VerifyAuthenticationFunc
+	listeners            []Listener
+	maxDataStreams       int
+	readBufferSize       int
+	writeQueueSize       int
+	maxMetadataSize      int
+	writeTimeout         time.Duration
+	idleTimeout          time.Duration
+	heartbeatInterval    time.Duration
+	heartbeatMisses      int
+	dispatchRouter       DispatchRouter
+	unicastFallback      UnicastFallback
+	deadLetterTag        *frame.Tag
+	observeTagAuthorizer ObserveTagAuthorizer
+	observeTagDenyPolicy ObserveTagDenyPolicy
+	tagNamespaces        bool
+	codec                frame.Codec
+	packetReadWriter     frame.PacketReadWriter
+	panicHandler         PanicHandler
+	frameMiddlewares     []FrameMiddleware
+	observer             ServerObserver
+	versions             []frame.Version
+	rateLimit            RateLimit
+	tagRateLimits        map[frame.Tag]RateLimit
+	streamSamplings      map[string]Sampling
+	rateLimitPolicy      RateLimitPolicy
+	reorderWindow        ReorderWindow
+	maxScheduledFrames   int
+	maxDeliveryAttempts  int
+	redeliveryBackoff    Backoff
+	backflowPolicy       BackflowPolicy
+	backflowWindow       time.Duration
+	backflowFallbackTag  frame.Tag
+	connectorOptions     []ConnectorOption
+	healthBeforeAuth     bool
+	maxConnLifetime      time.Duration
+	connLifetimeJitter   float64
+	overloaded           func() bool
+	metadataEncoding     metadata.Encoding
+	clock                Clock
+	logger               *slog.Logger
+	tracerProvider       oteltrace.TracerProvider
 }
 
 func defaultServerOptions() *serverOptions {
 	logger := ylog.Default()
 
 	opts := &serverOptions{
-		quicConfig: DefalutQuicConfig,
-		tlsConfig:  nil,
-		auths:      map[string]auth.Authentication{},
-		logger:     logger,
+		quicConfig:       DefalutQuicConfig,
+		tlsConfig:        nil,
+		auths:            map[string]auth.Authentication{},
+		codec:            y3codec.Codec(),
+		packetReadWriter: y3codec.PacketReadWriter(),
+		readBufferSize:   DefaultReadBufferSize,
+		maxMetadataSize:  DefaultMaxMetadataSize,
+		dispatchRouter:   BroadcastRouter,
+		observer:         nopServerObserver{},
+		versions:         frame.SupportedVersions,
+		clock:            SystemClock,
+		logger:           logger,
 	}
 	return opts
 }
@@ -48,13 +105,259 @@ func WithAuth(name string, args ...string) ServerOption {
 	}
 }
 
-// WithServerTLSConfig sets the TLS configuration for the server.
+// WithServerMaxDataStreams sets the max count of the data streams per connection, the handshakes exceed it
+// are rejected with frame.RejectRateLimited. Closing a data stream frees a slot. Zero means unlimited.
+func WithServerMaxDataStreams(n int) ServerOption {
+	return func(o *serverOptions) {
+		o.maxDataStreams = n
+	}
+}
+
+// WithServerHealthCheckBeforeAuth makes the server accept the HealthCheckFrames before the authentication,
+// so the probes without the credential can check its health, see ProbeHealth.
+func WithServerHealthCheckBeforeAuth() ServerOption {
+	return func(o *serverOptions) {
+		o.healthBeforeAuth = true
+	}
+}
+
+// WithServerOverloaded sets the func that reports whether the server is overloaded, the server replies
+// the HealthCheckFrames with frame.HealthOverloaded while it returns true.
+func WithServerOverloaded(fn func() bool) ServerOption {
+	return func(o *serverOptions) {
+		o.overloaded = fn
+	}
+}
+
+// WithServerListener adds an extra listener to the server, the connections accepted from it are served
+// like the quic ones. It is used to serve the clients those can not open raw QUIC, see SessionListener.
+func WithServerListener(ln Listener) ServerOption {
+	return func(o *serverOptions) {
+		o.listeners = append(o.listeners, ln)
+	}
+}
+
+// WithServerWriteQueueSize enables the write queue of the streams of the server, the control frames
+// go ahead of the DataFrames waiting to be written, at most size DataFrames wait per stream, see WithWriteQueue.
+func WithServerWriteQueueSize(size int) ServerOption {
+	return func(o *serverOptions) {
+		o.writeQueueSize = size
+	}
+}
+
+// WithServerMaxMetadataSize sets the max size of the encoded metadata of the handshakes and the DataFrames,
+// the handshakes exceed it are rejected with frame.RejectInvalid and the streams writing the DataFrames exceed it
+// are closed. The default is DefaultMaxMetadataSize, a non-positive size means unlimited.
+func WithServerMaxMetadataSize(size int) ServerOption {
+	return func(o *serverOptions) {
+		o.maxMetadataSize = size
+	}
+}
+
+// WithServerVerifyAuthentication sets the function that verifies the AuthenticationFrames,
+// it overrides the auths set by WithAuth.
+func WithServerVerifyAuthentication(fn VerifyAuthenticationFunc) ServerOption {
+	return func(o *serverOptions) {
+		o.verifyAuthentication = fn
+	}
+}
+
+// WithServerVerifier registers the function that verifies the AuthenticationFrames whose AuthName is the name,
+// so that the server supports multiple auth schemes, such as an api key and a JWT. Once a verifier is registered,
+// the credentials whose name matches neither a verifier nor an auth set by WithAuth are rejected.
+// It is overridden by WithServerVerifyAuthentication.
+func WithServerVerifier(name string, fn VerifyAuthenticationFunc) ServerOption {
+	return func(o *serverOptions) {
+		if o.verifiers == nil {
+			o.verifiers = make(map[string]VerifyAuthenticationFunc)
+		}
+		o.verifiers[name] = fn
+	}
+}
+
+// WithServerTLSConfig sets the TLS configuration for the server, for example, set ClientCAs and
+// ClientAuth for mutual TLS. A nil configuration means using the default self-signed one.
 func WithServerTLSConfig(tc *tls.Config) ServerOption {
 	return func(o *serverOptions) {
 		o.tlsConfig = tc
 	}
 }
 
+// WithServerALPN sets the application protocols that the server negotiates,
+// the default is the NextProtos of the TLS configuration, or "yomo" if it is empty.
+func WithServerALPN(protos ...string) ServerOption {
+	return func(o *serverOptions) {
+		o.alpn = protos
+	}
+}
+
+// WithServerReadBufferSize sets the size of the buffer that the streams of the server read ahead into,
+// a larger buffer reduces the reads on the QUIC streams under high frame rates. A non-positive size disables it.
+func WithServerReadBufferSize(size int) ServerOption {
+	return func(o *serverOptions) {
+		o.readBufferSize = size
+	}
+}
+
+// WithServerWriteTimeout sets the max time of writing a routed DataFrame to a stream, the frame is skipped
+// for the slow stream once the time elapses, and the stream is closed if the frame is partially written.
+// Zero means no timeout.
+func WithServerWriteTimeout(d time.Duration) ServerOption {
+	return func(o *serverOptions) {
+		o.writeTimeout = d
+	}
+}
+
+// WithServerIdleTimeout sets the max time a data stream can live without reading or writing a frame,
+// the idle stream is closed with a CloseStreamFrame of frame.CloseIdleTimeout. Zero means no timeout.
+// Unlike the max idle timeout of QUIC, it is not reset by the keep-alive packets, see WithServerMaxIdleTimeout.
+func WithServerIdleTimeout(d time.Duration) ServerOption {
+	return func(o *serverOptions) {
+		o.idleTimeout = d
+	}
+}
+
+// WithServer0RTT makes the server accept the 0-RTT connections of the clients resuming their TLS sessions,
+// see WithClient0RTT. Only the AuthenticationFrame is handled in 0-RTT, for the 0-RTT data can be replayed,
+// the data streams and their frames are handled after the handshake completes.
+//
+// The AuthenticationFrame is verified before the handshake completes, so an attacker can replay it to run
+// the verifier again. The verifiers must be replay-safe: they must not have side effects those count on being
+// run once per client, such as consuming a one-time token or charging a quota, see VerifyAuthenticationFunc.
+func WithServer0RTT() ServerOption {
+	return func(o *serverOptions) {
+		o.allow0RTT = true
+	}
+}
+
+// WithServerHeartbeat makes the server write a PingFrame on every data stream at the interval as the heartbeat,
+// the stream that reads no frame, neither the PongFrame nor others, within the misses heartbeats is closed with
+// a CloseStreamFrame of frame.CloseHeartbeatTimeout. It detects the half-open streams faster than the idle timeout.
+// Zero interval means no heartbeat, the misses is at least 1.
+func WithServerHeartbeat(interval time.Duration, misses int) ServerOption {
+	return func(o *serverOptions) {
+		if misses < 1 {
+			misses = 1
+		}
+		o.heartbeatInterval = interval
+		o.heartbeatMisses = misses
+	}
+}
+
+// WithServerScheduledDelivery enables the scheduled delivery, the DataFrame that carries a delay in its metadata
+// is held until the delay is due, then it is dispatched, see SetDeliverAfterToMetadata. Up to maxFrames DataFrames
+// are held, the ones beyond are dropped, and the ones of a closed data stream are dropped too.
+// It is disabled by default, the delays are ignored then.
+func WithServerScheduledDelivery(maxFrames int) ServerOption {
+	return func(o *serverOptions) {
+		o.maxScheduledFrames = maxFrames
+	}
+}
+
+// WithServerRedelivery enables the redelivery, the DataFrame given up by the stream function is re-delivered to it
+// after the backoff, until it has been delivered for maxAttempts times, then it is dead-lettered.
+// The delivery attempt is carried in the metadata, see MetadataDeliveryAttemptKey, so the stream function
+// can process the redelivered DataFrames idempotently. It is disabled by default.
+func WithServerRedelivery(maxAttempts int, backoff Backoff) ServerOption {
+	return func(o *serverOptions) {
+		o.maxDeliveryAttempts = maxAttempts
+		o.redeliveryBackoff = backoff
+	}
+}
+
+// WithServerBackflowBuffer buffers the backflow to the source that has disconnected for the window,
+// it is written to the source once the source reconnects with the same id in the window, see BackflowPolicyBuffer.
+// The backflow is dropped by default.
+func WithServerBackflowBuffer(window time.Duration) ServerOption {
+	return func(o *serverOptions) {
+		o.backflowPolicy = BackflowPolicyBuffer
+		o.backflowWindow = window
+	}
+}
+
+// WithServerBackflowFallbackTag reroutes the backflow to the source that has disconnected to the stream functions
+// observing the tag, see BackflowPolicyFallbackTag. The backflow is dropped by default.
+func WithServerBackflowFallbackTag(tag frame.Tag) ServerOption {
+	return func(o *serverOptions) {
+		o.backflowPolicy = BackflowPolicyFallbackTag
+		o.backflowFallbackTag = tag
+	}
+}
+
+// WithServerConnectorOptions sets the options of the Connector that keeps the data streams of the server,
+// such as WithConnectorShards and WithConnectorCapacity for tens of thousands of streams.
+func WithServerConnectorOptions(opts ...ConnectorOption) ServerOption {
+	return func(o *serverOptions) {
+		o.connectorOptions = append(o.connectorOptions, opts...)
+	}
+}
+
+// WithServerFrameMiddlewares appends the middlewares of the frames read from the data streams,
+// the frames go through the middlewares in the order they are appended, see FrameMiddleware.
+func WithServerFrameMiddlewares(middlewares ...FrameMiddleware) ServerOption {
+	return func(o *serverOptions) {
+		o.frameMiddlewares = append(o.frameMiddlewares, middlewares...)
+	}
+}
+
+// WithServerDeadLetterTag sets the dead-letter tag, the DataFrames that no stream observes or that the sfn
+// gives up are routed to it, the original tag and the reason are carried in the metadata, see MetadataDeadLetterTagKey.
+// The dead letters are dropped if it is not set.
+func WithServerDeadLetterTag(tag frame.Tag) ServerOption {
+	return func(o *serverOptions) {
+		o.deadLetterTag = &tag
+	}
+}
+
+// WithServerObserveTagAuthorizer sets the hook that authorizes the tags observed by the streams, both in the handshake
+// and at runtime. The handshakes those observe the denied tags are handled by the policy, the denied tags observed
+// at runtime are ignored.
+func WithServerObserveTagAuthorizer(authorizer ObserveTagAuthorizer, policy ObserveTagDenyPolicy) ServerOption {
+	return func(o *serverOptions) {
+		o.observeTagAuthorizer = authorizer
+		o.observeTagDenyPolicy = policy
+	}
+}
+
+// WithServerTagNamespaces enforces the tag namespaces, the streams write and observe the tags in the namespace
+// of their connection only, see MetadataNamespaceKey. The DataFrames written out of the namespace are dropped,
+// the observed tags are authorized by NamespaceObserveTagAuthorizer unless WithServerObserveTagAuthorizer is set.
+func WithServerTagNamespaces() ServerOption {
+	return func(o *serverOptions) {
+		o.tagNamespaces = true
+	}
+}
+
+// WithServerMaxConnectionLifetime sets the max lifetime of the connections, the connection is rotated once it lives
+// that long: the server sends the GoawayFrame that asks the client to reconnect and closes the connection, so the client
+// authenticates again with the new one. The jitter shortens the lifetime of every connection by up to the given fraction
+// at random, for example 0.1 means the connections live 90% to 100% of the lifetime, so the clients connected at the same
+// time do not reconnect at the same time. Zero lifetime means the connections live forever, it is the default.
+func WithServerMaxConnectionLifetime(lifetime time.Duration, jitter float64) ServerOption {
+	return func(o *serverOptions) {
+		o.maxConnLifetime = lifetime
+		o.connLifetimeJitter = jitter
+	}
+}
+
+// WithServerDispatchRouter sets the DispatchRouter that chooses the streams a DataFrame is dispatched to,
+// the default is BroadcastRouter.
+func WithServerDispatchRouter(r DispatchRouter) ServerOption {
+	return func(o *serverOptions) {
+		if r != nil {
+			o.dispatchRouter = r
+		}
+	}
+}
+
+// WithServerUnicastFallback sets what to do with the DataFrame unicast to the stream that is gone,
+// the default is UnicastFallbackBroadcast.
+func WithServerUnicastFallback(fallback UnicastFallback) ServerOption {
+	return func(o *serverOptions) {
+		o.unicastFallback = fallback
+	}
+}
+
 // WithServerQuicConfig sets the QUIC configuration for the server.
 func WithServerQuicConfig(qc *quic.Config) ServerOption {
 	return func(o *serverOptions) {
@@ -62,6 +365,28 @@ func WithServerQuicConfig(qc *quic.Config) ServerOption {
 	}
 }
 
+// WithServerKeepAlivePeriod sets the period the server sends QUIC keep-alive packets, it overrides the KeepAlivePeriod
+// of the quic config. It should be less than the max idle timeout, so that the idle connections are not closed.
+func WithServerKeepAlivePeriod(d time.Duration) ServerOption {
+	return func(o *serverOptions) {
+		o.keepAlivePeriod = d
+	}
+}
+
+// WithServerMaxIdleTimeout sets the time the QUIC connection is closed after no packet is received,
+// it overrides the MaxIdleTimeout of the quic config. The effective timeout is the smaller one of both sides.
+//
+// The max idle timeout works on the transport, the keep-alive packets keep the connection alive even if
+// no frame is sent, while the idle timeout set by WithServerIdleTimeout works on the data streams and is not
+// reset by the keep-alive packets. To keep the long-lived low-traffic connections, set the keep-alive period
+// less than the max idle timeout, and set the idle timeout of the data streams larger than the longest gap
+// between the frames, or leave it zero.
+func WithServerMaxIdleTimeout(d time.Duration) ServerOption {
+	return func(o *serverOptions) {
+		o.maxIdleTimeout = d
+	}
+}
+
 // WithServerLogger sets logger for the server.
 func WithServerLogger(logger *slog.Logger) ServerOption {
 	return func(o *serverOptions) {
@@ -75,3 +400,108 @@ func WithServerTracerProvider(tp oteltrace.TracerProvider) ServerOption {
 		o.tracerProvider = tp
 	}
 }
+
+// WithServerCodec sets the codec that encodes and decodes frames for every connection of the server,
+// the default codec is y3codec.
+func WithServerCodec(codec frame.Codec) ServerOption {
+	return func(o *serverOptions) {
+		if codec != nil {
+			o.codec = codec
+		}
+	}
+}
+
+// WithServerPacketReadWriter sets the PacketReadWriter that reads and writes packets for every connection of the server,
+// the default PacketReadWriter is y3codec.
+func WithServerPacketReadWriter(prw frame.PacketReadWriter) ServerOption {
+	return func(o *serverOptions) {
+		if prw != nil {
+			o.packetReadWriter = prw
+		}
+	}
+}
+
+// WithServerPanicHandler sets the handler that will be called when a stream handler panics,
+// the panic is always recovered and logged, the handler is used for reporting it elsewhere.
+func WithServerPanicHandler(h PanicHandler) ServerOption {
+	return func(o *serverOptions) {
+		o.panicHandler = h
+	}
+}
+
+// WithServerVersions sets the protocol versions the server supports, the connections of the clients
+// those have no version in common are closed. The default is frame.SupportedVersions.
+func WithServerVersions(versions ...frame.Version) ServerOption {
+	return func(o *serverOptions) {
+		o.versions = versions
+	}
+}
+
+// WithServerObserver sets the ServerObserver that observes the connections, the data streams and the frames
+// of the server, such as the Prometheus collector of the pkg/metrics package.
+func WithServerObserver(observer ServerObserver) ServerOption {
+	return func(o *serverOptions) {
+		o.observer = observer
+	}
+}
+
+// WithServerRateLimit sets the default rate limit of DataFrames for every tag,
+// the DataFrames exceed the limit are dropped or delayed according to the RateLimitPolicy.
+func WithServerRateLimit(limit RateLimit) ServerOption {
+	return func(o *serverOptions) {
+		o.rateLimit = limit
+	}
+}
+
+// WithServerTagRateLimit overrides the default rate limit for the tag.
+func WithServerTagRateLimit(tag frame.Tag, limit RateLimit) ServerOption {
+	return func(o *serverOptions) {
+		if o.tagRateLimits == nil {
+			o.tagRateLimits = make(map[frame.Tag]RateLimit)
+		}
+		o.tagRateLimits[tag] = limit
+	}
+}
+
+// WithServerStreamSampling samples the DataFrames delivered to the streams of the name, the DataFrames
+// sampled out are counted, see Server.StatsSampledOutCounter.
+func WithServerStreamSampling(streamName string, sampling Sampling) ServerOption {
+	return func(o *serverOptions) {
+		if o.streamSamplings == nil {
+			o.streamSamplings = make(map[string]Sampling)
+		}
+		o.streamSamplings[streamName] = sampling
+	}
+}
+
+// WithServerRateLimitPolicy sets what to do with the DataFrames exceed the rate limit, the default is RateLimitDrop.
+func WithServerRateLimitPolicy(policy RateLimitPolicy) ServerOption {
+	return func(o *serverOptions) {
+		o.rateLimitPolicy = policy
+	}
+}
+
+// WithServerReorderWindow enables the reorder buffer that delivers the sequenced DataFrames
+// in sequence order within a tag, see ReorderWindow.
+func WithServerReorderWindow(window ReorderWindow) ServerOption {
+	return func(o *serverOptions) {
+		o.reorderWindow = window
+	}
+}
+
+// WithServerMetadataEncoding sets the encoding of the metadata of DataFrames that the server re-encodes,
+// the default is msgpack, JSON is larger but can be inspected by standard tools.
+// The server decodes metadata in either encoding.
+func WithServerMetadataEncoding(enc metadata.Encoding) ServerOption {
+	return func(o *serverOptions) {
+		o.metadataEncoding = enc
+	}
+}
+
+// WithServerClock sets the clock of the time-dependent features of the server, such as the idle timeout,
+// the heartbeats and the scheduled delivery. It is SystemClock by default, see ManualClock for the tests.
+func WithServerClock(clock Clock) ServerOption {
+	return func(o *serverOptions) {
+		o.clock = clock
+	}
+}
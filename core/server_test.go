@@ -1,12 +1,20 @@
 package core
 
 import (
+	"context"
+	"strconv"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/yomorun/yomo/core/auth"
 	"github.com/yomorun/yomo/core/frame"
+	"github.com/yomorun/yomo/core/frame/frametest"
 	"github.com/yomorun/yomo/core/metadata"
+	"github.com/yomorun/yomo/core/router"
+	"github.com/yomorun/yomo/core/ylog"
 	_ "github.com/yomorun/yomo/pkg/auth"
+	"github.com/yomorun/yomo/pkg/config"
 )
 
 func TestMakeSourceTagFindStreamFunc(t *testing.T) {
@@ -31,6 +39,19 @@ func TestMakeSourceTagFindStreamFunc(t *testing.T) {
 	})
 }
 
+func TestInspectorTagFindStreamFunc(t *testing.T) {
+	findFunc := inspectorTagFindStreamFunc(frame.Tag(7))
+
+	inspector := &mockStreamInfo{id: "inspector", observed: []frame.Tag{frame.Tag(7)}, streamType: StreamTypeInspector}
+	assert.True(t, findFunc(inspector))
+
+	sfn := &mockStreamInfo{id: "sfn", observed: []frame.Tag{frame.Tag(7)}, streamType: StreamTypeStreamFunction}
+	assert.False(t, findFunc(sfn))
+
+	other := &mockStreamInfo{id: "other", observed: []frame.Tag{frame.Tag(6)}, streamType: StreamTypeInspector}
+	assert.False(t, findFunc(other))
+}
+
 type mockStreamInfo struct {
 	name       string
 	id         string
@@ -44,3 +65,162 @@ func (s *mockStreamInfo) Name() string                 { return s.name }
 func (s *mockStreamInfo) Metadata() metadata.M         { return s.metadata }
 func (s *mockStreamInfo) StreamType() StreamType       { return s.streamType }
 func (s *mockStreamInfo) ObserveDataTags() []frame.Tag { return s.observed }
+
+func TestExpireDataFrame(t *testing.T) {
+	s := &Server{}
+
+	f := &frame.DataFrame{Tag: 1, TTL: 2}
+	assert.False(t, s.expireDataFrame(f))
+	assert.Equal(t, uint8(1), f.TTL)
+	assert.True(t, s.expireDataFrame(f))
+	assert.Equal(t, int64(1), s.StatsExpiredCounter())
+
+	// the frame without ttl is taken as DefaultTTL.
+	f = &frame.DataFrame{Tag: 1}
+	assert.False(t, s.expireDataFrame(f))
+	assert.Equal(t, frame.DefaultTTL-1, f.TTL)
+}
+
+func TestDelayDataFrame(t *testing.T) {
+	clock := NewManualClock(time.Now())
+	s := NewServer("zipper", WithServerLogger(discardingLogger), WithServerClock(clock))
+	defer s.Close()
+
+	frameStream := NewFrameStream(newMemByteStream(nil), &byteCodec{}, &bytePacketReadWriter{})
+	stream := newDataStream("source", "source-id", "", StreamTypeSource, nil, nil, frameStream, nil, nil, clock)
+	c := newContext(stream, nil, discardingLogger)
+
+	done := make(chan bool)
+	go func() { done <- s.delayDataFrame(c, time.Second) }()
+	assert.Eventually(t, func() bool { return clock.Timers() == 1 }, time.Second, time.Millisecond)
+	clock.Advance(time.Second)
+	assert.True(t, <-done)
+
+	// the delay is given up once the stream is closed.
+	go func() { done <- s.delayDataFrame(c, time.Hour) }()
+	assert.Eventually(t, func() bool { return clock.Timers() == 1 }, time.Second, time.Millisecond)
+	assert.NoError(t, stream.Close())
+	assert.False(t, <-done)
+	assert.Equal(t, 0, clock.Timers())
+}
+
+func TestDispatchTargets(t *testing.T) {
+	candidates := []DataStream{
+		newDataStream("sfn", "sfn-1", "", StreamTypeStreamFunction, metadata.M{}, []frame.Tag{1}, nil, nil, nil, SystemClock),
+		newDataStream("sfn", "sfn-2", "", StreamTypeStreamFunction, metadata.M{}, []frame.Tag{1}, nil, nil, nil, SystemClock),
+	}
+	c := &Context{Frame: &frame.DataFrame{Tag: 1}, FrameMetadata: metadata.M{}, Logger: ylog.Default()}
+
+	s := &Server{opts: defaultServerOptions()}
+	assert.Equal(t, candidates, s.dispatchTargets(c, "", candidates))
+	assert.Equal(t, candidates[1:], s.dispatchTargets(c, "sfn-2", candidates))
+	assert.Equal(t, candidates, s.dispatchTargets(c, "sfn-3", candidates))
+
+	s.opts.unicastFallback = UnicastFallbackDrop
+	assert.Equal(t, candidates[:1], s.dispatchTargets(c, "sfn-1", candidates))
+	assert.Empty(t, s.dispatchTargets(c, "sfn-3", candidates))
+}
+
+func TestDispatchVerifyAuthentication(t *testing.T) {
+	verifier := func(token string) VerifyAuthenticationFunc {
+		return func(_ context.Context, f *frame.AuthenticationFrame) (metadata.M, bool, error) {
+			return metadata.M{"scheme": f.AuthName}, f.AuthPayloadString() == token, nil
+		}
+	}
+	verify := dispatchVerifyAuthentication(
+		map[string]VerifyAuthenticationFunc{"apikey": verifier("key"), "jwt": verifier("jwt")},
+		map[string]auth.Authentication{"token": nil},
+		verifier("token"),
+	)
+
+	md, ok, err := verify(context.TODO(), &frame.AuthenticationFrame{AuthName: "apikey", AuthPayload: []byte("key")})
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, metadata.M{"scheme": "apikey"}, md)
+
+	_, ok, err = verify(context.TODO(), &frame.AuthenticationFrame{AuthName: "jwt", AuthPayload: []byte("key")})
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	_, ok, err = verify(context.TODO(), &frame.AuthenticationFrame{AuthName: "token", AuthPayload: []byte("token")})
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	_, ok, err = verify(context.TODO(), &frame.AuthenticationFrame{AuthName: "basic", AuthPayload: []byte("key")})
+	assert.EqualError(t, err, `yomo: no verifier for the credential name "basic"`)
+	assert.False(t, ok)
+}
+
+func TestWithFirehoseRoutes(t *testing.T) {
+	route := router.Default([]config.Function{{Name: "sfn"}, {Name: "audit"}}).Route(metadata.M{})
+	assert.NoError(t, route.Add("sfn-1", "sfn", []frame.Tag{1}))
+
+	assert.Equal(t, []string{"sfn-1"}, withFirehoseRoutes(route, route.GetForwardRoutes(1)))
+
+	assert.NoError(t, route.Add("audit-1", "audit", []frame.Tag{1, frame.TagFirehose}))
+
+	assert.Equal(t, []string{"audit-1", "sfn-1"}, withFirehoseRoutes(route, route.GetForwardRoutes(1)))
+	assert.Equal(t, []string{"audit-1"}, withFirehoseRoutes(route, route.GetForwardRoutes(2)))
+}
+
+func TestAuthorizeFirehose(t *testing.T) {
+	tags, err := authorizeObserveTags(nil, ObserveTagDenyFilter, metadata.M{}, []frame.Tag{1, frame.TagFirehose})
+	assert.NoError(t, err)
+	assert.Equal(t, []frame.Tag{1}, tags)
+
+	_, err = authorizeObserveTags(nil, ObserveTagDenyReject, metadata.M{}, []frame.Tag{frame.TagFirehose})
+	assert.Error(t, err)
+
+	privileged := func(md metadata.M, _ frame.Tag) bool {
+		role, _ := md.Get("role")
+		return role == "auditor"
+	}
+	tags, err = authorizeObserveTags(privileged, ObserveTagDenyReject, metadata.M{"role": "auditor"}, []frame.Tag{frame.TagFirehose})
+	assert.NoError(t, err)
+	assert.Equal(t, []frame.Tag{frame.TagFirehose}, tags)
+}
+
+// BenchmarkServerRouteDataFrame benchmarks routing the DataFrames to the sfns, the sfn streams are
+// in-memory and the frames are passed through, so neither QUIC nor the serialization is measured.
+func BenchmarkServerRouteDataFrame(b *testing.B) {
+	codec, prw := &frametest.NoopCodec{Tag: 1}, &frametest.PacketReadWriter{}
+
+	for _, sfns := range []int{1, 16} {
+		b.Run("sfns-"+strconv.Itoa(sfns), func(b *testing.B) {
+			s := NewServer("zipper", WithServerLogger(discardingLogger))
+			s.connector = NewConnector(context.Background())
+			functions := make([]config.Function, sfns)
+			for i := range functions {
+				functions[i] = config.Function{Name: "sfn-" + strconv.Itoa(i)}
+			}
+			s.ConfigRouter(router.Default(functions))
+
+			route := s.router.Route(metadata.M{})
+			for _, fn := range functions {
+				fs := NewFrameStream(frametest.NewDiscardStream(), codec, prw)
+				stream := newDataStream(fn.Name, fn.Name, "", StreamTypeStreamFunction, metadata.M{}, []frame.Tag{1}, fs, nil, nil, SystemClock)
+				if err := s.connector.Store(fn.Name, stream); err != nil {
+					b.Fatal(err)
+				}
+				if err := route.Add(fn.Name, fn.Name, []frame.Tag{1}); err != nil {
+					b.Fatal(err)
+				}
+			}
+			source := newDataStream("source", "source", "", StreamTypeSource, metadata.M{}, nil, nil, nil, nil, SystemClock)
+			payload := []byte("pre-encoded payload")
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				c := newContext(source, route, discardingLogger)
+				if err := c.WithFrame(&frame.DataFrame{Tag: 1, Payload: payload}); err != nil {
+					b.Fatal(err)
+				}
+				if err := s.handleDataFrame(c); err != nil {
+					b.Fatal(err)
+				}
+				c.Release()
+			}
+		})
+	}
+}
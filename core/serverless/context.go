@@ -2,13 +2,22 @@
 package serverless
 
 import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
 	"github.com/yomorun/yomo/core/frame"
+	"github.com/yomorun/yomo/core/metadata"
+	"github.com/yomorun/yomo/pkg/id"
 )
 
 // Context sfn handler context
 type Context struct {
 	writer    frame.Writer
 	dataFrame *frame.DataFrame
+	// deadLettered is true once the data frame is given up by DeadLetter.
+	deadLettered bool
 }
 
 // NewContext creates a new serverless Context
@@ -35,11 +44,163 @@ func (c *Context) Write(tag uint32, data []byte) error {
 		return nil
 	}
 
+	// inherit the ttl, so the sfns routing data to each other in a loop do not loop forever.
+	dataFrame, err := frame.NewDataFrame(tag, data, frame.WithEncodedMetadata(c.dataFrame.Metadata), frame.WithTTL(c.dataFrame.TTL))
+	if err != nil {
+		return err
+	}
+
+	return c.writer.WriteFrame(dataFrame)
+}
+
+// reservedMetadataPrefix is the key prefix of the metadata used by yomo, such as tid and sid,
+// the metadata written by WriteWithMetadata can not override them.
+const reservedMetadataPrefix = "yomo-"
+
+// WriteWithMetadata writes the data with additional metadata, the metadata is merged into
+// the metadata of the incoming data frame, the keys prefixed with "yomo-" are ignored.
+func (c *Context) WriteWithMetadata(tag uint32, data []byte, md map[string]string) error {
+	if data == nil {
+		return nil
+	}
+	if len(md) == 0 {
+		return c.Write(tag, data)
+	}
+
+	fmd, err := metadata.Decode(c.dataFrame.Metadata)
+	if err != nil {
+		return err
+	}
+	for k, v := range md {
+		if strings.HasPrefix(k, reservedMetadataPrefix) {
+			continue
+		}
+		fmd.Set(k, v)
+	}
+	b, err := fmd.EncodeWith(metadata.EncodingOf(c.dataFrame.Metadata))
+	if err != nil {
+		return err
+	}
+
+	dataFrame, err := frame.NewDataFrame(tag, data, frame.WithEncodedMetadata(b), frame.WithTTL(c.dataFrame.TTL))
+	if err != nil {
+		return err
+	}
+
+	return c.writer.WriteFrame(dataFrame)
+}
+
+// deadLetterKey is the metadata key of the reason why the sfn gives up the data frame, it is core.MetadataDeadLetterKey.
+const deadLetterKey = reservedMetadataPrefix + "dead-letter"
+
+// DeadLetter gives up the incoming data frame, the data frame is written back with the reason,
+// and the zipper routes it to its dead-letter tag.
+func (c *Context) DeadLetter(reason string) error {
+	fmd, err := metadata.Decode(c.dataFrame.Metadata)
+	if err != nil {
+		return err
+	}
+	c.deadLettered = true
+	fmd.Set(deadLetterKey, reason)
+	b, err := fmd.EncodeWith(metadata.EncodingOf(c.dataFrame.Metadata))
+	if err != nil {
+		return err
+	}
+
 	dataFrame := &frame.DataFrame{
-		Tag:      tag,
-		Metadata: c.dataFrame.Metadata,
-		Payload:  data,
+		Tag:      c.dataFrame.Tag,
+		Metadata: b,
+		Payload:  c.dataFrame.Payload,
+		TTL:      c.dataFrame.TTL,
 	}
 
 	return c.writer.WriteFrame(dataFrame)
 }
+
+// deliveryAttemptKey is the metadata key of the delivery attempt of the data frame, it is core.MetadataDeliveryAttemptKey.
+const deliveryAttemptKey = reservedMetadataPrefix + "delivery-attempt"
+
+// Attempt returns the delivery attempt of the data frame, it starts from 1 and increases every time
+// the data frame given up by DeadLetter is re-delivered, so the sfn can process it idempotently.
+func (c *Context) Attempt() int {
+	fmd, err := metadata.Decode(c.dataFrame.Metadata)
+	if err != nil {
+		return 1
+	}
+	v, _ := fmd.Get(deliveryAttemptKey)
+	attempt, err := strconv.Atoi(v)
+	if err != nil || attempt < 1 {
+		return 1
+	}
+	return attempt
+}
+
+// DeadLettered reports whether the data frame has been given up by DeadLetter.
+func (c *Context) DeadLettered() bool {
+	return c.deadLettered
+}
+
+const (
+	// backflowAckIDKey is the metadata key of the id of the backflow ack, it is core.MetadataBackflowAckIDKey.
+	backflowAckIDKey = reservedMetadataPrefix + "backflow-ack-id"
+	// backflowAckStreamKey is the metadata key of the stream waits for the backflow ack, it is core.MetadataBackflowAckStreamKey.
+	backflowAckStreamKey = reservedMetadataPrefix + "backflow-ack-stream"
+)
+
+var (
+	// ErrBackflowAckTimeout is returned by WriteWithAck if the source does not acknowledge the backflow in time.
+	ErrBackflowAckTimeout = errors.New("yomo: backflow ack timeout")
+	// ErrBackflowAckNotSupported is returned by WriteWithAck if the writer of the context can not wait for the ack.
+	ErrBackflowAckNotSupported = errors.New("yomo: backflow ack is not supported")
+)
+
+// backflowAckWriter is the writer that can wait for the backflow acks, it is implemented by core.Client.
+type backflowAckWriter interface {
+	frame.Writer
+	ClientID() string
+	ExpectBackflowAck(id string) (<-chan struct{}, func())
+}
+
+// WriteWithAck writes the data and waits until the source acknowledges its backflow, it returns
+// ErrBackflowAckTimeout if the ack does not arrive within the timeout. The ack is opt-in per write,
+// the data written by Write and WriteWithMetadata are not acknowledged.
+func (c *Context) WriteWithAck(tag uint32, data []byte, timeout time.Duration) error {
+	writer, ok := c.writer.(backflowAckWriter)
+	if !ok {
+		return ErrBackflowAckNotSupported
+	}
+
+	fmd, err := metadata.Decode(c.dataFrame.Metadata)
+	if err != nil {
+		return err
+	}
+	ackID := id.New()
+	fmd.Set(backflowAckIDKey, ackID)
+	fmd.Set(backflowAckStreamKey, writer.ClientID())
+	b, err := fmd.EncodeWith(metadata.EncodingOf(c.dataFrame.Metadata))
+	if err != nil {
+		return err
+	}
+
+	dataFrame, err := frame.NewDataFrame(tag, data, frame.WithEncodedMetadata(b), frame.WithTTL(c.dataFrame.TTL))
+	if err != nil {
+		return err
+	}
+
+	acked, cancel := writer.ExpectBackflowAck(ackID)
+	defer cancel()
+
+	if err := writer.WriteFrame(dataFrame); err != nil {
+		return err
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case <-acked:
+		return nil
+	case <-timer.C:
+		return ErrBackflowAckTimeout
+	}
+}
@@ -0,0 +1,138 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"sync"
+)
+
+// StreamSession is a multiplexed session that opens and accepts bidirectional streams, such as a WebTransport session.
+// A WebTransport session can be adapted to StreamSession with a thin wrapper that returns its streams as
+// io.ReadWriteCloser and closes the session with an application error code.
+type StreamSession interface {
+	// Context returns the context that is cancelled once the session is closed.
+	Context() context.Context
+	// LocalAddr returns the local address.
+	LocalAddr() net.Addr
+	// RemoteAddr returns the address of the peer.
+	RemoteAddr() net.Addr
+	// OpenStream opens a new bidirectional stream.
+	OpenStream() (io.ReadWriteCloser, error)
+	// AcceptStream returns the next stream opened by the peer, blocking until one is available.
+	AcceptStream(context.Context) (io.ReadWriteCloser, error)
+	// CloseWithError closes the session with an error.
+	CloseWithError(string) error
+}
+
+// sessionConnection adapts StreamSession to Connection, so the StreamGroup works unchanged with it.
+type sessionConnection struct {
+	session StreamSession
+	stats   *statsRecorder
+}
+
+// NewSessionConnection returns a Connection that transmits the frames on the streams of the session.
+func NewSessionConnection(session StreamSession) Connection {
+	return &sessionConnection{
+		session: session,
+		stats:   new(statsRecorder),
+	}
+}
+
+func (sc *sessionConnection) LocalAddr() string  { return sc.session.LocalAddr().String() }
+func (sc *sessionConnection) RemoteAddr() string { return sc.session.RemoteAddr().String() }
+func (sc *sessionConnection) CloseWithError(errString string) error {
+	return sc.session.CloseWithError(errString)
+}
+func (sc *sessionConnection) Stats() ConnectionStats { return sc.stats.snapshot() }
+
+func (sc *sessionConnection) recordDataStream(delta int64) { sc.stats.recordDataStream(delta) }
+
+func (sc *sessionConnection) setObserver(observer ServerObserver) { sc.stats.observer = observer }
+
+func (sc *sessionConnection) OpenStream() (ContextReadWriteCloser, error) {
+	stream, err := sc.session.OpenStream()
+	if err != nil {
+		return nil, err
+	}
+	return sc.wrap(stream), nil
+}
+
+func (sc *sessionConnection) AcceptStream(ctx context.Context) (ContextReadWriteCloser, error) {
+	stream, err := sc.session.AcceptStream(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return sc.wrap(stream), nil
+}
+
+func (sc *sessionConnection) wrap(stream io.ReadWriteCloser) ContextReadWriteCloser {
+	return &statsStream{&sessionStream{stream, sc.session.Context()}, sc.stats}
+}
+
+// sessionStream is a stream of the session, its context is the context of the session.
+type sessionStream struct {
+	io.ReadWriteCloser
+	ctx context.Context
+}
+
+func (s *sessionStream) Context() context.Context { return s.ctx }
+
+// ErrListenerClosed is returned by SessionListener once it is closed.
+var ErrListenerClosed = errors.New("yomo: listener closed")
+
+// SessionListener is a Listener that accepts the sessions served to it, it is used to serve
+// the clients those can not open raw QUIC, for example the browsers use WebTransport over HTTP/3.
+// The HTTP/3 server upgrades the requests to sessions and calls Serve, and the SessionListener is
+// added to the zipper by WithServerListener.
+type SessionListener struct {
+	addr      net.Addr
+	conns     chan Connection
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+var _ Listener = (*SessionListener)(nil)
+
+// NewSessionListener returns a SessionListener, the addr is the address of the HTTP/3 server.
+func NewSessionListener(addr net.Addr) *SessionListener {
+	return &SessionListener{
+		addr:   addr,
+		conns:  make(chan Connection),
+		closed: make(chan struct{}),
+	}
+}
+
+// Serve hands the session over to the server, it blocks until the session is accepted.
+func (l *SessionListener) Serve(ctx context.Context, session StreamSession) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-l.closed:
+		return ErrListenerClosed
+	case l.conns <- NewSessionConnection(session):
+		return nil
+	}
+}
+
+// Accept returns the next session served.
+func (l *SessionListener) Accept(ctx context.Context) (Connection, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-l.closed:
+		return nil, ErrListenerClosed
+	case conn := <-l.conns:
+		return conn, nil
+	}
+}
+
+// Addr returns the address of the HTTP/3 server.
+func (l *SessionListener) Addr() net.Addr { return l.addr }
+
+// Close closes the listener, the sessions served later are rejected.
+func (l *SessionListener) Close() error {
+	l.closeOnce.Do(func() { close(l.closed) })
+	return nil
+}
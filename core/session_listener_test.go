@@ -0,0 +1,146 @@
+package core
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yomorun/yomo/core/auth"
+	"github.com/yomorun/yomo/core/frame"
+	"github.com/yomorun/yomo/core/metadata"
+	"github.com/yomorun/yomo/core/router"
+	"github.com/yomorun/yomo/pkg/config"
+	"github.com/yomorun/yomo/pkg/frame-codec/y3codec"
+)
+
+// memSession is a StreamSession in memory, the streams opened are accepted by its peer.
+type memSession struct {
+	ctx     context.Context
+	cancel  context.CancelFunc
+	streams chan io.ReadWriteCloser
+	peer    *memSession
+}
+
+func newMemSessionPair() (*memSession, *memSession) {
+	ctx, cancel := context.WithCancel(context.Background())
+	a := &memSession{ctx: ctx, cancel: cancel, streams: make(chan io.ReadWriteCloser, 10)}
+	b := &memSession{ctx: ctx, cancel: cancel, streams: make(chan io.ReadWriteCloser, 10)}
+	a.peer, b.peer = b, a
+	return a, b
+}
+
+func (s *memSession) Context() context.Context { return s.ctx }
+func (s *memSession) LocalAddr() net.Addr      { return &net.TCPAddr{} }
+func (s *memSession) RemoteAddr() net.Addr     { return &net.TCPAddr{} }
+
+func (s *memSession) OpenStream() (io.ReadWriteCloser, error) {
+	local, remote := net.Pipe()
+	select {
+	case <-s.ctx.Done():
+		return nil, s.ctx.Err()
+	case s.peer.streams <- remote:
+		return local, nil
+	}
+}
+
+func (s *memSession) AcceptStream(ctx context.Context) (io.ReadWriteCloser, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-s.ctx.Done():
+		return nil, s.ctx.Err()
+	case stream := <-s.streams:
+		return stream, nil
+	}
+}
+
+func (s *memSession) CloseWithError(string) error {
+	s.cancel()
+	return nil
+}
+
+func TestStreamGroupOverSession(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	serverSession, clientSession := newMemSessionPair()
+	defer serverSession.CloseWithError("")
+
+	ln := NewSessionListener(&net.TCPAddr{})
+	defer ln.Close()
+	go ln.Serve(ctx, serverSession)
+
+	// the zipper side, the StreamGroup echoes the payloads read.
+	go func() {
+		conn, err := ln.Accept(ctx)
+		if !assert.NoError(t, err) {
+			return
+		}
+		stream0, err := conn.AcceptStream(ctx)
+		if !assert.NoError(t, err) {
+			return
+		}
+		controlStream := NewServerControlStream(conn, stream0, y3codec.Codec(), y3codec.PacketReadWriter(), discardingLogger)
+		md, err := controlStream.VerifyAuthentication(ctx, func(context.Context, *frame.AuthenticationFrame) (metadata.M, bool, error) {
+			return metadata.M{}, true, nil
+		})
+		if !assert.NoError(t, err) {
+			return
+		}
+		group := NewStreamGroup(ctx, md, controlStream, NewConnector(ctx), router.Default([]config.Function{{Name: "sfn"}}),
+			discardingLogger, NewServer("zipper").streamGroupOptions())
+		_ = group.Run(ctx, func(c *Context) {
+			for {
+				f, err := c.DataStream.ReadFrame()
+				if err != nil {
+					return
+				}
+				if df, ok := f.(*frame.DataFrame); ok {
+					_ = c.DataStream.WriteFrame(&frame.DataFrame{Tag: df.Tag, Metadata: df.Metadata, Payload: []byte("echo: " + string(df.Payload))})
+				}
+			}
+		})
+	}()
+
+	// the client side.
+	conn := NewSessionConnection(clientSession)
+	stream0, err := conn.OpenStream()
+	if !assert.NoError(t, err) {
+		return
+	}
+	controlStream := NewClientControlStream(clientSession.Context(), conn, stream0, y3codec.Codec(), y3codec.PacketReadWriter(), discardingLogger)
+	if !assert.NoError(t, controlStream.Authenticate(auth.NewCredential(""))) {
+		return
+	}
+	md, err := metadata.M{}.Encode()
+	assert.NoError(t, err)
+	err = controlStream.RequestStream(&frame.HandshakeFrame{
+		Name:            "source",
+		ID:              "source-id",
+		StreamType:      byte(StreamTypeSource),
+		ObserveDataTags: []frame.Tag{1},
+		Metadata:        md,
+	})
+	assert.NoError(t, err)
+
+	stream, err := controlStream.AcceptStream(ctx)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NoError(t, stream.WriteFrame(&frame.DataFrame{Tag: 1, Metadata: md, Payload: []byte("hello")}))
+
+	for {
+		f, err := stream.ReadFrame()
+		if !assert.NoError(t, err) {
+			return
+		}
+		if df, ok := f.(*frame.DataFrame); ok {
+			assert.Equal(t, "echo: hello", string(df.Payload))
+			break
+		}
+	}
+	assert.NotZero(t, conn.Stats().FramesWritten[frame.TypeDataFrame])
+}
@@ -0,0 +1,120 @@
+package core
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/yomorun/yomo/core/frame"
+)
+
+// ConnectionStats is a snapshot of the frames that have been written to a Connection.
+type ConnectionStats struct {
+	// BytesWritten is the total bytes of the encoded frames written.
+	BytesWritten uint64
+	// FramesWritten is the count of frames written, grouped by frame type.
+	FramesWritten map[frame.Type]uint64
+	// LastWriteTime is the time of the last frame written, it is zero if no frame written.
+	LastWriteTime time.Time
+	// DataStreams is the count of the active data streams of the connection, it is counted by the server only.
+	DataStreams int64
+	// BytesUncompressed and BytesCompressed are the total bytes of the fields compressed by the codec of the connection,
+	// before and after the compression. They are counted only if the codec is a frame.CompressionEncoder.
+	BytesUncompressed uint64
+	BytesCompressed   uint64
+}
+
+// CompressionRatio returns the ratio of the bytes before the compression to the bytes after it,
+// for example, 4 means the data is compressed to a quarter. It returns 0 if nothing is compressed.
+func (s ConnectionStats) CompressionRatio() float64 {
+	if s.BytesCompressed == 0 {
+		return 0
+	}
+	return float64(s.BytesUncompressed) / float64(s.BytesCompressed)
+}
+
+// statsRecorder records the stats of a Connection, it is safe for concurrent use.
+type statsRecorder struct {
+	bytesWritten  atomic.Uint64
+	framesWritten [256]atomic.Uint64
+	lastWrite     atomic.Int64
+	dataStreams   atomic.Int64
+	uncompressed  atomic.Uint64
+	compressed    atomic.Uint64
+	// observer is set by the server before the streams are used, it is nil for the client connections.
+	observer ServerObserver
+}
+
+func (r *statsRecorder) recordWrite(ftyp frame.Type, n int) {
+	r.bytesWritten.Add(uint64(n))
+	r.framesWritten[ftyp].Add(1)
+	r.lastWrite.Store(time.Now().UnixNano())
+	if r.observer != nil {
+		r.observer.FrameWritten(ftyp, n)
+	}
+}
+
+func (r *statsRecorder) recordRead(ftyp frame.Type, n int) {
+	if r.observer != nil {
+		r.observer.FrameRead(ftyp, n)
+	}
+}
+
+func (r *statsRecorder) recordCompression(original, compressed int) {
+	r.uncompressed.Add(uint64(original))
+	r.compressed.Add(uint64(compressed))
+}
+
+func (r *statsRecorder) recordDataStream(delta int64) {
+	r.dataStreams.Add(delta)
+}
+
+func (r *statsRecorder) snapshot() ConnectionStats {
+	stats := ConnectionStats{
+		BytesWritten:  r.bytesWritten.Load(),
+		FramesWritten: make(map[frame.Type]uint64),
+		DataStreams:   r.dataStreams.Load(),
+
+		BytesUncompressed: r.uncompressed.Load(),
+		BytesCompressed:   r.compressed.Load(),
+	}
+	for i := range r.framesWritten {
+		if n := r.framesWritten[i].Load(); n > 0 {
+			stats.FramesWritten[frame.Type(i)] = n
+		}
+	}
+	if last := r.lastWrite.Load(); last > 0 {
+		stats.LastWriteTime = time.Unix(0, last)
+	}
+	return stats
+}
+
+// frameWriteRecorder records the frame written to the stream.
+// the FrameStream records writes if the underlying stream implements it.
+type frameWriteRecorder interface {
+	recordWrite(ftyp frame.Type, n int)
+}
+
+// frameReadRecorder records the frame read from the stream.
+// the FrameStream records reads if the underlying stream implements it.
+type frameReadRecorder interface {
+	recordRead(ftyp frame.Type, n int)
+}
+
+// compressionRecorder records the bytes before and after the compression of the frames written to the stream,
+// the FrameStream records them if the underlying stream implements it and the codec is a frame.CompressionEncoder.
+type compressionRecorder interface {
+	recordCompression(original, compressed int)
+}
+
+// dataStreamRecorder records the data streams opened and closed on the connection,
+// the StreamGroup records them if the connection implements it.
+type dataStreamRecorder interface {
+	recordDataStream(delta int64)
+}
+
+// statsStream is the stream opened or accepted from a QuicConnection,
+// the frames written to it are counted in the stats of the connection.
+type statsStream struct {
+	ContextReadWriteCloser
+	*statsRecorder
+}
@@ -0,0 +1,84 @@
+package core
+
+import (
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/yomorun/yomo/core/frame"
+)
+
+// StatsReportObserver is the ServerObserver that observes the stats reported by the stream functions as well,
+// the server calls it with the name of the stream function once a StatsReportFrame is received.
+type StatsReportObserver interface {
+	StatsReported(streamName string, report *frame.StatsReportFrame)
+}
+
+// maxLatencySamples is the max number of the latencies kept for the percentiles of a report,
+// the latencies beyond it are sampled.
+const maxLatencySamples = 1024
+
+// statsAggregator aggregates the processing of the DataFrames into StatsReportFrames.
+type statsAggregator struct {
+	mu        sync.Mutex
+	processed uint64
+	errors    uint64
+	latencies []time.Duration
+}
+
+func newStatsAggregator() *statsAggregator {
+	return &statsAggregator{latencies: make([]time.Duration, 0, maxLatencySamples)}
+}
+
+// record records the processing of a DataFrame.
+func (a *statsAggregator) record(latency time.Duration, failed bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.processed++
+	if failed {
+		a.errors++
+	}
+	// reservoir sampling keeps every latency with the same chance.
+	if len(a.latencies) < maxLatencySamples {
+		a.latencies = append(a.latencies, latency)
+	} else if i := rand.Int63n(int64(a.processed)); i < maxLatencySamples {
+		a.latencies[i] = latency
+	}
+}
+
+// report returns the StatsReportFrame of the DataFrames processed since the last report and resets the stats,
+// it returns nil if there is no DataFrame processed.
+func (a *statsAggregator) report(streamID string) *frame.StatsReportFrame {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.processed == 0 {
+		return nil
+	}
+	sort.Slice(a.latencies, func(i, j int) bool { return a.latencies[i] < a.latencies[j] })
+
+	f := &frame.StatsReportFrame{
+		StreamID:   streamID,
+		Processed:  a.processed,
+		Errors:     a.errors,
+		P50Latency: uint64(percentile(a.latencies, 50).Microseconds()),
+		P99Latency: uint64(percentile(a.latencies, 99).Microseconds()),
+	}
+	a.processed, a.errors, a.latencies = 0, 0, a.latencies[:0]
+
+	return f
+}
+
+// percentile returns the p-th percentile of the sorted latencies by the nearest rank.
+func percentile(sorted []time.Duration, p int) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := (len(sorted)*p + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
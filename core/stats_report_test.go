@@ -0,0 +1,29 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yomorun/yomo/core/frame"
+)
+
+func TestStatsAggregator(t *testing.T) {
+	a := newStatsAggregator()
+
+	assert.Nil(t, a.report("id"), "nothing processed")
+
+	for i := 1; i <= 100; i++ {
+		a.record(time.Duration(i)*time.Millisecond, i%10 == 0)
+	}
+
+	assert.Equal(t, &frame.StatsReportFrame{
+		StreamID:   "id",
+		Processed:  100,
+		Errors:     10,
+		P50Latency: 50000,
+		P99Latency: 99000,
+	}, a.report("id"))
+
+	assert.Nil(t, a.report("id"), "the stats are reset by the report")
+}
@@ -0,0 +1,58 @@
+package core
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yomorun/yomo/core/frame"
+	"github.com/yomorun/yomo/pkg/frame-codec/compress"
+	"github.com/yomorun/yomo/pkg/frame-codec/y3codec"
+)
+
+func TestConnectionStats(t *testing.T) {
+	recorder := new(statsRecorder)
+
+	stats := recorder.snapshot()
+	assert.Equal(t, uint64(0), stats.BytesWritten)
+	assert.Empty(t, stats.FramesWritten)
+	assert.True(t, stats.LastWriteTime.IsZero())
+
+	stream := &statsStream{newMemByteStream(nil), recorder}
+	fs := NewFrameStream(stream, &byteCodec{}, &bytePacketReadWriter{})
+
+	for _, b := range []byte("yomo") {
+		assert.NoError(t, fs.WriteFrame(byteFrame(b)))
+	}
+
+	stats = recorder.snapshot()
+	assert.Equal(t, uint64(4), stats.BytesWritten)
+	assert.Equal(t, map[frame.Type]uint64{frame.TypeDataFrame: 4}, stats.FramesWritten)
+	assert.False(t, stats.LastWriteTime.IsZero())
+}
+
+func TestConnectionCompressionStats(t *testing.T) {
+	recorder := new(statsRecorder)
+
+	stream := &statsStream{newMemByteStream(nil), recorder}
+	fs := NewFrameStream(stream, y3codec.Codec(), y3codec.PacketReadWriter())
+	assert.Nil(t, fs.compression, "the codec does not compress")
+
+	assert.NoError(t, fs.WriteFrame(&frame.DataFrame{Tag: 1, Payload: bytes.Repeat([]byte("a"), 1000)}))
+	assert.Equal(t, float64(0), recorder.snapshot().CompressionRatio())
+
+	codec, err := compress.NewCompressionCodec(y3codec.Codec(), compress.Gzip, 1)
+	assert.NoError(t, err)
+	fs = NewFrameStream(stream, codec, y3codec.PacketReadWriter())
+
+	assert.NoError(t, fs.WriteFrame(&frame.DataFrame{Tag: 1, Payload: bytes.Repeat([]byte("a"), 1000)}))
+	assert.NoError(t, fs.WriteFrames(
+		&frame.BackflowFrame{Tag: 1, Carriage: bytes.Repeat([]byte("b"), 1000)},
+		&frame.PingFrame{},
+	))
+
+	stats := recorder.snapshot()
+	assert.Equal(t, uint64(2000), stats.BytesUncompressed)
+	assert.Less(t, stats.BytesCompressed, uint64(200))
+	assert.Greater(t, stats.CompressionRatio(), float64(10))
+}
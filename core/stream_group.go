@@ -3,12 +3,19 @@ package core
 import (
 	"context"
 	"errors"
+	"fmt"
+	"runtime/debug"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/yomorun/yomo/core/frame"
 	"github.com/yomorun/yomo/core/metadata"
 	"github.com/yomorun/yomo/core/router"
 	"github.com/yomorun/yomo/core/yerr"
+	"github.com/yomorun/yomo/pkg/id"
+	"github.com/yomorun/yomo/pkg/trace"
+	oteltrace "go.opentelemetry.io/otel/trace"
 	"golang.org/x/exp/slog"
 )
 
@@ -24,9 +31,35 @@ type StreamGroup struct {
 	connector     *Connector
 	router        router.Router
 	logger        *slog.Logger
-	group         sync.WaitGroup
+	streamGroupOptions
+	dataStreams atomic.Int64
+	group       sync.WaitGroup
 }
 
+// streamGroupOptions are the options of the StreamGroup, the server sets them from its serverOptions.
+type streamGroupOptions struct {
+	panicHandler PanicHandler
+	// maxDataStreams is the max count of the data streams of the connection, zero means unlimited.
+	maxDataStreams int
+	// idleTimeout is the max time a data stream can live without frame activity, zero means no timeout.
+	idleTimeout time.Duration
+	// heartbeatInterval is the interval of the heartbeats of the data streams, zero means no heartbeat.
+	heartbeatInterval time.Duration
+	// heartbeatMisses is the count of the heartbeats a data stream can miss before it is closed.
+	heartbeatMisses int
+	// maxMetadataSize is the max size of the encoded metadata of the handshakes, non-positive means unlimited.
+	maxMetadataSize int
+	// authorizeObserveTag authorizes the observed tags of the streams, nil means all the tags are allowed.
+	authorizeObserveTag ObserveTagAuthorizer
+	observeTagDeny      ObserveTagDenyPolicy
+	observer            ServerObserver
+	clock               Clock
+	tp                  oteltrace.TracerProvider
+}
+
+// PanicHandler is called with the stream and the recovered value when the contextFunc of the stream panics.
+type PanicHandler func(stream StreamInfo, recovered any)
+
 // NewStreamGroup returns the StreamGroup.
 func NewStreamGroup(
 	ctx context.Context,
@@ -35,14 +68,16 @@ func NewStreamGroup(
 	connector *Connector,
 	router router.Router,
 	logger *slog.Logger,
+	opts streamGroupOptions,
 ) *StreamGroup {
 	group := &StreamGroup{
-		ctx:           ctx,
-		baseMetadata:  baseMetadata,
-		controlStream: controlStream,
-		connector:     connector,
-		router:        router,
-		logger:        logger,
+		ctx:                ctx,
+		baseMetadata:       baseMetadata,
+		controlStream:      controlStream,
+		connector:          connector,
+		router:             router,
+		logger:             logger,
+		streamGroupOptions: opts,
 	}
 	logger.Info("connection connected")
 
@@ -84,17 +119,52 @@ func (g *StreamGroup) handleRoute(hf *frame.HandshakeFrame, md metadata.M) (rout
 
 type handshakeResult struct {
 	route router.Route
+	// correlationID is taken from the handshake metadata or generated, it is logged with the stream.
+	correlationID string
 }
 
 // makeHandshakeFunc creates a function that will handle a HandshakeFrame.
 // It takes route parameter, which will be assigned after the returned function is executed.
+// The rejected handshakes are reported to the ServerObserver.
 func (g *StreamGroup) makeHandshakeFunc(result *handshakeResult) func(hf *frame.HandshakeFrame) (metadata.M, error) {
+	handshake := g.handleHandshake(result)
+
+	return func(hf *frame.HandshakeFrame) (metadata.M, error) {
+		md, err := handshake(hf)
+		if err != nil {
+			g.observer.HandshakeRejected(handshakeRejectReason(err))
+		}
+		return md, err
+	}
+}
+
+func (g *StreamGroup) handleHandshake(result *handshakeResult) func(hf *frame.HandshakeFrame) (metadata.M, error) {
 	return func(hf *frame.HandshakeFrame) (metadata.M, error) {
-		_, ok, err := g.connector.Get(hf.ID)
+		if st := StreamType(hf.StreamType); !st.Valid() {
+			return metadata.M{}, &handshakeRejectError{
+				reason:  frame.RejectInvalid,
+				message: fmt.Sprintf("yomo: unknown stream type %s", st),
+			}
+		}
+		if max := g.maxDataStreams; max > 0 && g.dataStreams.Load() >= int64(max) {
+			return metadata.M{}, &handshakeRejectError{
+				reason:  frame.RejectRateLimited,
+				message: fmt.Sprintf("yomo: too many data streams, the max is %d", max),
+			}
+		}
+
+		if err := checkMetadataSize(hf.Metadata, g.maxMetadataSize); err != nil {
+			return metadata.M{}, &handshakeRejectError{
+				reason:  frame.RejectInvalid,
+				message: err.Error(),
+			}
+		}
+
+		exists, ok, err := g.connector.Get(hf.ID)
 		if err != nil {
 			return metadata.M{}, err
 		}
-		if ok {
+		if ok && !g.migrateStream(exists, hf) {
 			return metadata.M{}, errors.New("yomo: stream id is not allowed to be a duplicate")
 		}
 
@@ -102,6 +172,8 @@ func (g *StreamGroup) makeHandshakeFunc(result *handshakeResult) func(hf *frame.
 		if err != nil {
 			return metadata.M{}, err
 		}
+		// a client can not claim the namespace of another one.
+		stripNamespace(md)
 
 		// merge base metadata
 		g.baseMetadata.Range(func(k, v string) bool {
@@ -109,50 +181,499 @@ func (g *StreamGroup) makeHandshakeFunc(result *handshakeResult) func(hf *frame.
 			return true
 		})
 
+		// the data stream is created with the allowed tags only.
+		tags, err := authorizeObserveTags(g.authorizeObserveTag, g.observeTagDeny, md, hf.ObserveDataTags)
+		if err != nil {
+			return metadata.M{}, err
+		}
+		hf.ObserveDataTags = tags
+
+		// the streams of the same instance are replaced before routing, so they are not taken as
+		// the streams of the same name by the router.
+		g.replaceInstance(hf, md)
+
 		route, err := g.handleRoute(hf, md)
 		if err != nil {
 			return metadata.M{}, err
 		}
 		result.route = route
 
+		result.correlationID = GetCorrelationIDFromMetadata(md)
+		if result.correlationID == "" {
+			result.correlationID = id.New()
+		}
+
 		return metadata.M{}, err
 	}
 }
 
+// migrateStream takes over the stream that has the same id as the HandshakeFrame but belongs to another connection,
+// it happens when the network path of the client changes and the client handshakes again on a new connection.
+// The old stream is closed and removed from the connector, so the stream id is reused by the new stream.
+// It reports false if the stream is not the same stream of the client, the handshake should be rejected.
+// The new connection must be authenticated with the same credential as the old one, otherwise any client
+// knowing the id could take over the stream.
+func (g *StreamGroup) migrateStream(exists DataStream, hf *frame.HandshakeFrame) bool {
+	old, ok := exists.(*dataStream)
+	if !ok || old.serverController == nil || old.serverController == g.controlStream {
+		return false
+	}
+	if old.Name() != hf.Name || byte(old.StreamType()) != hf.StreamType {
+		return false
+	}
+	if old.serverController.identity != g.controlStream.identity {
+		g.logger.Warn("stream migration rejected, the credential is different", "stream_id", old.ID(), "stream_name", old.Name())
+		return false
+	}
+	g.logger.Info("stream migrated",
+		"stream_id", old.ID(), "stream_name", old.Name(),
+		"from", old.serverController.conn.RemoteAddr(), "to", g.controlStream.conn.RemoteAddr(),
+	)
+	old.migrated.Store(true)
+	if err := old.serverController.CloseStream(old.ID(), frame.CloseReplaced, "yomo: stream migrated"); err != nil {
+		g.logger.Debug("failed to send close stream frame", "stream_id", old.ID(), "err", err)
+	}
+	g.connector.CompareAndDelete(old.ID(), old)
+	_ = old.Close()
+	return true
+}
+
+// replaceInstance closes the streams of the same instance as the HandshakeFrame but with other ids,
+// it happens when an instance reconnects after a blip and its old streams are still alive.
+// The old streams are closed with a CloseStreamFrame of frame.CloseDuplicateInstance and removed from the connector
+// and the route.
+func (g *StreamGroup) replaceInstance(hf *frame.HandshakeFrame, md metadata.M) {
+	if hf.InstanceKey == "" {
+		return
+	}
+	streams, err := g.connector.Find(func(si StreamInfo) bool {
+		ds, ok := si.(*dataStream)
+		return ok && ds.instanceKey == hf.InstanceKey && ds.ID() != hf.ID &&
+			ds.Name() == hf.Name && byte(ds.StreamType()) == hf.StreamType
+	})
+	if err != nil {
+		return
+	}
+	for _, stream := range streams {
+		old := stream.(*dataStream)
+		g.logger.Info("stream replaced by the same instance",
+			"stream_id", old.ID(), "stream_name", old.Name(), "instance_key", hf.InstanceKey, "new_stream_id", hf.ID,
+		)
+		if err := old.serverController.CloseStream(old.ID(), frame.CloseDuplicateInstance, "yomo: stream replaced by the same instance"); err != nil {
+			g.logger.Debug("failed to send close stream frame", "stream_id", old.ID(), "err", err)
+		}
+		g.connector.CompareAndDelete(old.ID(), old)
+		if route := g.router.Route(md); route != nil && old.StreamType() == StreamTypeStreamFunction {
+			_ = route.Remove(old.ID())
+		}
+		_ = old.Close()
+	}
+}
+
+func isMigrated(stream DataStream) bool {
+	ds, ok := stream.(*dataStream)
+	return ok && ds.migrated.Load()
+}
+
 // Run run contextFunc with connector.
 // Run continuous Accepts DataStream and create a Context to run with contextFunc.
+// When the ctx is cancelled, Run sends a GoawayFrame to the client, closes the control stream,
+// waits for the in-flight contextFuncs and returns the ctx.Err().
 // TODO: run in aop model, like before -> handle -> after.
-func (g *StreamGroup) Run(contextFunc func(c *Context)) error {
+func (g *StreamGroup) Run(ctx context.Context, contextFunc func(c *Context)) error {
+	go g.handleControlFrames()
+
 	for {
 		var routeResult handshakeResult
 
 		handshakeFunc := g.makeHandshakeFunc(&routeResult)
 
-		stream, err := g.controlStream.OpenStream(g.ctx, handshakeFunc)
+		stream, err := g.controlStream.OpenStream(ctx, handshakeFunc)
 		if err != nil {
+			if ctx.Err() != nil {
+				return g.shutdown(ctx)
+			}
+			// the client is told to slow down, keep serving the opened data streams.
+			if re := new(handshakeRejectError); errors.As(err, &re) && re.reason == frame.RejectRateLimited {
+				g.logger.Debug("reject handshake", "err", err)
+				continue
+			}
 			return err
 		}
 
+		logger := g.logger.With("correlation_id", routeResult.correlationID)
+
 		g.group.Add(1)
+		g.recordDataStream(1)
+		g.observer.StreamOpened(stream.StreamType())
 		g.connector.Store(stream.ID(), stream)
-		g.logger.Debug("connector add stream", "stream_id", stream.ID(), "stream_type", stream.StreamType().String(), "stream_name", stream.Name())
+		logger.Debug("connector add stream", "stream_id", stream.ID(), "stream_type", stream.StreamType().String(), "stream_name", stream.Name())
+
+		g.traceStreamOpened(stream)
+
+		if stream.StreamType() == StreamTypeSource {
+			go g.sendReadyStreams(stream)
+		}
+
+		if g.idleTimeout > 0 {
+			go g.evictIdleStream(stream, logger)
+		}
+		if g.heartbeatInterval > 0 {
+			go g.heartbeatStream(stream, logger)
+		}
+
+		go g.handleContextFunc(routeResult.route, stream, routeResult.correlationID, logger, contextFunc)
+	}
+}
+
+// handleControlFrames handles the control frames from the client.
+func (g *StreamGroup) handleControlFrames() {
+	for f := range g.controlStream.ControlFrames() {
+		switch ff := f.(type) {
+		case *frame.MetadataUpdateFrame:
+			g.handleMetadataUpdateFrame(ff)
+		case *frame.FlowControlFrame:
+			g.handleFlowControlFrame(ff)
+		case *frame.ObserveTagFrame:
+			g.handleObserveTag(ff.StreamID, ff.Tag, true)
+		case *frame.UnobserveTagFrame:
+			g.handleObserveTag(ff.StreamID, ff.Tag, false)
+		case *frame.CloseStreamFrame:
+			g.handleCloseStreamFrame(ff)
+		case *frame.StatsReportFrame:
+			g.handleStatsReportFrame(ff)
+		case *frame.StreamReadyFrame:
+			g.handleStreamReadyFrame(ff)
+		}
+	}
+}
 
-		go g.handleContextFunc(routeResult.route, stream, contextFunc)
+// handleMetadataUpdateFrame merges the metadata carried by MetadataUpdateFrame into the DataStream,
+// the following frames of the DataStream will be handled with the updated metadata.
+func (g *StreamGroup) handleMetadataUpdateFrame(f *frame.MetadataUpdateFrame) {
+	stream, ok, err := g.connector.Get(f.StreamID)
+	if err != nil {
+		return
+	}
+	ds, isDataStream := stream.(*dataStream)
+	// a client can only update the streams opened by itself.
+	if !ok || !isDataStream || ds.serverController != g.controlStream {
+		g.logger.Warn("metadata update for unknown stream", "stream_id", f.StreamID)
+		return
+	}
+	if err := checkMetadataSize(f.Metadata, g.maxMetadataSize); err != nil {
+		g.logger.Warn("metadata update rejected", "stream_id", f.StreamID, "err", err)
+		return
+	}
+	md, err := metadata.Decode(f.Metadata)
+	if err != nil {
+		g.logger.Warn("failed to decode metadata update", "stream_id", f.StreamID, "err", err)
+		return
 	}
+	stripNamespace(md)
+	// the base metadata comes from the authentication, it is merged over the update like the handshake does,
+	// and the merged metadata is limited as well, so the repeated updates can not grow it without bound.
+	err = ds.updateMetadata(md, g.baseMetadata, func(merged metadata.M) error {
+		b, err := merged.Encode()
+		if err != nil {
+			return err
+		}
+		return checkMetadataSize(b, g.maxMetadataSize)
+	})
+	if err != nil {
+		g.logger.Warn("metadata update rejected", "stream_id", f.StreamID, "err", err)
+		return
+	}
+	g.logger.Debug("stream metadata updated", "stream_id", f.StreamID, "stream_name", ds.Name())
 }
 
-func (g *StreamGroup) handleContextFunc(route router.Route, stream DataStream, contextFunc func(c *Context)) {
+// handleObserveTag observes or unobserves the tag for the DataStream, the route of the StreamFunction
+// is updated with the observed data tags.
+func (g *StreamGroup) handleObserveTag(streamID string, tag frame.Tag, observe bool) {
+	stream, ok, err := g.connector.Get(streamID)
+	if err != nil {
+		return
+	}
+	ds, isDataStream := stream.(*dataStream)
+	// a client can only update the streams opened by itself.
+	if !ok || !isDataStream || ds.serverController != g.controlStream {
+		g.logger.Warn("observe tag for unknown stream", "stream_id", streamID)
+		return
+	}
+
+	var changed bool
+	if observe {
+		if !authorizeObserveTag(g.authorizeObserveTag, g.authorizationMetadata(ds), tag) {
+			g.logger.Warn("observing tag is not allowed", "stream_id", streamID, "data_tag", tag)
+			return
+		}
+		changed = ds.observeTag(tag)
+	} else {
+		changed = ds.unobserveTag(tag)
+	}
+	if !changed || ds.StreamType() != StreamTypeStreamFunction {
+		return
+	}
+	route := g.router.Route(ds.Metadata())
+	if route == nil {
+		g.logger.Warn("can't find route to observe tag", "stream_id", streamID)
+		return
+	}
+	if err := route.Add(ds.ID(), ds.Name(), ds.ObserveDataTags()); err != nil {
+		g.logger.Warn("failed to update the observed tags", "stream_id", streamID, "err", err)
+		return
+	}
+	g.logger.Debug("stream observed tags updated", "stream_id", streamID, "data_tag", tag, "observe", observe)
+}
+
+// authorizationMetadata returns the metadata of the stream merged with the connection metadata,
+// like the metadata that the observed tags are authorized with in the handshake.
+func (g *StreamGroup) authorizationMetadata(ds *dataStream) metadata.M {
+	md := ds.Metadata().Clone()
+	if md == nil {
+		md = metadata.M{}
+	}
+	g.baseMetadata.Range(func(k, v string) bool {
+		md.Set(k, v)
+		return true
+	})
+	return md
+}
+
+// handleCloseStreamFrame closes the DataStream that the client asks to close.
+func (g *StreamGroup) handleCloseStreamFrame(f *frame.CloseStreamFrame) {
+	stream, ok, err := g.connector.Get(f.StreamID)
+	if err != nil {
+		return
+	}
+	ds, isDataStream := stream.(*dataStream)
+	// a client can only close the streams opened by itself.
+	if !ok || !isDataStream || ds.serverController != g.controlStream {
+		g.logger.Warn("close unknown stream", "stream_id", f.StreamID)
+		return
+	}
+	g.logger.Info("client closes stream",
+		"stream_id", f.StreamID, "stream_name", ds.Name(), "close_reason", f.Reason, "close_code", f.Code.String(),
+	)
+	_ = ds.Close()
+}
+
+// evictIdleStream closes the DataStream with a CloseStreamFrame once no frame is read or written
+// within the idle timeout, and removes it from the connector. It returns when the stream is closed.
+func (g *StreamGroup) evictIdleStream(stream DataStream, logger *slog.Logger) {
+	timer := g.clock.NewTimer(g.idleTimeout)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return
+		case <-timer.C():
+			lastActive := stream.LastActivity()
+			// the activity resets the timeout, wait for the rest of it.
+			if idle := g.clock.Now().Sub(lastActive); idle < g.idleTimeout {
+				timer.Reset(g.idleTimeout - idle)
+				continue
+			}
+			logger.Info("evict idle stream",
+				"stream_id", stream.ID(), "stream_name", stream.Name(), "last_activity", lastActive, "idle_timeout", g.idleTimeout,
+			)
+			if err := g.controlStream.CloseStream(stream.ID(), frame.CloseIdleTimeout, "yomo: stream idle timeout"); err != nil {
+				logger.Debug("failed to send close stream frame", "stream_id", stream.ID(), "err", err)
+			}
+			g.connector.CompareAndDelete(stream.ID(), stream)
+			_ = stream.Close()
+			return
+		}
+	}
+}
+
+// heartbeatStream writes a PingFrame on the DataStream at the heartbeat interval, and closes the stream with
+// a CloseStreamFrame once it reads no frame within the missed heartbeats, the client replies a PongFrame to
+// every PingFrame. It returns when the stream is closed.
+func (g *StreamGroup) heartbeatStream(stream DataStream, logger *slog.Logger) {
+	ds, ok := stream.(*dataStream)
+	if !ok {
+		return
+	}
+	timer := g.clock.NewTimer(g.heartbeatInterval)
+	defer timer.Stop()
+
+	timeout := time.Duration(g.heartbeatMisses) * g.heartbeatInterval
+	for {
+		select {
+		case <-ds.Context().Done():
+			return
+		case <-timer.C():
+			timer.Reset(g.heartbeatInterval)
+			lastRead := ds.lastRead()
+			if g.clock.Now().Sub(lastRead) < timeout {
+				if err := ds.WriteFrame(&frame.PingFrame{}); err != nil {
+					logger.Debug("failed to write heartbeat", "stream_id", ds.ID(), "err", err)
+				}
+				continue
+			}
+			logger.Info("close stream missing heartbeats",
+				"stream_id", ds.ID(), "stream_name", ds.Name(), "last_read", lastRead, "heartbeat_misses", g.heartbeatMisses,
+			)
+			if err := g.controlStream.CloseStream(ds.ID(), frame.CloseHeartbeatTimeout, "yomo: stream heartbeat timeout"); err != nil {
+				logger.Debug("failed to send close stream frame", "stream_id", ds.ID(), "err", err)
+			}
+			g.connector.CompareAndDelete(ds.ID(), ds)
+			_ = ds.Close()
+			return
+		}
+	}
+}
+
+// handleFlowControlFrame forwards the FlowControlFrame to all sources, the sources ignore it
+// if they do not write the tag.
+func (g *StreamGroup) handleFlowControlFrame(f *frame.FlowControlFrame) {
+	sources, err := g.connector.Find(func(si StreamInfo) bool { return si.StreamType() == StreamTypeSource })
+	if err != nil {
+		return
+	}
+	g.logger.Debug("forward flow control", "data_tag", f.Tag, "pause", f.Pause, "rate", f.Rate, "sources", len(sources))
+	for _, source := range sources {
+		if err := source.WriteFrame(f); err != nil {
+			g.logger.Debug("failed to forward flow control", "stream_id", source.ID(), "err", err)
+		}
+	}
+}
+
+// handleStreamReadyFrame marks the stream ready and forwards the StreamReadyFrame to all sources
+// with the tags the stream observes.
+func (g *StreamGroup) handleStreamReadyFrame(f *frame.StreamReadyFrame) {
+	stream, ok, err := g.connector.Get(f.StreamID)
+	if err != nil {
+		return
+	}
+	ds, isDataStream := stream.(*dataStream)
+	// a client can only tell the readiness of the streams opened by itself.
+	if !ok || !isDataStream || ds.serverController != g.controlStream {
+		g.logger.Debug("stream ready for unknown stream", "stream_id", f.StreamID)
+		return
+	}
+	ds.ready.Store(true)
+
+	sources, err := g.connector.Find(func(si StreamInfo) bool { return si.StreamType() == StreamTypeSource })
+	if err != nil {
+		return
+	}
+	ready := &frame.StreamReadyFrame{StreamID: ds.ID(), Tags: ds.ObserveDataTags()}
+	g.logger.Debug("forward stream ready", "stream_id", ds.ID(), "stream_name", ds.Name(), "sources", len(sources))
+	for _, source := range sources {
+		if err := source.WriteFrame(ready); err != nil {
+			g.logger.Debug("failed to forward stream ready", "stream_id", source.ID(), "err", err)
+		}
+	}
+}
+
+// sendReadyStreams sends the StreamReadyFrames of the streams those are ready to the source just opened.
+func (g *StreamGroup) sendReadyStreams(source DataStream) {
+	streams, err := g.connector.Find(func(si StreamInfo) bool {
+		ds, ok := si.(*dataStream)
+		return ok && ds.ready.Load()
+	})
+	if err != nil {
+		return
+	}
+	for _, stream := range streams {
+		ready := &frame.StreamReadyFrame{StreamID: stream.ID(), Tags: stream.ObserveDataTags()}
+		if err := source.WriteFrame(ready); err != nil {
+			g.logger.Debug("failed to send stream ready", "stream_id", source.ID(), "err", err)
+			return
+		}
+	}
+}
+
+// handleStatsReportFrame feeds the stats reported by the stream function into the observer,
+// if the observer is a StatsReportObserver.
+func (g *StreamGroup) handleStatsReportFrame(f *frame.StatsReportFrame) {
+	observer, ok := g.observer.(StatsReportObserver)
+	if !ok {
+		return
+	}
+	stream, ok, err := g.connector.Get(f.StreamID)
+	if err != nil {
+		return
+	}
+	ds, isDataStream := stream.(*dataStream)
+	// a client can only report the stats of the streams opened by itself.
+	if !ok || !isDataStream || ds.serverController != g.controlStream {
+		g.logger.Debug("stats report for unknown stream", "stream_id", f.StreamID)
+		return
+	}
+	observer.StatsReported(ds.Name(), f)
+}
+
+// recordDataStream counts the data streams of the connection, the count is visible in the stats of the connection.
+func (g *StreamGroup) recordDataStream(delta int64) {
+	g.dataStreams.Add(delta)
+	if recorder, ok := g.controlStream.conn.(dataStreamRecorder); ok {
+		recorder.recordDataStream(delta)
+	}
+}
+
+// shutdown tells the client to go away and waits for all dataStreams down.
+func (g *StreamGroup) shutdown(ctx context.Context) error {
+	g.logger.Debug("stream group shutdown", "err", ctx.Err())
+	_ = g.controlStream.Goaway("yomo: server is shutting down")
+	g.group.Wait()
+	return ctx.Err()
+}
+
+// traceStreamOpened records a span for the stream created by the HandshakeFrame, it does nothing without TracerProvider.
+func (g *StreamGroup) traceStreamOpened(stream DataStream) {
+	if g.tp == nil {
+		return
+	}
+	span, err := trace.NewSpanWithAttrs(g.tp, "zipper", "open DataStream", "", "", false, map[string]string{
+		"yomo.stream_id":   stream.ID(),
+		"yomo.stream_name": stream.Name(),
+		"yomo.stream_type": stream.StreamType().String(),
+	})
+	if err != nil {
+		g.logger.Error("zipper trace error", "err", err)
+		return
+	}
+	span.End()
+}
+
+// handleContextFunc runs the contextFunc with the stream, the logger carries the correlation id of the stream,
+// so the logs of the stream lifecycle share it.
+func (g *StreamGroup) handleContextFunc(
+	route router.Route, stream DataStream, correlationID string, logger *slog.Logger, contextFunc func(c *Context),
+) {
 	defer func() {
+		// the stream id of a migrated stream is reused by the new stream, its route and connector entry are kept.
+		g.connector.CompareAndDelete(stream.ID(), stream)
 		// source route is always nil.
-		if route != nil {
+		if route != nil && !isMigrated(stream) {
 			route.Remove(stream.ID())
 		}
-		g.connector.Delete(stream.ID())
-		g.logger.Debug("connector remove stream", "stream_id", stream.ID(), "stream_type", stream.StreamType().String(), "stream_name", stream.Name())
+		logger.Debug("connector remove stream", "stream_id", stream.ID(), "stream_type", stream.StreamType().String(), "stream_name", stream.Name())
+		g.recordDataStream(-1)
+		g.observer.StreamClosed(stream.StreamType())
 		g.group.Done()
 	}()
 
-	c := newContext(stream, route, g.logger)
+	// a panic in contextFunc only closes the stream, other streams keep working.
+	defer func() {
+		if v := recover(); v != nil {
+			logger.Error("stream handler panic",
+				"stream_id", stream.ID(), "stream_type", stream.StreamType().String(), "stream_name", stream.Name(),
+				"panic", v, "stack", string(debug.Stack()),
+			)
+			_ = stream.Close()
+			if g.panicHandler != nil {
+				g.panicHandler(stream, v)
+			}
+		}
+	}()
+
+	c := newContext(stream, route, logger)
+	c.CorrelationID = correlationID
 	defer c.Release()
 
 	contextFunc(c)
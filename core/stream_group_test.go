@@ -0,0 +1,333 @@
+package core
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yomorun/yomo/core/auth"
+	"github.com/yomorun/yomo/core/frame"
+	"github.com/yomorun/yomo/core/metadata"
+	"github.com/yomorun/yomo/core/router"
+	"github.com/yomorun/yomo/pkg/config"
+	"github.com/yomorun/yomo/pkg/frame-codec/y3codec"
+)
+
+// runSessionStreamGroup runs a StreamGroup over an in-memory session, it returns the client control stream
+// authenticated with the StreamGroup. The StreamGroup runs until the ctx is done.
+func runSessionStreamGroup(
+	t *testing.T, ctx context.Context, connector *Connector, router router.Router, opts streamGroupOptions, contextFunc func(*Context),
+) *ClientControlStream {
+	serverSession, clientSession := newMemSessionPair()
+	t.Cleanup(func() { serverSession.CloseWithError("") })
+
+	serverConn, clientConn := NewSessionConnection(serverSession), NewSessionConnection(clientSession)
+	clientStream0, err := clientConn.OpenStream()
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	serverStream0, err := serverConn.AcceptStream(ctx)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	controlStream := NewServerControlStream(serverConn, serverStream0, y3codec.Codec(), y3codec.PacketReadWriter(), discardingLogger)
+	if opts.clock != nil {
+		controlStream.clock = opts.clock
+	}
+	verified := make(chan error, 1)
+	go func() {
+		_, err := controlStream.VerifyAuthentication(ctx, func(context.Context, *frame.AuthenticationFrame) (metadata.M, bool, error) {
+			return metadata.M{}, true, nil
+		})
+		verified <- err
+	}()
+
+	client := NewClientControlStream(clientSession.Context(), clientConn, clientStream0, y3codec.Codec(), y3codec.PacketReadWriter(), discardingLogger)
+	if !assert.NoError(t, client.Authenticate(auth.NewCredential(""))) || !assert.NoError(t, <-verified) {
+		t.FailNow()
+	}
+
+	group := NewStreamGroup(ctx, metadata.M{}, controlStream, connector, router, discardingLogger, opts)
+	go group.Run(ctx, contextFunc)
+
+	return client
+}
+
+// requestStream requests a data stream by the client control stream and waits for it to be accepted.
+func requestStream(t *testing.T, ctx context.Context, client *ClientControlStream, name, id string, streamType StreamType, tags ...frame.Tag) (DataStream, error) {
+	md, err := metadata.M{}.Encode()
+	assert.NoError(t, err)
+	err = client.RequestStream(&frame.HandshakeFrame{
+		Name:            name,
+		ID:              id,
+		StreamType:      byte(streamType),
+		ObserveDataTags: tags,
+		Metadata:        md,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return client.AcceptStream(ctx)
+}
+
+// echoFrames writes the DataFrames read back to the stream of the context until the stream is closed.
+func echoFrames(c *Context) {
+	for {
+		f, err := c.DataStream.ReadFrame()
+		if err != nil {
+			return
+		}
+		if df, ok := f.(*frame.DataFrame); ok {
+			_ = c.DataStream.WriteFrame(&frame.DataFrame{Tag: df.Tag, Metadata: df.Metadata, Payload: append([]byte(nil), df.Payload...)})
+		}
+	}
+}
+
+// readDataFrame reads the frames of the stream until a DataFrame is read.
+func readDataFrame(stream DataStream) (*frame.DataFrame, error) {
+	for {
+		f, err := stream.ReadFrame()
+		if err != nil {
+			return nil, err
+		}
+		if df, ok := f.(*frame.DataFrame); ok {
+			return df, nil
+		}
+	}
+}
+
+func TestHandleMetadataUpdateFrame(t *testing.T) {
+	controlStream := &ServerControlStream{}
+	g := &StreamGroup{
+		baseMetadata:       metadata.M{MetadataNamespaceKey: "1"},
+		controlStream:      controlStream,
+		connector:          NewConnector(context.Background()),
+		logger:             discardingLogger,
+		streamGroupOptions: streamGroupOptions{maxMetadataSize: 128},
+	}
+	stream := newDataStream("source", "source-id", "", StreamTypeSource,
+		metadata.M{"k": "v", MetadataNamespaceKey: "1"}, nil, nil, controlStream, nil, SystemClock)
+	assert.NoError(t, g.connector.Store(stream.ID(), stream))
+
+	update := func(md metadata.M) {
+		b, err := md.Encode()
+		assert.NoError(t, err)
+		g.handleMetadataUpdateFrame(&frame.MetadataUpdateFrame{StreamID: stream.ID(), Metadata: b})
+	}
+
+	// the keys from the authentication can not be rewritten by the client.
+	update(metadata.M{"k": "updated", MetadataNamespaceKey: "2"})
+	assert.Equal(t, metadata.M{"k": "updated", MetadataNamespaceKey: "1"}, stream.Metadata())
+
+	// the updates do not grow the metadata beyond the max size.
+	update(metadata.M{"large": string(make([]byte, 256))})
+	for _, k := range []string{"a-long-key-1", "a-long-key-2", "a-long-key-3", "a-long-key-4", "a-long-key-5"} {
+		update(metadata.M{k: "a-long-value-of-the-metadata"})
+	}
+	b, err := stream.Metadata().Encode()
+	assert.NoError(t, err)
+	assert.LessOrEqual(t, len(b), 128)
+	_, ok := stream.Metadata().Get("large")
+	assert.False(t, ok)
+}
+
+func TestStreamGroupPanicHandler(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	connector := NewConnector(ctx)
+	rt := router.Default([]config.Function{{Name: "sfn-panic"}, {Name: "sfn-echo"}})
+	panicked := make(chan StreamInfo, 1)
+	opts := NewServer("zipper").streamGroupOptions()
+	opts.panicHandler = func(stream StreamInfo, recovered any) {
+		assert.Equal(t, "boom", recovered)
+		panicked <- stream
+	}
+
+	client := runSessionStreamGroup(t, ctx, connector, rt, opts, func(c *Context) {
+		if c.DataStream.Name() == "sfn-panic" {
+			_, _ = c.DataStream.ReadFrame()
+			panic("boom")
+		}
+		echoFrames(c)
+	})
+
+	panicStream, err := requestStream(t, ctx, client, "sfn-panic", "sfn-panic-id", StreamTypeStreamFunction, 1)
+	if !assert.NoError(t, err) {
+		return
+	}
+	echoStream, err := requestStream(t, ctx, client, "sfn-echo", "sfn-echo-id", StreamTypeStreamFunction, 2)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, []string{"sfn-panic-id"}, rt.Route(metadata.M{}).GetForwardRoutes(1))
+
+	md, _ := metadata.M{}.Encode()
+	assert.NoError(t, panicStream.WriteFrame(&frame.DataFrame{Tag: 1, Metadata: md, Payload: []byte("panic")}))
+
+	select {
+	case stream := <-panicked:
+		assert.Equal(t, "sfn-panic-id", stream.ID())
+	case <-ctx.Done():
+		t.Fatal("the panic handler is not called")
+	}
+
+	// the stream panicked is closed and removed.
+	closed := make(chan error, 1)
+	go func() {
+		_, err := readDataFrame(panicStream)
+		closed <- err
+	}()
+	select {
+	case err := <-closed:
+		assert.Error(t, err)
+	case <-time.After(time.Second):
+		t.Error("the stream panicked is not closed")
+	}
+	assert.Eventually(t, func() bool {
+		_, ok, _ := connector.Get("sfn-panic-id")
+		return !ok && len(rt.Route(metadata.M{}).GetForwardRoutes(1)) == 0
+	}, time.Second, 10*time.Millisecond)
+
+	// other streams keep working.
+	assert.NoError(t, echoStream.WriteFrame(&frame.DataFrame{Tag: 2, Metadata: md, Payload: []byte("hello")}))
+	df, err := readDataFrame(echoStream)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "hello", string(df.Payload))
+	}
+	_, ok, _ := connector.Get("sfn-echo-id")
+	assert.True(t, ok)
+}
+
+func TestStreamGroupMaxDataStreams(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	opts := NewServer("zipper").streamGroupOptions()
+	opts.maxDataStreams = 1
+	client := runSessionStreamGroup(t, ctx, NewConnector(ctx), router.Default(nil), opts, echoFrames)
+
+	stream, err := requestStream(t, ctx, client, "source", "source-1", StreamTypeSource, 1)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// the handshake over the max is rejected, the client can retry later.
+	_, err = requestStream(t, ctx, client, "source", "source-2", StreamTypeSource, 1)
+	rejected := new(ErrHandshakeRejected)
+	if assert.ErrorAs(t, err, rejected) {
+		assert.Equal(t, frame.RejectRateLimited, rejected.Reason)
+		assert.Equal(t, "source-2", rejected.StreamID)
+	}
+
+	// closing a stream frees the slot.
+	assert.NoError(t, stream.Close())
+	assert.Eventually(t, func() bool {
+		_, err := requestStream(t, ctx, client, "source", "source-2", StreamTypeSource, 1)
+		return err == nil
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestStreamGroupEvictIdleStream(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	clock := NewManualClock(time.Now())
+	connector := NewConnector(ctx)
+	opts := NewServer("zipper").streamGroupOptions()
+	opts.clock = clock
+	opts.idleTimeout = time.Second
+	client := runSessionStreamGroup(t, ctx, connector, router.Default([]config.Function{{Name: "sfn"}}), opts, echoFrames)
+
+	closed := make(chan *frame.CloseStreamFrame, 1)
+	client.Handlers().OnCloseStream(func(f *frame.CloseStreamFrame) { closed <- f })
+
+	stream, err := requestStream(t, ctx, client, "sfn", "sfn-1", StreamTypeStreamFunction, 1)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Eventually(t, func() bool { return clock.Timers() == 1 }, time.Second, time.Millisecond)
+
+	// the activity resets the idle timeout.
+	clock.Advance(500 * time.Millisecond)
+	md, _ := metadata.M{}.Encode()
+	assert.NoError(t, stream.WriteFrame(&frame.DataFrame{Tag: 1, Metadata: md, Payload: []byte("hello")}))
+	_, err = readDataFrame(stream)
+	assert.NoError(t, err)
+	clock.Advance(500 * time.Millisecond)
+	assert.Eventually(t, func() bool { return clock.Timers() == 1 }, time.Second, time.Millisecond)
+	assert.Empty(t, closed)
+
+	clock.Advance(500 * time.Millisecond)
+	select {
+	case f := <-closed:
+		assert.Equal(t, "sfn-1", f.StreamID)
+		assert.Equal(t, frame.CloseIdleTimeout, f.Code)
+	case <-ctx.Done():
+		t.Fatal("the idle stream is not evicted")
+	}
+	assert.Eventually(t, func() bool {
+		_, ok, _ := connector.Get("sfn-1")
+		return !ok
+	}, time.Second, time.Millisecond)
+}
+
+func TestStreamGroupHeartbeatStream(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	clock := NewManualClock(time.Now())
+	connector := NewConnector(ctx)
+	opts := NewServer("zipper").streamGroupOptions()
+	opts.clock = clock
+	opts.heartbeatInterval = time.Second
+	opts.heartbeatMisses = 2
+	client := runSessionStreamGroup(t, ctx, connector, router.Default([]config.Function{{Name: "sfn"}}), opts, echoFrames)
+
+	closed := make(chan *frame.CloseStreamFrame, 1)
+	client.Handlers().OnCloseStream(func(f *frame.CloseStreamFrame) { closed <- f })
+
+	stream, err := requestStream(t, ctx, client, "sfn", "sfn-1", StreamTypeStreamFunction, 1)
+	if !assert.NoError(t, err) {
+		return
+	}
+	// the client reads the heartbeats but never replies.
+	pings := make(chan struct{}, 10)
+	go func() {
+		for {
+			f, err := stream.ReadFrame()
+			if err != nil {
+				return
+			}
+			if _, ok := f.(*frame.PingFrame); ok {
+				pings <- struct{}{}
+			}
+		}
+	}()
+	assert.Eventually(t, func() bool { return clock.Timers() == 1 }, time.Second, time.Millisecond)
+
+	clock.Advance(time.Second)
+	select {
+	case <-pings:
+	case <-ctx.Done():
+		t.Fatal("no heartbeat is written")
+	}
+	assert.Eventually(t, func() bool { return clock.Timers() == 1 }, time.Second, time.Millisecond)
+	assert.Empty(t, closed)
+
+	// the stream is closed once it misses the heartbeats.
+	clock.Advance(time.Second)
+	select {
+	case f := <-closed:
+		assert.Equal(t, "sfn-1", f.StreamID)
+		assert.Equal(t, frame.CloseHeartbeatTimeout, f.Code)
+	case <-ctx.Done():
+		t.Fatal("the stream missing heartbeats is not closed")
+	}
+	assert.Eventually(t, func() bool {
+		_, ok, _ := connector.Get("sfn-1")
+		return !ok
+	}, time.Second, time.Millisecond)
+}
@@ -0,0 +1,77 @@
+package core
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/yomorun/yomo/core/frame"
+)
+
+// streamReadiness tracks the tags those have a StreamFunction ready, it is updated by the StreamReadyFrames
+// forwarded by the zipper. Only the first write of a tag waits for the readiness, so a tag that never gets ready
+// costs one timeout at most.
+type streamReadiness struct {
+	mu sync.Mutex
+	// ready is closed once the tag is ready.
+	ready map[frame.Tag]chan struct{}
+	// waited are the tags whose first write has waited.
+	waited map[frame.Tag]struct{}
+}
+
+func newStreamReadiness() *streamReadiness {
+	return &streamReadiness{
+		ready:  make(map[frame.Tag]chan struct{}),
+		waited: make(map[frame.Tag]struct{}),
+	}
+}
+
+// readyChan returns the channel that is closed once the tag is ready, the caller must hold the mu.
+func (r *streamReadiness) readyChan(tag frame.Tag) chan struct{} {
+	ch, ok := r.ready[tag]
+	if !ok {
+		ch = make(chan struct{})
+		r.ready[tag] = ch
+	}
+	return ch
+}
+
+// markReady marks the tags ready.
+func (r *streamReadiness) markReady(tags []frame.Tag) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, tag := range tags {
+		ch := r.readyChan(tag)
+		select {
+		case <-ch:
+		default:
+			close(ch)
+		}
+	}
+}
+
+// waitFirst waits for the tag to be ready if it is the first write of the tag, it gives up after the timeout
+// or once the ctx is done. It reports false if it gives up.
+func (r *streamReadiness) waitFirst(ctx context.Context, clock Clock, tag frame.Tag, timeout time.Duration) bool {
+	r.mu.Lock()
+	if _, ok := r.waited[tag]; ok {
+		r.mu.Unlock()
+		return true
+	}
+	r.waited[tag] = struct{}{}
+	ch := r.readyChan(tag)
+	r.mu.Unlock()
+
+	timer := clock.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case <-ch:
+		return true
+	case <-timer.C():
+		return false
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,36 @@
+package core
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yomorun/yomo/core/frame"
+)
+
+func TestStreamReadiness(t *testing.T) {
+	clock := NewManualClock(time.Now())
+	r := newStreamReadiness()
+
+	r.markReady([]frame.Tag{1})
+	assert.True(t, r.waitFirst(context.Background(), clock, 1, time.Second))
+
+	done := make(chan bool)
+	go func() { done <- r.waitFirst(context.Background(), clock, 2, time.Second) }()
+	for clock.Timers() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	clock.Advance(time.Second)
+	assert.False(t, <-done)
+
+	// only the first write of the tag waits.
+	assert.True(t, r.waitFirst(context.Background(), clock, 2, time.Second))
+
+	go func() { done <- r.waitFirst(context.Background(), clock, 3, time.Second) }()
+	for clock.Timers() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	r.markReady([]frame.Tag{3, 3})
+	assert.True(t, <-done)
+}
@@ -0,0 +1,54 @@
+package core
+
+import (
+	"fmt"
+
+	"github.com/yomorun/yomo/core/frame"
+	"github.com/yomorun/yomo/core/metadata"
+)
+
+// ObserveTagAuthorizer reports whether the stream is allowed to observe the tag, it is used to keep the
+// streams from observing the tags of other tenants. The metadata is the merged metadata of the stream,
+// it includes the connection metadata derived from the authentication, such as the tenant id.
+// The authorizer is asked for frame.TagFirehose as well, it is denied if there is no authorizer.
+type ObserveTagAuthorizer func(md metadata.M, tag frame.Tag) bool
+
+// authorizeObserveTag reports whether the stream is allowed to observe the tag,
+// all the tags but frame.TagFirehose are allowed if the authorizer is nil.
+func authorizeObserveTag(authorizer ObserveTagAuthorizer, md metadata.M, tag frame.Tag) bool {
+	if authorizer == nil {
+		return tag != frame.TagFirehose
+	}
+	return authorizer(md, tag)
+}
+
+// ObserveTagDenyPolicy decides what to do with the handshake that observes the denied tags.
+type ObserveTagDenyPolicy int
+
+const (
+	// ObserveTagDenyReject rejects the handshake with frame.RejectForbidden.
+	ObserveTagDenyReject ObserveTagDenyPolicy = iota
+	// ObserveTagDenyFilter removes the denied tags from the observed tags silently.
+	ObserveTagDenyFilter
+)
+
+// authorizeObserveTags returns the tags those the authorizer allows to observe, it returns a handshakeRejectError
+// if any tag is denied and the policy is ObserveTagDenyReject.
+func authorizeObserveTags(
+	authorizer ObserveTagAuthorizer, policy ObserveTagDenyPolicy, md metadata.M, tags []frame.Tag,
+) ([]frame.Tag, error) {
+	allowed := make([]frame.Tag, 0, len(tags))
+	for _, tag := range tags {
+		if authorizeObserveTag(authorizer, md, tag) {
+			allowed = append(allowed, tag)
+			continue
+		}
+		if policy == ObserveTagDenyReject {
+			return nil, &handshakeRejectError{
+				reason:  frame.RejectForbidden,
+				message: fmt.Sprintf("yomo: observing tag %d is not allowed", tag),
+			}
+		}
+	}
+	return allowed, nil
+}
@@ -0,0 +1,164 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+
+	"github.com/yomorun/yomo/core/frame"
+)
+
+// ErrWriteQueueFull is returned by the write of a bulk frame, such as a DataFrame,
+// if the write queue of the FrameStream is full, the frame is dropped.
+var ErrWriteQueueFull = errors.New("yomo: write queue is full")
+
+// writePriority is the priority of the frame waiting for the writes of FrameStream.
+type writePriority int
+
+const (
+	// writePriorityBulk is the priority of the frames carrying the data.
+	writePriorityBulk writePriority = iota
+	// writePriorityControl is the priority of the control frames, they go ahead of the bulk frames.
+	writePriorityControl
+)
+
+// writePriorityOf returns the write priority of the frame type.
+func writePriorityOf(ftyp frame.Type) writePriority {
+	switch ftyp {
+	case frame.TypeDataFrame, frame.TypeBatchDataFrame, frame.TypeChunkedDataFrame, frame.TypeBackflowFrame:
+		return writePriorityBulk
+	default:
+		return writePriorityControl
+	}
+}
+
+// writeQueue is the write lock of FrameStream that is handed over to the waiting writes by priority,
+// the writes of the same priority are in FIFO order. The waiting bulk writes are ordered by their ranks,
+// such as the priorities of the tags observed, the bulk writes of the same rank are in FIFO order.
+// The waiting bulk writes are bounded by the size, the control writes are never dropped.
+type writeQueue struct {
+	size int
+
+	mu      sync.Mutex
+	locked  bool
+	control []chan struct{}
+	bulk    []bulkWaiter
+}
+
+// bulkWaiter is a waiting bulk write.
+type bulkWaiter struct {
+	ch   chan struct{}
+	rank uint8
+}
+
+func newWriteQueue(size int) *writeQueue {
+	return &writeQueue{size: size}
+}
+
+// acquire acquires the write lock with the priority, it gives up and returns io.EOF once the streamDone is closed,
+// or the ctx.Err() once the ctx is done. A nil ctx never gives up.
+func (q *writeQueue) acquire(priority writePriority, streamDone <-chan struct{}, ctx context.Context) error {
+	return q.acquireRanked(priority, 0, streamDone, ctx)
+}
+
+// acquireRanked acquires the write lock like acquire, the bulk write of a higher rank goes ahead of
+// the waiting bulk writes of lower ranks.
+func (q *writeQueue) acquireRanked(priority writePriority, rank uint8, streamDone <-chan struct{}, ctx context.Context) error {
+	q.mu.Lock()
+	if !q.locked {
+		q.locked = true
+		q.mu.Unlock()
+		return nil
+	}
+	if priority == writePriorityBulk && len(q.bulk) >= q.size {
+		q.mu.Unlock()
+		return ErrWriteQueueFull
+	}
+	ch := make(chan struct{})
+	if priority == writePriorityControl {
+		q.control = append(q.control, ch)
+	} else {
+		q.bulk = insertBulkWaiter(q.bulk, bulkWaiter{ch: ch, rank: rank})
+	}
+	q.mu.Unlock()
+
+	var ctxDone <-chan struct{}
+	if ctx != nil {
+		ctxDone = ctx.Done()
+	}
+	select {
+	case <-ch:
+		return nil
+	case <-streamDone:
+		return q.giveUp(ch, io.EOF)
+	case <-ctxDone:
+		return q.giveUp(ch, ctx.Err())
+	}
+}
+
+// giveUp removes the waiting ch from the queue and returns the err,
+// if the lock has been handed over to ch meanwhile, it is released.
+func (q *writeQueue) giveUp(ch chan struct{}, err error) error {
+	q.mu.Lock()
+	removed := false
+	q.control, removed = removeWaiter(q.control, ch)
+	if !removed {
+		q.bulk, removed = removeBulkWaiter(q.bulk, ch)
+	}
+	q.mu.Unlock()
+
+	if !removed {
+		q.release()
+	}
+	return err
+}
+
+// release releases the write lock, it is handed over to the first waiting control write if any,
+// or the first waiting bulk write.
+func (q *writeQueue) release() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	var next chan struct{}
+	if len(q.control) > 0 {
+		next, q.control = q.control[0], q.control[1:]
+	} else if len(q.bulk) > 0 {
+		next, q.bulk = q.bulk[0].ch, q.bulk[1:]
+	}
+	if next == nil {
+		q.locked = false
+		return
+	}
+	close(next)
+}
+
+func removeWaiter(waiters []chan struct{}, ch chan struct{}) ([]chan struct{}, bool) {
+	for i, w := range waiters {
+		if w == ch {
+			return append(waiters[:i], waiters[i+1:]...), true
+		}
+	}
+	return waiters, false
+}
+
+// insertBulkWaiter inserts the waiter after the waiters of the same or higher ranks.
+func insertBulkWaiter(waiters []bulkWaiter, w bulkWaiter) []bulkWaiter {
+	i := len(waiters)
+	for i > 0 && waiters[i-1].rank < w.rank {
+		i--
+	}
+	waiters = append(waiters, bulkWaiter{})
+	copy(waiters[i+1:], waiters[i:])
+	waiters[i] = w
+	return waiters
+}
+
+func removeBulkWaiter(waiters []bulkWaiter, ch chan struct{}) ([]bulkWaiter, bool) {
+	for i, w := range waiters {
+		if w.ch == ch {
+			return append(waiters[:i], waiters[i+1:]...), true
+		}
+	}
+	return waiters, false
+}
@@ -1,7 +1,24 @@
-// Package yerr describes yomo errors
+// Package yerr describes yomo errors.
+//
+// The ErrorCodes are the registry of the QUIC application error codes that yomo closes the connections with,
+// an ErrorCode is sent as the QUIC application error code as is, see ErrorCode.To, and the peer translates
+// the received code back by FromError. The codes are in the range 0xC0 to 0xD0:
+//
+//	0xC0 UnknownError        0xC8 StartHandler
+//	0xC1 NetClosed           0xC9 AuthenticateFailed
+//	0xC2 BeforeHandler       0xCA VersionMismatch
+//	0xC3 MainHandler         0xCB ProtocolError
+//	0xC4 AfterHandler        0xCC Rejected
+//	0xC5 Handshake           0xCD UnknownClient
+//	0xC6 DuplicateName       0xCE DataFrame
+//	0xC7 ClientAbort         0xCF Goaway
+//	                         0xD0 Rotated
+//
+// The connections closed for no error are closed with 0x13, which is not in the registry.
 package yerr
 
 import (
+	"errors"
 	"fmt"
 
 	"github.com/quic-go/quic-go"
@@ -69,6 +86,12 @@ const (
 	ErrorCodeDuplicateName ErrorCode = 0xC6
 	// ErrorCodeStartHandler start handler
 	ErrorCodeStartHandler ErrorCode = 0xC8
+	// ErrorCodeVersionMismatch the client and the server have no protocol version in common.
+	ErrorCodeVersionMismatch ErrorCode = 0xCA
+	// ErrorCodeProtocol the peer violates the protocol, such as sending an unexpected frame.
+	ErrorCodeProtocol ErrorCode = 0xCB
+	// ErrorCodeRotated the connection is rotated for reaching its max lifetime, the client reconnects.
+	ErrorCodeRotated ErrorCode = 0xD0
 )
 
 var errCodeStringMap = map[ErrorCode]string{
@@ -86,6 +109,9 @@ var errCodeStringMap = map[ErrorCode]string{
 	ErrorCodeUnknownClient:      "UnknownClient",
 	ErrorCodeDuplicateName:      "DuplicateName",
 	ErrorCodeStartHandler:       "StartHandler",
+	ErrorCodeVersionMismatch:    "VersionMismatch",
+	ErrorCodeProtocol:           "ProtocolError",
+	ErrorCodeRotated:            "Rotated",
 }
 
 func (e ErrorCode) String() string {
@@ -96,6 +122,27 @@ func (e ErrorCode) String() string {
 	return msg
 }
 
+// Registered reports whether the ErrorCode is in the registry.
+func (e ErrorCode) Registered() bool {
+	_, ok := errCodeStringMap[e]
+	return ok
+}
+
+// FromError translates the QUIC application error that the peer closes the connection with to the YomoError,
+// the message of the YomoError is the error message sent by the peer. It returns false if err is not
+// a QUIC application error or its code is not in the registry.
+func FromError(err error) (YomoError, bool) {
+	qerr := new(quic.ApplicationError)
+	if !errors.As(err, &qerr) {
+		return nil, false
+	}
+	code := Parse(qerr.ErrorCode)
+	if !code.Registered() {
+		return nil, false
+	}
+	return New(code, errors.New(qerr.ErrorMessage)), true
+}
+
 // Is parse quic ApplicationErrorCode to yomo ErrorCode
 func Is(qerr quic.ApplicationErrorCode, yerr ErrorCode) bool {
 	return uint64(qerr) == uint64(yerr)
@@ -2,6 +2,7 @@ package yerr
 
 import (
 	"errors"
+	"fmt"
 	"testing"
 
 	"github.com/quic-go/quic-go"
@@ -41,6 +42,25 @@ func TestErrorCode(t *testing.T) {
 	assert.Equal(t, to, qcode)
 }
 
+func TestFromError(t *testing.T) {
+	err := &quic.ApplicationError{
+		Remote:       true,
+		ErrorCode:    ErrorCodeAuthenticateFailed.To(),
+		ErrorMessage: "authentication failed",
+	}
+
+	ye, ok := FromError(fmt.Errorf("read: %w", err))
+	assert.True(t, ok)
+	assert.Equal(t, ErrorCodeAuthenticateFailed, ye.ErrorCode())
+	assert.Equal(t, "AuthenticateFailed error: message=authentication failed", ye.Error())
+
+	_, ok = FromError(&quic.ApplicationError{ErrorCode: 0x13})
+	assert.False(t, ok)
+
+	_, ok = FromError(errors.New("not a quic error"))
+	assert.False(t, ok)
+}
+
 func TestDuplicateName(t *testing.T) {
 	var (
 		err    = errors.New("errmsg")
@@ -0,0 +1,202 @@
+package yomo
+
+import (
+	"errors"
+	"io"
+	"sync"
+
+	"github.com/yomorun/yomo/core/frame"
+)
+
+// ErrWriterClosed is returned by the writes of the closed Writer.
+var ErrWriterClosed = errors.New("yomo: writer is closed")
+
+// Writer is an io.WriteCloser that writes the bytes to the zipper as the data of a tag, so the code
+// writing to an io.Writer, such as a logger or io.Copy, can pipe its bytes through YoMo.
+//
+// The bytes are buffered, every data written carries at most frameSize bytes. A full buffer is written
+// once more bytes come, Flush writes the buffered bytes at once, and Close flushes before closing.
+// Writer is safe for concurrent use.
+type Writer struct {
+	source    Source
+	tag       uint32
+	frameSize int
+
+	mu     sync.Mutex
+	buf    []byte
+	closed bool
+}
+
+var _ io.WriteCloser = (*Writer)(nil)
+
+// NewWriter returns a Writer that writes the bytes to the tag through the source, the source must be connected.
+// A frameSize not greater than 0 means DefaultChunkSize.
+func NewWriter(source Source, tag uint32, frameSize int) *Writer {
+	if frameSize <= 0 {
+		frameSize = DefaultChunkSize
+	}
+	return &Writer{
+		source:    source,
+		tag:       tag,
+		frameSize: frameSize,
+	}
+}
+
+// Write implements io.Writer, n is the number of bytes taken into the Writer. If the full buffer fails
+// to be written, Write returns the error with the bytes taken before, the buffer is kept for the next write.
+func (w *Writer) Write(p []byte) (n int, err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return 0, ErrWriterClosed
+	}
+	for len(p) > 0 {
+		if len(w.buf) == w.frameSize {
+			if err := w.flush(); err != nil {
+				return n, err
+			}
+		}
+		if w.buf == nil {
+			w.buf = make([]byte, 0, w.frameSize)
+		}
+		k := copy(w.buf[len(w.buf):w.frameSize], p)
+		w.buf = w.buf[:len(w.buf)+k]
+		p = p[k:]
+		n += k
+	}
+	return n, nil
+}
+
+// Flush writes the buffered bytes.
+func (w *Writer) Flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return ErrWriterClosed
+	}
+	return w.flush()
+}
+
+// Close flushes the buffered bytes and closes the Writer, the source is not closed.
+// The Writer is closed even if the flush fails.
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	return w.flush()
+}
+
+// flush writes the buffered bytes, the caller must hold the mu.
+func (w *Writer) flush() error {
+	if len(w.buf) == 0 {
+		return nil
+	}
+	if err := w.source.Write(w.tag, w.buf); err != nil {
+		return err
+	}
+	// the data may be held until it is sent, so the buffer is not reused.
+	w.buf = nil
+	return nil
+}
+
+// readerBufferSize is the number of the payloads buffered by Reader, the handler
+// feeding the Reader blocks once the buffer is full, so the Reader is never outrun.
+const readerBufferSize = 16
+
+// Reader is an io.ReadCloser that reads the payloads of the data received as a byte stream,
+// the payloads are read in the order they are received.
+type Reader struct {
+	payloads  chan []byte
+	done      chan struct{}
+	closeOnce sync.Once
+
+	// cur is the rest of the payload being read, it is only touched by Read.
+	cur []byte
+}
+
+var _ io.ReadCloser = (*Reader)(nil)
+
+// NewStreamFunctionReader returns a Reader that reads the payloads of the data observed by the stream function,
+// it sets the pipe handler of the stream function, so it must be called before the stream function connects.
+func NewStreamFunctionReader(sfn StreamFunction) (*Reader, error) {
+	r := newReader()
+	err := sfn.SetPipeHandler(func(in <-chan []byte, _ chan<- *frame.DataFrame) {
+		// the payloads are discarded once the Reader is closed, the pipe is still drained,
+		// otherwise the stream function is blocked on feeding it.
+		for data := range in {
+			r.push(data)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// NewSourceReader returns a Reader that reads the payloads of the data received by the source,
+// it sets the receive handler of the source.
+func NewSourceReader(source Source) *Reader {
+	r := newReader()
+	source.SetReceiveHandler(func(_ uint32, data []byte) { r.push(data) })
+	return r
+}
+
+func newReader() *Reader {
+	return &Reader{
+		payloads: make(chan []byte, readerBufferSize),
+		done:     make(chan struct{}),
+	}
+}
+
+// push feeds the payload to the Reader, it blocks until the payload is buffered, and reports false
+// if the Reader is closed.
+func (r *Reader) push(data []byte) bool {
+	select {
+	case <-r.done:
+		return false
+	default:
+	}
+	if len(data) == 0 {
+		return true
+	}
+	// the payload of the frame may be reused once the frame is handled.
+	b := make([]byte, len(data))
+	copy(b, data)
+
+	select {
+	case r.payloads <- b:
+		return true
+	case <-r.done:
+		return false
+	}
+}
+
+// Read implements io.Reader, it blocks until a payload is received, and returns io.EOF once the Reader is closed.
+// Read must not be called concurrently.
+func (r *Reader) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if len(r.cur) == 0 {
+		select {
+		case r.cur = <-r.payloads:
+		case <-r.done:
+			return 0, io.EOF
+		}
+	}
+	n := copy(p, r.cur)
+	r.cur = r.cur[n:]
+	return n, nil
+}
+
+// Close closes the Reader, the payloads received later are dropped.
+func (r *Reader) Close() error {
+	r.closeOnce.Do(func() { close(r.done) })
+	return nil
+}
@@ -0,0 +1,122 @@
+package yomo
+
+import (
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yomorun/yomo/core"
+	"github.com/yomorun/yomo/core/frame"
+)
+
+// writeRecorder is a Source that records the writes.
+type writeRecorder struct {
+	Source
+	tags   []uint32
+	writes []string
+	err    error
+}
+
+func (s *writeRecorder) Write(tag uint32, data []byte) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.tags = append(s.tags, tag)
+	s.writes = append(s.writes, string(data))
+	return nil
+}
+
+func TestWriter(t *testing.T) {
+	source := &writeRecorder{}
+	w := NewWriter(source, 0x21, 4)
+
+	n, err := w.Write([]byte("hello world"))
+	assert.NoError(t, err)
+	assert.Equal(t, 11, n)
+	assert.Equal(t, []string{"hell", "o wo"}, source.writes)
+
+	assert.NoError(t, w.Flush())
+	assert.Equal(t, []string{"hell", "o wo", "rld"}, source.writes)
+	assert.Equal(t, []uint32{0x21, 0x21, 0x21}, source.tags)
+
+	// the full buffer is kept if it fails to be written.
+	n, err = w.Write([]byte("abcdefgh"))
+	assert.NoError(t, err)
+	assert.Equal(t, 8, n)
+	source.err = errors.New("broken")
+	n, err = w.Write([]byte("ij"))
+	assert.Equal(t, source.err, err)
+	assert.Equal(t, 0, n)
+
+	source.err = nil
+	n, err = w.Write([]byte("ij"))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
+	assert.NoError(t, w.Close())
+	assert.Equal(t, []string{"hell", "o wo", "rld", "abcd", "efgh", "ij"}, source.writes)
+
+	_, err = w.Write([]byte("x"))
+	assert.Equal(t, ErrWriterClosed, err)
+	assert.NoError(t, w.Close())
+}
+
+func TestReader(t *testing.T) {
+	r := newReader()
+
+	payload := []byte("hello")
+	assert.True(t, r.push(payload))
+	assert.True(t, r.push(nil))
+	assert.True(t, r.push([]byte(" world")))
+	// the payload is copied.
+	payload[0] = 'j'
+
+	p := make([]byte, 3)
+	var got []byte
+	for len(got) < 11 {
+		n, err := r.Read(p)
+		assert.NoError(t, err)
+		got = append(got, p[:n]...)
+	}
+	assert.Equal(t, "hello world", string(got))
+
+	assert.NoError(t, r.Close())
+	_, err := r.Read(p)
+	assert.Equal(t, io.EOF, err)
+	assert.False(t, r.push([]byte("dropped")))
+}
+
+// pipeRecorder is a StreamFunction that records the pipe handler.
+type pipeRecorder struct {
+	StreamFunction
+	handler core.PipeHandler
+}
+
+func (s *pipeRecorder) SetPipeHandler(fn core.PipeHandler) error {
+	s.handler = fn
+	return nil
+}
+
+func TestStreamFunctionReaderClosed(t *testing.T) {
+	sfn := &pipeRecorder{}
+	r, err := NewStreamFunctionReader(sfn)
+	assert.NoError(t, err)
+
+	// the pipe of the stream function is unbuffered.
+	in := make(chan []byte)
+	go sfn.handler(in, make(chan *frame.DataFrame))
+	defer close(in)
+
+	in <- []byte("hello")
+	assert.NoError(t, r.Close())
+
+	// the frames delivered after the Reader is closed do not block the stream function.
+	for _, data := range []string{"dropped", "dropped again"} {
+		select {
+		case in <- []byte(data):
+		case <-time.After(time.Second):
+			t.Fatal("the stream function is blocked after the reader is closed")
+		}
+	}
+}
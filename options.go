@@ -2,10 +2,12 @@ package yomo
 
 import (
 	"crypto/tls"
+	"time"
 
 	"github.com/quic-go/quic-go"
 	"github.com/yomorun/yomo/core"
 	"github.com/yomorun/yomo/core/frame"
+	"github.com/yomorun/yomo/core/metadata"
 	"go.opentelemetry.io/otel/trace"
 	"golang.org/x/exp/slog"
 )
@@ -23,12 +25,33 @@ var (
 	// WithObserveDataTags sets the list of data tags for the Source.
 	WithObserveDataTags = func(tags ...frame.Tag) SourceOption { return SourceOption(core.WithObserveDataTags(tags...)) }
 
+	// WithBinaryCredential sets the credential with the binary payload for the Source.
+	WithBinaryCredential = func(name string, payload []byte) SourceOption {
+		return SourceOption(core.WithBinaryCredential(name, payload))
+	}
+
+	// WithSourceWaitStreamReady makes the first write of every tag wait up to the timeout for an sfn
+	// observing the tag to be ready, see core.WithWaitStreamReady.
+	WithSourceWaitStreamReady = func(timeout time.Duration) SourceOption {
+		return SourceOption(core.WithWaitStreamReady(timeout))
+	}
+
+	// WithSourceLabels sets the labels of the connection of the Source, such as the region, the version
+	// or the canary, see core.WithLabels.
+	WithSourceLabels = func(labels map[string]string) SourceOption { return SourceOption(core.WithLabels(labels)) }
+
 	// WithCredential sets the credential method for the Source.
 	WithCredential = func(payload string) SourceOption { return SourceOption(core.WithCredential(payload)) }
 
 	// WithSourceTLSConfig sets tls config for the Source.
 	WithSourceTLSConfig = func(tc *tls.Config) SourceOption { return SourceOption(core.WithClientTLSConfig(tc)) }
 
+	// WithSourceALPN sets the application protocols negotiated by the Source.
+	WithSourceALPN = func(protos ...string) SourceOption { return SourceOption(core.WithClientALPN(protos...)) }
+
+	// WithSource0RTT makes the Source authenticate in 0-RTT when it reconnects, see core.WithClient0RTT.
+	WithSource0RTT = func() SourceOption { return SourceOption(core.WithClient0RTT()) }
+
 	// WithSourceQuicConfig sets quic config for the Source.
 	WithSourceQuicConfig = func(qc *quic.Config) SourceOption { return SourceOption(core.WithClientQuicConfig(qc)) }
 
@@ -37,24 +60,94 @@ var (
 
 	// WithTracerProvider sets tracer provider for the Source.
 	WithTracerProvider = func(tp trace.TracerProvider) SourceOption { return SourceOption(core.WithTracerProvider(tp)) }
+
+	// WithReconnectBackoff sets the backoff between reconnect attempts for the Source.
+	WithReconnectBackoff = func(initial, max time.Duration, jitter float64) SourceOption {
+		return SourceOption(core.WithReconnectBackoff(initial, max, jitter))
+	}
+
+	// WithHandshakeAckTimeout sets the time the Source waits for the server to accept its data stream.
+	WithHandshakeAckTimeout = func(timeout time.Duration) SourceOption {
+		return SourceOption(core.WithHandshakeAckTimeout(timeout))
+	}
+
+	// WithMaxPause sets the max time the data of a tag are paused by the flow control of the Sfn.
+	WithMaxPause = func(d time.Duration) SourceOption { return SourceOption(core.WithMaxPause(d)) }
+
+	// WithMetadataEncoding sets the encoding of the metadata written by the Source.
+	WithMetadataEncoding = func(enc metadata.Encoding) SourceOption { return SourceOption(core.WithMetadataEncoding(enc)) }
+
+	// WithSourceChecksum protects the packets of the Source with checksums if the zipper supports it.
+	WithSourceChecksum = func() SourceOption { return SourceOption(core.WithChecksum()) }
 )
 
 // Sfn Options.
 var (
+	// WithSfnBinaryCredential sets the credential with the binary payload for the Sfn.
+	WithSfnBinaryCredential = func(name string, payload []byte) SfnOption {
+		return SfnOption(core.WithBinaryCredential(name, payload))
+	}
+
 	// WithSfnCredential sets the credential method for the Sfn.
 	WithSfnCredential = func(payload string) SfnOption { return SfnOption(core.WithCredential(payload)) }
 
+	// WithSfnLabels sets the labels of the connection of the Sfn, such as the region, the version
+	// or the canary, see core.WithLabels.
+	WithSfnLabels = func(labels map[string]string) SfnOption { return SfnOption(core.WithLabels(labels)) }
+
 	// WithSfnTLSConfig sets tls config for the Sfn.
 	WithSfnTLSConfig = func(tc *tls.Config) SfnOption { return SfnOption(core.WithClientTLSConfig(tc)) }
 
+	// WithSfnALPN sets the application protocols negotiated by the Sfn.
+	WithSfnALPN = func(protos ...string) SfnOption { return SfnOption(core.WithClientALPN(protos...)) }
+
+	// WithSfn0RTT makes the Sfn authenticate in 0-RTT when it reconnects, see core.WithClient0RTT.
+	WithSfn0RTT = func() SfnOption { return SfnOption(core.WithClient0RTT()) }
+
 	// WithSfnQuicConfig sets quic config for the Sfn.
 	WithSfnQuicConfig = func(qc *quic.Config) SfnOption { return SfnOption(core.WithClientQuicConfig(qc)) }
 
+	// WithSfnChecksum protects the packets of the Sfn with checksums if the zipper supports it.
+	WithSfnChecksum = func() SfnOption { return SfnOption(core.WithChecksum()) }
+
 	// WithSfnLogger sets logger for the Sfn.
 	WithSfnLogger = func(l *slog.Logger) SfnOption { return SfnOption(core.WithLogger(l)) }
 
 	// WithSfnTracerProvider sets tracer provider for the Sfn.
 	WithSfnTracerProvider = func(tp trace.TracerProvider) SfnOption { return SfnOption(core.WithTracerProvider(tp)) }
+
+	// WithSfnReconnectBackoff sets the backoff between reconnect attempts for the Sfn.
+	WithSfnReconnectBackoff = func(initial, max time.Duration, jitter float64) SfnOption {
+		return SfnOption(core.WithReconnectBackoff(initial, max, jitter))
+	}
+
+	// WithSfnHandshakeAckTimeout sets the time the Sfn waits for the server to accept its data stream.
+	WithSfnHandshakeAckTimeout = func(timeout time.Duration) SfnOption {
+		return SfnOption(core.WithHandshakeAckTimeout(timeout))
+	}
+
+	// WithSfnMetadataEncoding sets the encoding of the metadata written by the Sfn.
+	WithSfnMetadataEncoding = func(enc metadata.Encoding) SfnOption { return SfnOption(core.WithMetadataEncoding(enc)) }
+
+	// WithSfnChunkReassembly sets the max size of the payload that the Sfn reassembles from the chunks
+	// written by Source.WriteChunked, and the time that the chunks are reassembled in.
+	WithSfnChunkReassembly = func(maxSize int, timeout time.Duration) SfnOption {
+		return SfnOption(core.WithChunkReassembly(maxSize, timeout))
+	}
+
+	// WithSfnInstanceKey sets the key stable per instance of the Sfn, the zipper replaces the streams of the same
+	// instance when the Sfn reconnects, so the data is not processed twice, see core.WithInstanceKey.
+	WithSfnInstanceKey = func(key string) SfnOption { return SfnOption(core.WithInstanceKey(key)) }
+
+	// WithSfnObserveTagPriorities sets the priorities of the tags observed by the Sfn, the zipper writes the backlogged
+	// data of the higher priority tags to the Sfn first, see core.WithObserveTagPriorities.
+	WithSfnObserveTagPriorities = func(priorities map[uint32]uint8) SfnOption {
+		return SfnOption(core.WithObserveTagPriorities(priorities))
+	}
+
+	// WithSfnStatsReport makes the Sfn report the number, the errors and the latencies of the processed data
+	// to the zipper at the interval, the zipper feeds them into its observer, see core.StatsReportObserver.
+	WithSfnStatsReport = func(interval time.Duration) SfnOption { return SfnOption(core.WithStatsReport(interval)) }
 )
 
 // ClientOption is option for the upstream Zipper.
@@ -83,6 +176,207 @@ var (
 		}
 	}
 
+	// WithZipperReorderWindow enables the reorder buffer of the sequenced data, see core.ReorderWindow.
+	WithZipperReorderWindow = func(size int, hold time.Duration) ZipperOption {
+		return func(zo *zipperOptions) {
+			zo.serverOption = append(zo.serverOption, core.WithServerReorderWindow(core.ReorderWindow{Size: size, Hold: hold}))
+		}
+	}
+
+	// WithZipperStreamSampling delivers 1 in rate data to the sfns of the name, deterministically by the metadata key
+	// if key is not empty, otherwise probabilistically, see core.Sampling.
+	WithZipperStreamSampling = func(sfnName string, rate uint32, key string) ZipperOption {
+		return func(zo *zipperOptions) {
+			zo.serverOption = append(zo.serverOption, core.WithServerStreamSampling(sfnName, core.Sampling{Rate: rate, Key: key}))
+		}
+	}
+
+	// WithZipperMaxDataStreams sets the max count of the data streams per connection, zero means unlimited.
+	WithZipperMaxDataStreams = func(n int) ZipperOption {
+		return func(zo *zipperOptions) {
+			zo.serverOption = append(zo.serverOption, core.WithServerMaxDataStreams(n))
+		}
+	}
+
+	// WithZipperKeepAlive sets the QUIC keep-alive period and max idle timeout of the zipper, a zero value keeps the default.
+	WithZipperKeepAlive = func(keepAlivePeriod, maxIdleTimeout time.Duration) ZipperOption {
+		return func(zo *zipperOptions) {
+			zo.serverOption = append(zo.serverOption,
+				core.WithServerKeepAlivePeriod(keepAlivePeriod),
+				core.WithServerMaxIdleTimeout(maxIdleTimeout),
+			)
+		}
+	}
+
+	// WithZipperIdleTimeout sets the max time a data stream can live without frame activity, zero means no timeout.
+	WithZipperIdleTimeout = func(d time.Duration) ZipperOption {
+		return func(zo *zipperOptions) {
+			zo.serverOption = append(zo.serverOption, core.WithServerIdleTimeout(d))
+		}
+	}
+
+	// WithZipper0RTT makes the Zipper accept the 0-RTT connections, the auth verifiers must be replay-safe,
+	// see core.WithServer0RTT.
+	WithZipper0RTT = func() ZipperOption {
+		return func(zo *zipperOptions) {
+			zo.serverOption = append(zo.serverOption, core.WithServer0RTT())
+		}
+	}
+
+	// WithZipperHeartbeat sets the interval and the misses of the heartbeat of the data streams, see core.WithServerHeartbeat.
+	WithZipperHeartbeat = func(interval time.Duration, misses int) ZipperOption {
+		return func(zo *zipperOptions) {
+			zo.serverOption = append(zo.serverOption, core.WithServerHeartbeat(interval, misses))
+		}
+	}
+
+	// WithZipperScheduledDelivery enables the scheduled delivery of the DataFrames written by Source.WriteAfter,
+	// see core.WithServerScheduledDelivery.
+	WithZipperScheduledDelivery = func(maxFrames int) ZipperOption {
+		return func(zo *zipperOptions) {
+			zo.serverOption = append(zo.serverOption, core.WithServerScheduledDelivery(maxFrames))
+		}
+	}
+
+	// WithZipperRedelivery re-delivers the data given up by the sfn to it after the backoff, up to maxAttempts
+	// deliveries in total, before the data is dead-lettered, see core.WithServerRedelivery.
+	WithZipperRedelivery = func(maxAttempts int, backoff core.Backoff) ZipperOption {
+		return func(zo *zipperOptions) {
+			zo.serverOption = append(zo.serverOption, core.WithServerRedelivery(maxAttempts, backoff))
+		}
+	}
+
+	// WithZipperBackflowBuffer buffers the backflow to the source that has disconnected for the window,
+	// until the source reconnects, see core.WithServerBackflowBuffer.
+	WithZipperBackflowBuffer = func(window time.Duration) ZipperOption {
+		return func(zo *zipperOptions) {
+			zo.serverOption = append(zo.serverOption, core.WithServerBackflowBuffer(window))
+		}
+	}
+
+	// WithZipperBackflowFallbackTag reroutes the backflow to the source that has disconnected to the sfns
+	// observing the tag, see core.WithServerBackflowFallbackTag.
+	WithZipperBackflowFallbackTag = func(tag uint32) ZipperOption {
+		return func(zo *zipperOptions) {
+			zo.serverOption = append(zo.serverOption, core.WithServerBackflowFallbackTag(tag))
+		}
+	}
+
+	// WithZipperHealthCheckBeforeAuth makes the zipper accept the health checks before the authentication,
+	// see core.WithServerHealthCheckBeforeAuth.
+	WithZipperHealthCheckBeforeAuth = func() ZipperOption {
+		return func(zo *zipperOptions) {
+			zo.serverOption = append(zo.serverOption, core.WithServerHealthCheckBeforeAuth())
+		}
+	}
+
+	// WithZipperMaxConnectionLifetime sets the max lifetime of the connections, the sources and the sfns reconnect
+	// and authenticate again once their connections are rotated, see core.WithServerMaxConnectionLifetime.
+	WithZipperMaxConnectionLifetime = func(lifetime time.Duration, jitter float64) ZipperOption {
+		return func(zo *zipperOptions) {
+			zo.serverOption = append(zo.serverOption, core.WithServerMaxConnectionLifetime(lifetime, jitter))
+		}
+	}
+
+	// WithZipperOverloaded sets the func that reports whether the zipper is overloaded, see core.WithServerOverloaded.
+	WithZipperOverloaded = func(fn func() bool) ZipperOption {
+		return func(zo *zipperOptions) {
+			zo.serverOption = append(zo.serverOption, core.WithServerOverloaded(fn))
+		}
+	}
+
+	// WithZipperFrameMiddlewares appends the middlewares of the frames, see core.WithServerFrameMiddlewares.
+	WithZipperFrameMiddlewares = func(middlewares ...core.FrameMiddleware) ZipperOption {
+		return func(zo *zipperOptions) {
+			zo.serverOption = append(zo.serverOption, core.WithServerFrameMiddlewares(middlewares...))
+		}
+	}
+
+	// WithZipperDeadLetterTag sets the tag that the DataFrames no sfn processes are routed to.
+	WithZipperDeadLetterTag = func(tag frame.Tag) ZipperOption {
+		return func(zo *zipperOptions) {
+			zo.serverOption = append(zo.serverOption, core.WithServerDeadLetterTag(tag))
+		}
+	}
+
+	// WithZipperObserveTagAuthorizer sets the hook that authorizes the tags observed by the sfns.
+	WithZipperObserveTagAuthorizer = func(authorizer core.ObserveTagAuthorizer, policy core.ObserveTagDenyPolicy) ZipperOption {
+		return func(zo *zipperOptions) {
+			zo.serverOption = append(zo.serverOption, core.WithServerObserveTagAuthorizer(authorizer, policy))
+		}
+	}
+
+	// WithZipperTagNamespaces enforces the tag namespaces, the sources and the sfns write and observe
+	// the tags in the namespace of their connection only, see core.MetadataNamespaceKey.
+	WithZipperTagNamespaces = func() ZipperOption {
+		return func(zo *zipperOptions) {
+			zo.serverOption = append(zo.serverOption, core.WithServerTagNamespaces())
+		}
+	}
+
+	// WithZipperDispatchRouter sets the DispatchRouter that chooses the sfn streams a DataFrame is dispatched to,
+	// the default broadcasts to all the observers of the tag.
+	WithZipperDispatchRouter = func(r core.DispatchRouter) ZipperOption {
+		return func(zo *zipperOptions) {
+			zo.serverOption = append(zo.serverOption, core.WithServerDispatchRouter(r))
+		}
+	}
+
+	// WithZipperUnicastFallback sets what to do with the DataFrame unicast to the sfn stream that is gone,
+	// the default dispatches it as if it is not unicast.
+	WithZipperUnicastFallback = func(fallback core.UnicastFallback) ZipperOption {
+		return func(zo *zipperOptions) {
+			zo.serverOption = append(zo.serverOption, core.WithServerUnicastFallback(fallback))
+		}
+	}
+
+	// WithZipperObserver sets the observer of the connections, the data streams and the frames of the zipper,
+	// for example, the Prometheus collector created by metrics.New.
+	WithZipperObserver = func(observer core.ServerObserver) ZipperOption {
+		return func(zo *zipperOptions) {
+			zo.serverOption = append(zo.serverOption, core.WithServerObserver(observer))
+		}
+	}
+
+	// WithZipperVerifyAuthentication sets the function that verifies the credentials of the clients,
+	// it overrides the auth set by WithAuth.
+	WithZipperVerifyAuthentication = func(fn core.VerifyAuthenticationFunc) ZipperOption {
+		return func(zo *zipperOptions) {
+			zo.serverOption = append(zo.serverOption, core.WithServerVerifyAuthentication(fn))
+		}
+	}
+
+	// WithZipperVerifier registers the function that verifies the credentials of the clients whose name is the name,
+	// the credentials of multiple names can be verified by multiple verifiers.
+	WithZipperVerifier = func(name string, fn core.VerifyAuthenticationFunc) ZipperOption {
+		return func(zo *zipperOptions) {
+			zo.serverOption = append(zo.serverOption, core.WithServerVerifier(name, fn))
+		}
+	}
+
+	// WithZipperMaxMetadataSize sets the max size of the encoded metadata of the handshakes and the DataFrames,
+	// the default is core.DefaultMaxMetadataSize.
+	WithZipperMaxMetadataSize = func(size int) ZipperOption {
+		return func(zo *zipperOptions) {
+			zo.serverOption = append(zo.serverOption, core.WithServerMaxMetadataSize(size))
+		}
+	}
+
+	// WithZipperListener adds an extra listener to the zipper, such as a core.SessionListener
+	// that accepts WebTransport sessions.
+	WithZipperListener = func(ln core.Listener) ZipperOption {
+		return func(zo *zipperOptions) {
+			zo.serverOption = append(zo.serverOption, core.WithServerListener(ln))
+		}
+	}
+
+	// WithZipperALPN sets the application protocols negotiated by the zipper.
+	WithZipperALPN = func(protos ...string) ZipperOption {
+		return func(zo *zipperOptions) {
+			zo.serverOption = append(zo.serverOption, core.WithServerALPN(protos...))
+		}
+	}
+
 	// WithZipperQuicConfig sets the QUIC configuration for the zipper.
 	WithZipperQuicConfig = func(qc *quic.Config) ZipperOption {
 		return func(zo *zipperOptions) {
@@ -104,6 +398,20 @@ var (
 		}
 	}
 
+	// WithZipperPanicHandler sets the handler that will be called when a stream handler of the zipper panics.
+	WithZipperPanicHandler = func(h core.PanicHandler) ZipperOption {
+		return func(o *zipperOptions) {
+			o.serverOption = append(o.serverOption, core.WithServerPanicHandler(h))
+		}
+	}
+
+	// WithZipperMetadataEncoding sets the encoding of the metadata re-encoded by the zipper.
+	WithZipperMetadataEncoding = func(enc metadata.Encoding) ZipperOption {
+		return func(o *zipperOptions) {
+			o.serverOption = append(o.serverOption, core.WithServerMetadataEncoding(enc))
+		}
+	}
+
 	// WithZipperTracerProvider sets tracer provider for the zipper.
 	WithZipperTracerProvider = func(tp trace.TracerProvider) ZipperOption {
 		return func(o *zipperOptions) {
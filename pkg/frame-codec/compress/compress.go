@@ -0,0 +1,315 @@
+// Package compress provides a frame.Codec wrapper that compresses the payload of frames.
+package compress
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/yomorun/yomo/core/frame"
+	"github.com/yomorun/yomo/pkg/frame-codec/y3codec"
+)
+
+// Algorithm is the compression algorithm, it is written as the first byte of the compressed payload.
+type Algorithm byte
+
+const (
+	// None means the payload is not compressed.
+	None Algorithm = 0x00
+	// Gzip compresses the payload with gzip.
+	Gzip Algorithm = 0x01
+	// Zstd compresses the payload with zstd.
+	Zstd Algorithm = 0x02
+	// ZstdDict compresses the payload with zstd and the shared dictionary, see WithDictionary.
+	// It is not chosen as the algorithm of the codec, the codec that has a dictionary uses it by itself.
+	ZstdDict Algorithm = 0x03
+)
+
+// String returns the name of the algorithm.
+func (a Algorithm) String() string {
+	switch a {
+	case None:
+		return "none"
+	case Gzip:
+		return "gzip"
+	case Zstd:
+		return "zstd"
+	case ZstdDict:
+		return "zstd-dict"
+	default:
+		return fmt.Sprintf("unknown(%d)", a)
+	}
+}
+
+// DefaultThreshold is the default payload size threshold, payloads smaller than it are not compressed.
+const DefaultThreshold = 1024
+
+// ErrUnknownAlgorithm is returned when the algorithm marker of the payload is unknown.
+var ErrUnknownAlgorithm = errors.New("compress: unknown algorithm")
+
+// CompressionCodec wraps a frame.Codec and compresses the Payload of DataFrame and the Carriage of BackflowFrame.
+// The compressed field is always prefixed with a one-byte Algorithm marker, fields smaller than the threshold
+// are prefixed with None and left uncompressed. The Metadata of DataFrame is never compressed,
+// so that the zipper can route frames cheaply.
+//
+// Both sides of a connection must use the CompressionCodec, the algorithm of the encoder side
+// is detected from the marker on the decoder side.
+type CompressionCodec struct {
+	codec     frame.Codec
+	algorithm Algorithm
+	threshold int
+	// maxSize is the max size of a payload decompressed.
+	maxSize int
+
+	gzipWriters sync.Pool
+	zstdEncoder *zstd.Encoder
+	zstdDecoder *zstd.Decoder
+
+	// dictionaries are the ones loaded, the current one is used to compress.
+	dictionaries []Dictionary
+	dictionary   atomic.Pointer[zstdDictionary]
+	dictDecoder  atomic.Pointer[zstd.Decoder]
+	mu           sync.Mutex
+}
+
+var (
+	_ frame.CompressionEncoder = (*CompressionCodec)(nil)
+	_ frame.DictionaryCodec    = (*CompressionCodec)(nil)
+)
+
+// Option is the option of the CompressionCodec.
+type Option func(*CompressionCodec)
+
+// WithDictionary makes the codec compress the payloads with the zstd dictionary, the dictionary compresses the
+// small payloads those are below the threshold as well, it improves the ratio of the small but repetitive payloads a lot.
+// The dictionary loaded last is the current one, the others are kept to decompress the payloads of the old versions.
+func WithDictionary(d Dictionary) Option {
+	return func(c *CompressionCodec) {
+		c.dictionaries = append(c.dictionaries, d)
+	}
+}
+
+// WithMaxDecompressedSize sets the max size of a payload decompressed, the default is y3codec.DefaultMaxFrameSize.
+// The payload that exceeds it fails the Decode with a *y3codec.FrameTooLargeError, so a small payload
+// can not be inflated to exhaust the memory. A non-positive size will be ignored.
+func WithMaxDecompressedSize(size int) Option {
+	return func(c *CompressionCodec) {
+		if size > 0 {
+			c.maxSize = size
+		}
+	}
+}
+
+// NewCompressionCodec returns a CompressionCodec that wraps the codec,
+// it compresses the payloads whose size is not less than threshold with the algorithm.
+// If threshold <= 0, the DefaultThreshold is used.
+func NewCompressionCodec(codec frame.Codec, algorithm Algorithm, threshold int, opts ...Option) (*CompressionCodec, error) {
+	switch algorithm {
+	case None, Gzip, Zstd:
+	default:
+		return nil, ErrUnknownAlgorithm
+	}
+	if threshold <= 0 {
+		threshold = DefaultThreshold
+	}
+
+	c := &CompressionCodec{
+		codec:     codec,
+		algorithm: algorithm,
+		threshold: threshold,
+		maxSize:   y3codec.DefaultMaxFrameSize,
+	}
+	for _, o := range opts {
+		o(c)
+	}
+
+	zstdEncoder, err := zstd.NewWriter(nil)
+	if err != nil {
+		return nil, err
+	}
+	zstdDecoder, err := zstd.NewReader(nil, c.decoderOptions()...)
+	if err != nil {
+		return nil, err
+	}
+	c.zstdEncoder = zstdEncoder
+	c.zstdDecoder = zstdDecoder
+
+	dictionaries := c.dictionaries
+	c.dictionaries = nil
+	for _, d := range dictionaries {
+		if err := c.loadDictionary(d); err != nil {
+			return nil, err
+		}
+	}
+
+	return c, nil
+}
+
+// Encode compresses the payload of the frame and encodes the frame by the wrapped codec.
+// The frame passed in is not modified.
+func (c *CompressionCodec) Encode(f frame.Frame) ([]byte, error) {
+	b, _, _, err := c.EncodeCompressed(f)
+	return b, err
+}
+
+// EncodeCompressed encodes the frame like Encode, and reports the size of the payload before and after the compression.
+func (c *CompressionCodec) EncodeCompressed(f frame.Frame) ([]byte, int, int, error) {
+	return c.encodeCompressed(f, c.dictionary.Load())
+}
+
+func (c *CompressionCodec) encodeCompressed(f frame.Frame, dict *zstdDictionary) ([]byte, int, int, error) {
+	switch ff := f.(type) {
+	case *frame.DataFrame:
+		payload, err := c.compress(ff.Payload, dict)
+		if err != nil {
+			return nil, 0, 0, err
+		}
+		copied := *ff
+		copied.Payload = payload
+		b, err := c.codec.Encode(&copied)
+		return b, len(ff.Payload), len(payload), err
+	case *frame.BackflowFrame:
+		carriage, err := c.compress(ff.Carriage, dict)
+		if err != nil {
+			return nil, 0, 0, err
+		}
+		copied := *ff
+		copied.Carriage = carriage
+		b, err := c.codec.Encode(&copied)
+		return b, len(ff.Carriage), len(carriage), err
+	default:
+		b, err := c.codec.Encode(f)
+		return b, 0, 0, err
+	}
+}
+
+// Decode decodes the frame by the wrapped codec and decompresses the payload of the frame.
+func (c *CompressionCodec) Decode(data []byte, f frame.Frame) error {
+	if err := c.codec.Decode(data, f); err != nil {
+		return err
+	}
+
+	var err error
+	switch ff := f.(type) {
+	case *frame.DataFrame:
+		ff.Payload, err = c.decompress(ff.Payload)
+	case *frame.BackflowFrame:
+		ff.Carriage, err = c.decompress(ff.Carriage)
+	}
+	if errors.Is(err, errDecompressedTooLarge) {
+		// the decompression stops once the max size is exceeded, the size is unknown beyond it.
+		return &y3codec.FrameTooLargeError{Type: f.Type(), Size: c.maxSize + 1, MaxSize: c.maxSize}
+	}
+	return err
+}
+
+// errDecompressedTooLarge is returned by decompress when the payload decompressed exceeds the max size.
+var errDecompressedTooLarge = errors.New("compress: decompressed payload too large")
+
+// decoderOptions returns the options of the zstd decoders, the size decoded is limited by the max size.
+func (c *CompressionCodec) decoderOptions(opts ...zstd.DOption) []zstd.DOption {
+	return append(opts, zstd.WithDecoderMaxMemory(uint64(c.maxSize)))
+}
+
+func (c *CompressionCodec) compress(data []byte, dict *zstdDictionary) ([]byte, error) {
+	// the dictionary compresses the small payloads as well, they are left uncompressed if it does not pay off.
+	if dict != nil && len(data) > 0 && (c.algorithm == Zstd || c.algorithm != None && len(data) < c.threshold) {
+		dst := make([]byte, 1, len(data)/2+1)
+		dst[0] = byte(ZstdDict)
+		if dst = dict.encoder.EncodeAll(data, dst); len(dst) <= len(data) {
+			return dst, nil
+		}
+		return uncompressed(data), nil
+	}
+
+	algorithm := c.algorithm
+	if len(data) < c.threshold {
+		algorithm = None
+	}
+
+	switch algorithm {
+	case Gzip:
+		buf := bytes.NewBuffer(make([]byte, 0, len(data)/2))
+		buf.WriteByte(byte(Gzip))
+
+		w, ok := c.gzipWriters.Get().(*gzip.Writer)
+		if ok {
+			w.Reset(buf)
+		} else {
+			w = gzip.NewWriter(buf)
+		}
+		defer c.gzipWriters.Put(w)
+
+		if _, err := w.Write(data); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	case Zstd:
+		dst := make([]byte, 1, len(data)/2)
+		dst[0] = byte(Zstd)
+		return c.zstdEncoder.EncodeAll(data, dst), nil
+	default:
+		return uncompressed(data), nil
+	}
+}
+
+// uncompressed returns the data prefixed with None.
+func uncompressed(data []byte) []byte {
+	dst := make([]byte, len(data)+1)
+	dst[0] = byte(None)
+	copy(dst[1:], data)
+	return dst
+}
+
+func (c *CompressionCodec) decompress(data []byte) ([]byte, error) {
+	if len(data) == 0 {
+		return data, nil
+	}
+
+	switch Algorithm(data[0]) {
+	case None:
+		return data[1:], nil
+	case Gzip:
+		r, err := gzip.NewReader(bytes.NewReader(data[1:]))
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		// one more byte than the max size is read, so the payload of exactly the max size is allowed.
+		b, err := io.ReadAll(io.LimitReader(r, int64(c.maxSize)+1))
+		if err == nil && len(b) > c.maxSize {
+			return nil, errDecompressedTooLarge
+		}
+		return b, err
+	case Zstd:
+		return zstdDecoded(c.zstdDecoder.DecodeAll(data[1:], nil))
+	case ZstdDict:
+		decoder := c.dictDecoder.Load()
+		if decoder == nil {
+			return nil, ErrUnknownDictionary
+		}
+		b, err := zstdDecoded(decoder.DecodeAll(data[1:], nil))
+		if errors.Is(err, zstd.ErrUnknownDictionary) {
+			return nil, ErrUnknownDictionary
+		}
+		return b, err
+	default:
+		return nil, ErrUnknownAlgorithm
+	}
+}
+
+// zstdDecoded maps the error of the zstd decoder that exceeds the max size to errDecompressedTooLarge.
+func zstdDecoded(b []byte, err error) ([]byte, error) {
+	if errors.Is(err, zstd.ErrDecoderSizeExceeded) {
+		return nil, errDecompressedTooLarge
+	}
+	return b, err
+}
@@ -0,0 +1,181 @@
+package compress
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yomorun/yomo/core/frame"
+	"github.com/yomorun/yomo/pkg/frame-codec/y3codec"
+)
+
+func TestCompressionCodec(t *testing.T) {
+	var (
+		small = []byte("yomo")
+		large = bytes.Repeat([]byte(`{"key":"value"}`), 1000)
+	)
+
+	for _, algorithm := range []Algorithm{None, Gzip, Zstd} {
+		codec, err := NewCompressionCodec(y3codec.Codec(), algorithm, 0)
+		assert.NoError(t, err)
+
+		t.Run(algorithm.String(), func(t *testing.T) {
+			for _, payload := range [][]byte{small, large} {
+				df := &frame.DataFrame{Tag: 1, Metadata: []byte("metadata"), Payload: payload}
+
+				b, err := codec.Encode(df)
+				assert.NoError(t, err)
+				// the frame passed in must not be modified.
+				assert.Equal(t, payload, df.Payload)
+				if algorithm != None && len(payload) >= DefaultThreshold {
+					assert.Less(t, len(b), len(payload))
+				}
+
+				got := new(frame.DataFrame)
+				assert.NoError(t, codec.Decode(b, got))
+				assert.Equal(t, df, got)
+			}
+
+			bf := &frame.BackflowFrame{Tag: 1, Carriage: large}
+			b, err := codec.Encode(bf)
+			assert.NoError(t, err)
+
+			got := new(frame.BackflowFrame)
+			assert.NoError(t, codec.Decode(b, got))
+			assert.Equal(t, bf, got)
+		})
+	}
+}
+
+func TestMaxDecompressedSize(t *testing.T) {
+	const maxSize = 4096
+
+	// the payloads of zeros are inflated a lot by the decompression.
+	bomb := make([]byte, 1<<20)
+	dict := Dictionary{ID: 1, Content: make([]byte, 64)}
+
+	for _, algorithm := range []Algorithm{Gzip, Zstd, ZstdDict} {
+		t.Run(algorithm.String(), func(t *testing.T) {
+			var opts []Option
+			if algorithm == ZstdDict {
+				algorithm, opts = Zstd, append(opts, WithDictionary(dict))
+			}
+			encoder, err := NewCompressionCodec(y3codec.Codec(), algorithm, 0, opts...)
+			assert.NoError(t, err)
+			decoder, err := NewCompressionCodec(y3codec.Codec(), algorithm, 0, append(opts, WithMaxDecompressedSize(maxSize))...)
+			assert.NoError(t, err)
+
+			b, err := encoder.Encode(&frame.DataFrame{Tag: 1, Payload: bomb})
+			assert.NoError(t, err)
+			assert.Less(t, len(b), maxSize)
+
+			err = decoder.Decode(b, new(frame.DataFrame))
+			assert.ErrorIs(t, err, y3codec.ErrFrameTooLarge)
+			var tooLarge *y3codec.FrameTooLargeError
+			assert.ErrorAs(t, err, &tooLarge)
+			assert.Equal(t, frame.TypeDataFrame, tooLarge.Type)
+			assert.Equal(t, maxSize, tooLarge.MaxSize)
+
+			// the payload of the max size is decoded.
+			df := &frame.DataFrame{Tag: 1, Payload: bomb[:maxSize]}
+			b, err = encoder.Encode(df)
+			assert.NoError(t, err)
+			got := new(frame.DataFrame)
+			assert.NoError(t, decoder.Decode(b, got))
+			assert.Equal(t, df, got)
+		})
+	}
+}
+
+func TestUnknownAlgorithm(t *testing.T) {
+	_, err := NewCompressionCodec(y3codec.Codec(), Algorithm(0x7F), 0)
+	assert.ErrorIs(t, err, ErrUnknownAlgorithm)
+
+	codec, err := NewCompressionCodec(y3codec.Codec(), Zstd, 0)
+	assert.NoError(t, err)
+
+	b, err := y3codec.Codec().Encode(&frame.DataFrame{Tag: 1, Payload: []byte{0x7F, 'a'}})
+	assert.NoError(t, err)
+
+	err = codec.Decode(b, new(frame.DataFrame))
+	assert.ErrorIs(t, err, ErrUnknownAlgorithm)
+}
+
+func TestDictionary(t *testing.T) {
+	telemetry := func(i int) []byte {
+		return []byte(fmt.Sprintf(`{"device":"sensor-%d","temperature":%d,"humidity":%d,"unit":"celsius"}`, i%8, 20+i%10, 40+i%20))
+	}
+	samples := make([][]byte, 64)
+	for i := range samples {
+		samples[i] = telemetry(i)
+	}
+	dict := SampleDictionary(1, samples, 4096)
+
+	server, err := NewCompressionCodec(y3codec.Codec(), Zstd, 0, WithDictionary(dict))
+	assert.NoError(t, err)
+	plain, err := NewCompressionCodec(y3codec.Codec(), Zstd, 0)
+	assert.NoError(t, err)
+
+	df := &frame.DataFrame{Tag: 1, Payload: telemetry(101)}
+
+	// the small payload is compressed with the dictionary.
+	b, original, compressed, err := server.EncodeCompressed(df)
+	assert.NoError(t, err)
+	assert.Equal(t, len(df.Payload), original)
+	assert.Less(t, compressed, original/2)
+
+	got := new(frame.DataFrame)
+	assert.NoError(t, server.Decode(b, got))
+	assert.Equal(t, df, got)
+
+	// the client without the dictionary can't decode it until the dictionary is shipped.
+	assert.ErrorIs(t, plain.Decode(b, new(frame.DataFrame)), ErrUnknownDictionary)
+
+	shipped := server.Dictionary()
+	loaded, err := plain.CloneWithDictionary(shipped)
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(1), loaded.DictionaryID())
+	got = new(frame.DataFrame)
+	assert.NoError(t, loaded.Decode(b, got))
+	assert.Equal(t, df, got)
+
+	// the dictionary is loaded to the copy only, the codec shared by other connections is not changed.
+	assert.Equal(t, uint32(0), plain.DictionaryID())
+	assert.ErrorIs(t, plain.Decode(b, new(frame.DataFrame)), ErrUnknownDictionary)
+
+	// the peer of another version falls back to the compression without the dictionary.
+	b, _, _, err = server.PeerCodec(2).(frame.CompressionEncoder).EncodeCompressed(df)
+	assert.NoError(t, err)
+	fresh, err := NewCompressionCodec(y3codec.Codec(), Zstd, 0)
+	assert.NoError(t, err)
+	got = new(frame.DataFrame)
+	assert.NoError(t, fresh.Decode(b, got))
+	assert.Equal(t, df, got)
+
+	// the old versions are still decoded after the new version is loaded.
+	old, err := server.PeerCodec(1).Encode(df)
+	assert.NoError(t, err)
+	assert.NoError(t, server.loadDictionary(SampleDictionary(2, samples[:8], 4096)))
+	assert.Equal(t, uint32(2), server.DictionaryID())
+	got = new(frame.DataFrame)
+	assert.NoError(t, server.Decode(old, got))
+	assert.Equal(t, df, got)
+}
+
+func TestDictionaryBinary(t *testing.T) {
+	b, err := Dictionary{ID: 7, Content: []byte("content")}.MarshalBinary()
+	assert.NoError(t, err)
+
+	var d Dictionary
+	assert.NoError(t, d.UnmarshalBinary(b))
+	assert.Equal(t, Dictionary{ID: 7, Content: []byte("content")}, d)
+
+	_, err = Dictionary{Content: []byte("content")}.MarshalBinary()
+	assert.ErrorIs(t, err, ErrInvalidDictionary)
+	assert.ErrorIs(t, d.UnmarshalBinary([]byte{0, 0}), ErrInvalidDictionary)
+
+	// the latest distinct samples are picked up to the size.
+	dict := SampleDictionary(1, [][]byte{[]byte("aa"), []byte("bb"), []byte("cc"), []byte("cc")}, 4)
+	assert.Equal(t, []byte("bbcc"), dict.Content)
+}
@@ -0,0 +1,206 @@
+package compress
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"os"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/yomorun/yomo/core/frame"
+)
+
+var (
+	// ErrInvalidDictionary is returned when the dictionary has no version or it is malformed.
+	ErrInvalidDictionary = errors.New("compress: invalid dictionary")
+	// ErrUnknownDictionary is returned when the payload is compressed with a dictionary the codec does not have.
+	ErrUnknownDictionary = errors.New("compress: unknown dictionary")
+)
+
+// Dictionary is the zstd dictionary shared by the client and the server. The Content is the raw content
+// that zstd finds the matches in, the samples of the payloads make a good one, see SampleDictionary.
+// The ID is the version of the dictionary, it must not be zero.
+type Dictionary struct {
+	ID      uint32
+	Content []byte
+}
+
+// MarshalBinary encodes the dictionary to be shipped to the peer, it is the 4-byte ID in big endian
+// followed by the content.
+func (d Dictionary) MarshalBinary() ([]byte, error) {
+	if d.ID == 0 {
+		return nil, ErrInvalidDictionary
+	}
+	b := make([]byte, 4+len(d.Content))
+	binary.BigEndian.PutUint32(b, d.ID)
+	copy(b[4:], d.Content)
+	return b, nil
+}
+
+// UnmarshalBinary decodes the dictionary encoded by MarshalBinary.
+func (d *Dictionary) UnmarshalBinary(b []byte) error {
+	if len(b) < 4 || binary.BigEndian.Uint32(b) == 0 {
+		return ErrInvalidDictionary
+	}
+	d.ID = binary.BigEndian.Uint32(b)
+	d.Content = append([]byte(nil), b[4:]...)
+	return nil
+}
+
+// ReadDictionaryFile reads the content of the dictionary of the version from the file,
+// such as the one written with the content of SampleDictionary.
+func ReadDictionaryFile(id uint32, path string) (Dictionary, error) {
+	if id == 0 {
+		return Dictionary{}, ErrInvalidDictionary
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return Dictionary{}, err
+	}
+	return Dictionary{ID: id, Content: content}, nil
+}
+
+// SampleDictionary makes the raw dictionary of the version from the samples of the payloads, the content is
+// the distinct samples up to the size, the later samples are closer to the end, where zstd finds the
+// matches at the smaller offsets. The samples are not trained, it is not the entropy-tuned dictionary of
+// "zstd --train", but the small and repetitive payloads, such as telemetry, share most of their bytes with them.
+func SampleDictionary(id uint32, samples [][]byte, size int) Dictionary {
+	seen := make(map[string]bool, len(samples))
+	picked := make([][]byte, 0, len(samples))
+	n := 0
+	// the latest samples are picked first, they are the most likely to look like the coming payloads.
+	for i := len(samples) - 1; i >= 0 && n < size; i-- {
+		sample := samples[i]
+		if len(sample) == 0 || seen[string(sample)] || n+len(sample) > size {
+			continue
+		}
+		seen[string(sample)] = true
+		picked = append(picked, sample)
+		n += len(sample)
+	}
+
+	var content bytes.Buffer
+	content.Grow(n)
+	for i := len(picked) - 1; i >= 0; i-- {
+		content.Write(picked[i])
+	}
+	return Dictionary{ID: id, Content: content.Bytes()}
+}
+
+// zstdDictionary is the encoder of the dictionary.
+type zstdDictionary struct {
+	id      uint32
+	encoded []byte
+	encoder *zstd.Encoder
+}
+
+// loadDictionary makes the dictionary the current one, the decoder is rebuilt to know all the dictionaries loaded.
+func (c *CompressionCodec) loadDictionary(d Dictionary) error {
+	encoded, err := d.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	// the small payloads do not pay for the checksum, the integrity is protected by QUIC.
+	encoder, err := zstd.NewWriter(nil, zstd.WithEncoderDictRaw(d.ID, d.Content), zstd.WithEncoderCRC(false))
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	dictionaries := make([]Dictionary, 0, len(c.dictionaries)+1)
+	opts := make([]zstd.DOption, 0, len(c.dictionaries)+1)
+	for _, loaded := range c.dictionaries {
+		// the dictionary of the same version is replaced.
+		if loaded.ID != d.ID {
+			dictionaries = append(dictionaries, loaded)
+		}
+	}
+	dictionaries = append(dictionaries, d)
+	for _, loaded := range dictionaries {
+		opts = append(opts, zstd.WithDecoderDictRaw(loaded.ID, loaded.Content))
+	}
+	decoder, err := zstd.NewReader(nil, c.decoderOptions(opts...)...)
+	if err != nil {
+		return err
+	}
+
+	c.dictionaries = dictionaries
+	c.dictDecoder.Store(decoder)
+	c.dictionary.Store(&zstdDictionary{id: d.ID, encoded: encoded, encoder: encoder})
+	return nil
+}
+
+// DictionaryID returns the version of the current dictionary, zero means no dictionary.
+func (c *CompressionCodec) DictionaryID() uint32 {
+	if dict := c.dictionary.Load(); dict != nil {
+		return dict.id
+	}
+	return 0
+}
+
+// Dictionary returns the current dictionary encoded by Dictionary.MarshalBinary, it is nil if no dictionary.
+func (c *CompressionCodec) Dictionary() []byte {
+	if dict := c.dictionary.Load(); dict != nil {
+		return dict.encoded
+	}
+	return nil
+}
+
+// CloneWithDictionary returns a copy of the codec that loads the dictionary encoded by Dictionary.MarshalBinary,
+// it is the current dictionary of the copy. The codec itself is not changed, the copy shares its encoders and decoders.
+func (c *CompressionCodec) CloneWithDictionary(b []byte) (frame.DictionaryCodec, error) {
+	var d Dictionary
+	if err := d.UnmarshalBinary(b); err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	dictionaries := c.dictionaries
+	c.mu.Unlock()
+
+	clone := &CompressionCodec{
+		codec:        c.codec,
+		algorithm:    c.algorithm,
+		threshold:    c.threshold,
+		maxSize:      c.maxSize,
+		zstdEncoder:  c.zstdEncoder,
+		zstdDecoder:  c.zstdDecoder,
+		dictionaries: dictionaries,
+	}
+	// the dictionaries are copied by loadDictionary, the ones of the codec are not changed.
+	if err := clone.loadDictionary(d); err != nil {
+		return nil, err
+	}
+	return clone, nil
+}
+
+// PeerCodec returns the codec for the peer that has the dictionary of the version, it compresses the payloads
+// with the current dictionary if it is the same version, and without any dictionary otherwise.
+func (c *CompressionCodec) PeerCodec(dictionaryID uint32) frame.Codec {
+	dict := c.dictionary.Load()
+	if dict != nil && dict.id != dictionaryID {
+		dict = nil
+	}
+	return &peerCodec{CompressionCodec: c, dict: dict}
+}
+
+// peerCodec is the CompressionCodec that compresses the payloads with the dictionary of the peer.
+type peerCodec struct {
+	*CompressionCodec
+	dict *zstdDictionary
+}
+
+var _ frame.CompressionEncoder = (*peerCodec)(nil)
+
+// Encode compresses the payload of the frame and encodes the frame by the wrapped codec.
+func (c *peerCodec) Encode(f frame.Frame) ([]byte, error) {
+	b, _, _, err := c.EncodeCompressed(f)
+	return b, err
+}
+
+// EncodeCompressed encodes the frame like Encode, and reports the size of the payload before and after the compression.
+func (c *peerCodec) EncodeCompressed(f frame.Frame) ([]byte, int, int, error) {
+	return c.encodeCompressed(f, c.dict)
+}
@@ -0,0 +1,139 @@
+// Package encryption provides a frame.Codec wrapper that encrypts the payload of DataFrames end to end.
+package encryption
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+
+	"github.com/yomorun/yomo/core/frame"
+)
+
+// DefaultTenantKey is the default metadata key of the tenant id.
+const DefaultTenantKey = "tenant"
+
+var (
+	// ErrMissingTenant is returned when the tenant id is not found in the metadata of the DataFrame.
+	ErrMissingTenant = errors.New("encryption: missing tenant in metadata")
+	// ErrMalformedPayload is returned when the encrypted payload is too short to be decrypted.
+	ErrMalformedPayload = errors.New("encryption: malformed payload")
+)
+
+// KeyProvider provides the keys of the tenants, the keys are AES keys of 16, 24 or 32 bytes.
+// Every key has a version, the version is written as the first byte of the encrypted payload,
+// so the payloads encrypted by the old keys can still be decrypted after the key is rotated.
+type KeyProvider interface {
+	// CurrentKey returns the key and its version used to encrypt the payloads of the tenant.
+	CurrentKey(tenant string) (version byte, key []byte, err error)
+	// Key returns the key of the version used to decrypt the payloads of the tenant.
+	Key(tenant string, version byte) ([]byte, error)
+}
+
+// EncryptionCodec wraps a frame.Codec and encrypts the Payload of DataFrame with AES-GCM.
+// The Tag and the Metadata are left in clear so that the zipper can route frames without the keys,
+// the zipper does not need the EncryptionCodec. The key is chosen by the tenant id in the Metadata.
+//
+// The encrypted payload is laid out as: version(1 byte) | nonce(12 bytes) | ciphertext.
+type EncryptionCodec struct {
+	codec     frame.Codec
+	keys      KeyProvider
+	tenantKey string
+}
+
+var _ frame.Codec = (*EncryptionCodec)(nil)
+
+// NewEncryptionCodec returns an EncryptionCodec that wraps the codec and gets the keys from the provider,
+// the tenant id is read from the metadata by the tenantKey, if tenantKey is empty, the DefaultTenantKey is used.
+func NewEncryptionCodec(codec frame.Codec, keys KeyProvider, tenantKey string) *EncryptionCodec {
+	if tenantKey == "" {
+		tenantKey = DefaultTenantKey
+	}
+	return &EncryptionCodec{
+		codec:     codec,
+		keys:      keys,
+		tenantKey: tenantKey,
+	}
+}
+
+// Encode encrypts the payload of the DataFrame and encodes the frame by the wrapped codec.
+// The frame passed in is not modified.
+func (c *EncryptionCodec) Encode(f frame.Frame) ([]byte, error) {
+	df, ok := f.(*frame.DataFrame)
+	if !ok {
+		return c.codec.Encode(f)
+	}
+
+	tenant, ok := df.GetMetadata(c.tenantKey)
+	if !ok {
+		return nil, ErrMissingTenant
+	}
+	version, key, err := c.keys.CurrentKey(tenant)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	headerSize := 1 + aead.NonceSize()
+	payload := make([]byte, headerSize, headerSize+len(df.Payload)+aead.Overhead())
+	payload[0] = version
+	if _, err := rand.Read(payload[1:headerSize]); err != nil {
+		return nil, err
+	}
+	payload = aead.Seal(payload, payload[1:headerSize], df.Payload, nil)
+
+	copied := *df
+	copied.Payload = payload
+	return c.codec.Encode(&copied)
+}
+
+// Decode decodes the frame by the wrapped codec, then authenticates and decrypts the payload of the DataFrame.
+func (c *EncryptionCodec) Decode(data []byte, f frame.Frame) error {
+	if err := c.codec.Decode(data, f); err != nil {
+		return err
+	}
+	df, ok := f.(*frame.DataFrame)
+	if !ok {
+		return nil
+	}
+
+	tenant, ok := df.GetMetadata(c.tenantKey)
+	if !ok {
+		return ErrMissingTenant
+	}
+	if len(df.Payload) < 1 {
+		return ErrMalformedPayload
+	}
+	key, err := c.keys.Key(tenant, df.Payload[0])
+	if err != nil {
+		return err
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return err
+	}
+
+	headerSize := 1 + aead.NonceSize()
+	if len(df.Payload) < headerSize+aead.Overhead() {
+		return ErrMalformedPayload
+	}
+	payload, err := aead.Open(nil, df.Payload[1:headerSize], df.Payload[headerSize:], nil)
+	if err != nil {
+		return fmt.Errorf("encryption: decrypt payload: %w", err)
+	}
+	df.Payload = payload
+
+	return nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
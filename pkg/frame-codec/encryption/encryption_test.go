@@ -0,0 +1,67 @@
+package encryption
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yomorun/yomo/core/frame"
+	"github.com/yomorun/yomo/core/metadata"
+	"github.com/yomorun/yomo/pkg/frame-codec/y3codec"
+)
+
+type testKeys struct {
+	current byte
+	keys    map[byte][]byte
+}
+
+func (k *testKeys) CurrentKey(tenant string) (byte, []byte, error) {
+	return k.current, k.keys[k.current], nil
+}
+
+func (k *testKeys) Key(tenant string, version byte) ([]byte, error) {
+	key, ok := k.keys[version]
+	if !ok {
+		return nil, errors.New("unknown key version")
+	}
+	return key, nil
+}
+
+func TestEncryptionCodec(t *testing.T) {
+	keys := &testKeys{
+		current: 1,
+		keys:    map[byte][]byte{1: bytes.Repeat([]byte{1}, 32)},
+	}
+	codec := NewEncryptionCodec(y3codec.Codec(), keys, "")
+
+	md, err := metadata.M{DefaultTenantKey: "t1"}.Encode()
+	assert.NoError(t, err)
+
+	df := &frame.DataFrame{Tag: 1, Metadata: md, Payload: []byte("yomo")}
+	b, err := codec.Encode(df)
+	assert.NoError(t, err)
+	// the frame passed in must not be modified, and the payload is not seen by the zipper.
+	assert.Equal(t, []byte("yomo"), df.Payload)
+	assert.False(t, bytes.Contains(b, []byte("yomo")))
+
+	zipperSide := new(frame.DataFrame)
+	assert.NoError(t, y3codec.Codec().Decode(b, zipperSide))
+	assert.Equal(t, df.Tag, zipperSide.Tag)
+	assert.Equal(t, df.Metadata, zipperSide.Metadata)
+
+	// rotate the key, the payload encrypted by the old key can still be decrypted.
+	keys.keys[2] = bytes.Repeat([]byte{2}, 32)
+	keys.current = 2
+
+	got := new(frame.DataFrame)
+	assert.NoError(t, codec.Decode(b, got))
+	assert.Equal(t, []byte("yomo"), got.Payload)
+
+	// tampered payload fails to authenticate.
+	b[len(b)-1] ^= 0xff
+	assert.Error(t, codec.Decode(b, new(frame.DataFrame)))
+
+	_, err = codec.Encode(&frame.DataFrame{Tag: 1, Payload: []byte("yomo")})
+	assert.ErrorIs(t, err, ErrMissingTenant)
+}
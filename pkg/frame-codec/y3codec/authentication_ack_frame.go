@@ -9,6 +9,23 @@ import (
 func encodeAuthenticationAckFrame(f *frame.AuthenticationAckFrame) ([]byte, error) {
 	// frame
 	ack := y3.NewNodePacketEncoder(byte(f.Type()))
+	// version
+	if f.Version != 0 {
+		versionBlock := y3.NewPrimitivePacketEncoder(tagAuthenticationAckVersion)
+		versionBlock.SetBytesValue([]byte{byte(f.Version)})
+		ack.AddPrimitivePacket(versionBlock)
+	}
+	// dictionary
+	if f.DictionaryID != 0 {
+		dictionaryIDBlock := y3.NewPrimitivePacketEncoder(tagAuthenticationAckDictionaryID)
+		dictionaryIDBlock.SetUInt32Value(f.DictionaryID)
+		ack.AddPrimitivePacket(dictionaryIDBlock)
+	}
+	if len(f.Dictionary) > 0 {
+		dictionaryBlock := y3.NewPrimitivePacketEncoder(tagAuthenticationAckDictionary)
+		dictionaryBlock.SetBytesValue(f.Dictionary)
+		ack.AddPrimitivePacket(dictionaryBlock)
+	}
 
 	return ack.Encode(), nil
 }
@@ -20,5 +37,28 @@ func decodeAuthenticationAckFrame(data []byte, f *frame.AuthenticationAckFrame)
 	if err != nil {
 		return err
 	}
+	// version
+	if versionBlock, ok := node.PrimitivePackets[tagAuthenticationAckVersion]; ok {
+		if b := versionBlock.ToBytes(); len(b) > 0 {
+			f.Version = frame.Version(b[0])
+		}
+	}
+	// dictionary
+	if dictionaryIDBlock, ok := node.PrimitivePackets[tagAuthenticationAckDictionaryID]; ok {
+		id, err := dictionaryIDBlock.ToUInt32()
+		if err != nil {
+			return err
+		}
+		f.DictionaryID = id
+	}
+	if dictionaryBlock, ok := node.PrimitivePackets[tagAuthenticationAckDictionary]; ok {
+		f.Dictionary = dictionaryBlock.ToBytes()
+	}
 	return nil
 }
+
+var (
+	tagAuthenticationAckVersion      byte = 0x01
+	tagAuthenticationAckDictionaryID byte = 0x02
+	tagAuthenticationAckDictionary   byte = 0x03
+)
@@ -3,6 +3,7 @@ package y3codec
 import (
 	"github.com/yomorun/y3"
 	frame "github.com/yomorun/yomo/core/frame"
+	"github.com/yomorun/yomo/core/metadata"
 )
 
 // encodeAuthenticationFrame encodes AuthenticationFrame to bytes in Y3 codec.
@@ -11,11 +12,39 @@ func encodeAuthenticationFrame(f *frame.AuthenticationFrame) ([]byte, error) {
 	authNameBlock := y3.NewPrimitivePacketEncoder(tagAuthenticationName)
 	authNameBlock.SetStringValue(f.AuthName)
 	authPayloadBlock := y3.NewPrimitivePacketEncoder(tagAuthenticationPayload)
-	authPayloadBlock.SetStringValue(f.AuthPayload)
+	authPayloadBlock.SetBytesValue(f.AuthPayload)
 	// authentication frame
 	authentication := y3.NewNodePacketEncoder(byte(f.Type()))
 	authentication.AddPrimitivePacket(authNameBlock)
 	authentication.AddPrimitivePacket(authPayloadBlock)
+	// versions
+	if len(f.Versions) > 0 {
+		versions := make([]byte, len(f.Versions))
+		for i, v := range f.Versions {
+			versions[i] = byte(v)
+		}
+		versionsBlock := y3.NewPrimitivePacketEncoder(tagAuthenticationVersions)
+		versionsBlock.SetBytesValue(versions)
+		authentication.AddPrimitivePacket(versionsBlock)
+	}
+
+	// labels
+	if len(f.Labels) > 0 {
+		labels, err := metadata.M(f.Labels).Encode()
+		if err != nil {
+			return nil, err
+		}
+		labelsBlock := y3.NewPrimitivePacketEncoder(tagAuthenticationLabels)
+		labelsBlock.SetBytesValue(labels)
+		authentication.AddPrimitivePacket(labelsBlock)
+	}
+
+	// dictionary, it is present only if the client accepts the dictionaries.
+	if f.AcceptDictionary {
+		dictionaryBlock := y3.NewPrimitivePacketEncoder(tagAuthenticationDictionary)
+		dictionaryBlock.SetUInt32Value(f.DictionaryID)
+		authentication.AddPrimitivePacket(dictionaryBlock)
+	}
 
 	return authentication.Encode(), nil
 }
@@ -37,17 +66,39 @@ func decodeAuthenticationFrame(data []byte, f *frame.AuthenticationFrame) error
 	}
 	// payload
 	if authPayloadBlock, ok := node.PrimitivePackets[tagAuthenticationPayload]; ok {
-		authPayload, err := authPayloadBlock.ToUTF8String()
+		f.AuthPayload = authPayloadBlock.ToBytes()
+	}
+	// versions
+	if versionsBlock, ok := node.PrimitivePackets[tagAuthenticationVersions]; ok {
+		for _, v := range versionsBlock.ToBytes() {
+			f.Versions = append(f.Versions, frame.Version(v))
+		}
+	}
+	// labels
+	if labelsBlock, ok := node.PrimitivePackets[tagAuthenticationLabels]; ok {
+		labels, err := metadata.Decode(labelsBlock.ToBytes())
+		if err != nil {
+			return err
+		}
+		f.Labels = labels
+	}
+	// dictionary
+	if dictionaryBlock, ok := node.PrimitivePackets[tagAuthenticationDictionary]; ok {
+		id, err := dictionaryBlock.ToUInt32()
 		if err != nil {
 			return err
 		}
-		f.AuthPayload = authPayload
+		f.AcceptDictionary = true
+		f.DictionaryID = id
 	}
 
 	return nil
 }
 
 var (
-	tagAuthenticationName    byte = 0x04
-	tagAuthenticationPayload byte = 0x05
+	tagAuthenticationName       byte = 0x04
+	tagAuthenticationPayload    byte = 0x05
+	tagAuthenticationVersions   byte = 0x06
+	tagAuthenticationLabels     byte = 0x07
+	tagAuthenticationDictionary byte = 0x08
 )
@@ -0,0 +1,54 @@
+package y3codec
+
+import (
+	"github.com/yomorun/y3"
+	"github.com/yomorun/yomo/core/frame"
+)
+
+// encodeBackflowAckFrame encodes BackflowAckFrame to Y3 encoded bytes.
+func encodeBackflowAckFrame(f *frame.BackflowAckFrame) ([]byte, error) {
+	// id
+	idBlock := y3.NewPrimitivePacketEncoder(tagBackflowAckID)
+	idBlock.SetStringValue(f.ID)
+	// stream id
+	streamIDBlock := y3.NewPrimitivePacketEncoder(tagBackflowAckStreamID)
+	streamIDBlock.SetStringValue(f.StreamID)
+	// frame
+	ff := y3.NewNodePacketEncoder(byte(f.Type()))
+	ff.AddPrimitivePacket(idBlock)
+	ff.AddPrimitivePacket(streamIDBlock)
+
+	return ff.Encode(), nil
+}
+
+// decodeBackflowAckFrame decodes Y3 encoded bytes to BackflowAckFrame.
+func decodeBackflowAckFrame(data []byte, f *frame.BackflowAckFrame) error {
+	node := y3.NodePacket{}
+	_, err := y3.DecodeToNodePacket(data, &node)
+	if err != nil {
+		return err
+	}
+	// id
+	if idBlock, ok := node.PrimitivePackets[tagBackflowAckID]; ok {
+		id, err := idBlock.ToUTF8String()
+		if err != nil {
+			return err
+		}
+		f.ID = id
+	}
+	// stream id
+	if streamIDBlock, ok := node.PrimitivePackets[tagBackflowAckStreamID]; ok {
+		streamID, err := streamIDBlock.ToUTF8String()
+		if err != nil {
+			return err
+		}
+		f.StreamID = streamID
+	}
+
+	return nil
+}
+
+var (
+	tagBackflowAckID       byte = 0x01
+	tagBackflowAckStreamID byte = 0x02
+)
@@ -17,6 +17,12 @@ func encodeBackflowFrame(f *frame.BackflowFrame) ([]byte, error) {
 	node.AddPrimitivePacket(tag)
 	node.AddPrimitivePacket(carriage)
 
+	if len(f.Metadata) > 0 {
+		md := y3.NewPrimitivePacketEncoder(tagBackflowMetadata)
+		md.SetBytesValue(f.Metadata)
+		node.AddPrimitivePacket(md)
+	}
+
 	return node.Encode(), nil
 }
 
@@ -40,10 +46,15 @@ func decodeBackflowFrame(data []byte, f *frame.BackflowFrame) error {
 		f.Carriage = p.GetValBuf()
 	}
 
+	if p, ok := nodeBlock.PrimitivePackets[tagBackflowMetadata]; ok {
+		f.Metadata = p.GetValBuf()
+	}
+
 	return nil
 }
 
 var (
 	tagBackflowDataTag  byte = 0x01
 	tagBackflowCarriage byte = 0x02
+	tagBackflowMetadata byte = 0x03
 )
@@ -0,0 +1,83 @@
+package y3codec
+
+import (
+	"encoding/binary"
+	"errors"
+
+	"github.com/yomorun/y3"
+	frame "github.com/yomorun/yomo/core/frame"
+)
+
+// errMalformedBatch is returned when the entries of BatchDataFrame can not be decoded.
+var errMalformedBatch = errors.New("y3codec: malformed batch entries")
+
+// encodeBatchDataFrame encodes BatchDataFrame to Y3 encoded bytes.
+// The entries are packed in a single primitive packet, every entry is
+// a 4 bytes little endian tag, an uvarint payload length and the payload.
+func encodeBatchDataFrame(f *frame.BatchDataFrame) ([]byte, error) {
+	// metadata
+	metadataBlock := y3.NewPrimitivePacketEncoder(tagBatchMetadata)
+	metadataBlock.SetBytesValue(f.Metadata)
+	// entries
+	size := 0
+	for _, entry := range f.Entries {
+		size += 4 + binary.MaxVarintLen64 + len(entry.Payload)
+	}
+	buf := make([]byte, 0, size)
+	for _, entry := range f.Entries {
+		buf = binary.LittleEndian.AppendUint32(buf, uint32(entry.Tag))
+		buf = binary.AppendUvarint(buf, uint64(len(entry.Payload)))
+		buf = append(buf, entry.Payload...)
+	}
+	entriesBlock := y3.NewPrimitivePacketEncoder(tagBatchEntries)
+	entriesBlock.SetBytesValue(buf)
+	// frame
+	node := y3.NewNodePacketEncoder(byte(f.Type()))
+	node.AddPrimitivePacket(metadataBlock)
+	node.AddPrimitivePacket(entriesBlock)
+
+	return node.Encode(), nil
+}
+
+// decodeBatchDataFrame decodes Y3 encoded bytes to BatchDataFrame.
+func decodeBatchDataFrame(data []byte, f *frame.BatchDataFrame) error {
+	node := y3.NodePacket{}
+	_, err := y3.DecodeToNodePacket(data, &node)
+	if err != nil {
+		return err
+	}
+	// metadata
+	if metadataBlock, ok := node.PrimitivePackets[tagBatchMetadata]; ok {
+		f.Metadata = metadataBlock.ToBytes()
+	}
+	// entries
+	if entriesBlock, ok := node.PrimitivePackets[tagBatchEntries]; ok {
+		buf := entriesBlock.GetValBuf()
+		for len(buf) > 0 {
+			if len(buf) < 4 {
+				return errMalformedBatch
+			}
+			tag := binary.LittleEndian.Uint32(buf)
+			buf = buf[4:]
+
+			length, n := binary.Uvarint(buf)
+			if n <= 0 || uint64(len(buf)-n) < length {
+				return errMalformedBatch
+			}
+			buf = buf[n:]
+
+			f.Entries = append(f.Entries, frame.BatchEntry{
+				Tag:     frame.Tag(tag),
+				Payload: buf[:length:length],
+			})
+			buf = buf[length:]
+		}
+	}
+
+	return nil
+}
+
+var (
+	tagBatchMetadata byte = 0x01
+	tagBatchEntries  byte = 0x02
+)
@@ -0,0 +1,45 @@
+package y3codec
+
+import "sync"
+
+// DefaultMaxPooledBufferSize is the default max capacity of a buffer that can be returned to the BufferPool.
+const DefaultMaxPooledBufferSize = 64 << 10
+
+// BufferPool is a sync.Pool backed pool of packet buffers.
+//
+// A buffer got from the pool is owned by the caller until it is returned by Free,
+// the frames decoded from the buffer share its memory, so the buffer must not be freed
+// until the frames are fully consumed, and the frames must not be retained after that.
+type BufferPool struct {
+	maxSize int
+	pool    sync.Pool
+}
+
+// NewBufferPool returns a BufferPool, the buffers larger than maxSize are not pooled,
+// a non-positive maxSize means DefaultMaxPooledBufferSize.
+func NewBufferPool(maxSize int) *BufferPool {
+	if maxSize <= 0 {
+		maxSize = DefaultMaxPooledBufferSize
+	}
+	return &BufferPool{maxSize: maxSize}
+}
+
+// Get returns a buffer with the length of size.
+func (p *BufferPool) Get(size int) []byte {
+	if v := p.pool.Get(); v != nil {
+		if b := *(v.(*[]byte)); cap(b) >= size {
+			return b[:size]
+		}
+		p.pool.Put(v)
+	}
+	return make([]byte, size)
+}
+
+// Free returns the buffer to the pool, the buffer must not be used after Free.
+func (p *BufferPool) Free(b []byte) {
+	if cap(b) == 0 || cap(b) > p.maxSize {
+		return
+	}
+	b = b[:0]
+	p.pool.Put(&b)
+}
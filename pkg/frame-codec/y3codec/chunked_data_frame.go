@@ -0,0 +1,98 @@
+package y3codec
+
+import (
+	"github.com/yomorun/y3"
+	frame "github.com/yomorun/yomo/core/frame"
+)
+
+// encodeChunkedDataFrame encodes ChunkedDataFrame to Y3 encoded bytes.
+func encodeChunkedDataFrame(f *frame.ChunkedDataFrame) ([]byte, error) {
+	// metadata
+	metadataBlock := y3.NewPrimitivePacketEncoder(tagChunkedDataFrameMetadata)
+	metadataBlock.SetBytesValue(f.Metadata)
+	// tag
+	tagBlock := y3.NewPrimitivePacketEncoder(tagChunkedDataFrameTag)
+	tagBlock.SetUInt32Value(f.Tag)
+	// transfer id
+	transferIDBlock := y3.NewPrimitivePacketEncoder(tagChunkedDataFrameTransferID)
+	transferIDBlock.SetStringValue(f.TransferID)
+	// index
+	indexBlock := y3.NewPrimitivePacketEncoder(tagChunkedDataFrameIndex)
+	indexBlock.SetUInt32Value(f.Index)
+	// last
+	lastBlock := y3.NewPrimitivePacketEncoder(tagChunkedDataFrameLast)
+	lastBlock.SetBoolValue(f.Last)
+	// payload
+	payloadBlock := y3.NewPrimitivePacketEncoder(tagChunkedDataFramePayload)
+	payloadBlock.SetBytesValue(f.Payload)
+	// frame
+	ff := y3.NewNodePacketEncoder(byte(f.Type()))
+	ff.AddPrimitivePacket(metadataBlock)
+	ff.AddPrimitivePacket(tagBlock)
+	ff.AddPrimitivePacket(transferIDBlock)
+	ff.AddPrimitivePacket(indexBlock)
+	ff.AddPrimitivePacket(lastBlock)
+	ff.AddPrimitivePacket(payloadBlock)
+
+	return ff.Encode(), nil
+}
+
+// decodeChunkedDataFrame decodes Y3 encoded bytes to ChunkedDataFrame.
+func decodeChunkedDataFrame(data []byte, f *frame.ChunkedDataFrame) error {
+	node := y3.NodePacket{}
+	_, err := y3.DecodeToNodePacket(data, &node)
+	if err != nil {
+		return err
+	}
+	// metadata
+	if metadataBlock, ok := node.PrimitivePackets[tagChunkedDataFrameMetadata]; ok {
+		f.Metadata = metadataBlock.ToBytes()
+	}
+	// tag
+	if tagBlock, ok := node.PrimitivePackets[tagChunkedDataFrameTag]; ok {
+		tag, err := tagBlock.ToUInt32()
+		if err != nil {
+			return err
+		}
+		f.Tag = tag
+	}
+	// transfer id
+	if transferIDBlock, ok := node.PrimitivePackets[tagChunkedDataFrameTransferID]; ok {
+		transferID, err := transferIDBlock.ToUTF8String()
+		if err != nil {
+			return err
+		}
+		f.TransferID = transferID
+	}
+	// index
+	if indexBlock, ok := node.PrimitivePackets[tagChunkedDataFrameIndex]; ok {
+		index, err := indexBlock.ToUInt32()
+		if err != nil {
+			return err
+		}
+		f.Index = index
+	}
+	// last
+	if lastBlock, ok := node.PrimitivePackets[tagChunkedDataFrameLast]; ok {
+		last, err := lastBlock.ToBool()
+		if err != nil {
+			return err
+		}
+		f.Last = last
+	}
+	// payload
+	if payloadBlock, ok := node.PrimitivePackets[tagChunkedDataFramePayload]; ok {
+		f.Payload = payloadBlock.ToBytes()
+	}
+
+	return nil
+}
+
+var (
+	tagChunkedDataFrameMetadata   byte = 0x01
+	tagChunkedDataFrameTag        byte = 0x02
+	tagChunkedDataFrameTransferID byte = 0x03
+	tagChunkedDataFrameIndex      byte = 0x04
+	tagChunkedDataFrameLast       byte = 0x05
+	tagChunkedDataFramePayload    byte = 0x06
+)
@@ -0,0 +1,67 @@
+package y3codec
+
+import (
+	"github.com/yomorun/y3"
+	frame "github.com/yomorun/yomo/core/frame"
+)
+
+// encodeCloseStreamFrame encodes CloseStreamFrame to Y3 encoded bytes.
+func encodeCloseStreamFrame(f *frame.CloseStreamFrame) ([]byte, error) {
+	// stream id
+	idBlock := y3.NewPrimitivePacketEncoder(tagCloseStreamStreamID)
+	idBlock.SetStringValue(f.StreamID)
+	// reason
+	reasonBlock := y3.NewPrimitivePacketEncoder(tagCloseStreamReason)
+	reasonBlock.SetStringValue(f.Reason)
+	// code
+	codeBlock := y3.NewPrimitivePacketEncoder(tagCloseStreamCode)
+	codeBlock.SetUInt32Value(uint32(f.Code))
+	// frame
+	ff := y3.NewNodePacketEncoder(byte(f.Type()))
+	ff.AddPrimitivePacket(idBlock)
+	ff.AddPrimitivePacket(reasonBlock)
+	ff.AddPrimitivePacket(codeBlock)
+
+	return ff.Encode(), nil
+}
+
+// decodeCloseStreamFrame decodes Y3 encoded bytes to CloseStreamFrame.
+func decodeCloseStreamFrame(data []byte, f *frame.CloseStreamFrame) error {
+	node := y3.NodePacket{}
+	_, err := y3.DecodeToNodePacket(data, &node)
+	if err != nil {
+		return err
+	}
+	// stream id
+	if idBlock, ok := node.PrimitivePackets[tagCloseStreamStreamID]; ok {
+		id, err := idBlock.ToUTF8String()
+		if err != nil {
+			return err
+		}
+		f.StreamID = id
+	}
+	// reason
+	if reasonBlock, ok := node.PrimitivePackets[tagCloseStreamReason]; ok {
+		reason, err := reasonBlock.ToUTF8String()
+		if err != nil {
+			return err
+		}
+		f.Reason = reason
+	}
+	// code
+	if codeBlock, ok := node.PrimitivePackets[tagCloseStreamCode]; ok {
+		code, err := codeBlock.ToUInt32()
+		if err != nil {
+			return err
+		}
+		f.Code = frame.CloseCode(code)
+	}
+
+	return nil
+}
+
+var (
+	tagCloseStreamStreamID byte = 0x01
+	tagCloseStreamReason   byte = 0x02
+	tagCloseStreamCode     byte = 0x03
+)
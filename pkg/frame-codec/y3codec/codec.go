@@ -3,35 +3,13 @@ package y3codec
 
 import (
 	"errors"
-	"io"
 
-	"github.com/yomorun/y3"
 	"github.com/yomorun/yomo/core/frame"
 )
 
 // ErrUnknownFrame is returned when unknown frame is received.
 var ErrUnknownFrame = errors.New("y3codec: unknown frame")
 
-type packetReadWriter struct{}
-
-// PacketReadWriter returns the y3 implement of frame.PacketReadWriter.
-func PacketReadWriter() frame.PacketReadWriter {
-	return &packetReadWriter{}
-}
-
-func (pr *packetReadWriter) ReadPacket(stream io.Reader) (frame.Type, []byte, error) {
-	buf, err := y3.ReadPacket(stream)
-	if err != nil {
-		return 0, nil, err
-	}
-	return frame.Type(buf[0] & 0x7F), buf, nil
-}
-
-func (pr *packetReadWriter) WritePacket(stream io.Writer, ftyp frame.Type, data []byte) error {
-	_, err := stream.Write(data)
-	return err
-}
-
 type y3codec struct{}
 
 // Codec returns the y3 implement of frame.Codec.
@@ -57,8 +35,36 @@ func (c *y3codec) Encode(f frame.Frame) ([]byte, error) {
 		return encodeBackflowFrame(ff)
 	case *frame.GoawayFrame:
 		return encodeGoawayFrame(ff)
+	case *frame.PingFrame:
+		return encodePingFrame(ff)
+	case *frame.PongFrame:
+		return encodePongFrame(ff)
+	case *frame.BatchDataFrame:
+		return encodeBatchDataFrame(ff)
+	case *frame.MetadataUpdateFrame:
+		return encodeMetadataUpdateFrame(ff)
+	case *frame.FlowControlFrame:
+		return encodeFlowControlFrame(ff)
+	case *frame.ObserveTagFrame:
+		return encodeObserveTagFrame(ff)
+	case *frame.UnobserveTagFrame:
+		return encodeUnobserveTagFrame(ff)
+	case *frame.CloseStreamFrame:
+		return encodeCloseStreamFrame(ff)
+	case *frame.ChunkedDataFrame:
+		return encodeChunkedDataFrame(ff)
+	case *frame.StatsReportFrame:
+		return encodeStatsReportFrame(ff)
+	case *frame.StreamReadyFrame:
+		return encodeStreamReadyFrame(ff)
+	case *frame.BackflowAckFrame:
+		return encodeBackflowAckFrame(ff)
+	case *frame.HealthCheckFrame:
+		return encodeHealthCheckFrame(ff)
+	case *frame.HealthCheckAckFrame:
+		return encodeHealthCheckAckFrame(ff)
 	default:
-		return nil, ErrUnknownFrame
+		return encodeCustomFrame(f)
 	}
 }
 
@@ -82,7 +88,35 @@ func (c *y3codec) Decode(data []byte, f frame.Frame) error {
 		return decodeBackflowFrame(data, ff)
 	case *frame.GoawayFrame:
 		return decodeGoawayFrame(data, ff)
+	case *frame.PingFrame:
+		return decodePingFrame(data, ff)
+	case *frame.PongFrame:
+		return decodePongFrame(data, ff)
+	case *frame.BatchDataFrame:
+		return decodeBatchDataFrame(data, ff)
+	case *frame.MetadataUpdateFrame:
+		return decodeMetadataUpdateFrame(data, ff)
+	case *frame.FlowControlFrame:
+		return decodeFlowControlFrame(data, ff)
+	case *frame.ObserveTagFrame:
+		return decodeObserveTagFrame(data, ff)
+	case *frame.UnobserveTagFrame:
+		return decodeUnobserveTagFrame(data, ff)
+	case *frame.CloseStreamFrame:
+		return decodeCloseStreamFrame(data, ff)
+	case *frame.ChunkedDataFrame:
+		return decodeChunkedDataFrame(data, ff)
+	case *frame.StatsReportFrame:
+		return decodeStatsReportFrame(data, ff)
+	case *frame.StreamReadyFrame:
+		return decodeStreamReadyFrame(data, ff)
+	case *frame.BackflowAckFrame:
+		return decodeBackflowAckFrame(data, ff)
+	case *frame.HealthCheckFrame:
+		return decodeHealthCheckFrame(data, ff)
+	case *frame.HealthCheckAckFrame:
+		return decodeHealthCheckAckFrame(data, ff)
 	default:
-		return ErrUnknownFrame
+		return decodeCustomFrame(data, f)
 	}
 }
@@ -3,10 +3,12 @@ package y3codec
 import (
 	"bytes"
 	"io"
+	"os"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	frame "github.com/yomorun/yomo/core/frame"
+	"github.com/yomorun/yomo/core/frame/frametest"
 )
 
 func TestReadPacket(t *testing.T) {
@@ -14,11 +16,13 @@ func TestReadPacket(t *testing.T) {
 	codec := Codec()
 
 	hf := &frame.HandshakeFrame{
-		Name:            "a",
-		ID:              "b",
-		StreamType:      0x10,
-		ObserveDataTags: []uint32{1, 2, 3},
-		Metadata:        []byte{'c'},
+		Name:                 "a",
+		ID:                   "b",
+		StreamType:           0x10,
+		ObserveDataTags:      []uint32{1, 2, 3},
+		Metadata:             []byte{'c'},
+		InstanceKey:          "d",
+		ObserveTagPriorities: map[uint32]uint8{3: 2, 1: 5},
 	}
 	b, err := codec.Encode(hf)
 	assert.NoError(t, err)
@@ -30,6 +34,10 @@ func TestReadPacket(t *testing.T) {
 	assert.Equal(t, b, bb)
 	assert.Equal(t, frame.TypeHandshakeFrame, ft)
 
+	decoded := new(frame.HandshakeFrame)
+	assert.NoError(t, codec.Decode(bb, decoded))
+	assert.Equal(t, hf, decoded)
+
 	ft, bb, err = prw.ReadPacket(stream)
 	assert.Equal(t, io.EOF, err)
 	assert.Equal(t, []byte(nil), bb)
@@ -57,7 +65,7 @@ func TestCodec(t *testing.T) {
 				newF: new(frame.AuthenticationFrame),
 				dataF: &frame.AuthenticationFrame{
 					AuthName:    "token",
-					AuthPayload: "a",
+					AuthPayload: []byte("a"),
 				},
 				data: []byte{
 					0x80 | byte(frame.TypeAuthenticationFrame), 0xa,
@@ -66,6 +74,40 @@ func TestCodec(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "AuthenticationFrameWithVersions",
+			args: args{
+				newF: new(frame.AuthenticationFrame),
+				dataF: &frame.AuthenticationFrame{
+					AuthName:    "token",
+					AuthPayload: []byte("a"),
+					Versions:    []frame.Version{frame.Version1, 2},
+				},
+				data: []byte{
+					0x80 | byte(frame.TypeAuthenticationFrame), 0xe,
+					byte(tagAuthenticationName), 0x05, 0x74, 0x6f, 0x6b, 0x65, 0x6e,
+					byte(tagAuthenticationPayload), 0x01, 0x61,
+					byte(tagAuthenticationVersions), 0x02, 0x01, 0x02,
+				},
+			},
+		},
+		{
+			name: "AuthenticationFrameWithDictionary",
+			args: args{
+				newF: new(frame.AuthenticationFrame),
+				dataF: &frame.AuthenticationFrame{
+					AuthName:         "token",
+					AuthPayload:      []byte("a"),
+					AcceptDictionary: true,
+				},
+				data: []byte{
+					0x80 | byte(frame.TypeAuthenticationFrame), 0xd,
+					byte(tagAuthenticationName), 0x05, 0x74, 0x6f, 0x6b, 0x65, 0x6e,
+					byte(tagAuthenticationPayload), 0x01, 0x61,
+					byte(tagAuthenticationDictionary), 0x01, 0x00,
+				},
+			},
+		},
 		{
 			name: "AuthenticationAckFrame",
 			args: args{
@@ -74,6 +116,26 @@ func TestCodec(t *testing.T) {
 				data:  []byte{0x91, 0x0},
 			},
 		},
+		{
+			name: "AuthenticationAckFrameWithVersion",
+			args: args{
+				newF:  new(frame.AuthenticationAckFrame),
+				dataF: &frame.AuthenticationAckFrame{Version: frame.Version1},
+				data:  []byte{0x91, 0x3, byte(tagAuthenticationAckVersion), 0x01, 0x01},
+			},
+		},
+		{
+			name: "AuthenticationAckFrameWithDictionary",
+			args: args{
+				newF:  new(frame.AuthenticationAckFrame),
+				dataF: &frame.AuthenticationAckFrame{DictionaryID: 2, Dictionary: []byte("ab")},
+				data: []byte{
+					0x91, 0x7,
+					byte(tagAuthenticationAckDictionaryID), 0x01, 0x02,
+					byte(tagAuthenticationAckDictionary), 0x02, 0x61, 0x62,
+				},
+			},
+		},
 		{
 			name: "BackflowFrame",
 			args: args{
@@ -85,6 +147,16 @@ func TestCodec(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "BackflowFrame with metadata",
+			args: args{
+				newF:  new(frame.BackflowFrame),
+				dataF: &frame.BackflowFrame{Tag: 0x10, Carriage: []byte("hi"), Metadata: []byte("md")},
+				data: []byte{
+					0xad, 0xb, 0x1, 0x1, 0x10, 0x2, 0x2, 0x68, 0x69, 0x3, 0x2, 0x6d, 0x64,
+				},
+			},
+		},
 		{
 			name: "DataFrame",
 			args: args{
@@ -100,6 +172,22 @@ func TestCodec(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "DataFrameWithTTL",
+			args: args{
+				newF: new(frame.DataFrame),
+				dataF: &frame.DataFrame{
+					Tag:      0x15,
+					Metadata: []byte("metadata"),
+					Payload:  []byte("yomo"),
+					TTL:      frame.DefaultTTL,
+				},
+				data: []byte{
+					0xbf, 0x16, 0x1, 0x1, 0x15, 0x3, 0x8, 0x6d, 0x65, 0x74, 0x61, 0x64,
+					0x61, 0x74, 0x61, 0x2, 0x4, 0x79, 0x6f, 0x6d, 0x6f, 0x5, 0x1, 0x10,
+				},
+			},
+		},
 		{
 			name: "HandshakeAckFrame",
 			args: args{
@@ -156,6 +244,34 @@ func TestCodec(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "RejectedFrame with reason",
+			args: args{
+				newF: new(frame.RejectedFrame),
+				dataF: &frame.RejectedFrame{
+					Message: "rejected",
+					Reason:  frame.RejectRateLimited,
+				},
+				data: []byte{0xb9, 0xd, 0x1, 0x8, 0x72, 0x65, 0x6a, 0x65, 0x63, 0x74, 0x65,
+					0x64, 0x2, 0x1, 0x2,
+				},
+			},
+		},
+		{
+			name: "HandshakeRejectedFrame with reason",
+			args: args{
+				newF: new(frame.HandshakeRejectedFrame),
+				dataF: &frame.HandshakeRejectedFrame{
+					ID:      "hello",
+					Message: "yomo",
+					Reason:  frame.RejectInternal,
+				},
+				data: []byte{
+					0x94, 0x10, 0x15, 0x5, 0x68, 0x65, 0x6c, 0x6c, 0x6f, 0x16, 0x4, 0x79, 0x6f,
+					0x6d, 0x6f, 0x17, 0x1, 0x4,
+				},
+			},
+		},
 		{
 			name: "GoawayFrame",
 			args: args{
@@ -168,6 +284,156 @@ func TestCodec(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "GoawayFrame reconnect",
+			args: args{
+				newF: new(frame.GoawayFrame),
+				dataF: &frame.GoawayFrame{
+					Message:   "goaway error",
+					Reconnect: true,
+				},
+				data: []byte{0xae, 0x11, 0x1, 0xc, 0x67, 0x6f, 0x61, 0x77, 0x61, 0x79, 0x20,
+					0x65, 0x72, 0x72, 0x6f, 0x72, 0x2, 0x1, 0x1,
+				},
+			},
+		},
+		{
+			name: "PingFrame",
+			args: args{
+				newF:  new(frame.PingFrame),
+				dataF: &frame.PingFrame{Nonce: []byte("ping")},
+				data:  []byte{0xba, 0x6, 0x1, 0x4, 0x70, 0x69, 0x6e, 0x67},
+			},
+		},
+		{
+			name: "PongFrame",
+			args: args{
+				newF:  new(frame.PongFrame),
+				dataF: &frame.PongFrame{Nonce: []byte("pong")},
+				data:  []byte{0xbb, 0x6, 0x1, 0x4, 0x70, 0x6f, 0x6e, 0x67},
+			},
+		},
+		{
+			name: "BatchDataFrame",
+			args: args{
+				newF: new(frame.BatchDataFrame),
+				dataF: &frame.BatchDataFrame{
+					Metadata: []byte("md"),
+					Entries: []frame.BatchEntry{
+						{Tag: 1, Payload: []byte("a")},
+						{Tag: 2, Payload: []byte("bc")},
+					},
+				},
+				data: []byte{
+					0xbc, 0x13, 0x1, 0x2, 0x6d, 0x64, 0x2, 0xd, 0x1, 0x0, 0x0, 0x0, 0x1, 0x61,
+					0x2, 0x0, 0x0, 0x0, 0x2, 0x62, 0x63,
+				},
+			},
+		},
+		{
+			name: "MetadataUpdateFrame",
+			args: args{
+				newF: new(frame.MetadataUpdateFrame),
+				dataF: &frame.MetadataUpdateFrame{
+					StreamID: "id",
+					Metadata: []byte("md"),
+				},
+				data: []byte{0xbd, 0x8, 0x1, 0x2, 0x69, 0x64, 0x2, 0x2, 0x6d, 0x64},
+			},
+		},
+		{
+			name: "FlowControlFrame",
+			args: args{
+				newF:  new(frame.FlowControlFrame),
+				dataF: &frame.FlowControlFrame{Tag: 1, Pause: true, Rate: 10},
+				data:  []byte{0xbe, 0x9, 0x1, 0x1, 0x1, 0x2, 0x1, 0x1, 0x3, 0x1, 0xa},
+			},
+		},
+		{
+			name: "ObserveTagFrame",
+			args: args{
+				newF:  new(frame.ObserveTagFrame),
+				dataF: &frame.ObserveTagFrame{StreamID: "a", Tag: 1},
+				data:  []byte{0xb2, 0x6, 0x1, 0x1, 0x61, 0x2, 0x1, 0x1},
+			},
+		},
+		{
+			name: "UnobserveTagFrame",
+			args: args{
+				newF:  new(frame.UnobserveTagFrame),
+				dataF: &frame.UnobserveTagFrame{StreamID: "a", Tag: 1},
+				data:  []byte{0xb3, 0x6, 0x1, 0x1, 0x61, 0x2, 0x1, 0x1},
+			},
+		},
+		{
+			name: "CloseStreamFrame",
+			args: args{
+				newF:  new(frame.CloseStreamFrame),
+				dataF: &frame.CloseStreamFrame{StreamID: "a", Reason: "b", Code: frame.CloseIdleTimeout},
+				data:  []byte{0xb4, 0x9, 0x1, 0x1, 0x61, 0x2, 0x1, 0x62, 0x3, 0x1, 0x3},
+			},
+		},
+		{
+			name: "ChunkedDataFrame",
+			args: args{
+				newF: new(frame.ChunkedDataFrame),
+				dataF: &frame.ChunkedDataFrame{
+					Metadata:   []byte("a"),
+					Tag:        1,
+					TransferID: "b",
+					Index:      2,
+					Last:       true,
+					Payload:    []byte("c"),
+				},
+				data: []byte{0xb5, 0x12, 0x1, 0x1, 0x61, 0x2, 0x1, 0x1, 0x3, 0x1, 0x62, 0x4, 0x1, 0x2, 0x5, 0x1, 0x1, 0x6, 0x1, 0x63},
+			},
+		},
+		{
+			name: "StatsReportFrame",
+			args: args{
+				newF: new(frame.StatsReportFrame),
+				dataF: &frame.StatsReportFrame{
+					StreamID:   "a",
+					Processed:  1,
+					Errors:     2,
+					P50Latency: 3,
+					P99Latency: 4,
+				},
+				data: []byte{0xb6, 0xf, 0x1, 0x1, 0x61, 0x2, 0x1, 0x1, 0x3, 0x1, 0x2, 0x4, 0x1, 0x3, 0x5, 0x1, 0x4},
+			},
+		},
+		{
+			name: "StreamReadyFrame",
+			args: args{
+				newF:  new(frame.StreamReadyFrame),
+				dataF: &frame.StreamReadyFrame{StreamID: "a", Tags: []uint32{1, 2}},
+				data:  []byte{0xb7, 0xd, 0x1, 0x1, 0x61, 0x2, 0x8, 0x1, 0x0, 0x0, 0x0, 0x2, 0x0, 0x0, 0x0},
+			},
+		},
+		{
+			name: "BackflowAckFrame",
+			args: args{
+				newF:  new(frame.BackflowAckFrame),
+				dataF: &frame.BackflowAckFrame{ID: "a", StreamID: "b"},
+				data:  []byte{0xb8, 0x6, 0x1, 0x1, 0x61, 0x2, 0x1, 0x62},
+			},
+		},
+		{
+			name: "HealthCheckFrame",
+			args: args{
+				newF:  new(frame.HealthCheckFrame),
+				dataF: &frame.HealthCheckFrame{},
+				data:  []byte{0xaf, 0x0},
+			},
+		},
+		{
+			name: "HealthCheckAckFrame",
+			args: args{
+				newF:  new(frame.HealthCheckAckFrame),
+				dataF: &frame.HealthCheckAckFrame{Status: frame.HealthDraining},
+				data:  []byte{0xb0, 0x3, 0x1, 0x1, 0x1},
+			},
+		},
 		{
 			name: "error",
 			args: args{
@@ -195,3 +461,258 @@ func TestCodec(t *testing.T) {
 		})
 	}
 }
+
+func TestReadPacketMaxFrameSize(t *testing.T) {
+	prw := PacketReadWriter(WithMaxFrameSize(4))
+
+	b, err := Codec().Encode(&frame.DataFrame{Tag: 1, Payload: []byte("yomo")})
+	assert.NoError(t, err)
+
+	_, _, err = prw.ReadPacket(bytes.NewReader(b))
+	assert.ErrorIs(t, err, ErrFrameTooLarge)
+
+	var tooLarge *FrameTooLargeError
+	assert.ErrorAs(t, err, &tooLarge)
+	assert.Equal(t, frame.TypeDataFrame, tooLarge.Type)
+	assert.Equal(t, len(b)-2, tooLarge.Size)
+	assert.Equal(t, 4, tooLarge.MaxSize)
+
+	ft, bb, err := PacketReadWriter().ReadPacket(bytes.NewReader(b))
+	assert.NoError(t, err)
+	assert.Equal(t, frame.TypeDataFrame, ft)
+	assert.Equal(t, b, bb)
+}
+
+// hiccupReader reads the data, and fails with err once it reaches each of the cuts.
+type hiccupReader struct {
+	data []byte
+	pos  int
+	cuts []int
+	err  error
+}
+
+func (r *hiccupReader) Read(p []byte) (int, error) {
+	if len(r.cuts) > 0 && r.pos == r.cuts[0] {
+		r.cuts = r.cuts[1:]
+		return 0, r.err
+	}
+	if r.pos == len(r.data) {
+		return 0, io.EOF
+	}
+	end := len(r.data)
+	if len(r.cuts) > 0 {
+		end = r.cuts[0]
+	}
+	n := copy(p, r.data[r.pos:end])
+	r.pos += n
+	return n, nil
+}
+
+func TestReadPacketShortRead(t *testing.T) {
+	codec := Codec()
+
+	b1, err := codec.Encode(&frame.DataFrame{Tag: 1, Payload: []byte("hello")})
+	assert.NoError(t, err)
+	b2, err := codec.Encode(&frame.DataFrame{Tag: 2, Payload: []byte("yomo")})
+	assert.NoError(t, err)
+	data := append(append([]byte{}, b1...), b2...)
+
+	t.Run("resumed after the timeouts", func(t *testing.T) {
+		prw := PacketReadWriter(WithBufferPool(NewBufferPool(0))).(frame.StreamPacketReadWriter).ForStream()
+		// hiccups in the middle of the header and the body of the first packet.
+		r := &hiccupReader{data: data, cuts: []int{1, 5}, err: os.ErrDeadlineExceeded}
+
+		_, _, err := prw.ReadPacket(r)
+		assert.ErrorIs(t, err, ErrShortRead)
+		assert.ErrorIs(t, err, os.ErrDeadlineExceeded)
+
+		var shortRead *ShortReadError
+		assert.ErrorAs(t, err, &shortRead)
+		assert.Equal(t, frame.TypeDataFrame, shortRead.Type)
+		assert.Equal(t, 1, shortRead.Read)
+		assert.True(t, shortRead.Resumed)
+
+		_, _, err = prw.ReadPacket(r)
+		assert.ErrorIs(t, err, ErrShortRead)
+		assert.ErrorAs(t, err, &shortRead)
+		assert.Equal(t, 5, shortRead.Read)
+
+		// the packets are not desynced.
+		ft, bb, err := prw.ReadPacket(r)
+		assert.NoError(t, err)
+		assert.Equal(t, frame.TypeDataFrame, ft)
+		assert.Equal(t, b1, bb)
+
+		ft, bb, err = prw.ReadPacket(r)
+		assert.NoError(t, err)
+		assert.Equal(t, frame.TypeDataFrame, ft)
+		assert.Equal(t, b2, bb)
+
+		_, _, err = prw.ReadPacket(r)
+		assert.Equal(t, io.EOF, err)
+	})
+
+	t.Run("truncated by EOF", func(t *testing.T) {
+		prw := PacketReadWriter()
+		r := bytes.NewReader(b1[:len(b1)-1])
+
+		_, _, err := prw.ReadPacket(r)
+		assert.ErrorIs(t, err, ErrShortRead)
+		assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+
+		var shortRead *ShortReadError
+		assert.ErrorAs(t, err, &shortRead)
+		assert.Equal(t, len(b1)-1, shortRead.Read)
+		assert.False(t, shortRead.Resumed)
+
+		// nothing is kept for the stream failed.
+		_, _, err = prw.ReadPacket(r)
+		assert.Equal(t, io.EOF, err)
+	})
+
+	t.Run("not resumed by the shared one", func(t *testing.T) {
+		prw := PacketReadWriter()
+		r := &hiccupReader{data: data, cuts: []int{4}, err: os.ErrDeadlineExceeded}
+
+		_, _, err := prw.ReadPacket(r)
+		var shortRead *ShortReadError
+		assert.ErrorAs(t, err, &shortRead)
+		assert.False(t, shortRead.Resumed)
+	})
+
+	t.Run("kept per stream", func(t *testing.T) {
+		shared := PacketReadWriter().(frame.StreamPacketReadWriter)
+		prw1, prw2 := shared.ForStream(), shared.ForStream()
+		r1 := &hiccupReader{data: data, cuts: []int{4}, err: os.ErrDeadlineExceeded}
+		r2 := bytes.NewReader(b2)
+
+		_, _, err := prw1.ReadPacket(r1)
+		assert.ErrorIs(t, err, ErrShortRead)
+
+		// the packet read in part by a stream does not leak to another stream.
+		_, bb, err := prw2.ReadPacket(r2)
+		assert.NoError(t, err)
+		assert.Equal(t, b2, bb)
+
+		_, bb, err = prw1.ReadPacket(r1)
+		assert.NoError(t, err)
+		assert.Equal(t, b1, bb)
+	})
+
+	t.Run("discarded", func(t *testing.T) {
+		prw := PacketReadWriter().(frame.StreamPacketReadWriter).ForStream()
+		r := &hiccupReader{data: data, cuts: []int{4}, err: os.ErrDeadlineExceeded}
+
+		_, _, err := prw.ReadPacket(r)
+		assert.ErrorIs(t, err, ErrShortRead)
+
+		assert.NotNil(t, prw.(*streamPacketReadWriter).partial)
+
+		prw.(interface{ Discard() }).Discard()
+		assert.Nil(t, prw.(*streamPacketReadWriter).partial)
+	})
+}
+
+func BenchmarkReadPacket(b *testing.B) {
+	data, err := Codec().Encode(&frame.DataFrame{Tag: 1, Payload: make([]byte, 1024)})
+	assert.NoError(b, err)
+
+	b.Run("without pool", func(b *testing.B) {
+		prw := PacketReadWriter()
+		reader := bytes.NewReader(data)
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			reader.Reset(data)
+			_, _, _ = prw.ReadPacket(reader)
+		}
+	})
+
+	b.Run("with pool", func(b *testing.B) {
+		prw := PacketReadWriter(WithBufferPool(NewBufferPool(0)))
+		reader := bytes.NewReader(data)
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			reader.Reset(data)
+			_, packet, _ := prw.ReadPacket(reader)
+			prw.(frame.PacketFreer).Free(packet)
+		}
+	})
+}
+
+func TestDump(t *testing.T) {
+	prw := PacketReadWriter()
+	codec := Codec()
+
+	b, err := codec.Encode(&frame.DataFrame{Tag: 1, Metadata: []byte{0x81, 0xa1, 'a'}, Payload: bytes.Repeat([]byte{'x'}, 100)})
+	assert.NoError(t, err)
+
+	s, err := frame.Dump(b, prw, codec)
+	assert.NoError(t, err)
+	assert.Contains(t, s, "DataFrame")
+	assert.Contains(t, s, "Tag: 1")
+	assert.Contains(t, s, "Metadata: 3 bytes")
+	assert.Contains(t, s, "(100 bytes, truncated)")
+
+	b[0] = 0x80 | 0x20
+	s, err = frame.Dump(b, prw, codec)
+	assert.NoError(t, err)
+	assert.Contains(t, s, "UnknownFrame(0x20)")
+}
+
+func TestChecksumPacketReadWriter(t *testing.T) {
+	prw := frame.ChecksumPacketReadWriter(PacketReadWriter())
+
+	data, err := Codec().Encode(&frame.DataFrame{Tag: 1, Payload: []byte("yomo")})
+	assert.NoError(t, err)
+
+	var buf bytes.Buffer
+	assert.NoError(t, prw.WritePacket(&buf, frame.TypeDataFrame, data))
+	assert.NoError(t, prw.WritePacket(&buf, frame.TypeDataFrame, data))
+	assert.Equal(t, 2*(len(data)+4), buf.Len())
+
+	ftyp, packet, err := prw.ReadPacket(&buf)
+	assert.NoError(t, err)
+	assert.Equal(t, frame.TypeDataFrame, ftyp)
+	assert.Equal(t, data, packet)
+
+	// corrupt the payload of the second packet.
+	b := buf.Bytes()
+	b[len(b)-5] ^= 0xff
+	_, _, err = prw.ReadPacket(&buf)
+	assert.ErrorIs(t, err, frame.ErrChecksumMismatch)
+}
+
+func TestRecordReplay(t *testing.T) {
+	a, b := frametest.Pipe()
+	defer a.Close()
+
+	var buf bytes.Buffer
+	recorder := frame.NewRecordingReadWriter(a, &buf, Codec())
+
+	go func() {
+		b.WriteFrame(&frame.DataFrame{Tag: 1, Payload: []byte("in")})
+		b.ReadFrame()
+	}()
+
+	f, err := recorder.ReadFrame()
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("in"), f.(*frame.DataFrame).Payload)
+	assert.NoError(t, recorder.WriteFrame(&frame.DataFrame{Tag: 2, Payload: []byte("out")}))
+	assert.NoError(t, recorder.Err())
+
+	records := bytes.NewReader(buf.Bytes())
+	replay := frame.NewReplayReader(records, Codec())
+
+	f, err = replay.ReadFrame()
+	assert.NoError(t, err)
+	assert.Equal(t, &frame.DataFrame{Tag: 1, Payload: []byte("in")}, f)
+	_, err = replay.ReadFrame()
+	assert.Equal(t, io.EOF, err)
+
+	// read the written frames.
+	records.Reset(buf.Bytes())
+	replay = frame.NewReplayReader(records, Codec(), frame.WithReplayDirection(frame.DirectionWrite), frame.WithReplayTiming())
+	f, err = replay.ReadFrame()
+	assert.NoError(t, err)
+	assert.Equal(t, frame.Tag(2), f.(*frame.DataFrame).Tag)
+}
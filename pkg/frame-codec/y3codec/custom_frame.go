@@ -0,0 +1,51 @@
+package y3codec
+
+import (
+	"encoding"
+
+	"github.com/yomorun/y3"
+	"github.com/yomorun/yomo/core/frame"
+)
+
+// encodeCustomFrame encodes the custom frame registered by frame.RegisterFrameType to Y3 encoded bytes,
+// the custom frame must implement encoding.BinaryMarshaler.
+func encodeCustomFrame(f frame.Frame) ([]byte, error) {
+	m, ok := f.(encoding.BinaryMarshaler)
+	if !ok {
+		return nil, ErrUnknownFrame
+	}
+	b, err := m.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	// body
+	bodyBlock := y3.NewPrimitivePacketEncoder(tagCustomBody)
+	bodyBlock.SetBytesValue(b)
+	// frame
+	ff := y3.NewNodePacketEncoder(byte(f.Type()))
+	ff.AddPrimitivePacket(bodyBlock)
+
+	return ff.Encode(), nil
+}
+
+// decodeCustomFrame decodes Y3 encoded bytes to the custom frame registered by frame.RegisterFrameType,
+// the custom frame must implement encoding.BinaryUnmarshaler.
+func decodeCustomFrame(data []byte, f frame.Frame) error {
+	u, ok := f.(encoding.BinaryUnmarshaler)
+	if !ok {
+		return ErrUnknownFrame
+	}
+	node := y3.NodePacket{}
+	_, err := y3.DecodeToNodePacket(data, &node)
+	if err != nil {
+		return err
+	}
+	var body []byte
+	if bodyBlock, ok := node.PrimitivePackets[tagCustomBody]; ok {
+		body = bodyBlock.ToBytes()
+	}
+
+	return u.UnmarshalBinary(body)
+}
+
+var tagCustomBody byte = 0x01
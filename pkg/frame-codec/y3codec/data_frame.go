@@ -25,6 +25,20 @@ func encodeDataFrame(f *frame.DataFrame) ([]byte, error) {
 	data.AddPrimitivePacket(metadataBlock)
 	data.AddPrimitivePacket(payloadBlock)
 
+	// seq, only the sequenced data frame carries it.
+	if f.Seq != 0 {
+		seqBlock := y3.NewPrimitivePacketEncoder(tagDataFrameSeq)
+		seqBlock.SetUInt64Value(f.Seq)
+		data.AddPrimitivePacket(seqBlock)
+	}
+
+	// ttl, the zero ttl is not set.
+	if f.TTL != 0 {
+		ttlBlock := y3.NewPrimitivePacketEncoder(tagDataFrameTTL)
+		ttlBlock.SetUInt32Value(uint32(f.TTL))
+		data.AddPrimitivePacket(ttlBlock)
+	}
+
 	return data.Encode(), nil
 }
 
@@ -57,6 +71,24 @@ func decodeDataFrame(data []byte, f *frame.DataFrame) error {
 		f.Payload = payload
 	}
 
+	// seq
+	if seqBlock, ok := packet.PrimitivePackets[byte(tagDataFrameSeq)]; ok {
+		seq, err := seqBlock.ToUInt64()
+		if err != nil {
+			return err
+		}
+		f.Seq = seq
+	}
+
+	// ttl
+	if ttlBlock, ok := packet.PrimitivePackets[byte(tagDataFrameTTL)]; ok {
+		ttl, err := ttlBlock.ToUInt32()
+		if err != nil {
+			return err
+		}
+		f.TTL = uint8(ttl)
+	}
+
 	return nil
 }
 
@@ -64,4 +96,6 @@ var (
 	tagDataFrameTag       byte = 0x01
 	tagDataFramePayload   byte = 0x02
 	tagDataFramesMetadata byte = 0x03
+	tagDataFrameSeq       byte = 0x04
+	tagDataFrameTTL       byte = 0x05
 )
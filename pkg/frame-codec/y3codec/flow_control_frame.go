@@ -0,0 +1,67 @@
+package y3codec
+
+import (
+	"github.com/yomorun/y3"
+	frame "github.com/yomorun/yomo/core/frame"
+)
+
+// encodeFlowControlFrame encodes FlowControlFrame to Y3 encoded bytes.
+func encodeFlowControlFrame(f *frame.FlowControlFrame) ([]byte, error) {
+	// tag
+	tagBlock := y3.NewPrimitivePacketEncoder(tagFlowControlTag)
+	tagBlock.SetUInt32Value(f.Tag)
+	// pause
+	pauseBlock := y3.NewPrimitivePacketEncoder(tagFlowControlPause)
+	pauseBlock.SetBoolValue(f.Pause)
+	// rate
+	rateBlock := y3.NewPrimitivePacketEncoder(tagFlowControlRate)
+	rateBlock.SetUInt32Value(f.Rate)
+	// frame
+	ff := y3.NewNodePacketEncoder(byte(f.Type()))
+	ff.AddPrimitivePacket(tagBlock)
+	ff.AddPrimitivePacket(pauseBlock)
+	ff.AddPrimitivePacket(rateBlock)
+
+	return ff.Encode(), nil
+}
+
+// decodeFlowControlFrame decodes Y3 encoded bytes to FlowControlFrame.
+func decodeFlowControlFrame(data []byte, f *frame.FlowControlFrame) error {
+	node := y3.NodePacket{}
+	_, err := y3.DecodeToNodePacket(data, &node)
+	if err != nil {
+		return err
+	}
+	// tag
+	if tagBlock, ok := node.PrimitivePackets[tagFlowControlTag]; ok {
+		tag, err := tagBlock.ToUInt32()
+		if err != nil {
+			return err
+		}
+		f.Tag = tag
+	}
+	// pause
+	if pauseBlock, ok := node.PrimitivePackets[tagFlowControlPause]; ok {
+		pause, err := pauseBlock.ToBool()
+		if err != nil {
+			return err
+		}
+		f.Pause = pause
+	}
+	// rate
+	if rateBlock, ok := node.PrimitivePackets[tagFlowControlRate]; ok {
+		rate, err := rateBlock.ToUInt32()
+		if err != nil {
+			return err
+		}
+		f.Rate = rate
+	}
+
+	return nil
+}
+
+var (
+	tagFlowControlTag   byte = 0x01
+	tagFlowControlPause byte = 0x02
+	tagFlowControlRate  byte = 0x03
+)
@@ -14,6 +14,13 @@ func encodeHandshakeAckFrame(f *frame.HandshakeAckFrame) ([]byte, error) {
 
 	ack.AddPrimitivePacket(streamIDBlock)
 
+	// checksum, the peers do not support it ignore it.
+	if f.Checksum {
+		checksumBlock := y3.NewPrimitivePacketEncoder(tagHandshakeAckChecksum)
+		checksumBlock.SetBoolValue(f.Checksum)
+		ack.AddPrimitivePacket(checksumBlock)
+	}
+
 	return ack.Encode(), nil
 }
 
@@ -33,7 +40,19 @@ func decodeHandshakeAckFrame(data []byte, f *frame.HandshakeAckFrame) error {
 		}
 		f.StreamID = streamID
 	}
+
+	// checksum
+	if checksumBlock, ok := node.PrimitivePackets[tagHandshakeAckChecksum]; ok {
+		checksum, err := checksumBlock.ToBool()
+		if err != nil {
+			return err
+		}
+		f.Checksum = checksum
+	}
 	return nil
 }
 
-var tagHandshakeAckStreamID byte = 0x28
+var (
+	tagHandshakeAckStreamID byte = 0x28
+	tagHandshakeAckChecksum byte = 0x29
+)
@@ -2,6 +2,7 @@ package y3codec
 
 import (
 	"encoding/binary"
+	"sort"
 
 	"github.com/yomorun/y3"
 	frame "github.com/yomorun/yomo/core/frame"
@@ -35,6 +36,29 @@ func encodeHandshakeFrame(f *frame.HandshakeFrame) ([]byte, error) {
 	handshake.AddPrimitivePacket(typeBlock)
 	handshake.AddPrimitivePacket(observeDataTagsBlock)
 	handshake.AddPrimitivePacket(metadataBlock)
+	// instance key
+	if f.InstanceKey != "" {
+		instanceKeyBlock := y3.NewPrimitivePacketEncoder(tagHandshakeInstanceKey)
+		instanceKeyBlock.SetStringValue(f.InstanceKey)
+		handshake.AddPrimitivePacket(instanceKeyBlock)
+	}
+	// observe tag priorities, every entry is the tag followed by its priority, sorted by the tag.
+	if len(f.ObserveTagPriorities) > 0 {
+		tags := make([]frame.Tag, 0, len(f.ObserveTagPriorities))
+		for tag := range f.ObserveTagPriorities {
+			tags = append(tags, tag)
+		}
+		sort.Slice(tags, func(i, j int) bool { return tags[i] < tags[j] })
+
+		prioritiesBlock := y3.NewPrimitivePacketEncoder(tagHandshakeObserveTagPriorities)
+		entry := make([]byte, 5)
+		for _, tag := range tags {
+			binary.LittleEndian.PutUint32(entry, uint32(tag))
+			entry[4] = f.ObserveTagPriorities[tag]
+			prioritiesBlock.AddBytes(entry)
+		}
+		handshake.AddPrimitivePacket(prioritiesBlock)
+	}
 
 	return handshake.Encode(), nil
 }
@@ -82,14 +106,34 @@ func decodeHandshakeFrame(data []byte, f *frame.HandshakeFrame) error {
 		metadata := typeBlock.ToBytes()
 		f.Metadata = metadata
 	}
+	// instance key
+	if instanceKeyBlock, ok := node.PrimitivePackets[tagHandshakeInstanceKey]; ok {
+		instanceKey, err := instanceKeyBlock.ToUTF8String()
+		if err != nil {
+			return err
+		}
+		f.InstanceKey = instanceKey
+	}
+	// observe tag priorities
+	if prioritiesBlock, ok := node.PrimitivePackets[tagHandshakeObserveTagPriorities]; ok {
+		buf := prioritiesBlock.GetValBuf()
+		if len(buf) > 0 {
+			f.ObserveTagPriorities = make(map[frame.Tag]uint8, len(buf)/5)
+		}
+		for pos := 0; pos+5 <= len(buf); pos += 5 {
+			f.ObserveTagPriorities[frame.Tag(binary.LittleEndian.Uint32(buf[pos:pos+4]))] = buf[pos+4]
+		}
+	}
 
 	return nil
 }
 
 var (
-	tagHandshakeName            byte = 0x01
-	tagHandshakeStreamType      byte = 0x02
-	tagHandshakeID              byte = 0x03
-	tagHandshakeObserveDataTags byte = 0x06
-	tagHandshakeMetadata        byte = 0x07
+	tagHandshakeName                 byte = 0x01
+	tagHandshakeStreamType           byte = 0x02
+	tagHandshakeID                   byte = 0x03
+	tagHandshakeObserveDataTags      byte = 0x06
+	tagHandshakeMetadata             byte = 0x07
+	tagHandshakeInstanceKey          byte = 0x08
+	tagHandshakeObserveTagPriorities byte = 0x09
 )
@@ -17,6 +17,12 @@ func encodeHandshakeRejectedFrame(f *frame.HandshakeRejectedFrame) ([]byte, erro
 	ack := y3.NewNodePacketEncoder(byte(f.Type()))
 	ack.AddPrimitivePacket(idBlock)
 	ack.AddPrimitivePacket(messageBlock)
+	// reason, it is omitted if unspecified for the peers that do not know it.
+	if f.Reason != frame.RejectUnspecified {
+		reasonBlock := y3.NewPrimitivePacketEncoder(tagHandshakeRejectedReason)
+		reasonBlock.SetUInt32Value(uint32(f.Reason))
+		ack.AddPrimitivePacket(reasonBlock)
+	}
 
 	return ack.Encode(), nil
 }
@@ -45,6 +51,14 @@ func decodeHandshakeRejectedFrame(data []byte, f *frame.HandshakeRejectedFrame)
 		}
 		f.Message = message
 	}
+	// reason
+	if reasonBlock, ok := node.PrimitivePackets[tagHandshakeRejectedReason]; ok {
+		reason, err := reasonBlock.ToUInt32()
+		if err != nil {
+			return err
+		}
+		f.Reason = frame.RejectCode(reason)
+	}
 
 	return nil
 }
@@ -52,4 +66,5 @@ func decodeHandshakeRejectedFrame(data []byte, f *frame.HandshakeRejectedFrame)
 var (
 	tagHandshakeRejectedStreamID byte = 0x15
 	tagHandshakeRejectedMessage  byte = 0x16
+	tagHandshakeRejectedReason   byte = 0x17
 )
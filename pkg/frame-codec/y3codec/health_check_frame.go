@@ -0,0 +1,54 @@
+package y3codec
+
+import (
+	"github.com/yomorun/y3"
+	"github.com/yomorun/yomo/core/frame"
+)
+
+// encodeHealthCheckFrame encodes HealthCheckFrame to Y3 encoded bytes.
+func encodeHealthCheckFrame(f *frame.HealthCheckFrame) ([]byte, error) {
+	ff := y3.NewNodePacketEncoder(byte(f.Type()))
+
+	return ff.Encode(), nil
+}
+
+// decodeHealthCheckFrame decodes Y3 encoded bytes to HealthCheckFrame.
+func decodeHealthCheckFrame(data []byte, f *frame.HealthCheckFrame) error {
+	node := y3.NodePacket{}
+	_, err := y3.DecodeToNodePacket(data, &node)
+
+	return err
+}
+
+// encodeHealthCheckAckFrame encodes HealthCheckAckFrame to Y3 encoded bytes.
+func encodeHealthCheckAckFrame(f *frame.HealthCheckAckFrame) ([]byte, error) {
+	// status
+	statusBlock := y3.NewPrimitivePacketEncoder(tagHealthCheckAckStatus)
+	statusBlock.SetUInt32Value(uint32(f.Status))
+	// frame
+	ff := y3.NewNodePacketEncoder(byte(f.Type()))
+	ff.AddPrimitivePacket(statusBlock)
+
+	return ff.Encode(), nil
+}
+
+// decodeHealthCheckAckFrame decodes Y3 encoded bytes to HealthCheckAckFrame.
+func decodeHealthCheckAckFrame(data []byte, f *frame.HealthCheckAckFrame) error {
+	node := y3.NodePacket{}
+	_, err := y3.DecodeToNodePacket(data, &node)
+	if err != nil {
+		return err
+	}
+	// status
+	if statusBlock, ok := node.PrimitivePackets[tagHealthCheckAckStatus]; ok {
+		status, err := statusBlock.ToUInt32()
+		if err != nil {
+			return err
+		}
+		f.Status = frame.HealthStatus(status)
+	}
+
+	return nil
+}
+
+var tagHealthCheckAckStatus byte = 0x01
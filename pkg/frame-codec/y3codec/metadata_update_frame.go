@@ -0,0 +1,50 @@
+package y3codec
+
+import (
+	"github.com/yomorun/y3"
+	frame "github.com/yomorun/yomo/core/frame"
+)
+
+// encodeMetadataUpdateFrame encodes MetadataUpdateFrame to Y3 encoded bytes.
+func encodeMetadataUpdateFrame(f *frame.MetadataUpdateFrame) ([]byte, error) {
+	// stream id
+	idBlock := y3.NewPrimitivePacketEncoder(tagMetadataUpdateStreamID)
+	idBlock.SetStringValue(f.StreamID)
+	// metadata
+	metadataBlock := y3.NewPrimitivePacketEncoder(tagMetadataUpdateMetadata)
+	metadataBlock.SetBytesValue(f.Metadata)
+	// frame
+	ff := y3.NewNodePacketEncoder(byte(f.Type()))
+	ff.AddPrimitivePacket(idBlock)
+	ff.AddPrimitivePacket(metadataBlock)
+
+	return ff.Encode(), nil
+}
+
+// decodeMetadataUpdateFrame decodes Y3 encoded bytes to MetadataUpdateFrame.
+func decodeMetadataUpdateFrame(data []byte, f *frame.MetadataUpdateFrame) error {
+	node := y3.NodePacket{}
+	_, err := y3.DecodeToNodePacket(data, &node)
+	if err != nil {
+		return err
+	}
+	// stream id
+	if idBlock, ok := node.PrimitivePackets[tagMetadataUpdateStreamID]; ok {
+		id, err := idBlock.ToUTF8String()
+		if err != nil {
+			return err
+		}
+		f.StreamID = id
+	}
+	// metadata
+	if metadataBlock, ok := node.PrimitivePackets[tagMetadataUpdateMetadata]; ok {
+		f.Metadata = metadataBlock.ToBytes()
+	}
+
+	return nil
+}
+
+var (
+	tagMetadataUpdateStreamID byte = 0x01
+	tagMetadataUpdateMetadata byte = 0x02
+)
@@ -0,0 +1,74 @@
+package y3codec
+
+import (
+	"github.com/yomorun/y3"
+	frame "github.com/yomorun/yomo/core/frame"
+)
+
+// encodeObserveTagFrame encodes ObserveTagFrame to Y3 encoded bytes.
+func encodeObserveTagFrame(f *frame.ObserveTagFrame) ([]byte, error) {
+	return encodeStreamTag(f.Type(), f.StreamID, f.Tag), nil
+}
+
+// decodeObserveTagFrame decodes Y3 encoded bytes to ObserveTagFrame.
+func decodeObserveTagFrame(data []byte, f *frame.ObserveTagFrame) error {
+	return decodeStreamTag(data, &f.StreamID, &f.Tag)
+}
+
+// encodeUnobserveTagFrame encodes UnobserveTagFrame to Y3 encoded bytes.
+func encodeUnobserveTagFrame(f *frame.UnobserveTagFrame) ([]byte, error) {
+	return encodeStreamTag(f.Type(), f.StreamID, f.Tag), nil
+}
+
+// decodeUnobserveTagFrame decodes Y3 encoded bytes to UnobserveTagFrame.
+func decodeUnobserveTagFrame(data []byte, f *frame.UnobserveTagFrame) error {
+	return decodeStreamTag(data, &f.StreamID, &f.Tag)
+}
+
+// encodeStreamTag encodes the frame that carries a stream id and a tag.
+func encodeStreamTag(ftyp frame.Type, streamID string, tag frame.Tag) []byte {
+	// stream id
+	idBlock := y3.NewPrimitivePacketEncoder(tagObserveTagStreamID)
+	idBlock.SetStringValue(streamID)
+	// tag
+	tagBlock := y3.NewPrimitivePacketEncoder(tagObserveTagTag)
+	tagBlock.SetUInt32Value(tag)
+	// frame
+	ff := y3.NewNodePacketEncoder(byte(ftyp))
+	ff.AddPrimitivePacket(idBlock)
+	ff.AddPrimitivePacket(tagBlock)
+
+	return ff.Encode()
+}
+
+// decodeStreamTag decodes the frame that carries a stream id and a tag.
+func decodeStreamTag(data []byte, streamID *string, tag *frame.Tag) error {
+	node := y3.NodePacket{}
+	_, err := y3.DecodeToNodePacket(data, &node)
+	if err != nil {
+		return err
+	}
+	// stream id
+	if idBlock, ok := node.PrimitivePackets[tagObserveTagStreamID]; ok {
+		id, err := idBlock.ToUTF8String()
+		if err != nil {
+			return err
+		}
+		*streamID = id
+	}
+	// tag
+	if tagBlock, ok := node.PrimitivePackets[tagObserveTagTag]; ok {
+		t, err := tagBlock.ToUInt32()
+		if err != nil {
+			return err
+		}
+		*tag = t
+	}
+
+	return nil
+}
+
+var (
+	tagObserveTagStreamID byte = 0x01
+	tagObserveTagTag      byte = 0x02
+)
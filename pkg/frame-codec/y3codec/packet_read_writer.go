@@ -0,0 +1,258 @@
+package y3codec
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+
+	"github.com/yomorun/y3/encoding"
+	"github.com/yomorun/yomo/core/frame"
+)
+
+// DefaultMaxFrameSize is the default max size of a packet that can be read by the PacketReadWriter.
+const DefaultMaxFrameSize = 16 << 20
+
+var (
+	// ErrFrameTooLarge is returned when the declared length of a packet exceeds the max frame size.
+	ErrFrameTooLarge = errors.New("y3codec: frame too large")
+	// ErrMalformedPacket is returned when the length of a packet can not be parsed.
+	ErrMalformedPacket = errors.New("y3codec: malformed packet")
+	// ErrShortRead is returned when the stream fails in the middle of a packet, see ShortReadError.
+	ErrShortRead = errors.New("y3codec: short read")
+)
+
+// ShortReadError is returned by ReadPacket when the stream fails in the middle of a packet,
+// it matches ErrShortRead by errors.Is and unwraps to the error of the stream.
+type ShortReadError struct {
+	Type frame.Type
+	// Read is the number of the bytes of the packet read, including the header.
+	Read int
+	// Resumed reports whether the packet is resumed by the next ReadPacket of the stream.
+	Resumed bool
+	Err     error
+}
+
+// Error implements error interface.
+func (e *ShortReadError) Error() string {
+	return fmt.Sprintf("y3codec: short read of %s after %d bytes: %v", e.Type, e.Read, e.Err)
+}
+
+// Is reports whether the target is ErrShortRead.
+func (e *ShortReadError) Is(target error) bool { return target == ErrShortRead }
+
+// Resumable reports whether the packet is resumed by the next ReadPacket of the stream.
+func (e *ShortReadError) Resumable() bool { return e.Resumed }
+
+// Unwrap returns the error of the stream.
+func (e *ShortReadError) Unwrap() error { return e.Err }
+
+// FrameTooLargeError is returned by ReadPacket when the declared length of a packet exceeds the max frame size.
+// It matches ErrFrameTooLarge by errors.Is. It is returned as well by the codecs those decompress the payload,
+// when the payload decompressed exceeds the max size.
+type FrameTooLargeError struct {
+	Type    frame.Type
+	Size    int
+	MaxSize int
+}
+
+// Error implements error interface.
+func (e *FrameTooLargeError) Error() string {
+	return fmt.Sprintf("y3codec: %s declares %d bytes, exceeds the max frame size %d", e.Type, e.Size, e.MaxSize)
+}
+
+// Is reports whether the target is ErrFrameTooLarge.
+func (e *FrameTooLargeError) Is(target error) bool { return target == ErrFrameTooLarge }
+
+// PacketReadWriterOption is the option for PacketReadWriter.
+type PacketReadWriterOption func(*packetReadWriter)
+
+// WithMaxFrameSize sets the max size of a packet that can be read, the size counts the y3 value only,
+// a non-positive size will be ignored.
+func WithMaxFrameSize(size int) PacketReadWriterOption {
+	return func(prw *packetReadWriter) {
+		if size > 0 {
+			prw.maxFrameSize = size
+		}
+	}
+}
+
+// WithBufferPool makes the PacketReadWriter read packets into the buffers of the pool,
+// the caller owns the packet returned by ReadPacket and can return it to the pool by Free
+// after the frame decoded from it is fully consumed.
+func WithBufferPool(pool *BufferPool) PacketReadWriterOption {
+	return func(prw *packetReadWriter) {
+		prw.pool = pool
+	}
+}
+
+type packetReadWriter struct {
+	maxFrameSize int
+	pool         *BufferPool
+}
+
+// PacketReadWriter returns the y3 implement of frame.PacketReadWriter.
+func PacketReadWriter(opts ...PacketReadWriterOption) frame.PacketReadWriter {
+	prw := &packetReadWriter{
+		maxFrameSize: DefaultMaxFrameSize,
+	}
+	for _, o := range opts {
+		o(prw)
+	}
+	return prw
+}
+
+// ReadPacket reads a y3 packet from the stream, the declared length of the packet is checked
+// before the value is allocated.
+//
+// If the stream fails in the middle of a packet, ReadPacket returns a *ShortReadError that matches ErrShortRead.
+// The packet is not resumed, see ForStream for the PacketReadWriter that resumes it.
+func (pr *packetReadWriter) ReadPacket(stream io.Reader) (frame.Type, []byte, error) {
+	ftyp, packet, _, err := pr.resumePacket(stream, nil, false)
+	return ftyp, packet, err
+}
+
+// ForStream returns the PacketReadWriter of a stream, it shares the max frame size and the buffer pool.
+// If the read of the stream times out in the middle of a packet, such as the read deadline exceeded,
+// the part read is kept and the next ReadPacket resumes the packet, so the reads are not desynced by the hiccup.
+func (pr *packetReadWriter) ForStream() frame.PacketReadWriter {
+	return &streamPacketReadWriter{packetReadWriter: pr}
+}
+
+// resumePacket reads the packet from where p has been read, p is nil for a new packet. If the packet is
+// resumable and the read times out, the packet read in part is returned to be resumed by the next read.
+func (pr *packetReadWriter) resumePacket(stream io.Reader, p *partialPacket, resumable bool) (frame.Type, []byte, *partialPacket, error) {
+	if p == nil {
+		p = &partialPacket{}
+		p.header = p.headerBuf[:0]
+	}
+
+	err := pr.readPacket(stream, p)
+	if err == nil {
+		return p.ftyp(), p.buf, nil, nil
+	}
+	if len(p.header) == 0 || errors.Is(err, ErrMalformedPacket) || errors.Is(err, ErrFrameTooLarge) {
+		return 0, nil, nil, err
+	}
+
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	shortRead := &ShortReadError{Type: p.ftyp(), Read: p.read(), Resumed: resumable && isTimeout(err), Err: err}
+	if shortRead.Resumed {
+		return 0, nil, p, shortRead
+	}
+	if p.buf != nil {
+		pr.Free(p.buf)
+	}
+	return 0, nil, nil, shortRead
+}
+
+// readPacket reads the packet into p from where p has been read.
+func (pr *packetReadWriter) readPacket(stream io.Reader, p *partialPacket) error {
+	var b [1]byte
+
+	// the header is y3.Tag and y3.Length, y3.Length of an int32 takes 5 bytes at most,
+	// y3.Length is in varint format.
+	for !p.headerDone {
+		if _, err := io.ReadFull(stream, b[:]); err != nil {
+			return err
+		}
+		if len(p.header) == cap(p.headerBuf) {
+			return ErrMalformedPacket
+		}
+		p.header = append(p.header, b[0])
+		p.headerDone = len(p.header) > 1 && b[0]&0x80 != 0x80
+	}
+
+	if p.buf == nil {
+		var length int32
+		codec := encoding.VarCodec{}
+		if err := codec.DecodePVarInt32(p.header[1:], &length); err != nil || length < 0 {
+			return ErrMalformedPacket
+		}
+
+		if int(length) > pr.maxFrameSize {
+			return &FrameTooLargeError{Type: p.ftyp(), Size: int(length), MaxSize: pr.maxFrameSize}
+		}
+
+		p.buf = pr.alloc(len(p.header) + int(length))
+		p.n = copy(p.buf, p.header)
+	}
+
+	n, err := io.ReadFull(stream, p.buf[p.n:])
+	p.n += n
+
+	return err
+}
+
+// partialPacket is the packet read in part.
+type partialPacket struct {
+	headerBuf  [6]byte
+	header     []byte
+	headerDone bool
+	// buf is allocated once the header is done, n bytes of it have been read.
+	buf []byte
+	n   int
+}
+
+func (p *partialPacket) ftyp() frame.Type { return frame.Type(p.header[0] & 0x7F) }
+
+func (p *partialPacket) read() int {
+	if p.buf != nil {
+		return p.n
+	}
+	return len(p.header)
+}
+
+// streamPacketReadWriter is the PacketReadWriter of a stream, it keeps the packet the stream has read in part.
+// It must be used by the reader of the stream only, including Discard.
+type streamPacketReadWriter struct {
+	*packetReadWriter
+	partial *partialPacket
+}
+
+// ReadPacket reads a y3 packet from the stream, it resumes the packet read in part by the last ReadPacket.
+func (s *streamPacketReadWriter) ReadPacket(stream io.Reader) (frame.Type, []byte, error) {
+	ftyp, packet, partial, err := s.resumePacket(stream, s.partial, true)
+	s.partial = partial
+	return ftyp, packet, err
+}
+
+// Discard discards the packet the stream has read in part, it is called by the reader once the stream is closed.
+func (s *streamPacketReadWriter) Discard() {
+	if s.partial != nil && s.partial.buf != nil {
+		s.Free(s.partial.buf)
+	}
+	s.partial = nil
+}
+
+// isTimeout reports whether the err is a timeout, the stream can go on reading after it.
+func isTimeout(err error) bool {
+	if errors.Is(err, os.ErrDeadlineExceeded) {
+		return true
+	}
+	var ne net.Error
+	return errors.As(err, &ne) && ne.Timeout()
+}
+
+func (pr *packetReadWriter) alloc(size int) []byte {
+	if pr.pool == nil {
+		return make([]byte, size)
+	}
+	return pr.pool.Get(size)
+}
+
+// Free returns the packet to the buffer pool, it does nothing if the PacketReadWriter has no buffer pool.
+func (pr *packetReadWriter) Free(packet []byte) {
+	if pr.pool == nil {
+		return
+	}
+	pr.pool.Free(packet)
+}
+
+func (pr *packetReadWriter) WritePacket(stream io.Writer, ftyp frame.Type, data []byte) error {
+	_, err := stream.Write(data)
+	return err
+}
@@ -0,0 +1,69 @@
+package y3codec
+
+import (
+	"github.com/yomorun/y3"
+	frame "github.com/yomorun/yomo/core/frame"
+)
+
+// encodePingFrame encodes PingFrame to Y3 encoded bytes.
+func encodePingFrame(f *frame.PingFrame) ([]byte, error) {
+	return encodeNonce(byte(f.Type()), f.Nonce), nil
+}
+
+// decodePingFrame decodes Y3 encoded bytes to PingFrame.
+func decodePingFrame(data []byte, f *frame.PingFrame) error {
+	nonce, err := decodeNonce(data)
+	if err != nil {
+		return err
+	}
+	f.Nonce = nonce
+
+	return nil
+}
+
+// encodePongFrame encodes PongFrame to Y3 encoded bytes.
+func encodePongFrame(f *frame.PongFrame) ([]byte, error) {
+	return encodeNonce(byte(f.Type()), f.Nonce), nil
+}
+
+// decodePongFrame decodes Y3 encoded bytes to PongFrame.
+func decodePongFrame(data []byte, f *frame.PongFrame) error {
+	nonce, err := decodeNonce(data)
+	if err != nil {
+		return err
+	}
+	f.Nonce = nonce
+
+	return nil
+}
+
+// encodeNonce encodes a node packet that only contains a nonce, PingFrame and PongFrame share this layout.
+func encodeNonce(ftyp byte, nonce []byte) []byte {
+	// nonce
+	nonceBlock := y3.NewPrimitivePacketEncoder(tagPingNonce)
+	nonceBlock.SetBytesValue(nonce)
+	// frame
+	ff := y3.NewNodePacketEncoder(ftyp)
+	ff.AddPrimitivePacket(nonceBlock)
+
+	return ff.Encode()
+}
+
+// decodeNonce decodes the nonce from a node packet encoded by encodeNonce.
+func decodeNonce(data []byte) ([]byte, error) {
+	node := y3.NodePacket{}
+	_, err := y3.DecodeToNodePacket(data, &node)
+	if err != nil {
+		return nil, err
+	}
+	// nonce
+	if nonceBlock, ok := node.PrimitivePackets[tagPingNonce]; ok {
+		return nonceBlock.ToBytes(), nil
+	}
+
+	return nil, nil
+}
+
+var (
+	tagPingNonce byte = 0x01
+)
@@ -13,6 +13,12 @@ func encodeRejectedFrame(f *frame.RejectedFrame) ([]byte, error) {
 	// frame
 	ff := y3.NewNodePacketEncoder(byte(f.Type()))
 	ff.AddPrimitivePacket(messageBlock)
+	// reason, it is omitted if unspecified for the peers that do not know it.
+	if f.Reason != frame.RejectUnspecified {
+		reasonBlock := y3.NewPrimitivePacketEncoder(tagRejectedReason)
+		reasonBlock.SetUInt32Value(uint32(f.Reason))
+		ff.AddPrimitivePacket(reasonBlock)
+	}
 
 	return ff.Encode(), nil
 }
@@ -32,10 +38,19 @@ func decodeRejectedFrame(data []byte, f *frame.RejectedFrame) error {
 		}
 		f.Message = message
 	}
+	// reason
+	if reasonBlock, ok := node.PrimitivePackets[tagRejectedReason]; ok {
+		reason, err := reasonBlock.ToUInt32()
+		if err != nil {
+			return err
+		}
+		f.Reason = frame.RejectCode(reason)
+	}
 
 	return nil
 }
 
 var (
 	tagRejectedMessage byte = 0x01
+	tagRejectedReason  byte = 0x02
 )
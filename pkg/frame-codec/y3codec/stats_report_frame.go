@@ -0,0 +1,93 @@
+package y3codec
+
+import (
+	"github.com/yomorun/y3"
+	"github.com/yomorun/yomo/core/frame"
+)
+
+// encodeStatsReportFrame encodes StatsReportFrame to Y3 encoded bytes.
+func encodeStatsReportFrame(f *frame.StatsReportFrame) ([]byte, error) {
+	// stream id
+	streamIDBlock := y3.NewPrimitivePacketEncoder(tagStatsReportStreamID)
+	streamIDBlock.SetStringValue(f.StreamID)
+	// processed
+	processedBlock := y3.NewPrimitivePacketEncoder(tagStatsReportProcessed)
+	processedBlock.SetUInt64Value(f.Processed)
+	// errors
+	errorsBlock := y3.NewPrimitivePacketEncoder(tagStatsReportErrors)
+	errorsBlock.SetUInt64Value(f.Errors)
+	// p50 latency
+	p50Block := y3.NewPrimitivePacketEncoder(tagStatsReportP50Latency)
+	p50Block.SetUInt64Value(f.P50Latency)
+	// p99 latency
+	p99Block := y3.NewPrimitivePacketEncoder(tagStatsReportP99Latency)
+	p99Block.SetUInt64Value(f.P99Latency)
+	// frame
+	ff := y3.NewNodePacketEncoder(byte(f.Type()))
+	ff.AddPrimitivePacket(streamIDBlock)
+	ff.AddPrimitivePacket(processedBlock)
+	ff.AddPrimitivePacket(errorsBlock)
+	ff.AddPrimitivePacket(p50Block)
+	ff.AddPrimitivePacket(p99Block)
+
+	return ff.Encode(), nil
+}
+
+// decodeStatsReportFrame decodes Y3 encoded bytes to StatsReportFrame.
+func decodeStatsReportFrame(data []byte, f *frame.StatsReportFrame) error {
+	node := y3.NodePacket{}
+	_, err := y3.DecodeToNodePacket(data, &node)
+	if err != nil {
+		return err
+	}
+	// stream id
+	if streamIDBlock, ok := node.PrimitivePackets[tagStatsReportStreamID]; ok {
+		streamID, err := streamIDBlock.ToUTF8String()
+		if err != nil {
+			return err
+		}
+		f.StreamID = streamID
+	}
+	// processed
+	if processedBlock, ok := node.PrimitivePackets[tagStatsReportProcessed]; ok {
+		processed, err := processedBlock.ToUInt64()
+		if err != nil {
+			return err
+		}
+		f.Processed = processed
+	}
+	// errors
+	if errorsBlock, ok := node.PrimitivePackets[tagStatsReportErrors]; ok {
+		errors, err := errorsBlock.ToUInt64()
+		if err != nil {
+			return err
+		}
+		f.Errors = errors
+	}
+	// p50 latency
+	if p50Block, ok := node.PrimitivePackets[tagStatsReportP50Latency]; ok {
+		p50, err := p50Block.ToUInt64()
+		if err != nil {
+			return err
+		}
+		f.P50Latency = p50
+	}
+	// p99 latency
+	if p99Block, ok := node.PrimitivePackets[tagStatsReportP99Latency]; ok {
+		p99, err := p99Block.ToUInt64()
+		if err != nil {
+			return err
+		}
+		f.P99Latency = p99
+	}
+
+	return nil
+}
+
+var (
+	tagStatsReportStreamID   byte = 0x01
+	tagStatsReportProcessed  byte = 0x02
+	tagStatsReportErrors     byte = 0x03
+	tagStatsReportP50Latency byte = 0x04
+	tagStatsReportP99Latency byte = 0x05
+)
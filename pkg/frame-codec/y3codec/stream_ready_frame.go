@@ -0,0 +1,59 @@
+package y3codec
+
+import (
+	"encoding/binary"
+
+	"github.com/yomorun/y3"
+	"github.com/yomorun/yomo/core/frame"
+)
+
+// encodeStreamReadyFrame encodes StreamReadyFrame to Y3 encoded bytes.
+func encodeStreamReadyFrame(f *frame.StreamReadyFrame) ([]byte, error) {
+	// stream id
+	streamIDBlock := y3.NewPrimitivePacketEncoder(tagStreamReadyStreamID)
+	streamIDBlock.SetStringValue(f.StreamID)
+	// tags
+	tagsBlock := y3.NewPrimitivePacketEncoder(tagStreamReadyTags)
+	buf := make([]byte, 4)
+	for _, v := range f.Tags {
+		binary.LittleEndian.PutUint32(buf, v)
+		tagsBlock.AddBytes(buf)
+	}
+	// frame
+	ff := y3.NewNodePacketEncoder(byte(f.Type()))
+	ff.AddPrimitivePacket(streamIDBlock)
+	ff.AddPrimitivePacket(tagsBlock)
+
+	return ff.Encode(), nil
+}
+
+// decodeStreamReadyFrame decodes Y3 encoded bytes to StreamReadyFrame.
+func decodeStreamReadyFrame(data []byte, f *frame.StreamReadyFrame) error {
+	node := y3.NodePacket{}
+	_, err := y3.DecodeToNodePacket(data, &node)
+	if err != nil {
+		return err
+	}
+	// stream id
+	if streamIDBlock, ok := node.PrimitivePackets[tagStreamReadyStreamID]; ok {
+		streamID, err := streamIDBlock.ToUTF8String()
+		if err != nil {
+			return err
+		}
+		f.StreamID = streamID
+	}
+	// tags
+	if tagsBlock, ok := node.PrimitivePackets[tagStreamReadyTags]; ok {
+		buf := tagsBlock.GetValBuf()
+		for i := 0; i+4 <= len(buf); i += 4 {
+			f.Tags = append(f.Tags, binary.LittleEndian.Uint32(buf[i:i+4]))
+		}
+	}
+
+	return nil
+}
+
+var (
+	tagStreamReadyStreamID byte = 0x01
+	tagStreamReadyTags     byte = 0x02
+)
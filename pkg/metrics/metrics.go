@@ -0,0 +1,231 @@
+// Package metrics exports the metrics of the zipper to Prometheus.
+//
+// It is a separate package so that the zipper does not depend on Prometheus unless it is imported,
+// the collector is enabled by a single option:
+//
+//	zipper, err := yomo.NewZipper(name, functions, meshConfig, yomo.WithZipperObserver(metrics.New(prometheus.DefaultRegisterer)))
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/yomorun/yomo/core"
+	"github.com/yomorun/yomo/core/frame"
+)
+
+// Namespace is the namespace of the metrics.
+const Namespace = "yomo"
+
+// Collector is a core.ServerObserver that collects the metrics of the connections, the data streams
+// and the frames of the zipper, it is a prometheus.Collector as well.
+type Collector struct {
+	connections      prometheus.Gauge
+	streams          *prometheus.GaugeVec
+	framesRead       *prometheus.CounterVec
+	framesWritten    *prometheus.CounterVec
+	bytesRead        prometheus.Counter
+	bytesWritten     prometheus.Counter
+	handshakeRejects *prometheus.CounterVec
+	sfnProcessed     *prometheus.CounterVec
+	sfnErrors        *prometheus.CounterVec
+	sfnLatencyP50    *prometheus.GaugeVec
+	sfnLatencyP99    *prometheus.GaugeVec
+
+	// labelKeys are the keys of the connection labels that the labeledConnections are sliced by.
+	labelKeys          []string
+	labeledConnections *prometheus.GaugeVec
+}
+
+// Option is the option of the Collector.
+type Option func(*Collector)
+
+// WithConnectionLabels slices the active connections by the connection labels of the keys, such as the region,
+// the version or the canary, see core.WithLabels. The connections without a label of the keys are counted
+// with the empty value of it.
+func WithConnectionLabels(keys ...string) Option {
+	return func(c *Collector) {
+		c.labelKeys = keys
+	}
+}
+
+var (
+	_ core.ServerObserver           = (*Collector)(nil)
+	_ core.StatsReportObserver      = (*Collector)(nil)
+	_ core.ConnectionLabelsObserver = (*Collector)(nil)
+	_ prometheus.Collector          = (*Collector)(nil)
+)
+
+// New returns a Collector and registers it to the registerer, a nil registerer skips the registration.
+// It panics if the metrics have been registered, like prometheus.MustRegister.
+func New(registerer prometheus.Registerer, opts ...Option) *Collector {
+	c := &Collector{
+		connections: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: Namespace,
+			Name:      "connections_active",
+			Help:      "The number of the active connections.",
+		}),
+		streams: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: Namespace,
+			Name:      "streams_active",
+			Help:      "The number of the active data streams by stream type.",
+		}, []string{"stream_type"}),
+		framesRead: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Name:      "frames_read_total",
+			Help:      "The total number of the frames read by frame type.",
+		}, []string{"frame_type"}),
+		framesWritten: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Name:      "frames_written_total",
+			Help:      "The total number of the frames written by frame type.",
+		}, []string{"frame_type"}),
+		bytesRead: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Name:      "bytes_read_total",
+			Help:      "The total bytes of the encoded frames read.",
+		}),
+		bytesWritten: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Name:      "bytes_written_total",
+			Help:      "The total bytes of the encoded frames written.",
+		}),
+		handshakeRejects: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Name:      "handshake_rejects_total",
+			Help:      "The total number of the rejected handshakes by reason.",
+		}, []string{"reason"}),
+		sfnProcessed: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Name:      "sfn_processed_total",
+			Help:      "The total number of the data processed by the stream functions, reported by the stream functions.",
+		}, []string{"sfn"}),
+		sfnErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Name:      "sfn_errors_total",
+			Help:      "The total number of the data failed to be processed by the stream functions.",
+		}, []string{"sfn"}),
+		sfnLatencyP50: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: Namespace,
+			Name:      "sfn_latency_p50_seconds",
+			Help:      "The median processing latency of the stream functions in the last report.",
+		}, []string{"sfn"}),
+		sfnLatencyP99: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: Namespace,
+			Name:      "sfn_latency_p99_seconds",
+			Help:      "The 99th percentile processing latency of the stream functions in the last report.",
+		}, []string{"sfn"}),
+	}
+	for _, o := range opts {
+		o(c)
+	}
+	if len(c.labelKeys) > 0 {
+		c.labeledConnections = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: Namespace,
+			Name:      "connections_active_by_labels",
+			Help:      "The number of the active connections by the connection labels.",
+		}, c.labelKeys)
+	}
+	if registerer != nil {
+		registerer.MustRegister(c)
+	}
+	return c
+}
+
+// Describe implements prometheus.Collector.
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	c.connections.Describe(ch)
+	c.streams.Describe(ch)
+	c.framesRead.Describe(ch)
+	c.framesWritten.Describe(ch)
+	c.bytesRead.Describe(ch)
+	c.bytesWritten.Describe(ch)
+	c.handshakeRejects.Describe(ch)
+	c.sfnProcessed.Describe(ch)
+	c.sfnErrors.Describe(ch)
+	c.sfnLatencyP50.Describe(ch)
+	c.sfnLatencyP99.Describe(ch)
+	if c.labeledConnections != nil {
+		c.labeledConnections.Describe(ch)
+	}
+}
+
+// Collect implements prometheus.Collector.
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	c.connections.Collect(ch)
+	c.streams.Collect(ch)
+	c.framesRead.Collect(ch)
+	c.framesWritten.Collect(ch)
+	c.bytesRead.Collect(ch)
+	c.bytesWritten.Collect(ch)
+	c.handshakeRejects.Collect(ch)
+	c.sfnProcessed.Collect(ch)
+	c.sfnErrors.Collect(ch)
+	c.sfnLatencyP50.Collect(ch)
+	c.sfnLatencyP99.Collect(ch)
+	if c.labeledConnections != nil {
+		c.labeledConnections.Collect(ch)
+	}
+}
+
+// ConnectionOpened implements core.ServerObserver.
+func (c *Collector) ConnectionOpened() { c.connections.Inc() }
+
+// ConnectionClosed implements core.ServerObserver.
+func (c *Collector) ConnectionClosed() { c.connections.Dec() }
+
+// LabeledConnectionOpened implements core.ConnectionLabelsObserver.
+func (c *Collector) LabeledConnectionOpened(labels map[string]string) {
+	if c.labeledConnections != nil {
+		c.labeledConnections.WithLabelValues(c.labelValues(labels)...).Inc()
+	}
+}
+
+// LabeledConnectionClosed implements core.ConnectionLabelsObserver.
+func (c *Collector) LabeledConnectionClosed(labels map[string]string) {
+	if c.labeledConnections != nil {
+		c.labeledConnections.WithLabelValues(c.labelValues(labels)...).Dec()
+	}
+}
+
+// labelValues returns the values of the labelKeys in the connection labels.
+func (c *Collector) labelValues(labels map[string]string) []string {
+	values := make([]string, len(c.labelKeys))
+	for i, k := range c.labelKeys {
+		values[i] = labels[k]
+	}
+	return values
+}
+
+// StreamOpened implements core.ServerObserver.
+func (c *Collector) StreamOpened(streamType core.StreamType) {
+	c.streams.WithLabelValues(streamType.String()).Inc()
+}
+
+// StreamClosed implements core.ServerObserver.
+func (c *Collector) StreamClosed(streamType core.StreamType) {
+	c.streams.WithLabelValues(streamType.String()).Dec()
+}
+
+// FrameRead implements core.ServerObserver.
+func (c *Collector) FrameRead(ftyp frame.Type, n int) {
+	c.framesRead.WithLabelValues(ftyp.String()).Inc()
+	c.bytesRead.Add(float64(n))
+}
+
+// FrameWritten implements core.ServerObserver.
+func (c *Collector) FrameWritten(ftyp frame.Type, n int) {
+	c.framesWritten.WithLabelValues(ftyp.String()).Inc()
+	c.bytesWritten.Add(float64(n))
+}
+
+// HandshakeRejected implements core.ServerObserver.
+func (c *Collector) HandshakeRejected(reason frame.RejectCode) {
+	c.handshakeRejects.WithLabelValues(reason.String()).Inc()
+}
+
+// StatsReported implements core.StatsReportObserver.
+func (c *Collector) StatsReported(streamName string, report *frame.StatsReportFrame) {
+	c.sfnProcessed.WithLabelValues(streamName).Add(float64(report.Processed))
+	c.sfnErrors.WithLabelValues(streamName).Add(float64(report.Errors))
+	c.sfnLatencyP50.WithLabelValues(streamName).Set(float64(report.P50Latency) / 1e6)
+	c.sfnLatencyP99.WithLabelValues(streamName).Set(float64(report.P99Latency) / 1e6)
+}
@@ -0,0 +1,73 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/yomorun/yomo/core"
+	"github.com/yomorun/yomo/core/frame"
+)
+
+func TestCollector(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	c := New(registry)
+
+	c.ConnectionOpened()
+	c.ConnectionOpened()
+	c.ConnectionClosed()
+	c.StreamOpened(core.StreamTypeSource)
+	c.StreamOpened(core.StreamTypeStreamFunction)
+	c.StreamClosed(core.StreamTypeSource)
+	c.FrameRead(frame.TypeDataFrame, 10)
+	c.FrameRead(frame.TypeDataFrame, 20)
+	c.FrameWritten(frame.TypeHandshakeAckFrame, 5)
+	c.HandshakeRejected(frame.RejectRateLimited)
+
+	expected := `
+# HELP yomo_bytes_read_total The total bytes of the encoded frames read.
+# TYPE yomo_bytes_read_total counter
+yomo_bytes_read_total 30
+# HELP yomo_bytes_written_total The total bytes of the encoded frames written.
+# TYPE yomo_bytes_written_total counter
+yomo_bytes_written_total 5
+# HELP yomo_connections_active The number of the active connections.
+# TYPE yomo_connections_active gauge
+yomo_connections_active 1
+# HELP yomo_frames_read_total The total number of the frames read by frame type.
+# TYPE yomo_frames_read_total counter
+yomo_frames_read_total{frame_type="DataFrame"} 2
+# HELP yomo_frames_written_total The total number of the frames written by frame type.
+# TYPE yomo_frames_written_total counter
+yomo_frames_written_total{frame_type="HandshakeAckFrame"} 1
+# HELP yomo_handshake_rejects_total The total number of the rejected handshakes by reason.
+# TYPE yomo_handshake_rejects_total counter
+yomo_handshake_rejects_total{reason="RateLimited"} 1
+# HELP yomo_streams_active The number of the active data streams by stream type.
+# TYPE yomo_streams_active gauge
+yomo_streams_active{stream_type="Source"} 0
+yomo_streams_active{stream_type="StreamFunction"} 1
+`
+	err := testutil.GatherAndCompare(registry, strings.NewReader(expected))
+	assert.NoError(t, err)
+}
+
+func TestCollectorConnectionLabels(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	c := New(registry, WithConnectionLabels("region", "canary"))
+
+	c.LabeledConnectionOpened(map[string]string{"region": "us", "canary": "true", "version": "1"})
+	c.LabeledConnectionOpened(map[string]string{"region": "eu"})
+	c.LabeledConnectionOpened(map[string]string{"region": "eu"})
+	c.LabeledConnectionClosed(map[string]string{"region": "eu"})
+
+	expected := `
+# HELP yomo_connections_active_by_labels The number of the active connections by the connection labels.
+# TYPE yomo_connections_active_by_labels gauge
+yomo_connections_active_by_labels{canary="",region="eu"} 1
+yomo_connections_active_by_labels{canary="true",region="us"} 1
+`
+	assert.NoError(t, testutil.GatherAndCompare(registry, strings.NewReader(expected), "yomo_connections_active_by_labels"))
+}
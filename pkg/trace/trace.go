@@ -6,6 +6,7 @@ import (
 	"errors"
 	"log"
 	"os"
+	"strconv"
 	"time"
 
 	"go.opentelemetry.io/otel"
@@ -71,6 +72,14 @@ func NewTracerProviderWithJaeger(service string) (*tracesdk.TracerProvider, func
 	return tp, shutdown, nil
 }
 
+// FrameAttrs returns the span attributes of a frame that is handled by the stream.
+func FrameAttrs(tag uint32, streamID string) map[string]string {
+	return map[string]string{
+		"yomo.tag":       strconv.FormatUint(uint64(tag), 10),
+		"yomo.stream_id": streamID,
+	}
+}
+
 // NewSpan creates a new span of OpenTelemetry.
 func NewSpan(tp trace.TracerProvider, tracerName string, spanName string, traceID string, spanID string) (trace.Span, error) {
 	return NewSpanWithAttrs(tp, tracerName, spanName, traceID, spanID, false)
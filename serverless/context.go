@@ -9,6 +9,9 @@ type Context interface {
 	Tag() uint32
 	// Write write data to zipper
 	Write(tag uint32, data []byte) error
+	// WriteWithMetadata write data to zipper with additional metadata, the metadata is merged
+	// into the metadata of the incoming data and is delivered to the source by backflow.
+	WriteWithMetadata(tag uint32, data []byte, md map[string]string) error
 	// HTTP http interface
 	HTTP() HTTP
 }
@@ -3,20 +3,62 @@ package guest
 
 import (
 	"errors"
-	_ "unsafe"
+	"io"
+	"unsafe"
 
+	"github.com/yomorun/yomo/core/metadata"
 	"github.com/yomorun/yomo/serverless"
 )
 
 var (
 	// DataTags set handler observed data tags
 	DataTags func() []uint32 = func() []uint32 { return []uint32{0} }
+	// DataTagRanges set handler observed data tag ranges, it is useful to observe
+	// a large number of contiguous tags, eg. the tags grouped by high byte.
+	// It should be set in the init function of the guest module.
+	DataTagRanges func() []TagRange = func() []TagRange { return nil }
 	// Handler is the handler function for guest
 	Handler func(ctx serverless.Context) = func(serverless.Context) {}
+	// ErrorHandler is the handler function for guest that reports the failure of processing the data,
+	// the error is surfaced to the host. It takes precedence over Handler if it is set.
+	ErrorHandler func(ctx serverless.Context) error
 	// Init is the init function for guest
 	Init func() error = func() error { return nil }
+	// InitWithConfig is the init function for guest that receives the config provided by the host,
+	// the config is empty if the host provides none. It takes precedence over Init if it is set.
+	InitWithConfig func(config []byte) error
 )
 
+// tagHandlers are the handlers registered by HandlerFor and ErrorHandlerFor, keyed by the data tag.
+var tagHandlers = map[uint32]func(ctx serverless.Context) error{}
+
+// HandlerFor registers the handler of the data tag, the data of the tag is handled by fn instead of
+// Handler or ErrorHandler, so the guest observing several tags does not have to switch on the tag.
+// The tag is observed as well. It should be called in the main function of the guest module.
+func HandlerFor(tag uint32, fn func(ctx serverless.Context)) {
+	tagHandlers[tag] = func(ctx serverless.Context) error {
+		fn(ctx)
+		return nil
+	}
+}
+
+// ErrorHandlerFor registers the handler of the data tag like HandlerFor, the error returned by fn
+// is surfaced to the host like ErrorHandler.
+func ErrorHandlerFor(tag uint32, fn func(ctx serverless.Context) error) {
+	tagHandlers[tag] = fn
+}
+
+// TagRange is a contiguous range of data tags, both Min and Max are included.
+type TagRange struct {
+	Min uint32
+	Max uint32
+}
+
+// DataTagRange returns a TagRange that observes the data tags from min to max.
+func DataTagRange(min, max uint32) TagRange {
+	return TagRange{Min: min, Max: max}
+}
+
 // GuestContext is the context for guest
 type GuestContext struct{}
 
@@ -30,25 +72,132 @@ func (c *GuestContext) Data() []byte {
 	return GetBytes(ContextData)
 }
 
-// Write writes data to the context
+// DataReader returns a reader of the data of the context, it reads the data from the host
+// in chunks bounded by the buffer passed to Read, so the large data can be processed without
+// copying the whole data into the guest memory. Data is simpler for the small data.
+func (c *GuestContext) DataReader() io.Reader {
+	return &dataReader{}
+}
+
+// contextDataChunkError is returned by yomo_context_data_chunk if the host can not write the chunk into the guest memory.
+const contextDataChunkError = ^uint32(0)
+
+// ErrContextDataMemory is returned by the reader of DataReader if the host can not write the data into the guest memory.
+var ErrContextDataMemory = errors.New("yomoContextDataChunk: memory error")
+
+// dataReader reads the data of the context by yomo_context_data_chunk.
+type dataReader struct {
+	offset uint32
+}
+
+func (r *dataReader) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	n := yomoContextDataChunk(r.offset, &p[0], uint32(len(p)))
+	if n == contextDataChunkError {
+		return 0, ErrContextDataMemory
+	}
+	if n == 0 {
+		return 0, io.EOF
+	}
+	r.offset += n
+	return int(n), nil
+}
+
+var (
+	// ErrWriteBlocked is returned by Write if the stream can not accept data for now, the caller can back off and retry.
+	ErrWriteBlocked = errors.New("yomoWrite: write blocked")
+	// ErrStreamClosed is returned by Write if the stream has been closed, the caller should not retry.
+	ErrStreamClosed = errors.New("yomoWrite: stream closed")
+	// ErrWriteMemory is returned by Write if the host can not read the data from the guest memory.
+	ErrWriteMemory = errors.New("yomoWrite: memory error")
+	// ErrWrite is returned by Write if the write failed for an unclassified reason.
+	ErrWrite = errors.New("yomoWrite error")
+	// ErrWriteTimeout is returned by WriteWithTimeout if the write does not finish before the timeout.
+	ErrWriteTimeout = errors.New("yomoWrite: write timeout")
+)
+
+// writeError maps the return code of yomo_write to error, the code space is:
+//
+//	0: ok
+//	1: the host can not read the data from the guest memory
+//	2: the write failed for an unclassified reason
+//	3: the stream can not accept data for now
+//	4: the stream has been closed
+//	5: the write does not finish before the timeout
+func writeError(code uint32) error {
+	switch code {
+	case 0:
+		return nil
+	case 1:
+		return ErrWriteMemory
+	case 3:
+		return ErrWriteBlocked
+	case 4:
+		return ErrStreamClosed
+	case 5:
+		return ErrWriteTimeout
+	default:
+		return ErrWrite
+	}
+}
+
+// Write writes data to the context, the returned error can be checked by errors.Is
+// with ErrWriteBlocked and ErrStreamClosed to decide whether to retry.
 func (c *GuestContext) Write(tag uint32, data []byte) error {
 	if data == nil {
 		return nil
 	}
-	if yomoWrite(tag, &data[0], len(data)) != 0 {
-		return errors.New("yomoWrite error")
+	return writeError(yomoWrite(tag, &data[0], len(data)))
+}
+
+// WriteWithMetadata writes data to the context with additional metadata, the metadata is carried
+// to the source by backflow, the keys prefixed with "yomo-" are reserved and ignored by the host.
+func (c *GuestContext) WriteWithMetadata(tag uint32, data []byte, md map[string]string) error {
+	if data == nil {
+		return nil
 	}
-	return nil
+	b, err := metadata.M(md).Encode()
+	if err != nil {
+		return err
+	}
+	if len(b) == 0 {
+		return c.Write(tag, data)
+	}
+	return writeError(yomoWriteWithMetadata(tag, &data[0], len(data), &b[0], len(b)))
+}
+
+// WriteWithTimeout writes data to the context like Write, but returns ErrWriteTimeout
+// if the host does not finish the write in ms milliseconds, a zero ms means no timeout.
+// The timed out data may still be delivered later.
+func (c *GuestContext) WriteWithTimeout(tag uint32, data []byte, ms uint32) error {
+	if data == nil {
+		return nil
+	}
+	return writeError(yomoWriteTimeout(tag, &data[0], len(data), ms))
 }
 
 //export yomo_observe_datatag
 //go:linkname yomoObserveDataTag
 func yomoObserveDataTag(tag uint32)
 
+//export yomo_observe_datatag_range
+//go:linkname yomoObserveDataTagRange
+func yomoObserveDataTagRange(min uint32, max uint32)
+
 //export yomo_write
 //go:linkname yomoWrite
 func yomoWrite(tag uint32, pointer *byte, length int) uint32
 
+//export yomo_write_timeout
+//go:linkname yomoWriteTimeout
+func yomoWriteTimeout(tag uint32, pointer *byte, length int, timeoutMs uint32) uint32
+
+//export yomo_write_with_metadata
+//go:linkname yomoWriteWithMetadata
+func yomoWriteWithMetadata(tag uint32, pointer *byte, length int, mdPointer *byte, mdLength int) uint32
+
 //export yomo_context_tag
 //go:linkname yomoContextTag
 func yomoContextTag() uint32
@@ -57,34 +206,89 @@ func yomoContextTag() uint32
 //go:linkname contextData
 func contextData(ptr uintptr, size uint32) uint32
 
+//export yomo_context_data_chunk
+//go:linkname yomoContextDataChunk
+func yomoContextDataChunk(offset uint32, pointer *byte, limit uint32) uint32
+
 //export yomo_observe_datatags
 //go:linkname yomoObserveDataTags
 func yomoObserveDataTags() {
 	// set observe data tags
 	dataTags := DataTags()
+	observed := make(map[uint32]struct{}, len(dataTags))
 	for _, tag := range dataTags {
 		yomoObserveDataTag(tag)
+		observed[tag] = struct{}{}
+	}
+	// set observe the tags of the registered handlers
+	for tag := range tagHandlers {
+		if _, ok := observed[tag]; !ok {
+			yomoObserveDataTag(tag)
+		}
+	}
+	// set observe data tag ranges
+	for _, r := range DataTagRanges() {
+		yomoObserveDataTagRange(r.Min, r.Max)
 	}
 }
 
+//export yomo_handler_error
+//go:linkname yomoHandlerError
+func yomoHandlerError(pointer *byte, length int)
+
 //export yomo_handler
 //go:linkname yomoHandler
 func yomoHandler() {
 	ctx := &GuestContext{}
-	Handler(ctx)
+	if err := handler(ctx.Tag())(ctx); err != nil {
+		msg := err.Error()
+		if msg == "" {
+			msg = "unknown error"
+		}
+		yomoHandlerError(unsafe.StringData(msg), len(msg))
+	}
+}
+
+// handler returns the handler registered for the tag, otherwise the ErrorHandler, or the Handler wrapped
+// to never fail if ErrorHandler is not set.
+func handler(tag uint32) func(ctx serverless.Context) error {
+	if fn, ok := tagHandlers[tag]; ok {
+		return fn
+	}
+	if ErrorHandler != nil {
+		return ErrorHandler
+	}
+	return func(ctx serverless.Context) error {
+		Handler(ctx)
+		return nil
+	}
 }
 
+//export yomo_init_config
+//go:linkname initConfig
+func initConfig(ptr uintptr, size uint32) uint32
+
 //export yomo_init
 //go:linkname yomoInit
 func yomoInit() uint32 {
 	// init
-	if err := Init(); err != nil {
+	if err := initFunc()(); err != nil {
 		print("yomoInit error: ", err)
 		return 1
 	}
 	return 0
 }
 
+// initFunc returns the Init, or the InitWithConfig called with the config read from the host if it is set.
+func initFunc() func() error {
+	if InitWithConfig != nil {
+		return func() error {
+			return InitWithConfig(GetBytes(initConfig))
+		}
+	}
+	return Init
+}
+
 // ContextData returns the data of the context
 func ContextData(ptr uintptr, size uint32) uint32 {
 	return contextData(ptr, size)
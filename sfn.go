@@ -2,6 +2,7 @@ package yomo
 
 import (
 	"context"
+	"time"
 
 	"github.com/yomorun/yomo/core"
 	"github.com/yomorun/yomo/core/frame"
@@ -24,10 +25,23 @@ type StreamFunction interface {
 	SetErrorHandler(fn func(err error))
 	// SetPipeHandler set the pipe handler function
 	SetPipeHandler(fn core.PipeHandler) error
+	// PauseTag asks the sources to pause the data of the tag, the sources resume it after
+	// ResumeTag is called or the max pause time elapses.
+	PauseTag(tag uint32) error
+	// ResumeTag asks the sources to resume the data of the tag, a non-zero rate limits
+	// the data to rate per second.
+	ResumeTag(tag uint32, rate uint32) error
+	// Observe observes the data of the tag at runtime.
+	Observe(tag uint32) error
+	// Unobserve stops observing the data of the tag at runtime.
+	Unobserve(tag uint32) error
 	// Connect create a connection to the zipper
 	Connect() error
 	// Close will close the connection
 	Close() error
+	// CloseGracefully closes the connection after the running handlers have finished and the data they
+	// have written has been flushed, it waits up to the timeout, see core.Client.CloseGracefully.
+	CloseGracefully(timeout time.Duration) error
 	// Wait waits sfn to finish.
 	Wait()
 }
@@ -83,6 +97,26 @@ func (s *streamFunction) SetPipeHandler(fn core.PipeHandler) error {
 	return nil
 }
 
+// PauseTag asks the sources to pause the data of the tag.
+func (s *streamFunction) PauseTag(tag uint32) error {
+	return s.client.FlowControl(tag, true, 0)
+}
+
+// ResumeTag asks the sources to resume the data of the tag.
+func (s *streamFunction) ResumeTag(tag uint32, rate uint32) error {
+	return s.client.FlowControl(tag, false, rate)
+}
+
+// Observe observes the data of the tag at runtime.
+func (s *streamFunction) Observe(tag uint32) error {
+	return s.client.Observe(tag)
+}
+
+// Unobserve stops observing the data of the tag at runtime.
+func (s *streamFunction) Unobserve(tag uint32) error {
+	return s.client.Unobserve(tag)
+}
+
 // Connect create a connection to the zipper, when data arrvied, the data will be passed to the
 // handler which setted by SetHandler method.
 func (s *streamFunction) Connect() error {
@@ -124,10 +158,11 @@ func (s *streamFunction) Connect() error {
 						var span oteltrace.Span
 						var err error
 						// set parent span, if not traced, use empty string
+						attrs := trace.FrameAttrs(data.Tag, s.client.ClientID())
 						if parentTraced {
-							span, err = trace.NewSpan(tp, core.StreamTypeStreamFunction.String(), s.name, tid, sid)
+							span, err = trace.NewSpanWithAttrs(tp, core.StreamTypeStreamFunction.String(), s.name, tid, sid, false, attrs)
 						} else {
-							span, err = trace.NewSpan(tp, core.StreamTypeStreamFunction.String(), s.name, "", "")
+							span, err = trace.NewSpanWithAttrs(tp, core.StreamTypeStreamFunction.String(), s.name, "", "", false, attrs)
 						}
 						if err != nil {
 							s.client.Logger().Error("sfn trace error", "err", err)
@@ -150,7 +185,7 @@ func (s *streamFunction) Connect() error {
 					core.SetTIDToMetadata(md, tid)
 					core.SetSIDToMetadata(md, sid)
 					core.SetTracedToMetadata(md, traced)
-					newMetadata, err := md.Encode()
+					newMetadata, err := md.EncodeWith(s.client.MetadataEncoding())
 					if err != nil {
 						s.client.Logger().Error("sfn encode metadata error", "err", err)
 						break
@@ -193,6 +228,27 @@ func (s *streamFunction) Close() error {
 	return nil
 }
 
+// CloseGracefully closes the connection after the running handlers have finished and the data they
+// have written has been flushed.
+func (s *streamFunction) CloseGracefully(timeout time.Duration) error {
+	if s.pIn != nil {
+		close(s.pIn)
+	}
+
+	var err error
+	if s.client != nil {
+		if err = s.client.CloseGracefully(timeout); err != nil {
+			s.client.Logger().Error("failed to close sfn gracefully", "err", err)
+		}
+	}
+
+	if s.pOut != nil {
+		close(s.pOut)
+	}
+
+	return err
+}
+
 // Wait waits sfn to finish.
 func (s *streamFunction) Wait() {
 	s.client.Wait()
@@ -203,7 +259,7 @@ func (s *streamFunction) Wait() {
 func (s *streamFunction) onDataFrame(dataFrame *frame.DataFrame) {
 	if s.fn != nil {
 		tp := s.client.TracerProvider()
-		go func(tp oteltrace.TracerProvider, dataFrame *frame.DataFrame) {
+		s.client.Go(func() {
 			md, err := metadata.Decode(dataFrame.Metadata)
 			if err != nil {
 				s.client.Logger().Error("sfn decode metadata error", "err", err)
@@ -219,10 +275,11 @@ func (s *streamFunction) onDataFrame(dataFrame *frame.DataFrame) {
 				var span oteltrace.Span
 				var err error
 				// set parent span, if not traced, use empty string
+				attrs := trace.FrameAttrs(dataFrame.Tag, s.client.ClientID())
 				if parentTraced {
-					span, err = trace.NewSpan(tp, core.StreamTypeStreamFunction.String(), s.name, tid, sid)
+					span, err = trace.NewSpanWithAttrs(tp, core.StreamTypeStreamFunction.String(), s.name, tid, sid, false, attrs)
 				} else {
-					span, err = trace.NewSpan(tp, core.StreamTypeStreamFunction.String(), s.name, "", "")
+					span, err = trace.NewSpanWithAttrs(tp, core.StreamTypeStreamFunction.String(), s.name, "", "", false, attrs)
 				}
 				if err != nil {
 					s.client.Logger().Error("sfn trace error", "err", err)
@@ -245,7 +302,7 @@ func (s *streamFunction) onDataFrame(dataFrame *frame.DataFrame) {
 			core.SetTIDToMetadata(md, tid)
 			core.SetSIDToMetadata(md, sid)
 			core.SetTracedToMetadata(md, traced)
-			newMetadata, err := md.Encode()
+			newMetadata, err := md.EncodeWith(s.client.MetadataEncoding())
 			if err != nil {
 				s.client.Logger().Error("sfn encode metadata error", "err", err)
 				return
@@ -253,8 +310,10 @@ func (s *streamFunction) onDataFrame(dataFrame *frame.DataFrame) {
 			dataFrame.Metadata = newMetadata
 			s.client.Logger().Debug("sfn metadata", "tid", tid, "sid", sid, "parentTraced", parentTraced, "traced", traced)
 			serverlessCtx := serverless.NewContext(s.client, dataFrame)
+			start := time.Now()
 			s.fn(serverlessCtx)
-		}(tp, dataFrame)
+			s.client.RecordProcessing(time.Since(start), serverlessCtx.DeadLettered())
+		})
 	} else if s.pfn != nil {
 		data := dataFrame.Payload
 		s.client.Logger().Debug("pipe sfn receive", "data_len", len(data), "data", data)
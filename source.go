@@ -2,9 +2,14 @@ package yomo
 
 import (
 	"context"
+	"errors"
+	"io"
+	"sync"
+	"time"
 
 	"github.com/yomorun/yomo/core"
 	"github.com/yomorun/yomo/core/frame"
+	"github.com/yomorun/yomo/core/metadata"
 	"github.com/yomorun/yomo/pkg/id"
 	"github.com/yomorun/yomo/pkg/trace"
 )
@@ -13,16 +18,43 @@ import (
 type Source interface {
 	// Close will close the connection to YoMo-Zipper.
 	Close() error
+	// CloseGracefully closes the connection to YoMo-Zipper after the data written has been flushed,
+	// it waits up to the timeout, see core.Client.CloseGracefully.
+	CloseGracefully(timeout time.Duration) error
 	// Connect to YoMo-Zipper.
 	Connect() error
 	// Write the data to directed downstream.
 	Write(tag uint32, data []byte) error
+	// WriteWithSeq writes the data with the sequence number of the tag, the zipper delivers the data
+	// of the tag in sequence order if its reorder window is configured. The seq starts from 1.
+	WriteWithSeq(tag uint32, seq uint64, data []byte) error
+	// WriteAfter writes the data like Write, but the zipper delivers it after the delay,
+	// the zipper must enable the scheduled delivery, otherwise the data is delivered immediately.
+	WriteAfter(tag uint32, delay time.Duration, data []byte) error
 	// Broadcast broadcast the data to all downstream.
 	Broadcast(tag uint32, data []byte) error
+	// Unicast writes the data to the stream function whose stream id is streamID, such as the one that
+	// holds the state of a session. If the stream is gone, the zipper handles the data by its unicast fallback.
+	Unicast(tag uint32, streamID string, data []byte) error
+	// WriteChunked writes the data read from r until EOF in the chunks of DefaultChunkSize, the stream function
+	// reassembles the chunks and handles the data as a whole, so the data does not have to be in a single frame.
+	WriteChunked(tag uint32, r io.Reader) error
+	// WriteBatch writes multiple tagged data in a single frame, every entry is delivered
+	// to the stream functions that observe its tag.
+	WriteBatch(entries []frame.BatchEntry) error
+	// Request writes the data like Write and waits for the reply of the stream function,
+	// it returns ErrRequestTimeout if the reply does not arrive in DefaultRequestTimeout.
+	Request(tag uint32, data []byte) ([]byte, error)
+	// RequestWithContext writes the data like Write and waits for the reply of the stream function until the ctx is done.
+	// The reply is the first data written by the stream functions while handling the data, whatever its tag is.
+	RequestWithContext(ctx context.Context, tag uint32, data []byte) ([]byte, error)
 	// SetErrorHandler set the error handler function when server error occurs
 	SetErrorHandler(fn func(err error))
 	// [Experimental] SetReceiveHandler set the observe handler function
 	SetReceiveHandler(fn func(tag uint32, data []byte))
+	// [Experimental] SetReceiveMetadataHandler set the observe handler function that also receives
+	// the metadata written by the stream function.
+	SetReceiveMetadataHandler(fn func(tag uint32, data []byte, md map[string]string))
 }
 
 // YoMo-Source
@@ -31,8 +63,22 @@ type yomoSource struct {
 	zipperAddr string
 	client     *core.Client
 	fn         func(uint32, []byte)
+	mdfn       func(uint32, []byte, map[string]string)
+
+	// pending holds the channels that wait for the replies, keyed by the request id.
+	pendingMu sync.Mutex
+	pending   map[string]chan []byte
 }
 
+// DefaultRequestTimeout is the time Source.Request waits for the reply.
+var DefaultRequestTimeout = 5 * time.Second
+
+// DefaultChunkSize is the size of the chunks written by Source.WriteChunked.
+var DefaultChunkSize = 64 * 1024
+
+// ErrRequestTimeout is returned by Source.Request if the reply does not arrive in time.
+var ErrRequestTimeout = errors.New("yomo: request timeout")
+
 var _ Source = &yomoSource{}
 
 // NewSource create a yomo-source
@@ -48,6 +94,7 @@ func NewSource(name, zipperAddr string, opts ...SourceOption) Source {
 		name:       name,
 		zipperAddr: zipperAddr,
 		client:     client,
+		pending:    make(map[string]chan []byte),
 	}
 }
 
@@ -61,13 +108,34 @@ func (s *yomoSource) Close() error {
 	return nil
 }
 
+// CloseGracefully closes the connection to YoMo-Zipper after the data written has been flushed.
+func (s *yomoSource) CloseGracefully(timeout time.Duration) error {
+	if err := s.client.CloseGracefully(timeout); err != nil {
+		s.client.Logger().Error("failed to close the source gracefully", "err", err)
+		return err
+	}
+	s.client.Logger().Debug("the source is closed gracefully")
+	return nil
+}
+
 // Connect to YoMo-Zipper.
 func (s *yomoSource) Connect() error {
 	// set backflowframe handler
 	s.client.SetBackflowFrameObserver(func(frm *frame.BackflowFrame) {
+		if s.reply(frm) {
+			return
+		}
 		if s.fn != nil {
 			s.fn(frm.Tag, frm.Carriage)
 		}
+		if s.mdfn != nil {
+			md, err := metadata.Decode(frm.Metadata)
+			if err != nil {
+				s.client.Logger().Error("source decode backflow metadata error", "err", err)
+				return
+			}
+			s.mdfn(frm.Tag, frm.Carriage, md)
+		}
 	})
 
 	err := s.client.Connect(context.Background(), s.zipperAddr)
@@ -79,6 +147,79 @@ func (s *yomoSource) Write(tag uint32, data []byte) error {
 	return s.write(tag, data, false)
 }
 
+// WriteWithSeq writes data with specified tag and sequence number.
+func (s *yomoSource) WriteWithSeq(tag uint32, seq uint64, data []byte) error {
+	return s.writeFrame(false, nil, func(md []byte) (frame.Frame, error) {
+		s.client.Logger().Debug("source write", "tag", tag, "seq", seq, "data", data)
+		return frame.NewDataFrame(tag, data, frame.WithEncodedMetadata(md), frame.WithSeq(seq))
+	})
+}
+
+// Request writes data with specified tag and waits for the reply.
+func (s *yomoSource) Request(tag uint32, data []byte) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultRequestTimeout)
+	defer cancel()
+
+	return s.RequestWithContext(ctx, tag, data)
+}
+
+// RequestWithContext writes data with specified tag and waits for the reply until the ctx is done.
+func (s *yomoSource) RequestWithContext(ctx context.Context, tag uint32, data []byte) ([]byte, error) {
+	requestID := id.New()
+	replyCh := make(chan []byte, 1)
+
+	s.pendingMu.Lock()
+	s.pending[requestID] = replyCh
+	s.pendingMu.Unlock()
+
+	defer func() {
+		s.pendingMu.Lock()
+		delete(s.pending, requestID)
+		s.pendingMu.Unlock()
+	}()
+
+	err := s.writeFrame(false, func(md metadata.M) { md.Set(core.MetadataRequestIDKey, requestID) }, func(md []byte) (frame.Frame, error) {
+		s.client.Logger().Debug("source request", "tag", tag, "request_id", requestID, "data", data)
+		return frame.NewDataFrame(tag, data, frame.WithEncodedMetadata(md))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	select {
+	case reply := <-replyCh:
+		return reply, nil
+	case <-ctx.Done():
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return nil, ErrRequestTimeout
+		}
+		return nil, ctx.Err()
+	}
+}
+
+// reply delivers the BackflowFrame to the request waiting for it, it reports whether the frame is a reply.
+// The replies of the finished requests are dropped.
+func (s *yomoSource) reply(frm *frame.BackflowFrame) bool {
+	md, err := metadata.Decode(frm.Metadata)
+	if err != nil {
+		return false
+	}
+	requestID, ok := md.Get(core.MetadataRequestIDKey)
+	if !ok {
+		return false
+	}
+
+	s.pendingMu.Lock()
+	replyCh, ok := s.pending[requestID]
+	delete(s.pending, requestID)
+	s.pendingMu.Unlock()
+
+	if ok {
+		replyCh <- frm.Carriage
+	}
+	return true
+}
+
 // SetErrorHandler set the error handler function when server error occurs
 func (s *yomoSource) SetErrorHandler(fn func(err error)) {
 	s.client.SetErrorHandler(fn)
@@ -90,12 +231,81 @@ func (s *yomoSource) SetReceiveHandler(fn func(uint32, []byte)) {
 	s.client.Logger().Info("receive hander set for the source")
 }
 
+// [Experimental] SetReceiveMetadataHandler set the observe handler function with metadata
+func (s *yomoSource) SetReceiveMetadataHandler(fn func(uint32, []byte, map[string]string)) {
+	s.mdfn = fn
+	s.client.Logger().Info("receive metadata hander set for the source")
+}
+
 // Broadcast write the data to all downstreams.
 func (s *yomoSource) Broadcast(tag uint32, data []byte) error {
 	return s.write(tag, data, true)
 }
 
+// Unicast writes the data to the stream function of the streamID.
+func (s *yomoSource) Unicast(tag uint32, streamID string, data []byte) error {
+	return s.writeFrame(false, func(md metadata.M) { md.Set(core.MetadataTargetStreamIDKey, streamID) }, func(md []byte) (frame.Frame, error) {
+		s.client.Logger().Debug("source unicast", "tag", tag, "target_stream_id", streamID, "data", data)
+		return frame.NewDataFrame(tag, data, frame.WithEncodedMetadata(md))
+	})
+}
+
+// WriteAfter writes the data to be delivered after the delay.
+func (s *yomoSource) WriteAfter(tag uint32, delay time.Duration, data []byte) error {
+	return s.writeFrame(false, func(md metadata.M) { core.SetDeliverAfterToMetadata(md, delay) }, func(md []byte) (frame.Frame, error) {
+		s.client.Logger().Debug("source write after", "tag", tag, "delay", delay, "data", data)
+		return frame.NewDataFrame(tag, data, frame.WithEncodedMetadata(md))
+	})
+}
+
+// WriteChunked writes the data read from r in chunks.
+func (s *yomoSource) WriteChunked(tag uint32, r io.Reader) error {
+	transferID := id.New()
+	for index := uint32(0); ; index++ {
+		// every chunk has its own buffer, the frames are written asynchronously.
+		chunk := make([]byte, DefaultChunkSize)
+		n, err := io.ReadFull(r, chunk)
+		last := err == io.EOF || err == io.ErrUnexpectedEOF
+		if err != nil && !last {
+			return err
+		}
+		err = s.writeFrame(false, nil, func(md []byte) (frame.Frame, error) {
+			s.client.Logger().Debug("source write chunk", "tag", tag, "transfer_id", transferID, "index", index, "last", last)
+			return &frame.ChunkedDataFrame{
+				Metadata:   md,
+				Tag:        tag,
+				TransferID: transferID,
+				Index:      index,
+				Last:       last,
+				Payload:    chunk[:n],
+			}, nil
+		})
+		if err != nil || last {
+			return err
+		}
+	}
+}
+
+// WriteBatch writes multiple tagged data in a single frame.
+func (s *yomoSource) WriteBatch(entries []frame.BatchEntry) error {
+	return s.writeFrame(false, nil, func(md []byte) (frame.Frame, error) {
+		s.client.Logger().Debug("source write batch", "entries", len(entries))
+		return &frame.BatchDataFrame{
+			Metadata: md,
+			Entries:  entries,
+		}, nil
+	})
+}
+
 func (s *yomoSource) write(tag uint32, data []byte, broadcast bool) error {
+	return s.writeFrame(broadcast, nil, func(md []byte) (frame.Frame, error) {
+		s.client.Logger().Debug("source write", "tag", tag, "data", data, "broadcast", broadcast)
+		return frame.NewDataFrame(tag, data, frame.WithEncodedMetadata(md))
+	})
+}
+
+// writeFrame writes the frame built with the metadata of the source, setMetadata adds the extra metadata if it is not nil.
+func (s *yomoSource) writeFrame(broadcast bool, setMetadata func(md metadata.M), build func(md []byte) (frame.Frame, error)) error {
 	var tid, sid string
 	// trace
 	tp := s.client.TracerProvider()
@@ -121,15 +331,17 @@ func (s *yomoSource) write(tag uint32, data []byte, broadcast bool) error {
 	}
 	s.client.Logger().Debug("source metadata", "tid", tid, "sid", sid, "broadcast", broadcast, "traced", traced)
 	// metadata
-	md, err := core.NewDefaultMetadata(s.client.ClientID(), broadcast, tid, sid, traced).Encode()
+	m := core.NewDefaultMetadata(s.client.ClientID(), broadcast, tid, sid, traced)
+	if setMetadata != nil {
+		setMetadata(m)
+	}
+	md, err := m.EncodeWith(s.client.MetadataEncoding())
 	if err != nil {
 		return err
 	}
-	f := &frame.DataFrame{
-		Tag:      tag,
-		Metadata: md,
-		Payload:  data,
+	f, err := build(md)
+	if err != nil {
+		return err
 	}
-	s.client.Logger().Debug("source write", "tag", tag, "data", data, "broadcast", broadcast)
 	return s.client.WriteFrame(f)
 }
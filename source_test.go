@@ -1,6 +1,7 @@
 package yomo
 
 import (
+	"context"
 	"testing"
 	"time"
 
@@ -8,6 +9,7 @@ import (
 	"github.com/yomorun/yomo/core"
 	"github.com/yomorun/yomo/core/frame"
 	"github.com/yomorun/yomo/core/ylog"
+	"github.com/yomorun/yomo/serverless"
 )
 
 func TestSource(t *testing.T) {
@@ -50,3 +52,40 @@ func TestSource(t *testing.T) {
 
 	<-exit
 }
+
+func TestSourceRequest(t *testing.T) {
+	t.Parallel()
+
+	sfn := NewStreamFunction(
+		"sfn-ai-stream-response",
+		"localhost:9000",
+		WithSfnCredential("token:<CREDENTIAL>"),
+		WithSfnLogger(ylog.Default()),
+	)
+	sfn.SetObserveDataTags(0x31)
+	sfn.SetHandler(func(ctx serverless.Context) {
+		// the reply is not observed by the source, it is backflowed by the request id.
+		ctx.Write(0x32, append([]byte("reply: "), ctx.Data()...))
+	})
+	assert.Nil(t, sfn.Connect())
+	defer sfn.Close()
+
+	source := NewSource(
+		"test-request-source",
+		"localhost:9000",
+		WithCredential("token:<CREDENTIAL>"),
+		WithLogger(ylog.Default()),
+	)
+	assert.Nil(t, source.Connect())
+	defer source.Close()
+
+	reply, err := source.Request(0x31, []byte("ping"))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("reply: ping"), reply)
+
+	// no sfn observes the tag.
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, err = source.RequestWithContext(ctx, 0x33, []byte("ping"))
+	assert.ErrorIs(t, err, ErrRequestTimeout)
+}
@@ -21,6 +21,12 @@ type Zipper interface {
 	// ListenAndServe start zipper as server.
 	ListenAndServe(context.Context, string) error
 
+	// Drain makes the zipper reject the new connections, the connections served keep running until they are closed.
+	Drain()
+
+	// Draining reports whether the zipper is draining.
+	Draining() bool
+
 	// Close will close the zipper.
 	Close() error
 }
@@ -94,5 +100,7 @@ func statsToLogger(server *core.Server) {
 		"connector", server.StatsFunctions(),
 		"downstreams", server.Downstreams(),
 		"data_frame_received_num", server.StatsCounter(),
+		"data_frame_expired_num", server.StatsExpiredCounter(),
+		"data_frame_dead_letter_num", server.StatsDeadLetterCounter(),
 	)
 }